@@ -19,6 +19,7 @@ import (
     "namedot/internal/replication"
     dnssrv "namedot/internal/server/dns"
     restsrv "namedot/internal/server/rest"
+    "namedot/internal/server/rest/zoneio"
 )
 
 // Build information set via -ldflags during build.
@@ -56,6 +57,14 @@ func main() {
         exportFile string
         importFile string
         importMode string
+        importBind string
+        dryRun     bool
+        tokCreate  string
+        tokScopes  string
+        tokZones   string
+        tokDays    int
+        tokList    bool
+        tokRevoke  string
     )
 
     flag.Usage = func() {
@@ -69,6 +78,14 @@ func main() {
         fmt.Fprintf(os.Stderr, "  -export <file>            Export all zones to JSON file and exit\n")
         fmt.Fprintf(os.Stderr, "  -import <file>            Import zones from JSON file and exit\n")
         fmt.Fprintf(os.Stderr, "  -import-mode <mode>       Import mode: merge (default) or replace\n")
+        fmt.Fprintf(os.Stderr, "  -import-bind <path>       Import BIND zone files from a directory, tarball or named.conf and exit\n")
+        fmt.Fprintf(os.Stderr, "  -dry-run                  With -import or -import-bind: report the changes without making them\n")
+        fmt.Fprintf(os.Stderr, "  -token-create <name>      Create an API token, print it and exit\n")
+        fmt.Fprintf(os.Stderr, "  -token-scopes <list>      Scopes for -token-create: read,write,sync,approve,admin (default: read)\n")
+        fmt.Fprintf(os.Stderr, "  -token-zones <list>       Limit -token-create to zones (example.com, *.example.com)\n")
+        fmt.Fprintf(os.Stderr, "  -token-days <n>           Expire -token-create after n days (default: never)\n")
+        fmt.Fprintf(os.Stderr, "  -token-list               List API tokens and exit\n")
+        fmt.Fprintf(os.Stderr, "  -token-revoke <id|name>   Revoke an API token and exit\n")
         fmt.Fprintf(os.Stderr, "  -v, -version              Print version and exit\n")
         fmt.Fprintf(os.Stderr, "  -h, -help                 Show this help message\n")
         fmt.Fprintf(os.Stderr, "\nEnvironment Variables:\n")
@@ -83,6 +100,12 @@ func main() {
         fmt.Fprintf(os.Stderr, "  namedot -import backup.json      Import zones from file (merge)\n")
         fmt.Fprintf(os.Stderr, "  namedot -import backup.json -import-mode replace\n")
         fmt.Fprintf(os.Stderr, "                                   Import zones (replace all)\n")
+        fmt.Fprintf(os.Stderr, "  namedot -import backup.json -import-mode replace -dry-run\n")
+        fmt.Fprintf(os.Stderr, "                                   Show what a replace import would change\n")
+        fmt.Fprintf(os.Stderr, "  namedot -import-bind /etc/bind/named.conf -dry-run\n")
+        fmt.Fprintf(os.Stderr, "                                   Show what importing a BIND server would change\n")
+        fmt.Fprintf(os.Stderr, "  namedot -token-create team-a -token-scopes read,write -token-zones '*.team-a.example'\n")
+        fmt.Fprintf(os.Stderr, "                                   Create a token for one team\n")
         fmt.Fprintf(os.Stderr, "\nDocumentation: https://github.com/foxzi/namedot\n")
     }
 
@@ -97,6 +120,14 @@ func main() {
     flag.StringVar(&exportFile, "export", "", "")
     flag.StringVar(&importFile, "import", "", "")
     flag.StringVar(&importMode, "import-mode", "merge", "")
+    flag.StringVar(&importBind, "import-bind", "", "")
+    flag.BoolVar(&dryRun, "dry-run", false, "")
+    flag.StringVar(&tokCreate, "token-create", "", "")
+    flag.StringVar(&tokScopes, "token-scopes", "read", "")
+    flag.StringVar(&tokZones, "token-zones", "", "")
+    flag.IntVar(&tokDays, "token-days", 0, "")
+    flag.BoolVar(&tokList, "token-list", false, "")
+    flag.StringVar(&tokRevoke, "token-revoke", "", "")
     flag.BoolVar(&showVer, "v", false, "")
     flag.BoolVar(&showVer, "version", false, "")
     flag.Parse()
@@ -186,15 +217,107 @@ func main() {
             log.Fatalf("invalid import mode: %s (must be 'merge' or 'replace')", importMode)
         }
         fmt.Printf("Importing zones from %s (mode: %s)...\n", importFile, importMode)
-        if err := db.ImportZones(gormDB, importFile, importMode); err != nil {
+        diffs, err := db.ImportZones(gormDB, importFile, importMode, db.SerialManager{AutoSOA: cfg.AutoSOAOnMissing, Policy: cfg.SOASerialPolicy}, cfg.HasView, dryRun)
+        if err != nil {
             log.Fatalf("import failed: %v", err)
         }
+        if dryRun {
+            for _, d := range diffs {
+                printZoneDiff(d)
+            }
+            fmt.Println("Dry run: nothing was changed")
+            return
+        }
         var count int64
         gormDB.Model(&db.Zone{}).Count(&count)
         fmt.Printf("Successfully imported zones. Total zones in database: %d\n", count)
         return
     }
 
+    // Handle BIND import command
+    if importBind != "" {
+        mode := "upsert"
+        switch importMode {
+        case "merge":
+        case "replace":
+            mode = "replace"
+        default:
+            log.Fatalf("invalid import mode: %s (must be 'merge' or 'replace')", importMode)
+        }
+        sb, conf, err := zoneio.Load(importBind)
+        if err != nil {
+            log.Fatalf("import failed: %v", err)
+        }
+        rep, err := zoneio.BulkImport(gormDB, sb, zoneio.BulkOptions{
+            Mode:       mode,
+            NamedConf:  conf,
+            DefaultTTL: cfg.DefaultTTL,
+            DryRun:     dryRun,
+            HasView:    cfg.HasView,
+            Actor:      db.Actor{Name: "cli"},
+            Serials:    db.SerialManager{AutoSOA: cfg.AutoSOAOnMissing, Policy: cfg.SOASerialPolicy},
+        })
+        printBulkReport(rep)
+        if err != nil {
+            log.Fatalf("import failed: %v", err)
+        }
+        return
+    }
+
+    // Handle API token commands
+    if tokCreate != "" {
+        var expires *time.Time
+        if tokDays > 0 {
+            t := time.Now().AddDate(0, 0, tokDays)
+            expires = &t
+        }
+        tok, plain, err := db.CreateAPIToken(gormDB, tokCreate, db.SplitList(tokScopes), db.SplitList(tokZones), expires)
+        if err != nil {
+            log.Fatalf("create token failed: %v", err)
+        }
+        fmt.Printf("Created API token %q (id %d, scopes: %s)\n", tok.Name, tok.ID, tok.Scopes)
+        fmt.Printf("%s\n", plain)
+        fmt.Println("\nStore it now: the token cannot be shown again.")
+        return
+    }
+    if tokList {
+        toks, err := db.ListAPITokens(gormDB)
+        if err != nil {
+            log.Fatalf("list tokens failed: %v", err)
+        }
+        fmt.Printf("%-4s %-20s %-22s %-30s %-10s %s\n", "ID", "NAME", "SCOPES", "ZONES", "STATUS", "EXPIRES")
+        for _, t := range toks {
+            status := "active"
+            if t.RevokedAt != nil {
+                status = "revoked"
+            } else if !t.Active(time.Now()) {
+                status = "expired"
+            }
+            zones, expires := t.Zones, "never"
+            if zones == "" {
+                zones = "*"
+            }
+            if t.ExpiresAt != nil {
+                expires = t.ExpiresAt.Format(time.RFC3339)
+            }
+            fmt.Printf("%-4d %-20s %-22s %-30s %-10s %s\n", t.ID, t.Name, t.Scopes, zones, status, expires)
+        }
+        return
+    }
+    if tokRevoke != "" {
+        tok, err := db.RevokeAPIToken(gormDB, tokRevoke)
+        if err != nil {
+            log.Fatalf("revoke token failed: %v", err)
+        }
+        fmt.Printf("Revoked API token %q (id %d)\n", tok.Name, tok.ID)
+        return
+    }
+
+    // Checked after the token commands, which create the second identity
+    if err := db.CheckChangesetApproval(gormDB, cfg); err != nil {
+        log.Fatalf("config: %v", err)
+    }
+
     ctx, cancel := context.WithCancel(context.Background())
     defer cancel()
 
@@ -243,3 +366,60 @@ func main() {
     _ = restServer.Shutdown(shutdownCtx)
     _ = dnsServer.Shutdown()
 }
+
+// printBulkReport prints the zones of a BIND import with their changes
+func printBulkReport(rep zoneio.BulkReport) {
+    for _, z := range rep.Zones {
+        if z.Error != "" {
+            fmt.Printf("%s: %s: %s\n", zoneLabel(z.ZoneDiff), z.File, z.Error)
+            continue
+        }
+        printZoneDiff(z.ZoneDiff)
+    }
+    for _, s := range rep.Skipped {
+        fmt.Printf("skipped %s\n", s)
+    }
+    if rep.DryRun {
+        fmt.Println("Dry run: nothing was changed")
+    }
+}
+
+// printZoneDiff prints the RRSets an import adds (+), changes (~) or
+// removes (-) in a zone
+func printZoneDiff(d db.ZoneDiff) {
+    marks := map[string]string{db.ChangeAdded: "+", db.ChangeChanged: "~", db.ChangeRemoved: "-"}
+    state := ""
+    switch {
+    case d.Created:
+        state = ", new zone"
+    case d.Deleted:
+        state = ", zone deleted"
+    }
+    fmt.Printf("%s: %d added, %d changed, %d removed%s\n", zoneLabel(d), d.Added, d.Changed, d.Removed, state)
+    for _, ch := range d.Changes {
+        fmt.Printf("  %s %s %s\n", marks[ch.Change], ch.Name, ch.Type)
+        if ch.Change != db.ChangeChanged {
+            continue
+        }
+        for _, side := range []struct {
+            mark string
+            rs   *db.RRSet
+        }{{"-", ch.Before}, {"+", ch.After}} {
+            for _, r := range side.rs.Records {
+                fmt.Printf("      %s %d %s", side.mark, side.rs.TTL, r.Data)
+                if attrs := zoneio.RecordAttrs(r); attrs != "" {
+                    fmt.Printf(" (%s)", attrs)
+                }
+                fmt.Println()
+            }
+        }
+    }
+}
+
+// zoneLabel names a zone with its view
+func zoneLabel(d db.ZoneDiff) string {
+    if d.View != "" {
+        return d.Zone + " (view " + d.View + ")"
+    }
+    return d.Zone
+}
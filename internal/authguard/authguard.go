@@ -0,0 +1,176 @@
+// Package authguard throttles repeated authentication failures. Failures
+// are counted per client IP and per username; after a few free attempts
+// each further attempt must wait exponentially longer, and a key with too
+// many failures is locked out for a while. Rejected attempts never reach
+// the (expensive) password comparison.
+package authguard
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"namedot/internal/config"
+	"namedot/internal/metrics"
+)
+
+// Failure and lockout counters, labelled by source ("web" or "rest")
+var (
+	FailuresTotal = metrics.NewCounterVec("namedot_auth_failures_total", "Failed authentication attempts.", "source")
+	BlockedTotal  = metrics.NewCounterVec("namedot_auth_blocked_total", "Authentication attempts rejected by backoff or lockout.", "source")
+	LockoutsTotal = metrics.NewCounterVec("namedot_auth_lockouts_total", "Usernames or client IPs locked out.", "source")
+)
+
+// maxEntries bounds the memory used by tracked keys
+const maxEntries = 100000
+
+// Attempt identifies an authentication attempt. Username is empty for
+// bearer tokens.
+type Attempt struct {
+	Source   string
+	IP       string
+	Username string
+}
+
+func (a Attempt) keys() []string {
+	keys := []string{"ip:" + a.IP}
+	if a.Username != "" {
+		keys = append(keys, "user:"+a.Username)
+	}
+	return keys
+}
+
+type entry struct {
+	failures     int
+	last         time.Time
+	blockedUntil time.Time
+}
+
+// Guard tracks failures; it is safe for concurrent use
+type Guard struct {
+	disabled   bool
+	free       int
+	backoffMax time.Duration
+	lockout    int
+	lockoutFor time.Duration
+	window     time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	now     func() time.Time
+}
+
+// New returns a guard with the given settings, applying defaults to
+// unset values
+func New(cfg config.LoginProtectionConfig) *Guard {
+	return &Guard{
+		disabled:   cfg.Disabled,
+		free:       orDefault(cfg.FreeAttempts, 5),
+		backoffMax: time.Duration(orDefault(cfg.BackoffMaxSec, 60)) * time.Second,
+		lockout:    orDefault(cfg.LockoutAttempts, 20),
+		lockoutFor: time.Duration(orDefault(cfg.LockoutSec, 900)) * time.Second,
+		window:     time.Duration(orDefault(cfg.WindowSec, 900)) * time.Second,
+		entries:    map[string]*entry{},
+		now:        time.Now,
+	}
+}
+
+func orDefault(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+// Check returns how long the caller must wait before the attempt is
+// accepted; zero means go ahead. Rejections are logged and counted.
+func (g *Guard) Check(a Attempt) time.Duration {
+	if g.disabled {
+		return 0
+	}
+	g.mu.Lock()
+	now := g.now()
+	var wait time.Duration
+	for _, k := range a.keys() {
+		if e, ok := g.entries[k]; ok && e.blockedUntil.After(now) {
+			if d := e.blockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	g.mu.Unlock()
+
+	if wait > 0 {
+		BlockedTotal.Inc(a.Source)
+		log.Printf("AUTH blocked %s attempt user=%q ip=%s retry_after=%s", a.Source, a.Username, a.IP, wait.Round(time.Second))
+	}
+	return wait
+}
+
+// Fail records a failed attempt, starting the backoff or lockout of its keys
+func (g *Guard) Fail(a Attempt) {
+	FailuresTotal.Inc(a.Source)
+	log.Printf("AUTH failed %s login user=%q ip=%s", a.Source, a.Username, a.IP)
+	if g.disabled {
+		return
+	}
+
+	g.mu.Lock()
+	now := g.now()
+	if len(g.entries) >= maxEntries {
+		g.pruneLocked(now)
+	}
+	var locked []string
+	for _, k := range a.keys() {
+		e, ok := g.entries[k]
+		if !ok && len(g.entries) >= maxEntries {
+			continue
+		}
+		if !ok || now.Sub(e.last) > g.window {
+			e = &entry{}
+			g.entries[k] = e
+		}
+		e.failures++
+		e.last = now
+		switch {
+		case e.failures >= g.lockout:
+			if !e.blockedUntil.After(now) || e.failures == g.lockout {
+				locked = append(locked, k)
+			}
+			e.blockedUntil = now.Add(g.lockoutFor)
+		case e.failures > g.free:
+			delay := g.backoffMax
+			if shift := e.failures - g.free - 1; shift < 30 {
+				if d := time.Second << shift; d < delay {
+					delay = d
+				}
+			}
+			e.blockedUntil = now.Add(delay)
+		}
+	}
+	g.mu.Unlock()
+
+	for _, k := range locked {
+		LockoutsTotal.Inc(a.Source)
+		log.Printf("AUTH lockout %s %s for %s", a.Source, k, g.lockoutFor)
+	}
+}
+
+// Succeed forgets the failures of the username. The IP keeps its failures,
+// so one valid credential cannot be used to reset the backoff of an address.
+func (g *Guard) Succeed(a Attempt) {
+	if g.disabled || a.Username == "" {
+		return
+	}
+	g.mu.Lock()
+	delete(g.entries, "user:"+a.Username)
+	g.mu.Unlock()
+}
+
+func (g *Guard) pruneLocked(now time.Time) {
+	for k, e := range g.entries {
+		if now.Sub(e.last) > g.window && !e.blockedUntil.After(now) {
+			delete(g.entries, k)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package authguard
+
+import (
+	"testing"
+	"time"
+
+	"namedot/internal/config"
+)
+
+func newTestGuard(cfg config.LoginProtectionConfig) (*Guard, *time.Time) {
+	g := New(cfg)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	g.now = func() time.Time { return now }
+	return g, &now
+}
+
+func TestGuard_ExponentialBackoff(t *testing.T) {
+	g, now := newTestGuard(config.LoginProtectionConfig{FreeAttempts: 3, BackoffMaxSec: 4})
+	a := Attempt{Source: "test", IP: "192.0.2.1", Username: "alice"}
+
+	for i := 0; i < 3; i++ {
+		if wait := g.Check(a); wait != 0 {
+			t.Fatalf("attempt %d: free attempt delayed by %s", i+1, wait)
+		}
+		g.Fail(a)
+	}
+	// Free attempts used up: the next failures wait 1s, 2s, 4s, then the cap
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		if wait := g.Check(a); wait != 0 {
+			t.Fatalf("attempt after backoff rejected: %s", wait)
+		}
+		g.Fail(a)
+		if wait := g.Check(a); wait != want {
+			t.Fatalf("backoff: want %s, got %s", want, wait)
+		}
+		*now = now.Add(want)
+	}
+
+	// The IP is throttled for other usernames too
+	if wait := g.Check(Attempt{Source: "test", IP: "192.0.2.1", Username: "bob"}); wait != 0 {
+		t.Fatalf("backoff should have expired, got %s", wait)
+	}
+	g.Fail(a)
+	if wait := g.Check(Attempt{Source: "test", IP: "192.0.2.1", Username: "bob"}); wait == 0 {
+		t.Fatalf("IP backoff should apply to other usernames")
+	}
+}
+
+func TestGuard_LockoutAndWindow(t *testing.T) {
+	g, now := newTestGuard(config.LoginProtectionConfig{FreeAttempts: 100, LockoutAttempts: 3, LockoutSec: 60, WindowSec: 30})
+	before := LockoutsTotal.Value("lockout-test")
+	a := Attempt{Source: "lockout-test", IP: "192.0.2.2", Username: "carol"}
+
+	g.Fail(a)
+	g.Fail(a)
+	*now = now.Add(31 * time.Second)
+	g.Fail(a) // the first two failures are outside the window
+	if wait := g.Check(a); wait != 0 {
+		t.Fatalf("failures outside the window should be forgotten, got %s", wait)
+	}
+	g.Fail(a)
+	g.Fail(a)
+	if wait := g.Check(a); wait != time.Minute {
+		t.Fatalf("lockout: want 1m, got %s", wait)
+	}
+	if got := LockoutsTotal.Value("lockout-test") - before; got != 2 {
+		t.Fatalf("want 2 lockouts (ip and user), got %d", got)
+	}
+	if FailuresTotal.Value("lockout-test") != 5 || BlockedTotal.Value("lockout-test") != 1 {
+		t.Fatalf("unexpected counters: failures=%d blocked=%d", FailuresTotal.Value("lockout-test"), BlockedTotal.Value("lockout-test"))
+	}
+
+	*now = now.Add(time.Minute)
+	if wait := g.Check(a); wait != 0 {
+		t.Fatalf("lockout should expire, got %s", wait)
+	}
+}
+
+func TestGuard_SucceedKeepsIPFailures(t *testing.T) {
+	g, _ := newTestGuard(config.LoginProtectionConfig{FreeAttempts: 1})
+	a := Attempt{Source: "test", IP: "192.0.2.3", Username: "dave"}
+	g.Fail(a)
+	g.Fail(a)
+	g.Succeed(a)
+	if wait := g.Check(Attempt{Source: "test", IP: "192.0.2.4", Username: "dave"}); wait != 0 {
+		t.Fatalf("success should clear the username, got %s", wait)
+	}
+	if wait := g.Check(a); wait == 0 {
+		t.Fatalf("success must not clear the IP backoff")
+	}
+}
+
+func TestGuard_Disabled(t *testing.T) {
+	g, _ := newTestGuard(config.LoginProtectionConfig{Disabled: true, FreeAttempts: 1})
+	a := Attempt{Source: "test", IP: "192.0.2.5"}
+	for i := 0; i < 10; i++ {
+		g.Fail(a)
+	}
+	if wait := g.Check(a); wait != 0 {
+		t.Fatalf("disabled guard should not throttle, got %s", wait)
+	}
+}
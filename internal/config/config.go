@@ -3,11 +3,15 @@ package config
 import (
     "fmt"
     "net"
+    "net/url"
     "os"
     "strconv"
     "strings"
+    "time"
 
     "gopkg.in/yaml.v3"
+
+    "namedot/internal/totp"
 )
 
 type DBConfig struct {
@@ -36,9 +40,64 @@ type PerformanceConfig struct {
 }
 
 type AdminConfig struct {
-    Enabled      bool   `yaml:"enabled"`
-    Username     string `yaml:"username"`
-    PasswordHash string `yaml:"password_hash"` // bcrypt hash
+    Enabled      bool       `yaml:"enabled"`
+    Username     string     `yaml:"username"`
+    PasswordHash string     `yaml:"password_hash"` // bcrypt hash
+    TOTPSecret   string     `yaml:"totp_secret"`   // base32 TOTP secret of the config file admin, enables its second factor
+    Require2FA   bool       `yaml:"require_2fa"`   // local users must enroll TOTP before using the panel
+    OIDC         OIDCConfig `yaml:"oidc"`
+
+    SessionStore          string `yaml:"session_store"`            // "memory" (default) or "db" to survive restarts
+    SessionIdleTimeoutSec int    `yaml:"session_idle_timeout_sec"` // logout after inactivity (default 7200)
+    SessionMaxAgeSec      int    `yaml:"session_max_age_sec"`      // logout after login regardless of activity (default 86400)
+
+    LoginProtection LoginProtectionConfig `yaml:"login_protection"` // also applies to REST bearer tokens
+}
+
+// LoginProtectionConfig throttles repeated authentication failures per
+// client IP and per username. After FreeAttempts failures every further
+// attempt waits twice as long as the previous one (1s, 2s, 4s... up to
+// BackoffMaxSec); LockoutAttempts failures block the key for LockoutSec.
+type LoginProtectionConfig struct {
+    Disabled        bool `yaml:"disabled"`
+    FreeAttempts    int  `yaml:"free_attempts"`    // default 5
+    BackoffMaxSec   int  `yaml:"backoff_max_sec"`  // default 60
+    LockoutAttempts int  `yaml:"lockout_attempts"` // default 20
+    LockoutSec      int  `yaml:"lockout_sec"`      // default 900
+    WindowSec       int  `yaml:"window_sec"`       // failures older than this are forgotten, default 900
+}
+
+// SessionIdleTimeout returns the web session inactivity limit
+func (a AdminConfig) SessionIdleTimeout() time.Duration {
+    if a.SessionIdleTimeoutSec > 0 {
+        return time.Duration(a.SessionIdleTimeoutSec) * time.Second
+    }
+    return 2 * time.Hour
+}
+
+// SessionMaxAge returns the absolute web session lifetime
+func (a AdminConfig) SessionMaxAge() time.Duration {
+    if a.SessionMaxAgeSec > 0 {
+        return time.Duration(a.SessionMaxAgeSec) * time.Second
+    }
+    return 24 * time.Hour
+}
+
+// OIDCConfig enables single sign-on through an OpenID Connect provider
+// (authorization code flow with PKCE) next to the local login.
+type OIDCConfig struct {
+    Enabled       bool              `yaml:"enabled"`
+    Issuer        string            `yaml:"issuer"`        // e.g. https://login.example.com/realms/ops
+    ClientID      string            `yaml:"client_id"`
+    ClientSecret  string            `yaml:"client_secret"` // empty for public clients
+    RedirectURL   string            `yaml:"redirect_url"`  // https://dns.example.com/admin/oidc/callback
+    Scopes        []string          `yaml:"scopes"`        // default: openid, profile, email
+    UsernameClaim string            `yaml:"username_claim"` // default: preferred_username (falls back to email, sub)
+    RoleClaim     string            `yaml:"role_claim"`    // default: groups
+    RoleMap       map[string]string `yaml:"role_map"`      // role claim value -> viewer, editor or admin
+    DefaultRole   string            `yaml:"default_role"`  // role when no mapping matches; empty denies the login
+    ButtonLabel   string            `yaml:"button_label"`  // login page button, default "Sign in with SSO"
+    DisablePasswordLogin bool       `yaml:"disable_password_login"` // SSO only
 }
 
 type ReplicationConfig struct {
@@ -50,7 +109,7 @@ type ReplicationConfig struct {
 
 type Config struct {
     Listen       string     `yaml:"listen"`
-    Forwarder    string     `yaml:"forwarder"`
+    Forwarder    ForwarderList `yaml:"forwarder"` // one or more upstreams, see ParseUpstream
     EnableDNSSEC bool       `yaml:"enable_dnssec"`
     APIToken     string     `yaml:"api_token"`      // Plain text token (deprecated, use api_token_hash)
     APITokenHash string     `yaml:"api_token_hash"` // bcrypt hash of token (recommended)
@@ -59,8 +118,11 @@ type Config struct {
     TLSKeyFile   string     `yaml:"tls_key_file"`   // Path to TLS private key file for HTTPS
     TLSReloadSec int        `yaml:"tls_reload_sec"` // Certificate reload interval in seconds (0 = no reload)
     AllowedCIDRs []string   `yaml:"allowed_cidrs"`  // List of allowed CIDR blocks for REST API access (empty = allow all)
+    TrustedProxies []string `yaml:"trusted_proxies"` // proxies (IPs or CIDRs) whose X-Forwarded-For/X-Real-IP is believed; empty = none
     AutoSOAOnMissing bool   `yaml:"auto_soa_on_missing"`
     DefaultTTL   uint32     `yaml:"default_ttl"`
+    SOASerialPolicy string  `yaml:"soa_serial_policy"` // increment (default), date or epoch; zones may set their own
+    ChangesetApproval bool  `yaml:"changeset_approval"` // publishing a changeset needs approval by a second user or token
 
     DB          DBConfig          `yaml:"db"`
     GeoIP       GeoIPConfig       `yaml:"geoip"`
@@ -68,6 +130,10 @@ type Config struct {
     Performance PerformanceConfig `yaml:"performance"`
     Admin       AdminConfig       `yaml:"admin"`
     Replication ReplicationConfig `yaml:"replication"`
+    Forwarding  ForwardingConfig  `yaml:"forwarding"`
+
+    Views    []ViewConfig      `yaml:"views"`     // split-horizon views, see ViewConfig
+    TSIGKeys map[string]string `yaml:"tsig_keys"` // TSIG key name -> base64 secret
 }
 
 func Load(path string) (*Config, error) {
@@ -96,9 +162,29 @@ func Load(path string) (*Config, error) {
     if cfg.Performance.ForwarderTimeoutSec == 0 {
         cfg.Performance.ForwarderTimeoutSec = 2
     }
+    if cfg.Forwarding.Strategy == "" {
+        cfg.Forwarding.Strategy = StrategyRoundRobin
+    }
+    if cfg.Forwarding.MaxFails == 0 {
+        cfg.Forwarding.MaxFails = 3
+    }
+    if cfg.Forwarding.FailTimeoutSec == 0 {
+        cfg.Forwarding.FailTimeoutSec = 30
+    }
     if cfg.Replication.SyncIntervalSec == 0 && cfg.Replication.Mode == "slave" {
         cfg.Replication.SyncIntervalSec = 60 // Default: 60 seconds
     }
+    if o := &cfg.Admin.OIDC; o.Enabled {
+        if len(o.Scopes) == 0 {
+            o.Scopes = []string{"openid", "profile", "email"}
+        }
+        if o.UsernameClaim == "" {
+            o.UsernameClaim = "preferred_username"
+        }
+        if o.RoleClaim == "" {
+            o.RoleClaim = "groups"
+        }
+    }
     if cfg.TLSReloadSec == 0 && cfg.IsTLSEnabled() {
         cfg.TLSReloadSec = 3600 // Default: 3600 seconds (1 hour)
     }
@@ -131,11 +217,35 @@ func (c *Config) Validate() error {
         return fmt.Errorf("invalid rest_listen address: %w", err)
     }
 
-    // Validate forwarder if set
-    if c.Forwarder != "" {
-        if err := validateHost(c.Forwarder); err != nil {
-            return fmt.Errorf("invalid forwarder address: %w", err)
+    // Validate forwarders if set
+    if _, err := c.Forwarder.Upstreams(); err != nil {
+        return fmt.Errorf("invalid forwarder address: %w", err)
+    }
+    if !validStrategy(c.Forwarding.Strategy) {
+        return fmt.Errorf("forwarding.strategy must be 'round_robin', 'fastest' or 'failover' (got '%s')", c.Forwarding.Strategy)
+    }
+    if c.Forwarding.MaxFails < 0 || c.Forwarding.FailTimeoutSec < 0 {
+        return fmt.Errorf("forwarding.max_fails and forwarding.fail_timeout_sec must be >= 0")
+    }
+    for i, z := range c.Forwarding.Zones {
+        if strings.TrimSpace(z.Zone) == "" {
+            return fmt.Errorf("forwarding.zones[%d]: zone is required", i)
+        }
+        if len(z.Upstreams) == 0 {
+            return fmt.Errorf("forwarding.zones[%d]: at least one upstream is required", i)
         }
+        if _, err := z.Upstreams.Upstreams(); err != nil {
+            return fmt.Errorf("forwarding.zones[%d]: invalid upstream: %w", i, err)
+        }
+        if !validStrategy(z.Strategy) {
+            return fmt.Errorf("forwarding.zones[%d]: invalid strategy '%s'", i, z.Strategy)
+        }
+    }
+
+    switch c.SOASerialPolicy {
+    case "", "increment", "date", "epoch":
+    default:
+        return fmt.Errorf("soa_serial_policy must be 'increment', 'date' or 'epoch' (got '%s')", c.SOASerialPolicy)
     }
 
     // Validate DB config
@@ -214,12 +324,45 @@ func (c *Config) Validate() error {
         }
     }
 
+    if s := c.Admin.SessionStore; s != "" && s != "memory" && s != "db" {
+        return fmt.Errorf("admin.session_store must be 'memory' or 'db' (got '%s')", s)
+    }
+    if c.Admin.SessionIdleTimeoutSec < 0 || c.Admin.SessionMaxAgeSec < 0 {
+        return fmt.Errorf("admin.session_idle_timeout_sec and admin.session_max_age_sec must be >= 0")
+    }
+    if c.Admin.TOTPSecret != "" {
+        if _, err := totp.Code(c.Admin.TOTPSecret, time.Now()); err != nil {
+            return fmt.Errorf("admin.totp_secret must be base32: %w", err)
+        }
+    }
+    if c.Admin.Require2FA && c.Admin.PasswordHash != "" && c.Admin.TOTPSecret == "" {
+        return fmt.Errorf("admin.require_2fa needs admin.totp_secret for the config file admin")
+    }
+    if lp := c.Admin.LoginProtection; lp.FreeAttempts < 0 || lp.BackoffMaxSec < 0 || lp.LockoutAttempts < 0 || lp.LockoutSec < 0 || lp.WindowSec < 0 {
+        return fmt.Errorf("admin.login_protection values must be >= 0")
+    } else if lp.LockoutAttempts > 0 && lp.FreeAttempts > 0 && lp.LockoutAttempts <= lp.FreeAttempts {
+        return fmt.Errorf("admin.login_protection.lockout_attempts must be greater than free_attempts")
+    }
+    if err := c.Admin.OIDC.validate(); err != nil {
+        return err
+    }
+
+    // Validate views and TSIG keys
+    if err := c.validateViews(); err != nil {
+        return err
+    }
+
     // Validate allowed CIDRs
     for i, cidr := range c.AllowedCIDRs {
         if _, _, err := net.ParseCIDR(cidr); err != nil {
             return fmt.Errorf("allowed_cidrs[%d]: invalid CIDR %q: %w", i, cidr, err)
         }
     }
+    for i, p := range c.TrustedProxies {
+        if _, _, err := net.ParseCIDR(p); err != nil && net.ParseIP(p) == nil {
+            return fmt.Errorf("trusted_proxies[%d]: invalid IP or CIDR %q", i, p)
+        }
+    }
 
     return nil
 }
@@ -277,3 +420,27 @@ func validateHost(addr string) error {
 
     return nil
 }
+
+func (o *OIDCConfig) validate() error {
+    if !o.Enabled {
+        return nil
+    }
+    if o.Issuer == "" || o.ClientID == "" || o.RedirectURL == "" {
+        return fmt.Errorf("admin.oidc: issuer, client_id and redirect_url are required")
+    }
+    for _, u := range []string{o.Issuer, o.RedirectURL} {
+        if pu, err := url.Parse(u); err != nil || (pu.Scheme != "https" && pu.Scheme != "http") || pu.Host == "" {
+            return fmt.Errorf("admin.oidc: invalid URL %q", u)
+        }
+    }
+    validRole := func(r string) bool { return r == "viewer" || r == "editor" || r == "admin" }
+    for value, role := range o.RoleMap {
+        if !validRole(role) {
+            return fmt.Errorf("admin.oidc.role_map[%s]: role must be viewer, editor or admin (got %q)", value, role)
+        }
+    }
+    if o.DefaultRole != "" && !validRole(o.DefaultRole) {
+        return fmt.Errorf("admin.oidc.default_role must be viewer, editor, admin or empty (got %q)", o.DefaultRole)
+    }
+    return nil
+}
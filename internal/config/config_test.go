@@ -31,7 +31,7 @@ func TestConfigValidation(t *testing.T) {
 			name: "valid config with all fields",
 			config: &Config{
 				Listen:           "127.0.0.1:5353",
-				Forwarder:        "8.8.8.8",
+				Forwarder:        ForwarderList{"8.8.8.8"},
 				RESTListen:       "127.0.0.1:8081",
 				EnableDNSSEC:     true,
 				APIToken:         "test-token-123",
@@ -107,7 +107,7 @@ func TestConfigValidation(t *testing.T) {
 			config: &Config{
 				Listen:     "0.0.0.0:53",
 				RESTListen: "0.0.0.0:8080",
-				Forwarder:  "invalid forwarder",
+				Forwarder:  ForwarderList{"invalid forwarder"},
 				DB: DBConfig{
 					Driver: "sqlite",
 					DSN:    ":memory:",
@@ -337,6 +337,105 @@ func TestConfigValidation(t *testing.T) {
 			expectedError: "",
 			description:   "Should accept valid IPv4 and IPv6 CIDRs",
 		},
+		{
+			name: "valid views",
+			config: &Config{
+				Listen:     "0.0.0.0:53",
+				RESTListen: "0.0.0.0:8080",
+				DB:         DBConfig{Driver: "sqlite", DSN: ":memory:"},
+				TSIGKeys:   map[string]string{"internal-key": "c2VjcmV0"},
+				Views: []ViewConfig{
+					{Name: "internal", MatchClients: []string{"10.0.0.0/8"}, TSIGKeys: []string{"internal-key"}},
+					{Name: "lan", Listen: []string{"192.168.1.1:53"}},
+				},
+			},
+			expectedError: "",
+			description:   "Should accept views with clients, keys and listeners",
+		},
+		{
+			name: "view with unknown tsig key",
+			config: &Config{
+				Listen:     "0.0.0.0:53",
+				RESTListen: "0.0.0.0:8080",
+				DB:         DBConfig{Driver: "sqlite", DSN: ":memory:"},
+				Views:      []ViewConfig{{Name: "internal", TSIGKeys: []string{"missing"}}},
+			},
+			expectedError: "unknown tsig key",
+			description:   "Should reject views referencing undefined TSIG keys",
+		},
+		{
+			name: "duplicate view names",
+			config: &Config{
+				Listen:     "0.0.0.0:53",
+				RESTListen: "0.0.0.0:8080",
+				DB:         DBConfig{Driver: "sqlite", DSN: ":memory:"},
+				Views: []ViewConfig{
+					{Name: "internal", MatchClients: []string{"10.0.0.0/8"}},
+					{Name: "internal", MatchClients: []string{"172.16.0.0/12"}},
+				},
+			},
+			expectedError: "duplicate view name",
+			description:   "Should reject duplicate view names",
+		},
+		{
+			name: "view without selectors",
+			config: &Config{
+				Listen:     "0.0.0.0:53",
+				RESTListen: "0.0.0.0:8080",
+				DB:         DBConfig{Driver: "sqlite", DSN: ":memory:"},
+				Views:      []ViewConfig{{Name: "empty"}},
+			},
+			expectedError: "at least one of match_clients",
+			description:   "Should reject views that can never be selected",
+		},
+		{
+			name: "oidc without client",
+			config: &Config{
+				Listen:     "0.0.0.0:53",
+				RESTListen: "0.0.0.0:8080",
+				DB:         DBConfig{Driver: "sqlite", DSN: ":memory:"},
+				Admin:      AdminConfig{OIDC: OIDCConfig{Enabled: true, Issuer: "https://login.example.com", RedirectURL: "https://dns.example.com/admin/oidc/callback"}},
+			},
+			expectedError: "issuer, client_id and redirect_url are required",
+			description:   "Should require the OIDC client registration",
+		},
+		{
+			name: "oidc unknown role",
+			config: &Config{
+				Listen:     "0.0.0.0:53",
+				RESTListen: "0.0.0.0:8080",
+				DB:         DBConfig{Driver: "sqlite", DSN: ":memory:"},
+				Admin: AdminConfig{OIDC: OIDCConfig{
+					Enabled: true, Issuer: "https://login.example.com", ClientID: "namedot",
+					RedirectURL: "https://dns.example.com/admin/oidc/callback",
+					RoleMap:     map[string]string{"dns-ops": "root"},
+				}},
+			},
+			expectedError: "role must be viewer, editor or admin",
+			description:   "Should reject role mappings to unknown roles",
+		},
+		{
+			name: "unknown session store",
+			config: &Config{
+				Listen:     "0.0.0.0:53",
+				RESTListen: "0.0.0.0:8080",
+				DB:         DBConfig{Driver: "sqlite", DSN: ":memory:"},
+				Admin:      AdminConfig{SessionStore: "redis"},
+			},
+			expectedError: "admin.session_store must be 'memory' or 'db'",
+			description:   "Should reject unsupported session stores",
+		},
+		{
+			name: "required 2fa without admin totp secret",
+			config: &Config{
+				Listen:     "0.0.0.0:53",
+				RESTListen: "0.0.0.0:8080",
+				DB:         DBConfig{Driver: "sqlite", DSN: ":memory:"},
+				Admin:      AdminConfig{Enabled: true, Username: "admin", PasswordHash: "$2a$10$x", Require2FA: true},
+			},
+			expectedError: "admin.require_2fa needs admin.totp_secret",
+			description:   "Should not lock out the config file admin when 2FA is required",
+		},
 	}
 
 	for _, tt := range tests {
@@ -619,3 +718,87 @@ replication:
 		t.Error("Expected admin to be auto-disabled in slave mode, but it's still enabled")
 	}
 }
+
+func TestParseUpstream(t *testing.T) {
+	tests := []struct {
+		spec, addr, net, sni string
+		wantErr              bool
+	}{
+		{spec: "8.8.8.8", addr: "8.8.8.8:53", net: "udp"},
+		{spec: "8.8.8.8:5353", addr: "8.8.8.8:5353", net: "udp"},
+		{spec: "tcp://9.9.9.9", addr: "9.9.9.9:53", net: "tcp"},
+		{spec: "tls://1.1.1.1#cloudflare-dns.com", addr: "1.1.1.1:853", net: "tcp-tls", sni: "cloudflare-dns.com"},
+		{spec: "tls://dns.google", addr: "dns.google:853", net: "tcp-tls", sni: "dns.google"},
+		{spec: "2001:4860:4860::8888", addr: "[2001:4860:4860::8888]:53", net: "udp"},
+		{spec: "[2001:4860:4860::8888]:5353", addr: "[2001:4860:4860::8888]:5353", net: "udp"},
+		{spec: "https://8.8.8.8", wantErr: true},
+		{spec: "udp://8.8.8.8#name", wantErr: true},
+		{spec: "8.8.8.8:99999", wantErr: true},
+		{spec: "bad host", wantErr: true},
+	}
+	for _, tt := range tests {
+		u, err := ParseUpstream(tt.spec)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseUpstream(%q): expected error", tt.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseUpstream(%q): %v", tt.spec, err)
+			continue
+		}
+		if u.Addr != tt.addr || u.Net != tt.net || u.ServerName != tt.sni {
+			t.Errorf("ParseUpstream(%q) = %+v", tt.spec, u)
+		}
+	}
+}
+
+func TestConfigLoad_ForwarderScalarAndList(t *testing.T) {
+	tmpDir := t.TempDir()
+	base := "listen: \":53\"\ndb:\n  driver: sqlite\n  dsn: \":memory:\"\n"
+
+	scalar := filepath.Join(tmpDir, "scalar.yaml")
+	if err := os.WriteFile(scalar, []byte(base+"forwarder: \"8.8.8.8\"\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	cfg, err := Load(scalar)
+	if err != nil {
+		t.Fatalf("load scalar: %v", err)
+	}
+	if len(cfg.Forwarder) != 1 || cfg.Forwarder[0] != "8.8.8.8" {
+		t.Errorf("unexpected forwarder list: %v", cfg.Forwarder)
+	}
+	if cfg.Forwarding.Strategy != StrategyRoundRobin {
+		t.Errorf("expected default strategy round_robin, got %q", cfg.Forwarding.Strategy)
+	}
+
+	list := filepath.Join(tmpDir, "list.yaml")
+	yml := base + `forwarder:
+  - "8.8.8.8"
+  - "tls://1.1.1.1#cloudflare-dns.com"
+forwarding:
+  strategy: failover
+  zones:
+    - zone: corp.example
+      upstreams: ["10.0.0.53"]
+`
+	if err := os.WriteFile(list, []byte(yml), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	cfg, err = Load(list)
+	if err != nil {
+		t.Fatalf("load list: %v", err)
+	}
+	if len(cfg.Forwarder) != 2 || cfg.Forwarding.Strategy != StrategyFailover || len(cfg.Forwarding.Zones) != 1 {
+		t.Errorf("unexpected forwarding config: %v %+v", cfg.Forwarder, cfg.Forwarding)
+	}
+
+	bad := filepath.Join(tmpDir, "bad.yaml")
+	if err := os.WriteFile(bad, []byte(base+"forwarding:\n  strategy: random\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := Load(bad); err == nil || !strings.Contains(err.Error(), "forwarding.strategy") {
+		t.Errorf("expected strategy validation error, got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Forwarding strategies for choosing an upstream from the pool
+const (
+	StrategyRoundRobin = "round_robin"
+	StrategyFastest    = "fastest"
+	StrategyFailover   = "failover"
+)
+
+// ForwarderList is a list of upstream specs. In YAML it accepts either a
+// single string (legacy "forwarder: 8.8.8.8") or a sequence of strings.
+//
+// Spec format: [udp|tcp|tls://]host[:port][#tls-server-name]
+// Examples: "8.8.8.8", "tcp://9.9.9.9:53", "tls://1.1.1.1:853#cloudflare-dns.com"
+type ForwarderList []string
+
+func (l *ForwarderList) UnmarshalYAML(n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		var s string
+		if err := n.Decode(&s); err != nil {
+			return err
+		}
+		s = strings.TrimSpace(s)
+		if s == "" {
+			*l = nil
+		} else {
+			*l = ForwarderList{s}
+		}
+		return nil
+	case yaml.SequenceNode:
+		var items []string
+		if err := n.Decode(&items); err != nil {
+			return err
+		}
+		*l = ForwarderList(items)
+		return nil
+	default:
+		return fmt.Errorf("forwarder must be a string or a list of strings")
+	}
+}
+
+// ConditionalForward routes queries under Zone to its own upstreams
+type ConditionalForward struct {
+	Zone      string        `yaml:"zone"`
+	Upstreams ForwarderList `yaml:"upstreams"`
+	Strategy  string        `yaml:"strategy"` // empty = inherit forwarding.strategy
+}
+
+type ForwardingConfig struct {
+	Strategy       string               `yaml:"strategy"`         // round_robin (default), fastest, failover
+	MaxFails       int                  `yaml:"max_fails"`        // consecutive failures before an upstream is marked down
+	FailTimeoutSec int                  `yaml:"fail_timeout_sec"` // how long a down upstream is skipped
+	Zones          []ConditionalForward `yaml:"zones"`
+}
+
+// Upstream is a parsed forwarder spec
+type Upstream struct {
+	Addr       string // host:port
+	Net        string // "udp", "tcp" or "tcp-tls"
+	ServerName string // TLS server name for DoT
+}
+
+// String returns a compact form for logs
+func (u Upstream) String() string {
+	switch u.Net {
+	case "tcp":
+		return "tcp://" + u.Addr
+	case "tcp-tls":
+		return "tls://" + u.Addr
+	default:
+		return u.Addr
+	}
+}
+
+// ParseUpstream parses a forwarder spec into an Upstream
+func ParseUpstream(spec string) (Upstream, error) {
+	s := strings.TrimSpace(spec)
+	u := Upstream{Net: "udp"}
+	defPort := "53"
+	if i := strings.Index(s, "://"); i >= 0 {
+		switch strings.ToLower(s[:i]) {
+		case "udp", "dns":
+			u.Net = "udp"
+		case "tcp":
+			u.Net = "tcp"
+		case "tls", "dot":
+			u.Net = "tcp-tls"
+			defPort = "853"
+		default:
+			return u, fmt.Errorf("unsupported protocol %q", s[:i])
+		}
+		s = s[i+3:]
+	}
+	if i := strings.Index(s, "#"); i >= 0 {
+		u.ServerName = s[i+1:]
+		s = s[:i]
+		if u.Net != "tcp-tls" {
+			return u, fmt.Errorf("tls server name is only valid for tls:// upstreams")
+		}
+	}
+
+	host, port := s, defPort
+	if h, p, err := net.SplitHostPort(s); err == nil {
+		host, port = h, p
+	} else if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		host = strings.Trim(s, "[]")
+	}
+	if err := validateHost(host); err != nil {
+		return u, err
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return u, fmt.Errorf("invalid port %q", port)
+	}
+	u.Addr = net.JoinHostPort(host, port)
+	if u.Net == "tcp-tls" && u.ServerName == "" && net.ParseIP(host) == nil {
+		u.ServerName = host
+	}
+	return u, nil
+}
+
+// Upstreams parses every spec in the list
+func (l ForwarderList) Upstreams() ([]Upstream, error) {
+	out := make([]Upstream, 0, len(l))
+	for _, spec := range l {
+		u, err := ParseUpstream(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", spec, err)
+		}
+		out = append(out, u)
+	}
+	return out, nil
+}
+
+func validStrategy(s string) bool {
+	switch s {
+	case "", StrategyRoundRobin, StrategyFastest, StrategyFailover:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// ViewConfig describes a split-horizon view. Zones assigned to a view are
+// served only to clients that select the view; zones without a view are
+// served to everyone.
+//
+// A query selects a view when it arrives on one of the view's Listen
+// addresses, or when it matches the view's criteria. When both
+// MatchClients and TSIGKeys are set, both must match. Views are
+// evaluated in config order and the first match wins.
+type ViewConfig struct {
+	Name         string   `yaml:"name"`
+	MatchClients []string `yaml:"match_clients"` // client CIDRs (source address, not ECS)
+	TSIGKeys     []string `yaml:"tsig_keys"`     // names of keys from tsig_keys
+	Listen       []string `yaml:"listen"`        // dedicated DNS listen addresses for this view
+}
+
+// HasView reports whether a view with the given name is configured.
+// The empty name is the default view and always exists.
+func (c *Config) HasView(name string) bool {
+	if name == "" {
+		return true
+	}
+	for _, v := range c.Views {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ViewNames returns configured view names in config order
+func (c *Config) ViewNames() []string {
+	names := make([]string, 0, len(c.Views))
+	for _, v := range c.Views {
+		names = append(names, v.Name)
+	}
+	return names
+}
+
+func (c *Config) validateViews() error {
+	for name, secret := range c.TSIGKeys {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("tsig_keys: key name must not be empty")
+		}
+		if _, err := base64.StdEncoding.DecodeString(secret); err != nil {
+			return fmt.Errorf("tsig_keys[%s]: secret must be base64: %w", name, err)
+		}
+	}
+
+	seen := map[string]bool{}
+	for i, v := range c.Views {
+		if v.Name == "" {
+			return fmt.Errorf("views[%d]: name is required", i)
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("views[%d]: duplicate view name %q", i, v.Name)
+		}
+		seen[v.Name] = true
+		if len(v.MatchClients) == 0 && len(v.TSIGKeys) == 0 && len(v.Listen) == 0 {
+			return fmt.Errorf("views[%d] (%s): at least one of match_clients, tsig_keys or listen is required", i, v.Name)
+		}
+		for _, cidr := range v.MatchClients {
+			if _, err := netip.ParsePrefix(cidr); err != nil {
+				return fmt.Errorf("views[%d] (%s): invalid CIDR %q: %w", i, v.Name, cidr, err)
+			}
+		}
+		for _, k := range v.TSIGKeys {
+			if _, ok := c.TSIGKeys[k]; !ok {
+				return fmt.Errorf("views[%d] (%s): unknown tsig key %q", i, v.Name, k)
+			}
+		}
+		for _, addr := range v.Listen {
+			if err := validateAddr(addr); err != nil {
+				return fmt.Errorf("views[%d] (%s): invalid listen address %q: %w", i, v.Name, addr, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package db
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "slices"
+    "strconv"
+    "strings"
+    "time"
+
+    "gorm.io/gorm"
+)
+
+// Audit actions
+const (
+    AuditZoneCreate     = "zone.create"
+    AuditZoneDelete     = "zone.delete"
+    AuditZoneUpdate     = "zone.update"
+    AuditZoneImport     = "zone.import"
+    AuditZoneSync       = "zone.sync"
+    AuditZoneRollback   = "zone.rollback"
+    AuditZonePublish    = "zone.publish"
+    AuditRRSetCreate    = "rrset.create"
+    AuditRRSetUpdate    = "rrset.update"
+    AuditRRSetDelete    = "rrset.delete"
+    AuditRRSetBatch     = "rrset.batch"
+    AuditRRSetGenerate  = "rrset.generate"
+    AuditRRSetAutoPTR   = "rrset.auto_ptr"
+    AuditTemplateApply  = "template.apply"
+    AuditTemplateCreate = "template.create"
+    AuditTemplateUpdate = "template.update"
+    AuditTemplateDelete = "template.delete"
+    AuditTemplateSync   = "template.sync"
+)
+
+// Audit query limits
+const (
+    DefaultAuditLimit = 100
+    MaxAuditLimit     = 1000
+)
+
+// Actor identifies who made a change and from where
+type Actor struct {
+    Name string // token:<name>, user:<name> or cli
+    IP   string
+}
+
+// Audit records a change. before and after are stored as JSON; nil, a nil
+// pointer or an empty list leaves the snapshot empty.
+func Audit(db *gorm.DB, a Actor, action string, zone Zone, before, after any) error {
+    e := AuditLog{Actor: a.Name, IP: a.IP, Action: action, ZoneID: zone.ID, ZoneName: zone.Name}
+    var err error
+    if e.Before, err = snapshot(before); err != nil {
+        return err
+    }
+    if e.After, err = snapshot(after); err != nil {
+        return err
+    }
+    return db.Create(&e).Error
+}
+
+func snapshot(v any) (string, error) {
+    if v == nil {
+        return "", nil
+    }
+    b, err := json.Marshal(v)
+    if err != nil || string(b) == "null" || string(b) == "[]" {
+        return "", err
+    }
+    return string(b), nil
+}
+
+// RRSetSnapshot loads an RRSet with its records for an audit entry; nil when
+// it does not exist
+func RRSetSnapshot(db *gorm.DB, id uint) *RRSet {
+    var set RRSet
+    if err := db.Preload("Records").First(&set, id).Error; err != nil {
+        return nil
+    }
+    return &set
+}
+
+// ZoneSnapshot loads the RRSets of a zone with their records for an audit entry
+func ZoneSnapshot(db *gorm.DB, zoneID uint) []RRSet {
+    var sets []RRSet
+    db.Preload("Records").Where("zone_id = ?", zoneID).Order("name, type").Find(&sets)
+    return sets
+}
+
+// AuditFilter selects audit entries; zero fields match everything
+type AuditFilter struct {
+    ZoneID uint
+    Zone   string // zone name, with or without the trailing dot
+    Actor  string
+    Action string // an action, or a prefix ending in "." such as "rrset."
+    Since  time.Time
+    Until  time.Time
+    Limit  int
+    Offset int
+}
+
+// ListAudit returns the matching entries, newest first, and their total count
+func ListAudit(db *gorm.DB, f AuditFilter) ([]AuditLog, int64, error) {
+    query := func() *gorm.DB {
+        q := db.Model(&AuditLog{})
+        if f.ZoneID != 0 {
+            q = q.Where("zone_id = ?", f.ZoneID)
+        }
+        if f.Zone != "" {
+            name := strings.TrimSuffix(strings.ToLower(f.Zone), ".")
+            q = q.Where("zone_name IN ?", []string{name, name + "."})
+        }
+        if f.Actor != "" {
+            q = q.Where("actor = ?", f.Actor)
+        }
+        if strings.HasSuffix(f.Action, ".") {
+            q = q.Where("action LIKE ?", f.Action+"%")
+        } else if f.Action != "" {
+            q = q.Where("action = ?", f.Action)
+        }
+        if !f.Since.IsZero() {
+            q = q.Where("created_at >= ?", f.Since)
+        }
+        if !f.Until.IsZero() {
+            q = q.Where("created_at < ?", f.Until)
+        }
+        return q
+    }
+
+    var total int64
+    if err := query().Count(&total).Error; err != nil {
+        return nil, 0, err
+    }
+    limit := f.Limit
+    if limit <= 0 {
+        limit = DefaultAuditLimit
+    }
+    if limit > MaxAuditLimit {
+        limit = MaxAuditLimit
+    }
+    var entries []AuditLog
+    err := query().Order("id DESC").Limit(limit).Offset(f.Offset).Find(&entries).Error
+    return entries, total, err
+}
+
+// SameRRSets reports whether two RRSet lists hold the same records, ignoring
+// IDs, timestamps and order
+func SameRRSets(a, b []RRSet) bool {
+    return slices.Equal(rrsetKeys(a), rrsetKeys(b))
+}
+
+// RRSetETag is a strong HTTP entity tag of the content of an RRSet; it
+// changes whenever the TTL or any record changes
+func RRSetETag(rs RRSet) string {
+    sum := sha256.Sum256([]byte(strings.Join(rrsetKeys([]RRSet{rs}), "\n")))
+    return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// rrsetKeys describes the records of RRSets as sorted strings
+func rrsetKeys(sets []RRSet) []string {
+    var out []string
+    for _, rs := range sets {
+        for _, r := range rs.Records {
+            key := fmt.Sprintf("%s|%s|%d|%s|%s|%s|%s|%s", rs.Name, rs.Type, rs.TTL, r.Data,
+                deref(r.Country), deref(r.Continent), deref(r.Subnet), asnString(r.ASN))
+            if r.AutoPTR {
+                key += "|auto_ptr"
+            }
+            out = append(out, key)
+        }
+        if len(rs.Records) == 0 {
+            out = append(out, fmt.Sprintf("%s|%s|%d", rs.Name, rs.Type, rs.TTL))
+        }
+    }
+    slices.Sort(out)
+    return out
+}
+
+func deref(p *string) string {
+    if p == nil {
+        return ""
+    }
+    return *p
+}
+
+func asnString(p *int) string {
+    if p == nil {
+        return ""
+    }
+    return strconv.Itoa(*p)
+}
+
+// SameTemplate reports whether two templates have the same description and
+// records, ignoring IDs, timestamps and order
+func SameTemplate(a, b Template) bool {
+    keys := func(t Template) []string {
+        out := []string{t.Description}
+        for _, r := range t.Records {
+            out = append(out, fmt.Sprintf("%s|%s|%d|%s|%s|%s|%s|%s", r.Name, r.Type, r.TTL, r.Data,
+                deref(r.Country), deref(r.Continent), deref(r.Subnet), asnString(r.ASN)))
+        }
+        slices.Sort(out[1:])
+        return out
+    }
+    return slices.Equal(keys(a), keys(b))
+}
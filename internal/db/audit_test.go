@@ -0,0 +1,29 @@
+package db
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestAudit_ImportZonesAndSameRRSets(t *testing.T) {
+    db := newMemDB(t)
+    file := filepath.Join(t.TempDir(), "backup.json")
+    backup := `{"version":"1.0","zones":[{"name":"audit-import.test.","rrsets":[{"name":"www.audit-import.test.","type":"A","ttl":60,"records":[{"data":"192.0.2.1"}]}]}]}`
+    if err := os.WriteFile(file, []byte(backup), 0o600); err != nil { t.Fatal(err) }
+    if _, err := ImportZones(db, file, "merge", SerialManager{}, nil, false); err != nil { t.Fatalf("import: %v", err) }
+
+    entries, total, err := ListAudit(db, AuditFilter{Zone: "audit-import.test", Action: AuditZoneImport})
+    if err != nil || total != 1 { t.Fatalf("want one import entry, got %d (%v)", total, err) }
+    if e := entries[0]; e.Actor != "cli" || e.Before != "" || e.After == "" {
+        t.Fatalf("unexpected entry %+v", e)
+    }
+
+    var z Zone
+    db.Where("name = ?", "audit-import.test.").First(&z)
+    sets := ZoneSnapshot(db, z.ID)
+    copied := []RRSet{{Name: "www.audit-import.test.", Type: "A", TTL: 60, Records: []RData{{Data: "192.0.2.1"}}}}
+    if !SameRRSets(sets, copied) { t.Fatalf("IDs and timestamps must not matter") }
+    copied[0].Records[0].Data = "192.0.2.2"
+    if SameRRSets(sets, copied) { t.Fatalf("changed data must differ") }
+}
@@ -2,8 +2,10 @@ package db
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"sort"
 
 	"gorm.io/gorm"
 )
@@ -39,20 +41,42 @@ func ExportZones(db *gorm.DB, filename string) error {
 }
 
 // ImportZones imports zones from a JSON file
-// mode: "replace" - delete all existing zones, "merge" - keep existing zones
-func ImportZones(db *gorm.DB, filename string, mode string) error {
+// mode: "replace" - delete all existing zones, "merge" - keep existing zones.
+// Zones that existed before get their SOA serial bumped through serials, so
+// secondaries pick up the imported data; new zones keep the imported serial.
+// It returns what changed in each zone; with dryRun nothing is changed.
+// Zones of a view hasView rejects fail the import; a nil hasView accepts
+// only the default view.
+func ImportZones(db *gorm.DB, filename string, mode string, serials SerialManager, hasView func(view string) bool, dryRun bool) ([]ZoneDiff, error) {
 	data, err := os.ReadFile(filename)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
 	var backup BackupData
 	if err := json.Unmarshal(data, &backup); err != nil {
-		return fmt.Errorf("failed to parse JSON: %w", err)
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	for _, zone := range backup.Zones {
+		if hasView == nil && zone.View != "" || hasView != nil && !hasView(zone.View) {
+			return nil, fmt.Errorf("zone %s: unknown view %q", zone.Name, zone.View)
+		}
 	}
 
-	return db.Transaction(func(tx *gorm.DB) error {
+	cli := Actor{Name: "cli"}
+	var diffs []ZoneDiff
+	err = db.Transaction(func(tx *gorm.DB) error {
+		bumps := serials.Batch(tx)
+		// Zones before a replace import, by name and view, for the audit log
+		previous := map[string]Zone{}
 		if mode == "replace" {
+			var old []Zone
+			if err := tx.Preload("RRSets.Records").Find(&old).Error; err != nil {
+				return fmt.Errorf("failed to load zones: %w", err)
+			}
+			for _, z := range old {
+				previous[z.Name+" "+z.View] = z
+			}
 			// Delete all existing zones and their data
 			if err := tx.Exec("DELETE FROM r_data").Error; err != nil {
 				return fmt.Errorf("failed to delete records: %w", err)
@@ -67,32 +91,47 @@ func ImportZones(db *gorm.DB, filename string, mode string) error {
 
 		// Import zones
 		for _, zone := range backup.Zones {
+			var before []RRSet
+			if old, ok := previous[zone.Name+" "+zone.View]; ok {
+				before = old.RRSets
+				delete(previous, zone.Name+" "+zone.View)
+			}
+			existed := before != nil
 			var existingZone Zone
-			err := tx.Where("name = ?", zone.Name).First(&existingZone).Error
+			err := tx.Where("name = ? AND view = ?", zone.Name, zone.View).First(&existingZone).Error
 
 			if err == gorm.ErrRecordNotFound {
 				// Create new zone
-				newZone := Zone{Name: zone.Name}
+				newZone := Zone{Name: zone.Name, View: zone.View}
+				newZone.SetMeta(zone)
 				if err := tx.Create(&newZone).Error; err != nil {
 					return fmt.Errorf("failed to create zone %s: %w", zone.Name, err)
 				}
 				existingZone = newZone
 			} else if err != nil {
 				return fmt.Errorf("failed to check zone %s: %w", zone.Name, err)
+			} else {
+				existed = true
+				existingZone.SetMeta(zone)
+				if err := UpdateZoneMeta(tx, &existingZone); err != nil {
+					return fmt.Errorf("failed to update zone %s: %w", zone.Name, err)
+				}
 			}
 
-			// Delete existing RRSets for this zone if merge mode
+			// Delete existing RRSets for this zone if merge mode; hard delete,
+			// soft-deleted rows would collide with the unique index
 			if mode == "merge" {
+				before = ZoneSnapshot(tx, existingZone.ID)
 				var rrsetIDs []uint
-				if err := tx.Model(&RRSet{}).Where("zone_id = ?", existingZone.ID).Pluck("id", &rrsetIDs).Error; err != nil {
+				if err := tx.Unscoped().Model(&RRSet{}).Where("zone_id = ?", existingZone.ID).Pluck("id", &rrsetIDs).Error; err != nil {
 					return fmt.Errorf("failed to get rrset ids: %w", err)
 				}
 				if len(rrsetIDs) > 0 {
-					if err := tx.Where("rr_set_id IN ?", rrsetIDs).Delete(&RData{}).Error; err != nil {
+					if err := tx.Unscoped().Where("rr_set_id IN ?", rrsetIDs).Delete(&RData{}).Error; err != nil {
 						return fmt.Errorf("failed to delete records: %w", err)
 					}
 				}
-				if err := tx.Where("zone_id = ?", existingZone.ID).Delete(&RRSet{}).Error; err != nil {
+				if err := tx.Unscoped().Where("zone_id = ?", existingZone.ID).Delete(&RRSet{}).Error; err != nil {
 					return fmt.Errorf("failed to delete rrsets: %w", err)
 				}
 			}
@@ -117,8 +156,50 @@ func ImportZones(db *gorm.DB, filename string, mode string) error {
 					return fmt.Errorf("failed to create rrset %s/%s: %w", rrset.Name, rrset.Type, err)
 				}
 			}
+			after := ZoneSnapshot(tx, existingZone.ID)
+			diff := NewZoneDiff(existingZone, before, after)
+			diff.Created = !existed
+			diffs = append(diffs, diff)
+			if dryRun {
+				continue
+			}
+			if err := Audit(tx, cli, AuditZoneImport, existingZone, before, after); err != nil {
+				return fmt.Errorf("failed to write audit log: %w", err)
+			}
+			if existed {
+				bumps.Touch(existingZone)
+			} else if err := RecordVersion(tx, existingZone.ID, ""); err != nil {
+				return fmt.Errorf("failed to record version of zone %s: %w", zone.Name, err)
+			}
+		}
+		// Zones dropped by a replace import
+		dropped := make([]Zone, 0, len(previous))
+		for _, z := range previous {
+			dropped = append(dropped, z)
+		}
+		sort.Slice(dropped, func(i, j int) bool { return dropped[i].Name+" "+dropped[i].View < dropped[j].Name+" "+dropped[j].View })
+		for _, z := range dropped {
+			diff := NewZoneDiff(z, z.RRSets, nil)
+			diff.Deleted = true
+			diffs = append(diffs, diff)
+		}
+		if dryRun {
+			return ErrDryRun
+		}
+
+		if err := bumps.Flush(); err != nil {
+			return fmt.Errorf("failed to bump SOA serials: %w", err)
+		}
+		for _, z := range dropped {
+			if err := Audit(tx, cli, AuditZoneDelete, z, z.RRSets, nil); err != nil {
+				return fmt.Errorf("failed to write audit log: %w", err)
+			}
 		}
 
 		return nil
 	})
+	if errors.Is(err, ErrDryRun) {
+		err = nil
+	}
+	return diffs, err
 }
@@ -0,0 +1,134 @@
+package db
+
+import (
+    "fmt"
+    "slices"
+
+    "gorm.io/gorm"
+)
+
+// Batch change types, as in the PowerDNS API
+const (
+    OpReplace      = "REPLACE"       // set the RRSet to the given records; none deletes it
+    OpDelete       = "DELETE"        // delete the RRSet
+    OpAddRecord    = "ADD-RECORD"    // add records to the RRSet, creating it if missing
+    OpRemoveRecord = "REMOVE-RECORD" // remove records; the RRSet goes when empty
+)
+
+// RRSetOp is one operation of a batch, keyed by name and type
+type RRSetOp struct {
+    ChangeType string
+    Name       string // FQDN
+    Type       string
+    TTL        uint32 // 0 keeps the TTL of an existing RRSet
+    Records    []RData
+}
+
+// OpError reports the operation that made a batch fail
+type OpError struct {
+    Index int
+    Op    RRSetOp
+    Err   string
+}
+
+func (e *OpError) Error() string {
+    return fmt.Sprintf("operation %d (%s %s %s): %s", e.Index, e.Op.ChangeType, e.Op.Name, e.Op.Type, e.Err)
+}
+
+// ApplyRRSetOps applies a batch of operations to a zone in one transaction.
+// The operations run in order on the current state, so a later one sees the
+// result of an earlier one; if any fails nothing is changed. New RRSets
+// without TTL get defaultTTL. check, if not nil, vets the resulting RRSets
+// of the zone before they are written. The caller bumps the serial once,
+// usually in the transaction db it passes.
+func ApplyRRSetOps(db *gorm.DB, zoneID uint, ops []RRSetOp, defaultTTL uint32, check func([]RRSet) error) ([]RRSetChange, error) {
+    var changes []RRSetChange
+    err := db.Transaction(func(tx *gorm.DB) error {
+        before := ZoneSnapshot(tx, zoneID)
+        sets := map[string]RRSet{}
+        for _, rs := range before {
+            sets[rrsetKey(rs.Name, rs.Type)] = copyRRSet(zoneID, rs)
+        }
+        for i, op := range ops {
+            if err := applyOp(sets, op, defaultTTL); err != "" {
+                return &OpError{Index: i, Op: op, Err: err}
+            }
+        }
+        after := make([]RRSet, 0, len(sets))
+        for _, rs := range sets {
+            after = append(after, rs)
+        }
+        if check != nil {
+            if err := check(after); err != nil {
+                return err
+            }
+        }
+        changes = DiffRRSets(before, after)
+        for _, ch := range changes {
+            if err := replaceRRSet(tx, zoneID, ch); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+    return changes, err
+}
+
+// applyOp applies one operation to the RRSets keyed by rrsetKey and returns
+// why it cannot be applied, or ""
+func applyOp(sets map[string]RRSet, op RRSetOp, defaultTTL uint32) string {
+    if op.Name == "" || op.Type == "" {
+        return "name and type are required"
+    }
+    key := rrsetKey(op.Name, op.Type)
+    set, exists := sets[key]
+    if !exists {
+        set = RRSet{Name: op.Name, Type: op.Type, TTL: defaultTTL}
+    }
+    if op.TTL > 0 {
+        set.TTL = op.TTL
+    }
+
+    switch op.ChangeType {
+    case OpReplace:
+        set.Records = op.Records
+    case OpDelete:
+        set.Records = nil
+    case OpAddRecord:
+        if len(op.Records) == 0 {
+            return "no records to add"
+        }
+        for _, r := range op.Records {
+            if !slices.ContainsFunc(set.Records, func(x RData) bool { return sameRecord(x, r) }) {
+                set.Records = append(set.Records, r)
+            }
+        }
+    case OpRemoveRecord:
+        if len(op.Records) == 0 {
+            return "no records to remove"
+        }
+        for _, r := range op.Records {
+            i := slices.IndexFunc(set.Records, func(x RData) bool { return sameRecord(x, r) })
+            if i < 0 {
+                return fmt.Sprintf("record %q not found", r.Data)
+            }
+            set.Records = slices.Delete(set.Records, i, i+1)
+        }
+    default:
+        return fmt.Sprintf("unknown changetype %q", op.ChangeType)
+    }
+
+    if len(set.Records) == 0 {
+        delete(sets, key)
+    } else {
+        sets[key] = set
+    }
+    return ""
+}
+
+// sameRecord compares record data and geo selectors
+func sameRecord(a, b RData) bool {
+    return a.Data == b.Data && deref(a.Country) == deref(b.Country) &&
+        deref(a.Continent) == deref(b.Continent) && deref(a.Subnet) == deref(b.Subnet) &&
+        asnString(a.ASN) == asnString(b.ASN)
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+    "errors"
+    "testing"
+)
+
+func TestApplyRRSetOps(t *testing.T) {
+    db := newMemDB(t)
+    z := Zone{Name: "batch.test."}
+    if err := db.Create(&z).Error; err != nil { t.Fatalf("create zone: %v", err) }
+    db.Create(&RRSet{ZoneID: z.ID, Name: "www.batch.test.", Type: "A", TTL: 60, Records: []RData{{Data: "192.0.2.1"}, {Data: "192.0.2.2"}}})
+    db.Create(&RRSet{ZoneID: z.ID, Name: "old.batch.test.", Type: "A", TTL: 60, Records: []RData{{Data: "192.0.2.9"}}})
+
+    ops := []RRSetOp{
+        {ChangeType: OpRemoveRecord, Name: "www.batch.test.", Type: "A", Records: []RData{{Data: "192.0.2.1"}}},
+        {ChangeType: OpAddRecord, Name: "www.batch.test.", Type: "A", Records: []RData{{Data: "192.0.2.3"}, {Data: "192.0.2.2"}}},
+        {ChangeType: OpDelete, Name: "old.batch.test.", Type: "A"},
+        {ChangeType: OpAddRecord, Name: "new.batch.test.", Type: "TXT", Records: []RData{{Data: "\"hi\""}}},
+    }
+    changes, err := ApplyRRSetOps(db, z.ID, ops, 300, nil)
+    if err != nil { t.Fatalf("apply: %v", err) }
+    if len(changes) != 3 { t.Fatalf("want 3 changed RRSets, got %+v", changes) }
+    live := map[string]RRSet{}
+    for _, rs := range ZoneSnapshot(db, z.ID) { live[rrsetKey(rs.Name, rs.Type)] = rs }
+    if www := live["www.batch.test. A"]; len(www.Records) != 2 || www.TTL != 60 { t.Fatalf("unexpected www %+v", www) }
+    if _, ok := live["old.batch.test. A"]; ok { t.Fatalf("old must be deleted") }
+    if n := live["new.batch.test. TXT"]; n.TTL != 300 || len(n.Records) != 1 { t.Fatalf("new RRSet must get the default TTL, got %+v", n) }
+
+    // A failing operation rolls back the whole batch
+    ops = []RRSetOp{
+        {ChangeType: OpReplace, Name: "www.batch.test.", Type: "A", Records: []RData{{Data: "198.51.100.1"}}},
+        {ChangeType: OpRemoveRecord, Name: "new.batch.test.", Type: "TXT", Records: []RData{{Data: "\"missing\""}}},
+    }
+    var opErr *OpError
+    if _, err := ApplyRRSetOps(db, z.ID, ops, 300, nil); !errors.As(err, &opErr) || opErr.Index != 1 { t.Fatalf("want error on operation 1, got %v", err) }
+    for _, rs := range ZoneSnapshot(db, z.ID) {
+        if rs.Name == "www.batch.test." && len(rs.Records) != 2 { t.Fatalf("failed batch must not change the zone") }
+    }
+    if _, err := ApplyRRSetOps(db, z.ID, []RRSetOp{{ChangeType: "UPSERT", Name: "x.batch.test.", Type: "A"}}, 0, nil); !errors.As(err, &opErr) {
+        t.Fatalf("want unknown changetype refused, got %v", err)
+    }
+}
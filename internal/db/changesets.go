@@ -0,0 +1,325 @@
+package db
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "slices"
+    "strings"
+    "time"
+
+    "gorm.io/gorm"
+
+    "namedot/internal/config"
+)
+
+// Changeset states
+const (
+    ChangesetOpen      = "open"
+    ChangesetPublished = "published"
+    ChangesetDiscarded = "discarded"
+)
+
+var (
+    ErrNoChangeset        = errors.New("no open changeset")
+    ErrChangesetExists    = errors.New("zone already has an open changeset")
+    ErrChangesetUnchanged = errors.New("changeset has no changes")
+    ErrApprovalRequired   = errors.New("changeset needs approval by a second user before publishing")
+    ErrSelfApproval       = errors.New("changeset cannot be approved by its own author")
+    ErrSingleApprover     = errors.New("changeset_approval needs a second identity that may approve: create an API token with the approve scope or a web admin user")
+)
+
+// ConflictError reports RRSets changed on the live zone after the changeset
+// was started, which publishing would overwrite
+type ConflictError struct {
+    Keys []string // "name TYPE"
+}
+
+func (e *ConflictError) Error() string {
+    return "changed on the live zone since the changeset was started: " + strings.Join(e.Keys, ", ")
+}
+
+// OpenChangeset returns the open changeset of a zone; ErrNoChangeset if none
+func OpenChangeset(db *gorm.DB, zoneID uint) (Changeset, error) {
+    var cs Changeset
+    err := db.Where("zone_id = ? AND status = ?", zoneID, ChangesetOpen).Limit(1).Find(&cs).Error
+    if err == nil && cs.ID == 0 {
+        err = ErrNoChangeset
+    }
+    return cs, err
+}
+
+// StartChangeset opens a changeset on a copy of the live RRSets of a zone
+func StartChangeset(db *gorm.DB, zoneID uint, actor string) (Changeset, error) {
+    if _, err := OpenChangeset(db, zoneID); err == nil {
+        return Changeset{}, ErrChangesetExists
+    } else if !errors.Is(err, ErrNoChangeset) {
+        return Changeset{}, err
+    }
+    live, err := encodeRRSets(ZoneSnapshot(db, zoneID))
+    if err != nil {
+        return Changeset{}, err
+    }
+    cs := Changeset{ZoneID: zoneID, Status: ChangesetOpen, CreatedBy: actor, Base: live, Data: live}
+    if err := cs.addEditor(actor); err != nil {
+        return Changeset{}, err
+    }
+    return cs, db.Create(&cs).Error
+}
+
+// BaseRRSets decodes the RRSets the changeset was started from
+func (cs Changeset) BaseRRSets() ([]RRSet, error) { return decodeRRSets(cs.Base) }
+
+// RRSets decodes the edited RRSets of the changeset
+func (cs Changeset) RRSets() ([]RRSet, error) { return decodeRRSets(cs.Data) }
+
+// Changes lists what publishing the changeset would change
+func (cs Changeset) Changes() ([]RRSetChange, error) {
+    base, err := cs.BaseRRSets()
+    if err != nil {
+        return nil, err
+    }
+    draft, err := cs.RRSets()
+    if err != nil {
+        return nil, err
+    }
+    return DiffRRSets(base, draft), nil
+}
+
+// Conflicts lists the changed RRSets whose live state no longer matches the
+// state the changeset was started from
+func (cs Changeset) Conflicts(live []RRSet) ([]string, error) {
+    changes, err := cs.Changes()
+    if err != nil {
+        return nil, err
+    }
+    current := map[string]RRSet{}
+    for _, rs := range live {
+        current[rrsetKey(rs.Name, rs.Type)] = rs
+    }
+    var keys []string
+    for _, ch := range changes {
+        now, exists := current[rrsetKey(ch.Name, ch.Type)]
+        switch {
+        case ch.Before == nil && exists:
+        case ch.Before != nil && !exists:
+        case ch.Before != nil && !SameRRSets([]RRSet{*ch.Before}, []RRSet{now}):
+        default:
+            continue
+        }
+        keys = append(keys, ch.Name+" "+ch.Type)
+    }
+    return keys, nil
+}
+
+// PutDraftRRSet adds or replaces the RRSet set.Name/set.Type in the draft;
+// an RRSet without records removes it. Any approval is withdrawn.
+func PutDraftRRSet(db *gorm.DB, cs *Changeset, set RRSet, actor string) error {
+    sets, err := cs.RRSets()
+    if err != nil {
+        return err
+    }
+    key := rrsetKey(set.Name, set.Type)
+    out := sets[:0]
+    for _, rs := range sets {
+        if rrsetKey(rs.Name, rs.Type) != key {
+            out = append(out, rs)
+        }
+    }
+    if len(set.Records) > 0 {
+        out = append(out, copyRRSet(cs.ZoneID, set))
+    }
+    if cs.Data, err = encodeRRSets(out); err != nil {
+        return err
+    }
+    cs.UpdatedBy, cs.ApprovedBy = actor, ""
+    if err := cs.addEditor(actor); err != nil {
+        return err
+    }
+    return db.Save(cs).Error
+}
+
+// EditorList returns everyone who started or edited the changeset
+func (cs Changeset) EditorList() []string {
+    var editors []string
+    if cs.Editors != "" {
+        _ = json.Unmarshal([]byte(cs.Editors), &editors)
+    }
+    // Changesets started before editors were tracked
+    for _, a := range []string{cs.CreatedBy, cs.UpdatedBy} {
+        if a != "" && !slices.Contains(editors, a) {
+            editors = append(editors, a)
+        }
+    }
+    return editors
+}
+
+// addEditor records actor in Editors
+func (cs *Changeset) addEditor(actor string) error {
+    editors := cs.EditorList()
+    if !slices.Contains(editors, actor) {
+        editors = append(editors, actor)
+    }
+    b, err := json.Marshal(editors)
+    if err != nil {
+        return err
+    }
+    cs.Editors = string(b)
+    return nil
+}
+
+// ApproveChangeset records the approval of a user other than its authors:
+// whoever started or edited the draft at any point cannot approve it
+func ApproveChangeset(db *gorm.DB, cs *Changeset, actor string) error {
+    if slices.Contains(cs.EditorList(), actor) {
+        return ErrSelfApproval
+    }
+    cs.ApprovedBy = actor
+    return db.Save(cs).Error
+}
+
+// CheckChangesetApproval fails with ErrSingleApprover when changeset_approval
+// is on but only one identity can act (the config token or the open API, the
+// web admin of the config, API tokens and web users each count), or none of
+// them may approve (API tokens need the approve or admin scope, web users
+// the editor role): no changeset could then be published.
+// SSO users are created on login, so with OIDC the check passes.
+func CheckChangesetApproval(db *gorm.DB, cfg *config.Config) error {
+    if !cfg.ChangesetApproval || cfg.Admin.Enabled && cfg.Admin.OIDC.Enabled {
+        return nil
+    }
+    var tokens []APIToken
+    if err := db.Where("revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", time.Now()).Find(&tokens).Error; err != nil {
+        return err
+    }
+    issued, err := APITokensIssued(db)
+    if err != nil {
+        return err
+    }
+    identities, approvers := len(tokens), 0
+    for _, t := range tokens {
+        if t.HasScope(ScopeApprove) {
+            approvers++
+        }
+    }
+    if cfg.APIToken != "" || cfg.APITokenHash != "" || !issued {
+        identities++
+        approvers++
+    }
+    if cfg.Admin.Enabled {
+        var users []User
+        if err := db.Where("disabled = ?", false).Find(&users).Error; err != nil {
+            return err
+        }
+        identities += len(users)
+        for _, u := range users {
+            if u.HasRole(RoleEditor) {
+                approvers++
+            }
+        }
+        if cfg.Admin.Username != "" {
+            identities++
+            approvers++
+        }
+    }
+    if identities < 2 || approvers == 0 {
+        return ErrSingleApprover
+    }
+    return nil
+}
+
+// DiscardChangeset closes a changeset without applying it
+func DiscardChangeset(db *gorm.DB, cs *Changeset) error {
+    cs.Status = ChangesetDiscarded
+    return db.Save(cs).Error
+}
+
+// PublishChangeset applies the changes of a changeset to the live zone in one
+// transaction and bumps the SOA serial once. RRSets changed on the live zone
+// since the changeset was started make it fail with a *ConflictError.
+func PublishChangeset(db *gorm.DB, cs *Changeset, zone Zone, a Actor, requireApproval bool, serials SerialManager) error {
+    if requireApproval && cs.ApprovedBy == "" {
+        return ErrApprovalRequired
+    }
+    changes, err := cs.Changes()
+    if err != nil {
+        return err
+    }
+    if len(changes) == 0 {
+        return ErrChangesetUnchanged
+    }
+    return db.Transaction(func(tx *gorm.DB) error {
+        before := ZoneSnapshot(tx, zone.ID)
+        conflicts, err := cs.Conflicts(before)
+        if err != nil {
+            return err
+        }
+        if len(conflicts) > 0 {
+            return &ConflictError{Keys: conflicts}
+        }
+        for _, ch := range changes {
+            if err := replaceRRSet(tx, zone.ID, ch); err != nil {
+                return err
+            }
+        }
+        if err := serials.Bump(tx, zone); err != nil {
+            return err
+        }
+
+        now := time.Now()
+        cs.Status, cs.PublishedBy, cs.PublishedAt = ChangesetPublished, a.Name, &now
+        if err := tx.Save(cs).Error; err != nil {
+            return err
+        }
+        return Audit(tx, a, AuditZonePublish, zone, before, ZoneSnapshot(tx, zone.ID))
+    })
+}
+
+// replaceRRSet applies one change to the live zone
+func replaceRRSet(tx *gorm.DB, zoneID uint, ch RRSetChange) error {
+    // Hard delete; soft-deleted rows would collide with the unique index
+    var ids []uint
+    q := tx.Unscoped().Model(&RRSet{}).Where("zone_id = ? AND LOWER(name) = ? AND type = ?", zoneID, strings.ToLower(ch.Name), ch.Type)
+    if err := q.Pluck("id", &ids).Error; err != nil {
+        return err
+    }
+    if len(ids) > 0 {
+        if err := tx.Unscoped().Where("rr_set_id IN ?", ids).Delete(&RData{}).Error; err != nil {
+            return err
+        }
+        if err := tx.Unscoped().Where("id IN ?", ids).Delete(&RRSet{}).Error; err != nil {
+            return err
+        }
+    }
+    if ch.After == nil {
+        return nil
+    }
+    set := copyRRSet(zoneID, *ch.After)
+    if err := tx.Create(&set).Error; err != nil {
+        return fmt.Errorf("publish %s %s: %w", ch.Name, ch.Type, err)
+    }
+    return nil
+}
+
+func rrsetKey(name, rtype string) string {
+    return strings.ToLower(name) + " " + strings.ToUpper(rtype)
+}
+
+func encodeRRSets(sets []RRSet) (string, error) {
+    if sets == nil {
+        sets = []RRSet{}
+    }
+    b, err := json.Marshal(sets)
+    return string(b), err
+}
+
+func decodeRRSets(data string) ([]RRSet, error) {
+    var sets []RRSet
+    if data == "" {
+        return sets, nil
+    }
+    if err := json.Unmarshal([]byte(data), &sets); err != nil {
+        return nil, fmt.Errorf("decode changeset: %w", err)
+    }
+    return sets, nil
+}
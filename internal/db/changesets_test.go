@@ -0,0 +1,82 @@
+package db
+
+import (
+    "errors"
+    "testing"
+
+    "gorm.io/driver/sqlite"
+    "gorm.io/gorm"
+
+    "namedot/internal/config"
+)
+
+func TestChangesets_PublishApprovalAndConflicts(t *testing.T) {
+    db := newMemDB(t)
+    z := Zone{Name: "staged.test."}
+    if err := db.Create(&z).Error; err != nil { t.Fatalf("create zone: %v", err) }
+    db.Create(&RRSet{ZoneID: z.ID, Name: "staged.test.", Type: "SOA", TTL: 3600,
+        Records: []RData{{Data: "ns1.staged.test. hostmaster.staged.test. 7 7200 3600 1209600 300"}}})
+    www := RRSet{ZoneID: z.ID, Name: "www.staged.test.", Type: "A", TTL: 60, Records: []RData{{Data: "192.0.2.1"}}}
+    db.Create(&www)
+    db.Create(&RRSet{ZoneID: z.ID, Name: "old.staged.test.", Type: "A", TTL: 60, Records: []RData{{Data: "192.0.2.2"}}})
+
+    cs, err := StartChangeset(db, z.ID, "user:alice")
+    if err != nil { t.Fatalf("start: %v", err) }
+    if _, err := StartChangeset(db, z.ID, "user:bob"); !errors.Is(err, ErrChangesetExists) { t.Fatalf("want one open changeset per zone, got %v", err) }
+
+    // The edits stay staged; the live zone is untouched
+    PutDraftRRSet(db, &cs, RRSet{Name: "www.staged.test.", Type: "A", TTL: 300, Records: []RData{{Data: "198.51.100.1"}}}, "user:alice")
+    PutDraftRRSet(db, &cs, RRSet{Name: "old.staged.test.", Type: "A"}, "user:alice")
+    PutDraftRRSet(db, &cs, RRSet{Name: "new.staged.test.", Type: "TXT", TTL: 60, Records: []RData{{Data: "\"hi\""}}}, "user:alice")
+    if changes, _ := cs.Changes(); len(changes) != 3 { t.Fatalf("want 3 staged changes, got %+v", changes) }
+    if set := RRSetSnapshot(db, www.ID); set.Records[0].Data != "192.0.2.1" { t.Fatalf("draft edits must not go live") }
+
+    a := Actor{Name: "user:alice"}
+    if err := PublishChangeset(db, &cs, z, a, true, SerialManager{}); !errors.Is(err, ErrApprovalRequired) { t.Fatalf("want approval required, got %v", err) }
+    if err := ApproveChangeset(db, &cs, "user:alice"); !errors.Is(err, ErrSelfApproval) { t.Fatalf("want self approval refused, got %v", err) }
+    if err := ApproveChangeset(db, &cs, "user:bob"); err != nil { t.Fatalf("approve: %v", err) }
+
+    // A live edit of a staged RRSet blocks the publish
+    db.Model(&RData{}).Where("rr_set_id = ?", www.ID).Update("data", "203.0.113.5")
+    var conflict *ConflictError
+    if err := PublishChangeset(db, &cs, z, a, true, SerialManager{}); !errors.As(err, &conflict) || len(conflict.Keys) != 1 || conflict.Keys[0] != "www.staged.test. A" {
+        t.Fatalf("want conflict on www, got %v", err)
+    }
+    db.Model(&RData{}).Where("rr_set_id = ?", www.ID).Update("data", "192.0.2.1")
+
+    if err := PublishChangeset(db, &cs, z, a, true, SerialManager{}); err != nil { t.Fatalf("publish: %v", err) }
+    if cs.Status != ChangesetPublished || cs.PublishedBy != "user:alice" { t.Fatalf("unexpected changeset %+v", cs) }
+    live := ZoneSnapshot(db, z.ID)
+    if len(live) != 3 || serialOf(live) != 8 { t.Fatalf("want 3 RRSets and one serial bump, got %d RRSets serial %d", len(live), serialOf(live)) }
+    if _, n, _ := ListVersions(db, z.ID, 0, 0); n != 1 { t.Fatalf("publish must record one version, got %d", n) }
+    if _, n, _ := ListAudit(db, AuditFilter{ZoneID: z.ID, Action: AuditZonePublish}); n != 1 { t.Fatalf("publish must be audited") }
+    if _, err := OpenChangeset(db, z.ID); !errors.Is(err, ErrNoChangeset) { t.Fatalf("published changeset must be closed") }
+}
+
+func TestCheckChangesetApproval_NeedsTwoIdentities(t *testing.T) {
+    // A private database: the shared one holds tokens and users of other tests
+    db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+    if err != nil { t.Fatalf("open db: %v", err) }
+    sqlDB, _ := db.DB()
+    sqlDB.SetMaxOpenConns(1)
+    if err := AutoMigrate(db); err != nil { t.Fatalf("migrate: %v", err) }
+
+    cfg := &config.Config{ChangesetApproval: true, APIToken: "secret"}
+    if err := CheckChangesetApproval(db, cfg); !errors.Is(err, ErrSingleApprover) { t.Fatalf("config token alone: want ErrSingleApprover, got %v", err) }
+    if err := CheckChangesetApproval(db, &config.Config{ChangesetApproval: true}); !errors.Is(err, ErrSingleApprover) { t.Fatalf("open mode: want ErrSingleApprover, got %v", err) }
+
+    // The web admin of the config is a second identity
+    cfg.Admin = config.AdminConfig{Enabled: true, Username: "admin"}
+    if err := CheckChangesetApproval(db, cfg); err != nil { t.Fatalf("config token and web admin: %v", err) }
+
+    // Without the config token, one API token is not enough; a second one is
+    cfg.Admin, cfg.APIToken = config.AdminConfig{}, ""
+    if _, _, err := CreateAPIToken(db, "ci", []string{ScopeWrite}, nil, nil); err != nil { t.Fatalf("create token: %v", err) }
+    if err := CheckChangesetApproval(db, cfg); !errors.Is(err, ErrSingleApprover) { t.Fatalf("one token: want ErrSingleApprover, got %v", err) }
+    if _, _, err := CreateAPIToken(db, "writer", []string{ScopeWrite}, nil, nil); err != nil { t.Fatalf("create token: %v", err) }
+    if err := CheckChangesetApproval(db, cfg); !errors.Is(err, ErrSingleApprover) { t.Fatalf("no token may approve: want ErrSingleApprover, got %v", err) }
+    if _, _, err := CreateAPIToken(db, "reviewer", []string{ScopeApprove}, nil, nil); err != nil { t.Fatalf("create token: %v", err) }
+    if err := CheckChangesetApproval(db, cfg); err != nil { t.Fatalf("writer and approver tokens: %v", err) }
+
+    if err := CheckChangesetApproval(db, &config.Config{}); err != nil { t.Fatalf("approval off: %v", err) }
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+    "errors"
+    "strings"
+
+    "gorm.io/gorm"
+)
+
+// ErrDryRun rolls back the transaction of a dry run; callers that return it
+// from a transaction treat it as success
+var ErrDryRun = errors.New("dry run")
+
+// ZoneDiff is what an import changes in one zone
+type ZoneDiff struct {
+    Zone    string        `json:"zone"`
+    View    string        `json:"view,omitempty"`
+    Created bool          `json:"created"`
+    Deleted bool          `json:"deleted,omitempty"` // dropped by a replace import
+    Added   int           `json:"added"`
+    Changed int           `json:"changed"`
+    Removed int           `json:"removed"`
+    Changes []RRSetChange `json:"changes"`
+}
+
+// NewZoneDiff compares the RRSets of zone before and after an import
+func NewZoneDiff(zone Zone, before, after []RRSet) ZoneDiff {
+    d := ZoneDiff{Zone: strings.TrimSuffix(zone.Name, "."), View: zone.View, Changes: DiffRRSets(before, after)}
+    for _, ch := range d.Changes {
+        switch ch.Change {
+        case ChangeAdded:
+            d.Added++
+        case ChangeChanged:
+            d.Changed++
+        case ChangeRemoved:
+            d.Removed++
+        }
+    }
+    return d
+}
+
+// Empty reports whether the import leaves the zone as it was
+func (d ZoneDiff) Empty() bool {
+    return !d.Created && !d.Deleted && len(d.Changes) == 0
+}
+
+// DryRun runs change on the RRSets of zone in a transaction that is rolled
+// back, and returns what it would have changed
+func DryRun(db *gorm.DB, zone Zone, change func(tx *gorm.DB) error) (ZoneDiff, error) {
+    var diff ZoneDiff
+    err := db.Transaction(func(tx *gorm.DB) error {
+        before := ZoneSnapshot(tx, zone.ID)
+        if err := change(tx); err != nil {
+            return err
+        }
+        diff = NewZoneDiff(zone, before, ZoneSnapshot(tx, zone.ID))
+        return ErrDryRun
+    })
+    if errors.Is(err, ErrDryRun) {
+        err = nil
+    }
+    return diff, err
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+
+    "gorm.io/gorm"
+)
+
+func TestImportZones_DryRunReportsDiffWithoutChanges(t *testing.T) {
+    db := newMemDB(t)
+    z := Zone{Name: "dryrun-import.test."}
+    if err := db.Create(&z).Error; err != nil { t.Fatalf("create zone: %v", err) }
+    for _, rs := range []RRSet{
+        {ZoneID: z.ID, Name: "www.dryrun-import.test.", Type: "A", TTL: 60, Records: []RData{{Data: "192.0.2.1"}}},
+        {ZoneID: z.ID, Name: "old.dryrun-import.test.", Type: "A", TTL: 60, Records: []RData{{Data: "192.0.2.9"}}},
+    } {
+        if err := db.Create(&rs).Error; err != nil { t.Fatalf("create rrset: %v", err) }
+    }
+    before := ZoneSnapshot(db, z.ID)
+
+    file := filepath.Join(t.TempDir(), "backup.json")
+    backup := `{"version":"1.0","zones":[
+        {"name":"dryrun-import.test.","rrsets":[
+            {"name":"www.dryrun-import.test.","type":"A","ttl":60,"records":[{"data":"192.0.2.1","country":"RU"}]},
+            {"name":"new.dryrun-import.test.","type":"A","ttl":60,"records":[{"data":"192.0.2.2"}]}]},
+        {"name":"dryrun-new.test.","rrsets":[]}]}`
+    if err := os.WriteFile(file, []byte(backup), 0o600); err != nil { t.Fatal(err) }
+
+    diffs, err := ImportZones(db, file, "merge", SerialManager{}, nil, true)
+    if err != nil { t.Fatalf("dry run: %v", err) }
+    if len(diffs) != 2 { t.Fatalf("want 2 zone diffs, got %+v", diffs) }
+    d := diffs[0]
+    if d.Created || d.Added != 1 || d.Changed != 1 || d.Removed != 1 { t.Fatalf("unexpected diff %+v", d) }
+    for _, ch := range d.Changes {
+        if ch.Change == ChangeChanged && (ch.Before.Records[0].Country != nil || ch.After.Records[0].Country == nil) {
+            t.Fatalf("geo change not reported: %+v", ch)
+        }
+    }
+    if !diffs[1].Created { t.Fatalf("new zone not reported as created: %+v", diffs[1]) }
+    if !SameRRSets(before, ZoneSnapshot(db, z.ID)) { t.Fatalf("dry run changed the zone") }
+    var n int64
+    db.Model(&Zone{}).Where("name = ?", "dryrun-new.test.").Count(&n)
+    if n != 0 { t.Fatalf("dry run created a zone") }
+    if _, total, _ := ListAudit(db, AuditFilter{Zone: "dryrun-import.test"}); total != 0 { t.Fatalf("dry run wrote %d audit entries", total) }
+
+    // The import that follows makes the reported changes; merging twice works
+    for i := 0; i < 2; i++ {
+        if _, err := ImportZones(db, file, "merge", SerialManager{}, nil, false); err != nil { t.Fatalf("import %d: %v", i, err) }
+    }
+    if sets := ZoneSnapshot(db, z.ID); len(sets) != 2 { t.Fatalf("imported zone has %d rrsets", len(sets)) }
+
+    // Replace drops the zones missing from the backup
+    gone := Zone{Name: "dryrun-gone.test."}
+    if err := db.Create(&gone).Error; err != nil { t.Fatalf("create zone: %v", err) }
+    diffs, err = ImportZones(db, file, "replace", SerialManager{}, nil, true)
+    if err != nil { t.Fatalf("replace dry run: %v", err) }
+    dropped := false
+    for _, d := range diffs {
+        dropped = dropped || d.Deleted && d.Zone == "dryrun-gone.test"
+    }
+    if !dropped { t.Fatalf("replace dry run does not report the dropped zone: %+v", diffs) }
+    if err := db.First(&Zone{}, gone.ID).Error; err != nil { t.Fatalf("replace dry run deleted a zone: %v", err) }
+}
+
+func TestDryRun_RollsBack(t *testing.T) {
+    db := newMemDB(t)
+    z := Zone{Name: "dryrun-helper.test."}
+    if err := db.Create(&z).Error; err != nil { t.Fatalf("create zone: %v", err) }
+    diff, err := DryRun(db, z, func(tx *gorm.DB) error {
+        return tx.Create(&RRSet{ZoneID: z.ID, Name: "www.dryrun-helper.test.", Type: "A", TTL: 60, Records: []RData{{Data: "192.0.2.1"}}}).Error
+    })
+    if err != nil || diff.Added != 1 || diff.Zone != "dryrun-helper.test" { t.Fatalf("diff %+v, err %v", diff, err) }
+    if sets := ZoneSnapshot(db, z.ID); len(sets) != 0 { t.Fatalf("dry run kept %d rrsets", len(sets)) }
+}
+
+func TestImportZones_RejectsUnknownView(t *testing.T) {
+    db := newMemDB(t)
+    file := filepath.Join(t.TempDir(), "backup.json")
+    backup := `{"version":"1.0","zones":[{"name":"unknown-view-import.test.","view":"internal","rrsets":[]}]}`
+    if err := os.WriteFile(file, []byte(backup), 0o600); err != nil { t.Fatal(err) }
+
+    if _, err := ImportZones(db, file, "merge", SerialManager{}, nil, false); err == nil { t.Fatalf("a zone of an unknown view must fail the import") }
+    var count int64
+    db.Model(&Zone{}).Where("name = ?", "unknown-view-import.test.").Count(&count)
+    if count != 0 { t.Fatalf("nothing must be imported, got %d zones", count) }
+    known := func(view string) bool { return view == "" || view == "internal" }
+    if _, err := ImportZones(db, file, "merge", SerialManager{}, known, false); err != nil { t.Fatalf("configured view: %v", err) }
+}
@@ -0,0 +1,308 @@
+package db
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+    "unicode/utf8"
+
+    "gorm.io/gorm"
+
+    "namedot/internal/idn"
+)
+
+// Page sizes of zone, RRSet and search lists
+const (
+    DefaultListLimit = 100
+    MaxListLimit     = 1000
+)
+
+var ErrInvalidSort = errors.New("invalid sort field")
+
+// Page selects a slice of a sorted list. Limit 0 means DefaultListLimit;
+// larger limits are capped at MaxListLimit. Sort is a field name, "-"
+// prefixed for descending order.
+type Page struct {
+    Limit  int
+    Offset int
+    Sort   string
+}
+
+// order turns Sort into an ORDER BY clause for the allowed fields; the
+// default orders by name. The ID breaks ties so pages are stable.
+func (p Page) order(fields map[string]string) (string, error) {
+    field, desc := strings.CutPrefix(p.Sort, "-")
+    if field == "" {
+        field = "name"
+    }
+    col, ok := fields[field]
+    if !ok {
+        return "", fmt.Errorf("%w %q", ErrInvalidSort, field)
+    }
+    dir := "ASC"
+    if desc {
+        dir = "DESC"
+    }
+    return fmt.Sprintf("%s %s, id %s", col, dir, dir), nil
+}
+
+// PageLimit returns the page size a list query uses for p
+func (p Page) PageLimit() int {
+    if p.Limit <= 0 {
+        return DefaultListLimit
+    }
+    return min(p.Limit, MaxListLimit)
+}
+
+func (p Page) apply(q *gorm.DB) *gorm.DB {
+    q = q.Limit(p.PageLimit())
+    if p.Offset > 0 {
+        q = q.Offset(p.Offset)
+    }
+    return q
+}
+
+// ZoneFilter selects zones; empty fields match everything
+type ZoneFilter struct {
+    Page
+    Name    string   // exact name, with or without trailing dot
+    Suffix  string   // name ends with this domain, e.g. "example.com"
+    Search  string   // substring of the name
+    View    *string  // view name, "" for the default view
+    Kind    string   // primary, secondary or forward
+    Tag     string   // one of the tags of the zone
+    Allowed []string // zone restrictions of the token, see ZoneAllowed
+}
+
+// ListZones returns a page of the zones matching f and the total count
+func ListZones(db *gorm.DB, f ZoneFilter) ([]Zone, int64, error) {
+    order, err := f.order(map[string]string{"name": "name", "id": "id", "view": "view", "kind": "kind",
+        "created_at": "created_at", "updated_at": "updated_at"})
+    if err != nil {
+        return nil, 0, err
+    }
+    query := func() *gorm.DB {
+        q := db.Model(&Zone{})
+        if f.Name != "" {
+            q = q.Where("LOWER(name) IN ?", nameVariants(f.Name))
+        }
+        if f.Suffix != "" {
+            q = q.Where(suffixCond(db, "name", f.Suffix))
+        }
+        if f.Search != "" {
+            q = q.Where(likeForms(db, db.Model(&Zone{}), "name", f.Search))
+        }
+        if f.View != nil {
+            q = q.Where("view = ?", *f.View)
+        }
+        if f.Kind != "" {
+            q = q.Where("kind = ?", strings.ToLower(f.Kind))
+        }
+        if f.Tag != "" {
+            q = q.Where(tagCond(db, "tags", f.Tag))
+        }
+        if len(f.Allowed) > 0 {
+            q = q.Where(allowedCond(db, "name", f.Allowed))
+        }
+        return q
+    }
+    var total int64
+    if err := query().Count(&total).Error; err != nil {
+        return nil, 0, err
+    }
+    zones := []Zone{}
+    err = f.apply(query().Order(order)).Find(&zones).Error
+    return zones, total, err
+}
+
+// RRSetFilter selects RRSets of one zone; empty fields match everything
+type RRSetFilter struct {
+    Page
+    ZoneID uint
+    Name   string // exact FQDN
+    Suffix string // name ends with this domain
+    Type   string
+    Search string // substring of the name
+}
+
+// ListRRSets returns a page of the RRSets matching f, with records, and the
+// total count
+func ListRRSets(db *gorm.DB, f RRSetFilter) ([]RRSet, int64, error) {
+    order, err := f.order(map[string]string{"name": "name", "type": "type", "ttl": "ttl", "id": "id",
+        "updated_at": "updated_at"})
+    if err != nil {
+        return nil, 0, err
+    }
+    query := func() *gorm.DB {
+        q := db.Model(&RRSet{}).Where("zone_id = ?", f.ZoneID)
+        if f.Name != "" {
+            q = q.Where("LOWER(name) IN ?", nameVariants(f.Name))
+        }
+        if f.Suffix != "" {
+            q = q.Where(suffixCond(db, "name", f.Suffix))
+        }
+        if f.Type != "" {
+            q = q.Where("type = ?", strings.ToUpper(f.Type))
+        }
+        if f.Search != "" {
+            q = q.Where(likeForms(db, db.Model(&RRSet{}).Where("zone_id = ?", f.ZoneID), "name", f.Search))
+        }
+        return q
+    }
+    var total int64
+    if err := query().Count(&total).Error; err != nil {
+        return nil, 0, err
+    }
+    sets := []RRSet{}
+    err = f.apply(query().Preload("Records").Order(order)).Find(&sets).Error
+    return sets, total, err
+}
+
+// RecordMatch is a record found by SearchRecords
+type RecordMatch struct {
+    ZoneID  uint   `json:"zone_id"`
+    Zone    string `json:"zone"`
+    RRSetID uint   `json:"rrset_id"`
+    Name    string `json:"name"`
+    Type    string `json:"type"`
+    TTL     uint32 `json:"ttl"`
+    Data    string `json:"data"`
+}
+
+// RecordSearch selects records across all zones by their data
+type RecordSearch struct {
+    Page
+    Data     string // exact record data
+    Contains string // substring of the record data
+    Type     string
+    Allowed  []string // zone restrictions of the token
+}
+
+// SearchRecords finds records of all zones by value, e.g. the names pointing
+// at an address. The caller makes sure Data or Contains is set.
+func SearchRecords(db *gorm.DB, f RecordSearch) ([]RecordMatch, int64, error) {
+    order, err := f.order(map[string]string{"name": "rr_sets.name", "type": "rr_sets.type", "zone": "zones.name",
+        "data": "r_data.data"})
+    if err != nil {
+        return nil, 0, err
+    }
+    // The tie breaker of Page.order is ambiguous in a join
+    order = strings.Replace(order, ", id ", ", r_data.id ", 1)
+    query := func() *gorm.DB {
+        q := db.Table("r_data").
+            Joins("JOIN rr_sets ON rr_sets.id = r_data.rr_set_id AND rr_sets.deleted_at IS NULL").
+            Joins("JOIN zones ON zones.id = rr_sets.zone_id AND zones.deleted_at IS NULL").
+            Where("r_data.deleted_at IS NULL")
+        if f.Data != "" {
+            q = q.Where("r_data.data = ?", f.Data)
+        }
+        if f.Contains != "" {
+            q = q.Where("r_data.data LIKE ? ESCAPE '!'", "%"+likeEscaper.Replace(f.Contains)+"%")
+        }
+        if f.Type != "" {
+            q = q.Where("rr_sets.type = ?", strings.ToUpper(f.Type))
+        }
+        if len(f.Allowed) > 0 {
+            q = q.Where(allowedCond(db, "zones.name", f.Allowed))
+        }
+        return q
+    }
+    var total int64
+    if err := query().Count(&total).Error; err != nil {
+        return nil, 0, err
+    }
+    matches := []RecordMatch{}
+    err = f.apply(query().Order(order)).
+        Select("zones.id AS zone_id, zones.name AS zone, rr_sets.id AS rr_set_id, rr_sets.name AS name, " +
+            "rr_sets.type AS type, rr_sets.ttl AS ttl, r_data.data AS data").
+        Scan(&matches).Error
+    return matches, total, err
+}
+
+// ZoneByName finds a zone by name, with or without trailing dot, in a view
+// ("" for the default view)
+func ZoneByName(db *gorm.DB, name, view string) (Zone, error) {
+    var z Zone
+    err := db.Where("LOWER(name) IN ? AND view = ?", nameVariants(name), view).First(&z).Error
+    return z, err
+}
+
+// asciiName lowercases a domain name and converts Unicode labels to their
+// stored punycode form; names that do not convert are only lowercased
+func asciiName(name string) string {
+    if a, err := idn.ToASCII(name); err == nil {
+        return a
+    }
+    return strings.ToLower(name)
+}
+
+// nameVariants returns a domain name with and without trailing dot, lowercased
+// and in ASCII form
+func nameVariants(name string) []string {
+    n := strings.TrimSuffix(asciiName(name), ".")
+    return []string{n, n + "."}
+}
+
+// likeEscaper escapes the LIKE wildcards of a search term, so % and _ in it
+// match themselves; the conditions use ESCAPE '!', which every supported
+// database accepts
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// likeForms matches col against the substring term, typed in Unicode or
+// ASCII form. Punycode encodes whole labels only, so a partial Unicode term
+// is also compared with the decoded names of the rows of candidates that
+// hold punycode labels.
+func likeForms(db, candidates *gorm.DB, col, term string) *gorm.DB {
+    cond := db.Where("1 = 0")
+    for _, f := range idn.Forms(term) {
+        cond = cond.Or("LOWER("+col+") LIKE ? ESCAPE '!'", "%"+likeEscaper.Replace(f)+"%")
+    }
+    t := strings.ToLower(term)
+    if strings.IndexFunc(t, func(r rune) bool { return r >= utf8.RuneSelf }) < 0 {
+        return cond
+    }
+    var rows []struct {
+        ID   uint
+        Name string
+    }
+    candidates.Session(&gorm.Session{}).Select("id, "+col+" AS name").Where("LOWER("+col+") LIKE ?", "%xn--%").Scan(&rows)
+    var ids []uint
+    for _, r := range rows {
+        if strings.Contains(idn.ToUnicode(strings.ToLower(r.Name)), t) {
+            ids = append(ids, r.ID)
+        }
+    }
+    if len(ids) > 0 {
+        cond = cond.Or("id IN ?", ids)
+    }
+    return cond
+}
+
+// NameContains matches names containing term, typed in Unicode or punycode,
+// like the list filters; candidates selects the rows searched, e.g. the RRSets
+// of one zone
+func NameContains(db, candidates *gorm.DB, term string) *gorm.DB {
+    return likeForms(db, candidates, "name", term)
+}
+
+// suffixCond matches col equal to domain or below it
+func suffixCond(db *gorm.DB, col, domain string) *gorm.DB {
+    d := likeEscaper.Replace(strings.TrimSuffix(asciiName(domain), "."))
+    return db.Where("LOWER("+col+") IN ?", nameVariants(domain)).
+        Or("LOWER("+col+") LIKE ? ESCAPE '!'", "%."+d).
+        Or("LOWER("+col+") LIKE ? ESCAPE '!'", "%."+d+".")
+}
+
+// allowedCond matches col against zone restrictions like ZoneAllowed
+func allowedCond(db *gorm.DB, col string, restrictions []string) *gorm.DB {
+    cond := db.Where("1 = 0")
+    for _, r := range restrictions {
+        r = strings.TrimSuffix(asciiName(r), ".")
+        if suffix, ok := strings.CutPrefix(r, "*."); ok {
+            cond = cond.Or(suffixCond(db, col, suffix))
+        } else {
+            cond = cond.Or("LOWER("+col+") IN ?", nameVariants(r))
+        }
+    }
+    return cond
+}
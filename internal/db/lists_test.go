@@ -0,0 +1,70 @@
+package db
+
+import (
+    "errors"
+    "testing"
+)
+
+func TestListZonesAndSearchRecords(t *testing.T) {
+    db := newMemDB(t)
+    for _, name := range []string{"a.lists.test.", "b.lists.test.", "c.lists.test.", "other-lists.test."} {
+        z := Zone{Name: name}
+        if err := db.Create(&z).Error; err != nil { t.Fatalf("create zone: %v", err) }
+        db.Create(&RRSet{ZoneID: z.ID, Name: "www." + name, Type: "A", TTL: 60, Records: []RData{{Data: "10.91.2.3"}}})
+        db.Create(&RRSet{ZoneID: z.ID, Name: "mail." + name, Type: "A", TTL: 60, Records: []RData{{Data: "10.91.2.4"}}})
+    }
+
+    zones, total, err := ListZones(db, ZoneFilter{Page: Page{Limit: 2, Offset: 1, Sort: "-name"}, Suffix: "lists.test"})
+    if err != nil { t.Fatalf("list: %v", err) }
+    if total != 3 || len(zones) != 2 || zones[0].Name != "b.lists.test." || zones[1].Name != "a.lists.test." {
+        t.Fatalf("unexpected page %d %+v", total, zones)
+    }
+    if _, total, _ := ListZones(db, ZoneFilter{Allowed: []string{"*.lists.test", "other-lists.test"}, Search: "lists"}); total != 4 {
+        t.Fatalf("token restrictions must match like ZoneAllowed, got %d", total)
+    }
+    if _, _, err := ListZones(db, ZoneFilter{Page: Page{Sort: "data"}}); !errors.Is(err, ErrInvalidSort) {
+        t.Fatalf("want invalid sort refused, got %v", err)
+    }
+
+    matches, total, err := SearchRecords(db, RecordSearch{Data: "10.91.2.3", Allowed: []string{"*.lists.test"}, Page: Page{Sort: "zone"}})
+    if err != nil { t.Fatalf("search: %v", err) }
+    if total != 3 || len(matches) != 3 || matches[0].Name != "www.a.lists.test." || matches[0].Zone != "a.lists.test." || matches[0].RRSetID == 0 {
+        t.Fatalf("unexpected matches %d %+v", total, matches)
+    }
+    if _, total, _ := SearchRecords(db, RecordSearch{Contains: "10.91.2."}); total != 8 {
+        t.Fatalf("want 8 substring matches, got %d", total)
+    }
+}
+
+func TestSearchRecords_EscapesLikeWildcards(t *testing.T) {
+    db := newMemDB(t)
+    z := Zone{Name: "like-escape.test."}
+    if err := db.Create(&z).Error; err != nil { t.Fatalf("create zone: %v", err) }
+    db.Create(&RRSet{ZoneID: z.ID, Name: "a.like-escape.test.", Type: "TXT", TTL: 60, Records: []RData{{Data: `"100% sure"`}, {Data: `"100 sure"`}}})
+    db.Create(&RRSet{ZoneID: z.ID, Name: "_dmarc.like-escape.test.", Type: "TXT", TTL: 60, Records: []RData{{Data: `"v=DMARC1"`}}})
+    db.Create(&RRSet{ZoneID: z.ID, Name: "xdmarc.like-escape.test.", Type: "TXT", TTL: 60, Records: []RData{{Data: `"x"`}}})
+
+    if _, total, _ := SearchRecords(db, RecordSearch{Contains: "100%"}); total != 1 {
+        t.Fatalf("%% must match itself, got %d matches", total)
+    }
+    if _, total, _ := ListRRSets(db, RRSetFilter{ZoneID: z.ID, Search: "_dmarc"}); total != 1 {
+        t.Fatalf("_ must match itself, got %d rrsets", total)
+    }
+}
+
+func TestListZones_PartialUnicodeSearch(t *testing.T) {
+    db := newMemDB(t)
+    z := Zone{Name: "xn--e1afmkfd.xn--p1ai."} // пример.рф
+    if err := db.Create(&z).Error; err != nil { t.Fatalf("create zone: %v", err) }
+    db.Create(&RRSet{ZoneID: z.ID, Name: "xn--80aswg.xn--e1afmkfd.xn--p1ai.", Type: "A", TTL: 60, Records: []RData{{Data: "192.0.2.1"}}}) // сайт.пример.рф
+    db.Create(&RRSet{ZoneID: z.ID, Name: "www.xn--e1afmkfd.xn--p1ai.", Type: "A", TTL: 60, Records: []RData{{Data: "192.0.2.2"}}})
+
+    for _, term := range []string{"ример", "Пример", "xn--e1af"} {
+        if zones, _, _ := ListZones(db, ZoneFilter{Search: term}); len(zones) != 1 || zones[0].ID != z.ID {
+            t.Fatalf("search %q: want the zone, got %+v", term, zones)
+        }
+    }
+    if sets, _, _ := ListRRSets(db, RRSetFilter{ZoneID: z.ID, Search: "айт"}); len(sets) != 1 || sets[0].Name != "xn--80aswg.xn--e1afmkfd.xn--p1ai." {
+        t.Fatalf("partial Unicode label must match, got %+v", sets)
+    }
+}
@@ -1,18 +1,42 @@
 package db
 
 import (
+    "encoding/json"
     "time"
 
     "gorm.io/gorm"
+
+    "namedot/internal/idn"
 )
 
 type Zone struct {
-    ID        uint           `gorm:"primaryKey" json:"id"`
-    Name      string         `gorm:"uniqueIndex;size:255" json:"name"`
-    CreatedAt time.Time      `json:"created_at"`
-    UpdatedAt time.Time      `json:"updated_at"`
-    DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-    RRSets    []RRSet        `json:"rrsets"`
+    ID           uint           `gorm:"primaryKey" json:"id"`
+    Name         string         `gorm:"uniqueIndex:idx_zone_name_view;size:255" json:"name"`
+    View         string         `gorm:"uniqueIndex:idx_zone_name_view;size:64;default:''" json:"view,omitempty"` // empty = served to all views
+    Kind         string         `gorm:"size:20;default:'primary'" json:"kind"`                                   // primary, secondary or forward
+    Description  string         `gorm:"type:text" json:"description,omitempty"`
+    Owner        string         `gorm:"size:255" json:"owner,omitempty"` // contact of the people responsible, e.g. an email
+    Tags         string         `gorm:"size:255" json:"-"`               // comma separated, see TagList
+    DefaultTTL   uint32         `json:"default_ttl,omitempty"`           // overrides default_ttl of the config when set
+    SOA          SOADefaults    `gorm:"embedded;embeddedPrefix:soa_" json:"soa_defaults"`
+    SerialPolicy string         `gorm:"size:20" json:"serial_policy,omitempty"` // increment, date or epoch; empty uses soa_serial_policy of the config
+    Disabled     bool           `json:"disabled"` // kept but not served
+    CreatedAt    time.Time      `json:"created_at"`
+    UpdatedAt    time.Time      `json:"updated_at"`
+    DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+    RRSets       []RRSet        `json:"rrsets"`
+}
+
+// SOADefaults are the values of the SOA that a SerialManager creates for a
+// zone without one; zero values fall back to the built-in defaults
+type SOADefaults struct {
+    Primary    string `gorm:"size:255" json:"primary,omitempty"`    // MNAME, default ns1.<zone>
+    Hostmaster string `gorm:"size:255" json:"hostmaster,omitempty"` // RNAME, default hostmaster.<zone>
+    Refresh    uint32 `json:"refresh,omitempty"`
+    Retry      uint32 `json:"retry,omitempty"`
+    Expire     uint32 `json:"expire,omitempty"`
+    Minimum    uint32 `json:"minimum,omitempty"`
+    TTL        uint32 `json:"ttl,omitempty"`
 }
 
 type RRSet struct {
@@ -35,11 +59,55 @@ type RData struct {
     Continent *string        `gorm:"size:2" json:"continent,omitempty"`
     ASN       *int           `json:"asn,omitempty"`
     Subnet    *string        `gorm:"size:64" json:"subnet,omitempty"`
+    AutoPTR   bool           `json:"auto_ptr,omitempty"` // A/AAAA: keep a PTR in the hosted reverse zone, see SyncAutoPTR
     CreatedAt time.Time      `json:"created_at"`
     UpdatedAt time.Time      `json:"updated_at"`
     DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// MarshalJSON adds the Unicode form of an internationalized zone name as
+// unicode_name; name keeps the ASCII form used on the wire. Tags are a list.
+func (z Zone) MarshalJSON() ([]byte, error) {
+    type plain Zone
+    return json.Marshal(struct {
+        plain
+        UnicodeName string   `json:"unicode_name,omitempty"`
+        Tags        []string `json:"tags,omitempty"`
+    }{plain(z), unicodeName(z.Name), z.TagList()})
+}
+
+// UnmarshalJSON reads the tags list written by MarshalJSON
+func (z *Zone) UnmarshalJSON(b []byte) error {
+    type plain Zone
+    aux := struct {
+        *plain
+        Tags []string `json:"tags"`
+    }{plain: (*plain)(z)}
+    if err := json.Unmarshal(b, &aux); err != nil {
+        return err
+    }
+    z.Tags = JoinTags(aux.Tags)
+    return nil
+}
+
+// MarshalJSON adds the Unicode form of an internationalized owner name as
+// unicode_name
+func (rs RRSet) MarshalJSON() ([]byte, error) {
+    type plain RRSet
+    return json.Marshal(struct {
+        plain
+        UnicodeName string `json:"unicode_name,omitempty"`
+    }{plain(rs), unicodeName(rs.Name)})
+}
+
+// unicodeName is the display form of name, "" when it is plain ASCII
+func unicodeName(name string) string {
+    if u := idn.ToUnicode(name); u != name {
+        return u
+    }
+    return ""
+}
+
 // Template represents a DNS record template
 type Template struct {
     ID          uint             `gorm:"primaryKey" json:"id"`
@@ -68,3 +136,121 @@ type TemplateRecord struct {
     DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+
+// APIToken is a named REST credential. Only a SHA-256 digest of the token is
+// stored; the plain token is shown once when it is created.
+type APIToken struct {
+    ID         uint       `gorm:"primaryKey" json:"id"`
+    Name       string     `gorm:"uniqueIndex;size:100;not null" json:"name"`
+    TokenHash  string     `gorm:"uniqueIndex;size:64;not null" json:"-"`
+    Scopes     string     `gorm:"size:100;not null" json:"scopes"`   // comma separated: read,write,sync,admin
+    Zones      string     `gorm:"type:text" json:"zones,omitempty"` // comma separated zone names or *.suffix; empty = all zones
+    ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+    LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+    RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+    CreatedAt  time.Time  `json:"created_at"`
+    UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// User is a web admin account. Viewers and editors only see the zones they
+// own; admins see every zone and manage users.
+type User struct {
+    ID           uint      `gorm:"primaryKey" json:"id"`
+    Username     string    `gorm:"uniqueIndex;size:100;not null" json:"username"`
+    PasswordHash string    `gorm:"size:100;not null" json:"-"` // bcrypt hash
+    Role         string    `gorm:"size:20;not null" json:"role"` // viewer, editor or admin
+    Source       string    `gorm:"size:20;default:''" json:"source,omitempty"` // empty for local users, "oidc" for single sign-on
+    Disabled     bool      `json:"disabled"`
+    TOTPSecret   string    `gorm:"size:64" json:"-"` // base32; pending until TOTPEnabled
+    TOTPEnabled  bool      `json:"totp_enabled"`
+    TOTPLastStep int64     `json:"-"` // last accepted time step, rejects replayed codes
+    CreatedAt    time.Time `json:"created_at"`
+    UpdatedAt    time.Time `json:"updated_at"`
+    Zones        []Zone    `gorm:"many2many:user_zones" json:"zones,omitempty"`
+}
+
+// RecoveryCode is a single-use second factor for a user who lost their
+// authenticator. Only a SHA-256 digest of the code is stored.
+type RecoveryCode struct {
+    ID        uint       `gorm:"primaryKey"`
+    UserID    uint       `gorm:"index;not null"`
+    CodeHash  string     `gorm:"size:64;uniqueIndex;not null"`
+    UsedAt    *time.Time
+    CreatedAt time.Time
+}
+
+// WebSession is a web admin session kept by the database session store.
+// ID is a SHA-256 digest of the session cookie, which is never stored.
+type WebSession struct {
+    ID         string    `gorm:"primaryKey;size:64"`
+    UserID     uint      `gorm:"index"` // 0 for the admin from the config file
+    Username   string    `gorm:"size:100;index"`
+    CSRFToken  string    `gorm:"size:64"`
+    IP         string    `gorm:"size:64"`
+    UserAgent  string    `gorm:"size:255"`
+    CreatedAt  time.Time
+    LastSeen   time.Time
+    ExpiresAt  time.Time `gorm:"index"`
+    MFAPending bool      // password checked, waiting for the second factor
+}
+
+// AuditLog records one change of a zone, RRSet or template. Before and After
+// hold JSON snapshots of the changed object; empty for creations and deletions.
+type AuditLog struct {
+    ID        uint      `gorm:"primaryKey" json:"id"`
+    CreatedAt time.Time `gorm:"index" json:"created_at"`
+    Actor     string    `gorm:"size:120;index" json:"actor"` // token:<name>, user:<name> or cli
+    IP        string    `gorm:"size:64" json:"ip,omitempty"`
+    Action    string    `gorm:"size:40;index" json:"action"` // e.g. rrset.update, zone.import
+    ZoneID    uint      `gorm:"index" json:"zone_id,omitempty"`
+    ZoneName  string    `gorm:"size:255" json:"zone,omitempty"`
+    Before    string    `gorm:"type:text" json:"-"`
+    After     string    `gorm:"type:text" json:"-"`
+}
+
+// MarshalJSON embeds the snapshots as JSON values rather than strings
+func (a AuditLog) MarshalJSON() ([]byte, error) {
+    type plain AuditLog
+    raw := func(s string) json.RawMessage {
+        if s == "" {
+            return nil
+        }
+        return json.RawMessage(s)
+    }
+    return json.Marshal(struct {
+        plain
+        Before json.RawMessage `json:"before,omitempty"`
+        After  json.RawMessage `json:"after,omitempty"`
+    }{plain(a), raw(a.Before), raw(a.After)})
+}
+
+// ZoneVersion is a snapshot of all RRSets of a zone, taken each time a change
+// bumps the SOA serial. Data holds the RRSets as JSON.
+type ZoneVersion struct {
+    ID         uint      `gorm:"primaryKey" json:"id"`
+    ZoneID     uint      `gorm:"index;not null" json:"zone_id"`
+    Serial     uint32    `json:"serial"` // 0 when the zone has no SOA
+    RRSetCount int       `json:"rrset_count"`
+    Note       string    `gorm:"size:255" json:"note,omitempty"` // e.g. "rollback to version 12"
+    Data       string    `gorm:"type:text" json:"-"`
+    CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+// Changeset is a draft of changes to a zone. Base holds the RRSets of the zone
+// when the draft was started and Data the edited RRSets, both as JSON; the
+// difference between them is applied when the changeset is published.
+type Changeset struct {
+    ID          uint       `gorm:"primaryKey" json:"id"`
+    ZoneID      uint       `gorm:"index;not null" json:"zone_id"`
+    Status      string     `gorm:"size:20;index;not null" json:"status"` // open, published or discarded
+    CreatedBy   string     `gorm:"size:120" json:"created_by"`
+    UpdatedBy   string     `gorm:"size:120" json:"updated_by,omitempty"` // last editor
+    Editors     string     `gorm:"type:text" json:"-"`                     // every author as a JSON list, see EditorList
+    ApprovedBy  string     `gorm:"size:120" json:"approved_by,omitempty"`
+    PublishedBy string     `gorm:"size:120" json:"published_by,omitempty"`
+    PublishedAt *time.Time `json:"published_at,omitempty"`
+    Base        string     `gorm:"type:text" json:"-"`
+    Data        string     `gorm:"type:text" json:"-"`
+    CreatedAt   time.Time  `json:"created_at"`
+    UpdatedAt   time.Time  `json:"updated_at"`
+}
@@ -46,6 +46,15 @@ func OpenWithDebug(cfg config.DBConfig, debug bool) (*gorm.DB, error) {
 }
 
 func AutoMigrate(db *gorm.DB) error {
-    return db.AutoMigrate(&Zone{}, &RRSet{}, &RData{}, &Template{}, &TemplateRecord{})
+    if err := db.AutoMigrate(&Zone{}, &RRSet{}, &RData{}, &Template{}, &TemplateRecord{}, &APIToken{}, &User{}, &RecoveryCode{}, &WebSession{}, &AuditLog{}, &ZoneVersion{}, &Changeset{}); err != nil {
+        return err
+    }
+    // Zone names are unique per view; drop the name-only index from older schemas
+    if m := db.Migrator(); m.HasIndex(&Zone{}, "idx_zones_name") {
+        if err := m.DropIndex(&Zone{}, "idx_zones_name"); err != nil {
+            return fmt.Errorf("drop legacy zone name index: %w", err)
+        }
+    }
+    return nil
 }
 
@@ -0,0 +1,30 @@
+package db
+
+import (
+    "testing"
+
+    "gorm.io/driver/sqlite"
+    "gorm.io/gorm"
+)
+
+func TestAutoMigrate_DropsLegacyZoneNameIndex(t *testing.T) {
+    db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+    if err != nil { t.Fatalf("open db: %v", err) }
+    // Schema from before views: zone names globally unique
+    if err := db.Exec("CREATE TABLE zones (id integer PRIMARY KEY AUTOINCREMENT, name varchar(255), created_at datetime, updated_at datetime, deleted_at datetime)").Error; err != nil {
+        t.Fatalf("create legacy table: %v", err)
+    }
+    if err := db.Exec("CREATE UNIQUE INDEX idx_zones_name ON zones(name)").Error; err != nil {
+        t.Fatalf("create legacy index: %v", err)
+    }
+
+    if err := AutoMigrate(db); err != nil { t.Fatalf("migrate: %v", err) }
+
+    if err := db.Create(&Zone{Name: "example.com"}).Error; err != nil { t.Fatalf("create default zone: %v", err) }
+    if err := db.Create(&Zone{Name: "example.com", View: "internal"}).Error; err != nil {
+        t.Fatalf("same name in another view should be allowed: %v", err)
+    }
+    if err := db.Create(&Zone{Name: "example.com", View: "internal"}).Error; err == nil {
+        t.Fatalf("duplicate zone within a view should be rejected")
+    }
+}
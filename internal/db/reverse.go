@@ -0,0 +1,328 @@
+package db
+
+import (
+    "fmt"
+    "net/netip"
+    "strconv"
+    "strings"
+
+    "gorm.io/gorm"
+)
+
+// ReverseZone is an in-addr.arpa or ip6.arpa zone for part of an address
+// prefix
+type ReverseZone struct {
+    Name   string       // zone name without trailing dot
+    Prefix netip.Prefix // addresses the zone covers
+    Parent string       // RFC 2317: the /24 zone that delegates this classless zone, "" otherwise
+}
+
+// ReverseZonesFor returns the reverse zones for a prefix. Prefixes between
+// octet (IPv4) or nibble (IPv6) boundaries are split into the zones of the
+// next boundary, e.g. a /22 into four /24 zones. IPv4 prefixes longer than
+// /24 get an RFC 2317 classless zone named "<first>/<bits>.<parent>".
+func ReverseZonesFor(cidr string) ([]ReverseZone, error) {
+    p, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+    if err != nil {
+        return nil, err
+    }
+    p = p.Masked()
+    bits := p.Bits()
+    if bits < 8 {
+        return nil, fmt.Errorf("prefix /%d is too short for a reverse zone", bits)
+    }
+    if p.Addr().Is4() && bits > 24 {
+        parent := reverseZoneName(p.Addr(), 24)
+        name := fmt.Sprintf("%d/%d.%s", p.Addr().As4()[3], bits, parent)
+        return []ReverseZone{{Name: name, Prefix: p, Parent: parent}}, nil
+    }
+    step := 4
+    if p.Addr().Is4() {
+        step = 8
+    }
+    boundary := (bits + step - 1) / step * step
+    var out []ReverseZone
+    a := p.Addr()
+    for i := 0; i < 1<<(boundary-bits); i++ {
+        out = append(out, ReverseZone{Name: reverseZoneName(a, boundary), Prefix: netip.PrefixFrom(a, boundary)})
+        a = addBit(a, boundary-1)
+    }
+    return out, nil
+}
+
+// PTRName is the reverse name of an address, with trailing dot
+func PTRName(a netip.Addr) string {
+    return reverseZoneName(a, a.BitLen()) + "."
+}
+
+// reverseZoneName names the reverse zone of the first bits of a; bits is a
+// multiple of 8 for IPv4 and of 4 for IPv6
+func reverseZoneName(a netip.Addr, bits int) string {
+    var labels []string
+    if a.Is4() {
+        b := a.As4()
+        for i := bits/8 - 1; i >= 0; i-- {
+            labels = append(labels, strconv.Itoa(int(b[i])))
+        }
+        return strings.Join(append(labels, "in-addr.arpa"), ".")
+    }
+    b := a.As16()
+    for i := bits/4 - 1; i >= 0; i-- {
+        nibble := b[i/2] >> 4
+        if i%2 == 1 {
+            nibble = b[i/2] & 0x0f
+        }
+        labels = append(labels, strconv.FormatUint(uint64(nibble), 16))
+    }
+    return strings.Join(append(labels, "ip6.arpa"), ".")
+}
+
+// addBit adds 1 at bit position pos (0 is the most significant bit) of a
+func addBit(a netip.Addr, pos int) netip.Addr {
+    b := a.AsSlice()
+    carry := uint16(1) << (7 - pos%8)
+    for i := pos / 8; i >= 0 && carry > 0; i-- {
+        sum := uint16(b[i]) + carry
+        b[i], carry = byte(sum), sum>>8
+    }
+    out, _ := netip.AddrFromSlice(b)
+    return out
+}
+
+// FindReverseZone finds the hosted reverse zone of view that covers a, the
+// most specific one when several do, and the PTR name of a in it. RFC 2317
+// classless zones take "<last octet>.<zone>" as PTR name.
+func FindReverseZone(db *gorm.DB, view string, a netip.Addr) (Zone, string, bool, error) {
+    a = a.Unmap()
+    var zones []Zone
+    if a.Is4() {
+        parent := reverseZoneName(a, 24)
+        if err := db.Where("view = ? AND (name LIKE ? OR name LIKE ?)", view, "%/%."+parent, "%/%."+parent+".").Find(&zones).Error; err != nil {
+            return Zone{}, "", false, err
+        }
+        best, bestBits := -1, 0
+        for i, z := range zones {
+            first, bits, ok := classlessLabel(strings.TrimSuffix(z.Name, "."), parent)
+            if !ok || bits <= bestBits {
+                continue
+            }
+            if p := netip.PrefixFrom(netip.AddrFrom4([4]byte{a.As4()[0], a.As4()[1], a.As4()[2], first}), bits).Masked(); p.Contains(a) {
+                best, bestBits = i, bits
+            }
+        }
+        if best >= 0 {
+            z := zones[best]
+            return z, fmt.Sprintf("%d.%s.", a.As4()[3], strings.TrimSuffix(z.Name, ".")), true, nil
+        }
+    }
+    step := 4
+    if a.Is4() {
+        step = 8
+    }
+    var names []string
+    for bits := a.BitLen() - step; bits >= step; bits -= step {
+        n := reverseZoneName(a, bits)
+        names = append(names, n, n+".")
+    }
+    zones = nil
+    if err := db.Where("view = ? AND name IN ?", view, names).Find(&zones).Error; err != nil {
+        return Zone{}, "", false, err
+    }
+    var best *Zone
+    for i := range zones {
+        if best == nil || len(zones[i].Name) > len(best.Name) {
+            best = &zones[i]
+        }
+    }
+    if best == nil {
+        return Zone{}, "", false, nil
+    }
+    return *best, PTRName(a), true, nil
+}
+
+// classlessLabel parses the "<first>/<bits>" label of an RFC 2317 zone name
+// below parent
+func classlessLabel(name, parent string) (byte, int, bool) {
+    label, rest, ok := strings.Cut(name, ".")
+    if !ok || rest != parent {
+        return 0, 0, false
+    }
+    f, b, ok := strings.Cut(label, "/")
+    if !ok {
+        return 0, 0, false
+    }
+    first, err1 := strconv.ParseUint(f, 10, 8)
+    bits, err2 := strconv.Atoi(b)
+    if err1 != nil || err2 != nil || bits <= 24 || bits > 32 {
+        return 0, 0, false
+    }
+    return byte(first), bits, true
+}
+
+// ClasslessDelegation returns the RRSets an RFC 2317 parent zone needs for a
+// classless zone: a CNAME per address into the classless zone and, when
+// nameservers are given, the NS delegation of the classless zone
+func ClasslessDelegation(rz ReverseZone, nameservers []string, ttl uint32) []RRSet {
+    var out []RRSet
+    if len(nameservers) > 0 {
+        ns := RRSet{Name: rz.Name + ".", Type: "NS", TTL: ttl}
+        for _, n := range nameservers {
+            ns.Records = append(ns.Records, RData{Data: n})
+        }
+        out = append(out, ns)
+    }
+    for a := rz.Prefix.Addr(); rz.Prefix.Contains(a); a = a.Next() {
+        last := a.As4()[3]
+        out = append(out, RRSet{Name: fmt.Sprintf("%d.%s.", last, rz.Parent), Type: "CNAME", TTL: ttl,
+            Records: []RData{{Data: fmt.Sprintf("%d.%s.", last, rz.Name)}}})
+        if last == 255 {
+            break
+        }
+    }
+    return out
+}
+
+// PTRUpdate is a change SyncAutoPTR made to the PTR RRSet of a reverse zone
+type PTRUpdate struct {
+    Zone   Zone
+    Before *RRSet // nil when the RRSet was created
+    After  *RRSet // nil when the RRSet was deleted
+    Denied bool   // the caller may not change Zone, so its PTR was left alone
+}
+
+// autoPTR is an address of an A or AAAA record with AutoPTR set
+type autoPTR struct {
+    owner string
+    ttl   uint32
+}
+
+// autoPTRs collects the addresses of the auto_ptr records of sets
+func autoPTRs(sets []RRSet) map[netip.Addr]autoPTR {
+    out := map[netip.Addr]autoPTR{}
+    for _, rs := range sets {
+        if !strings.EqualFold(rs.Type, "A") && !strings.EqualFold(rs.Type, "AAAA") {
+            continue
+        }
+        for _, r := range rs.Records {
+            if !r.AutoPTR {
+                continue
+            }
+            if a, err := netip.ParseAddr(strings.TrimSpace(r.Data)); err == nil {
+                out[a.Unmap()] = autoPTR{owner: strings.ToLower(strings.TrimSuffix(rs.Name, ".")) + ".", ttl: rs.TTL}
+            }
+        }
+    }
+    return out
+}
+
+// SyncAutoPTR brings the PTR records of the reverse zones hosted in view in
+// line with a change of A and AAAA RRSets from before to after: addresses
+// that gained auto_ptr get a PTR to their owner, changed ones are updated and
+// removed ones lose the PTR pointing at their old owner. Addresses without
+// a hosted reverse zone are skipped, and so are those of a reverse zone allow
+// rejects (nil allows all), which come back as Denied updates. The caller
+// bumps the serials of the other returned zones.
+func SyncAutoPTR(db *gorm.DB, view string, before, after []RRSet, allow func(Zone) bool) ([]PTRUpdate, error) {
+    old, cur := autoPTRs(before), autoPTRs(after)
+    var updates []PTRUpdate
+    for a, o := range old {
+        if _, ok := cur[a]; ok {
+            continue
+        }
+        u, err := setPTR(db, view, a, o, false, allow)
+        if err != nil {
+            return nil, err
+        }
+        if u != nil {
+            updates = append(updates, *u)
+        }
+    }
+    for a, n := range cur {
+        if o, ok := old[a]; ok && o == n {
+            continue
+        }
+        u, err := setPTR(db, view, a, n, true, allow)
+        if err != nil {
+            return nil, err
+        }
+        if u != nil {
+            updates = append(updates, *u)
+        }
+    }
+    return updates, nil
+}
+
+// setPTR points the PTR of a at p.owner, or with set false removes a PTR
+// pointing at p.owner; nil when nothing changed
+func setPTR(db *gorm.DB, view string, a netip.Addr, p autoPTR, set bool, allow func(Zone) bool) (*PTRUpdate, error) {
+    zone, name, ok, err := FindReverseZone(db, view, a)
+    if err != nil || !ok {
+        return nil, err
+    }
+    if allow != nil && !allow(zone) {
+        return &PTRUpdate{Zone: zone, Denied: true}, nil
+    }
+    var rs RRSet
+    if err := db.Preload("Records").Where("zone_id = ? AND name = ? AND type = ?", zone.ID, name, "PTR").Limit(1).Find(&rs).Error; err != nil {
+        return nil, err
+    }
+    if rs.ID == 0 {
+        if !set {
+            return nil, nil
+        }
+        rs = RRSet{ZoneID: zone.ID, Name: name, Type: "PTR", TTL: p.ttl, Records: []RData{{Data: p.owner}}}
+        if err := db.Create(&rs).Error; err != nil {
+            return nil, err
+        }
+        return &PTRUpdate{Zone: zone, After: RRSetSnapshot(db, rs.ID)}, nil
+    }
+    before := rs
+    points := len(rs.Records) == 1 && strings.EqualFold(rs.Records[0].Data, p.owner)
+    switch {
+    case set && points && rs.TTL == p.ttl:
+        return nil, nil
+    case set:
+        if err := db.Where("rr_set_id = ?", rs.ID).Delete(&RData{}).Error; err != nil {
+            return nil, err
+        }
+        rs.TTL, rs.Records = p.ttl, []RData{{Data: p.owner}}
+        if err := db.Save(&rs).Error; err != nil {
+            return nil, err
+        }
+        return &PTRUpdate{Zone: zone, Before: &before, After: RRSetSnapshot(db, rs.ID)}, nil
+    }
+    // Only a PTR still pointing at the old owner is removed
+    var keep []RData
+    for _, r := range rs.Records {
+        if !strings.EqualFold(r.Data, p.owner) {
+            keep = append(keep, r)
+        }
+    }
+    if len(keep) == len(rs.Records) {
+        return nil, nil
+    }
+    if len(keep) > 0 {
+        if err := db.Where("rr_set_id = ? AND id NOT IN ?", rs.ID, ids(keep)).Delete(&RData{}).Error; err != nil {
+            return nil, err
+        }
+        return &PTRUpdate{Zone: zone, Before: &before, After: RRSetSnapshot(db, rs.ID)}, nil
+    }
+    // Hard delete, so a later auto_ptr address can create the PTR again
+    // under the unique zone/name/type index
+    if err := db.Unscoped().Where("rr_set_id = ?", rs.ID).Delete(&RData{}).Error; err != nil {
+        return nil, err
+    }
+    if err := db.Unscoped().Delete(&RRSet{}, rs.ID).Error; err != nil {
+        return nil, err
+    }
+    return &PTRUpdate{Zone: zone, Before: &before}, nil
+}
+
+// ids returns the IDs of records
+func ids(records []RData) []uint {
+    out := make([]uint, len(records))
+    for i, r := range records {
+        out[i] = r.ID
+    }
+    return out
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+    "net/netip"
+    "testing"
+)
+
+func TestReverseZonesFor(t *testing.T) {
+    cases := map[string][]string{
+        "192.0.2.0/24":   {"2.0.192.in-addr.arpa"},
+        "10.0.0.0/8":     {"10.in-addr.arpa"},
+        "198.51.100.7/22": {"100.51.198.in-addr.arpa", "101.51.198.in-addr.arpa", "102.51.198.in-addr.arpa", "103.51.198.in-addr.arpa"},
+        "192.0.2.64/26":  {"64/26.2.0.192.in-addr.arpa"},
+        "2001:db8::/32":  {"8.b.d.0.1.0.0.2.ip6.arpa"},
+        "2001:db8::/31":  {"8.b.d.0.1.0.0.2.ip6.arpa", "9.b.d.0.1.0.0.2.ip6.arpa"},
+    }
+    for cidr, want := range cases {
+        got, err := ReverseZonesFor(cidr)
+        if err != nil { t.Fatalf("%s: %v", cidr, err) }
+        if len(got) != len(want) { t.Fatalf("%s: want %v, got %+v", cidr, want, got) }
+        for i := range want {
+            if got[i].Name != want[i] { t.Fatalf("%s: want %v, got %+v", cidr, want, got) }
+        }
+    }
+    if rz, _ := ReverseZonesFor("192.0.2.64/26"); rz[0].Parent != "2.0.192.in-addr.arpa" { t.Fatalf("classless zone needs its parent: %+v", rz) }
+    for _, bad := range []string{"192.0.2.1", "10.0.0.0/4", "nonsense"} {
+        if _, err := ReverseZonesFor(bad); err == nil { t.Fatalf("%s must be rejected", bad) }
+    }
+
+    delegation := ClasslessDelegation(ReverseZone{Name: "64/26.2.0.192.in-addr.arpa", Prefix: netip.MustParsePrefix("192.0.2.64/26"),
+        Parent: "2.0.192.in-addr.arpa"}, []string{"ns1.example.net."}, 300)
+    if len(delegation) != 65 || delegation[0].Type != "NS" || delegation[1].Name != "64.2.0.192.in-addr.arpa." ||
+        delegation[1].Records[0].Data != "64.64/26.2.0.192.in-addr.arpa." || delegation[64].Name != "127.2.0.192.in-addr.arpa." {
+        t.Fatalf("unexpected delegation %+v", delegation[:2])
+    }
+}
+
+func TestSyncAutoPTR(t *testing.T) {
+    db := newMemDB(t)
+    rev := Zone{Name: "2.0.192.in-addr.arpa"}
+    classless := Zone{Name: "64/26.2.0.192.in-addr.arpa"}
+    rev6 := Zone{Name: "8.b.d.0.1.0.0.2.ip6.arpa"}
+    for _, z := range []*Zone{&rev, &classless, &rev6} {
+        if err := db.Create(z).Error; err != nil { t.Fatalf("create zone: %v", err) }
+    }
+    ptr := func(z Zone, name string) string {
+        var rs RRSet
+        db.Preload("Records").Where("zone_id = ? AND name = ? AND type = ?", z.ID, name, "PTR").Limit(1).Find(&rs)
+        if len(rs.Records) == 0 { return "" }
+        return rs.Records[0].Data
+    }
+
+    www := RRSet{Name: "www.example.com.", Type: "A", TTL: 60, Records: []RData{
+        {Data: "192.0.2.10", AutoPTR: true}, {Data: "192.0.2.70", AutoPTR: true}, {Data: "192.0.2.11"}}}
+    v6 := RRSet{Name: "www.example.com.", Type: "AAAA", TTL: 60, Records: []RData{{Data: "2001:db8::1", AutoPTR: true}}}
+    updates, err := SyncAutoPTR(db, "", nil, []RRSet{www, v6}, nil)
+    if err != nil || len(updates) != 3 { t.Fatalf("want 3 PTRs, got %d (%v)", len(updates), err) }
+    if ptr(rev, "10.2.0.192.in-addr.arpa.") != "www.example.com." { t.Fatalf("missing PTR in the /24 zone") }
+    if ptr(classless, "70.64/26.2.0.192.in-addr.arpa.") != "www.example.com." { t.Fatalf("classless zone must take the PTR") }
+    if ptr(rev, "11.2.0.192.in-addr.arpa.") != "" { t.Fatalf("records without auto_ptr get no PTR") }
+    if ptr(rev6, "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.") != "www.example.com." { t.Fatalf("missing ip6 PTR") }
+
+    // Renaming moves the PTR; dropping an address removes it
+    api := RRSet{Name: "api.example.com.", Type: "A", TTL: 60, Records: []RData{{Data: "192.0.2.10", AutoPTR: true}}}
+    if _, err := SyncAutoPTR(db, "", []RRSet{www}, []RRSet{api}, nil); err != nil { t.Fatalf("sync: %v", err) }
+    if ptr(rev, "10.2.0.192.in-addr.arpa.") != "api.example.com." || ptr(classless, "70.64/26.2.0.192.in-addr.arpa.") != "" {
+        t.Fatalf("PTRs not moved")
+    }
+    if updates, _ := SyncAutoPTR(db, "", []RRSet{api}, []RRSet{api}, nil); len(updates) != 0 { t.Fatalf("unchanged records must not touch PTRs") }
+
+    // Addresses outside hosted reverse zones or views are skipped
+    other := RRSet{Name: "x.example.com.", Type: "A", TTL: 60, Records: []RData{{Data: "198.51.100.1", AutoPTR: true}}}
+    if updates, err := SyncAutoPTR(db, "", nil, []RRSet{other}, nil); err != nil || len(updates) != 0 { t.Fatalf("unexpected %v %v", updates, err) }
+    if updates, _ := SyncAutoPTR(db, "internal", nil, []RRSet{api}, nil); len(updates) != 0 { t.Fatalf("other views must not be touched") }
+}
+
+func TestSyncAutoPTR_RecreatesRemovedPTR(t *testing.T) {
+    db := newMemDB(t)
+    rev := Zone{Name: "113.0.203.in-addr.arpa"}
+    if err := db.Create(&rev).Error; err != nil { t.Fatalf("create zone: %v", err) }
+    www := RRSet{Name: "www.example.com.", Type: "A", TTL: 60, Records: []RData{{Data: "203.0.113.10", AutoPTR: true}}}
+
+    // Create, delete and create again the same address
+    for i, step := range [][2][]RRSet{{nil, {www}}, {{www}, nil}, {nil, {www}}} {
+        updates, err := SyncAutoPTR(db, "", step[0], step[1], nil)
+        if err != nil || len(updates) != 1 { t.Fatalf("step %d: %d updates, err %v", i, len(updates), err) }
+    }
+    var rs RRSet
+    db.Preload("Records").Where("zone_id = ? AND name = ? AND type = ?", rev.ID, "10.113.0.203.in-addr.arpa.", "PTR").Limit(1).Find(&rs)
+    if len(rs.Records) != 1 || rs.Records[0].Data != "www.example.com." { t.Fatalf("PTR not recreated: %+v", rs) }
+}
+
+func TestAutoPTR_KeptByBatchRollbackAndPublish(t *testing.T) {
+    db := newMemDB(t)
+    z := Zone{Name: "autoptr-keep.test."}
+    if err := db.Create(&z).Error; err != nil { t.Fatalf("create zone: %v", err) }
+    db.Create(&RRSet{ZoneID: z.ID, Name: "www.autoptr-keep.test.", Type: "A", TTL: 60, Records: []RData{{Data: "203.0.113.20", AutoPTR: true}}})
+    flagged := func(name string) bool {
+        for _, rs := range ZoneSnapshot(db, z.ID) {
+            if rs.Name == name && rs.Type == "A" { return len(rs.Records) == 1 && rs.Records[0].AutoPTR }
+        }
+        return false
+    }
+    // want is the number of PTRs the step changes besides www's
+    untouched := func(step string, before []RRSet, want int) {
+        t.Helper()
+        if !flagged("www.autoptr-keep.test.") { t.Fatalf("%s dropped auto_ptr", step) }
+        if updates, _ := SyncAutoPTR(db, "", before, ZoneSnapshot(db, z.ID), nil); len(updates) != want { t.Fatalf("%s touched PTRs: %+v", step, updates) }
+    }
+
+    // A batch on another name leaves www as it is
+    before := ZoneSnapshot(db, z.ID)
+    changes, err := ApplyRRSetOps(db, z.ID, []RRSetOp{{ChangeType: OpReplace, Name: "mail.autoptr-keep.test.", Type: "A", Records: []RData{{Data: "192.0.2.25"}}}}, 60, nil)
+    if err != nil || len(changes) != 1 || changes[0].Name != "mail.autoptr-keep.test." { t.Fatalf("batch: %+v, %v", changes, err) }
+    untouched("batch", before, 0)
+
+    // Rollback to a version taken with the flag set
+    if err := RecordVersion(db, z.ID, "keep"); err != nil { t.Fatalf("version: %v", err) }
+    versions, _, _ := ListVersions(db, z.ID, 1, 0)
+    before = ZoneSnapshot(db, z.ID)
+    if _, err := RollbackZone(db, z, versions[0].ID, SerialManager{}); err != nil { t.Fatalf("rollback: %v", err) }
+    untouched("rollback", before, 0)
+
+    // Publishing a changeset keeps the flag of the RRSets it writes
+    cs, err := StartChangeset(db, z.ID, "user:alice")
+    if err != nil { t.Fatalf("start: %v", err) }
+    PutDraftRRSet(db, &cs, RRSet{Name: "api.autoptr-keep.test.", Type: "A", TTL: 60, Records: []RData{{Data: "203.0.113.21", AutoPTR: true}}}, "user:alice")
+    before = ZoneSnapshot(db, z.ID)
+    if err := PublishChangeset(db, &cs, z, Actor{Name: "user:alice"}, false, SerialManager{}); err != nil { t.Fatalf("publish: %v", err) }
+    untouched("publish", before, 1)
+    if !flagged("api.autoptr-keep.test.") { t.Fatalf("published RRSet lost auto_ptr") }
+}
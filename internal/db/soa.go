@@ -8,66 +8,186 @@ import (
     "gorm.io/gorm"
 )
 
-// BumpSOASerial finds SOA for zone and increments its serial.
-// Uses a non-erroring Find to avoid noisy "record not found" logs.
-func BumpSOASerial(db *gorm.DB, zoneID uint) {
-    var soa RRSet
-    tx := db.Preload("Records").Where("zone_id = ? AND type = ?", zoneID, "SOA").Limit(1).Find(&soa)
-    if tx.Error != nil {
-        return
+// SOA serial policies of Zone.SerialPolicy
+const (
+    SerialIncrement = "increment" // serial + 1
+    SerialDate      = "date"      // YYYYMMDDnn as in RFC 1912, nn counts changes of a day
+    SerialEpoch     = "epoch"     // unix time of the change
+)
+
+// SerialPolicies lists the valid values of Zone.SerialPolicy
+var SerialPolicies = []string{SerialIncrement, SerialDate, SerialEpoch}
+
+// SerialManager moves SOA serials forward after zone changes. REST, web
+// admin, changesets, rollbacks and imports all bump serials through it.
+type SerialManager struct {
+    AutoSOA bool             // create a default SOA for zones without one
+    Policy  string           // policy of zones without their own; "" means increment
+    Now     func() time.Time // clock, time.Now when nil
+}
+
+// Bump moves the serial of zone forward and records the resulting state of
+// the zone as a new ZoneVersion, also for zones without SOA.
+func (m SerialManager) Bump(db *gorm.DB, zone Zone) error {
+    b := m.Batch(db)
+    b.Touch(zone)
+    return b.Flush()
+}
+
+// SerialBatch coalesces the serial bumps of one transaction: a zone touched
+// several times gets a single bump, so a date serial does not burn through
+// its daily counter.
+type SerialBatch struct {
+    m     SerialManager
+    db    *gorm.DB
+    zones []Zone
+}
+
+// Batch starts collecting bumps on db, usually a transaction
+func (m SerialManager) Batch(db *gorm.DB) *SerialBatch {
+    return &SerialBatch{m: m, db: db}
+}
+
+// Touch marks zone as changed
+func (b *SerialBatch) Touch(zone Zone) {
+    for _, z := range b.zones {
+        if z.ID == zone.ID {
+            return
+        }
     }
-    if soa.ID == 0 || len(soa.Records) == 0 {
-        return
+    b.zones = append(b.zones, zone)
+}
+
+// Flush bumps every touched zone once and records its version
+func (b *SerialBatch) Flush() error {
+    zones := b.zones
+    b.zones = nil
+    for _, z := range zones {
+        if err := b.m.advance(b.db, z, 0); err != nil {
+            return err
+        }
+        if err := RecordVersion(b.db, z.ID, ""); err != nil {
+            return err
+        }
     }
-    parts := strings.Fields(soa.Records[0].Data)
-    if len(parts) < 7 {
-        return
+    return nil
+}
+
+// policy returns the serial policy of zone
+func (m SerialManager) policy(zone Zone) string {
+    if zone.SerialPolicy != "" {
+        return zone.SerialPolicy
     }
-    if n, err := strconv.ParseInt(parts[2], 10, 64); err == nil {
-        n++
-        parts[2] = strconv.FormatInt(n, 10)
-    } else {
-        parts[2] = strconv.FormatInt(time.Now().Unix(), 10)
+    if m.Policy != "" {
+        return m.Policy
+    }
+    return SerialIncrement
+}
+
+func (m SerialManager) now() time.Time {
+    if m.Now != nil {
+        return m.Now()
     }
-    newData := strings.Join(parts, " ")
-    _ = db.Model(&RData{}).Where("id = ?", soa.Records[0].ID).Update("data", newData).Error
+    return time.Now()
 }
 
-// BumpSOASerialAuto bumps serial or creates a default SOA if missing when auto is true.
-func BumpSOASerialAuto(db *gorm.DB, zone Zone, auto bool) {
+// advance sets the serial of zone past its current value and past floor, or
+// creates a default SOA when the zone has none and AutoSOA is set
+func (m SerialManager) advance(db *gorm.DB, zone Zone, floor uint32) error {
     var soa RRSet
-    tx := db.Preload("Records").Where("zone_id = ? AND type = ?", zone.ID, "SOA").Limit(1).Find(&soa)
-    if tx.Error != nil {
-        return
+    if err := db.Preload("Records").Where("zone_id = ? AND type = ?", zone.ID, "SOA").Limit(1).Find(&soa).Error; err != nil {
+        return err
     }
+    policy, now := m.policy(zone), m.now()
     if soa.ID == 0 || len(soa.Records) == 0 {
-        if !auto {
-            return
+        if !m.AutoSOA {
+            return nil
         }
-        // Create default SOA
-        zname := strings.TrimSuffix(strings.ToLower(zone.Name), ".")
-        origin := zname + "."
-        primary := "ns1." + origin
-        hostmaster := "hostmaster." + origin
-        serial := strconv.FormatInt(time.Now().Unix(), 10)
-        // Defaults: refresh 7200, retry 3600, expire 1209600, minimum 300, TTL 3600
-        data := strings.Join([]string{primary, hostmaster, serial, "7200", "3600", "1209600", "300"}, " ")
-        rs := RRSet{ZoneID: zone.ID, Name: origin, Type: "SOA", TTL: 3600,
-            Records: []RData{{Data: data}}}
-        _ = db.Create(&rs).Error
-        return
+        return db.Create(&RRSet{ZoneID: zone.ID, Name: strings.TrimSuffix(strings.ToLower(zone.Name), ".") + ".",
+            Type: "SOA", TTL: orDefault(zone.SOA.TTL, 3600),
+            Records: []RData{{Data: defaultSOA(zone, InitialSerial(policy, now))}}}).Error
     }
-    // bump existing
     parts := strings.Fields(soa.Records[0].Data)
     if len(parts) < 7 {
-        return
+        return nil
     }
-    if n, err := strconv.ParseInt(parts[2], 10, 64); err == nil {
-        n++
-        parts[2] = strconv.FormatInt(n, 10)
+    var serial uint32
+    if n, err := strconv.ParseUint(parts[2], 10, 32); err == nil {
+        cur := uint32(n)
+        if floor != 0 && SerialLess(cur, floor) {
+            cur = floor
+        }
+        serial = NextSerial(cur, policy, now)
     } else {
-        parts[2] = strconv.FormatInt(time.Now().Unix(), 10)
+        // Not a 32-bit serial; start over as for a new SOA
+        serial = InitialSerial(policy, now)
+    }
+    parts[2] = strconv.FormatUint(uint64(serial), 10)
+    return db.Model(&RData{}).Where("id = ?", soa.Records[0].ID).Update("data", strings.Join(parts, " ")).Error
+}
+
+// defaultSOA builds the record data of the SOA created for a zone from its
+// SOA defaults. Built-in defaults: refresh 7200, retry 3600, expire 1209600,
+// minimum 300.
+func defaultSOA(zone Zone, serial uint32) string {
+    origin := strings.TrimSuffix(strings.ToLower(zone.Name), ".") + "."
+    d := zone.SOA
+    return strings.Join([]string{
+        orDefault(d.Primary, "ns1."+origin), orDefault(d.Hostmaster, "hostmaster."+origin),
+        strconv.FormatUint(uint64(serial), 10),
+        strconv.FormatUint(uint64(orDefault(d.Refresh, 7200)), 10),
+        strconv.FormatUint(uint64(orDefault(d.Retry, 3600)), 10),
+        strconv.FormatUint(uint64(orDefault(d.Expire, 1209600)), 10),
+        strconv.FormatUint(uint64(orDefault(d.Minimum, 300)), 10),
+    }, " ")
+}
+
+// InitialSerial returns the serial of a new SOA: the date with counter 00
+// for the date policy, else the unix time
+func InitialSerial(policy string, now time.Time) uint32 {
+    if policy == SerialDate {
+        return dateSerial(now)
+    }
+    return uint32(now.Unix())
+}
+
+// NextSerial returns the serial after cur under policy. The date and epoch
+// policies jump to the serial for now when that is greater than cur in
+// RFC 1982 serial arithmetic, else they count on from cur, as increment
+// always does; increments wrap around at 2^32.
+func NextSerial(cur uint32, policy string, now time.Time) uint32 {
+    var target uint32
+    switch policy {
+    case SerialDate:
+        target = dateSerial(now)
+    case SerialEpoch:
+        target = uint32(now.Unix())
+    default:
+        return cur + 1
+    }
+    if SerialLess(cur, target) {
+        return target
+    }
+    return cur + 1
+}
+
+// SerialLess reports whether serial a comes before b in RFC 1982 serial
+// arithmetic for 32-bit serials. Serials exactly 2^31 apart are unordered.
+func SerialLess(a, b uint32) bool {
+    return a != b && b-a < 1<<31
+}
+
+// dateSerial is YYYYMMDD00 for the UTC date of t
+func dateSerial(t time.Time) uint32 {
+    y, mo, d := t.UTC().Date()
+    return uint32(y*1000000+int(mo)*10000+d*100)
+}
+
+// orDefault returns v, or def when v is the zero value
+func orDefault[T comparable](v, def T) T {
+    var zero T
+    if v == zero {
+        return def
     }
-    newData := strings.Join(parts, " ")
-    _ = db.Model(&RData{}).Where("id = ?", soa.Records[0].ID).Update("data", newData).Error
+    return v
 }
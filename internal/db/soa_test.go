@@ -1,9 +1,11 @@
 package db
 
 import (
+    "math"
     "strconv"
     "strings"
     "testing"
+    "time"
 
     "gorm.io/driver/sqlite"
     "gorm.io/gorm"
@@ -17,7 +19,7 @@ func newMemDB(t *testing.T) *gorm.DB {
     return db
 }
 
-func TestBumpSOASerialAuto_CreatesDefaultSOA(t *testing.T) {
+func TestSerialManager_AutoSOACreatesDefaultSOA(t *testing.T) {
     db := newMemDB(t)
     z := Zone{Name: "example.com"}
     if err := db.Create(&z).Error; err != nil { t.Fatalf("create zone: %v", err) }
@@ -28,7 +30,7 @@ func TestBumpSOASerialAuto_CreatesDefaultSOA(t *testing.T) {
     if cnt != 0 { t.Fatalf("expected no SOA, got %d", cnt) }
 
     // Auto-create
-    BumpSOASerialAuto(db, z, true)
+    SerialManager{AutoSOA: true}.Bump(db, z)
 
     var soa RRSet
     if err := db.Preload("Records").Where("zone_id = ? AND type = ?", z.ID, "SOA").First(&soa).Error; err != nil {
@@ -50,7 +52,7 @@ func TestBumpSOASerialAuto_CreatesDefaultSOA(t *testing.T) {
 
     // Bump again should increment serial
     oldSerial := parts[2]
-    BumpSOASerialAuto(db, z, true)
+    SerialManager{AutoSOA: true}.Bump(db, z)
     var soa2 RRSet
     if err := db.Preload("Records").Where("zone_id = ? AND type = ?", z.ID, "SOA").First(&soa2).Error; err != nil {
         t.Fatalf("soa not found on bump: %v", err)
@@ -63,3 +65,57 @@ func TestBumpSOASerialAuto_CreatesDefaultSOA(t *testing.T) {
     if n2 <= n1 { t.Fatalf("serial did not increase: %d -> %d", n1, n2) }
 }
 
+
+func TestNextSerial_Policies(t *testing.T) {
+    now := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+    cases := []struct {
+        cur    uint32
+        policy string
+        want   uint32
+    }{
+        {41, SerialIncrement, 42},
+        {41, "", 42},
+        {math.MaxUint32, SerialIncrement, 0},      // wraps around
+        {2024030400, SerialDate, 2024030500},      // new day resets the counter
+        {2024030500, SerialDate, 2024030501},      // same day counts on
+        {2024030599, SerialDate, 2024030600},      // counter overflow borrows the next day
+        {7, SerialDate, 2024030500},               // old increment serial jumps to the date
+        {uint32(now.Unix()) - 10, SerialEpoch, uint32(now.Unix())},
+        {uint32(now.Unix()) + 10, SerialEpoch, uint32(now.Unix()) + 11},
+        {4000000000, SerialDate, 4000000001},      // ahead of the date in RFC 1982 terms
+    }
+    for _, c := range cases {
+        if got := NextSerial(c.cur, c.policy, now); got != c.want {
+            t.Errorf("NextSerial(%d, %q) = %d, want %d", c.cur, c.policy, got, c.want)
+        }
+    }
+    if !SerialLess(math.MaxUint32, 1) || SerialLess(1, math.MaxUint32) || SerialLess(5, 5) {
+        t.Fatalf("serial arithmetic must wrap around")
+    }
+    if SerialLess(0, 1<<31) || SerialLess(1<<31, 0) { t.Fatalf("serials 2^31 apart are unordered") }
+}
+
+func TestSerialBatch_CoalescesBumpsAndUsesZonePolicy(t *testing.T) {
+    db := newMemDB(t)
+    z := Zone{Name: "serials.test.", SerialPolicy: SerialDate}
+    if err := db.Create(&z).Error; err != nil { t.Fatalf("create zone: %v", err) }
+    db.Create(&RRSet{ZoneID: z.ID, Name: "serials.test.", Type: "SOA", TTL: 3600,
+        Records: []RData{{Data: "ns1.serials.test. hostmaster.serials.test. 2024030500 7200 3600 1209600 300"}}})
+    m := SerialManager{Policy: SerialEpoch, Now: func() time.Time { return time.Date(2024, 3, 5, 8, 0, 0, 0, time.UTC) }}
+
+    b := m.Batch(db)
+    b.Touch(z)
+    b.Touch(z)
+    b.Touch(z)
+    if err := b.Flush(); err != nil { t.Fatalf("flush: %v", err) }
+    serial := func() string {
+        var soa RRSet
+        db.Preload("Records").Where("zone_id = ? AND type = ?", z.ID, "SOA").First(&soa)
+        return strings.Fields(soa.Records[0].Data)[2]
+    }
+    if got := serial(); got != "2024030501" { t.Fatalf("want one date bump, got %s", got) }
+    if _, n, _ := ListVersions(db, z.ID, 0, 0); n != 1 { t.Fatalf("want one version, got %d", n) }
+
+    if err := m.Bump(db, z); err != nil { t.Fatalf("bump: %v", err) }
+    if got := serial(); got != "2024030502" { t.Fatalf("want second change of the day, got %s", got) }
+}
@@ -0,0 +1,219 @@
+package db
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+
+    "gorm.io/gorm"
+)
+
+// API token scopes. write implies read; admin implies every scope.
+// approve allows approving changesets of other authors.
+const (
+    ScopeRead    = "read"
+    ScopeWrite   = "write"
+    ScopeSync    = "sync"
+    ScopeApprove = "approve"
+    ScopeAdmin   = "admin"
+)
+
+// TokenPrefix marks tokens issued from the database
+const TokenPrefix = "ndt_"
+
+// ErrTokenNotFound is returned for unknown, revoked or expired tokens
+var ErrTokenNotFound = errors.New("token not found")
+
+// ParseScopes normalizes a scope list and rejects unknown scopes
+func ParseScopes(scopes []string) ([]string, error) {
+    var out []string
+    seen := map[string]bool{}
+    for _, sc := range scopes {
+        sc = strings.ToLower(strings.TrimSpace(sc))
+        if sc == "" || seen[sc] {
+            continue
+        }
+        switch sc {
+        case ScopeRead, ScopeWrite, ScopeSync, ScopeApprove, ScopeAdmin:
+        default:
+            return nil, fmt.Errorf("unknown scope %q (valid: read, write, sync, approve, admin)", sc)
+        }
+        seen[sc] = true
+        out = append(out, sc)
+    }
+    if len(out) == 0 {
+        return nil, fmt.Errorf("at least one scope is required")
+    }
+    return out, nil
+}
+
+// SplitList splits a comma separated list, dropping empty items
+func SplitList(s string) []string {
+    var out []string
+    for _, p := range strings.Split(s, ",") {
+        if p = strings.TrimSpace(p); p != "" {
+            out = append(out, p)
+        }
+    }
+    return out
+}
+
+// HashToken returns the stored digest of a plain token
+func HashToken(plain string) string {
+    sum := sha256.Sum256([]byte(plain))
+    return hex.EncodeToString(sum[:])
+}
+
+// ScopeList returns the token scopes
+func (t *APIToken) ScopeList() []string { return SplitList(t.Scopes) }
+
+// ZoneList returns the zone restrictions; empty means all zones
+func (t *APIToken) ZoneList() []string { return SplitList(t.Zones) }
+
+// HasScope reports whether the token grants scope
+func (t *APIToken) HasScope(scope string) bool {
+    for _, sc := range t.ScopeList() {
+        if sc == scope || sc == ScopeAdmin || (sc == ScopeWrite && scope == ScopeRead) {
+            return true
+        }
+    }
+    return false
+}
+
+// AllowsZone reports whether the token may touch zone. A restriction
+// "example.com" matches that zone only, "*.example.com" matches
+// example.com and every zone below it.
+func (t *APIToken) AllowsZone(zone string) bool {
+    return ZoneAllowed(t.ZoneList(), zone)
+}
+
+// ZoneAllowed matches zone against a restriction list; empty allows all
+func ZoneAllowed(restrictions []string, zone string) bool {
+    if len(restrictions) == 0 {
+        return true
+    }
+    zone = strings.TrimSuffix(asciiName(zone), ".")
+    for _, r := range restrictions {
+        r = strings.TrimSuffix(asciiName(r), ".")
+        if suffix, ok := strings.CutPrefix(r, "*."); ok {
+            if zone == suffix || strings.HasSuffix(zone, "."+suffix) {
+                return true
+            }
+        } else if zone == r {
+            return true
+        }
+    }
+    return false
+}
+
+// Active reports whether the token is neither revoked nor expired at now
+func (t *APIToken) Active(now time.Time) bool {
+    return t.RevokedAt == nil && (t.ExpiresAt == nil || now.Before(*t.ExpiresAt))
+}
+
+// CreateAPIToken stores a new token and returns it with its plain value,
+// which is not recoverable later.
+func CreateAPIToken(db *gorm.DB, name string, scopes, zones []string, expiresAt *time.Time) (APIToken, string, error) {
+    name = strings.TrimSpace(name)
+    if name == "" {
+        return APIToken{}, "", fmt.Errorf("token name is required")
+    }
+    scopes, err := ParseScopes(scopes)
+    if err != nil {
+        return APIToken{}, "", err
+    }
+    for i := range zones {
+        zones[i] = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(zones[i])), ".")
+    }
+    buf := make([]byte, 24)
+    if _, err := rand.Read(buf); err != nil {
+        return APIToken{}, "", err
+    }
+    plain := TokenPrefix + hex.EncodeToString(buf)
+    tok := APIToken{
+        Name:      name,
+        TokenHash: HashToken(plain),
+        Scopes:    strings.Join(scopes, ","),
+        Zones:     strings.Join(SplitList(strings.Join(zones, ",")), ","),
+        ExpiresAt: expiresAt,
+    }
+    if err := db.Create(&tok).Error; err != nil {
+        return APIToken{}, "", err
+    }
+    return tok, plain, nil
+}
+
+// FindAPIToken returns the active token matching plain
+func FindAPIToken(db *gorm.DB, plain string) (*APIToken, error) {
+    if !strings.HasPrefix(plain, TokenPrefix) {
+        return nil, ErrTokenNotFound
+    }
+    var tok APIToken
+    if err := db.Where("token_hash = ?", HashToken(plain)).Limit(1).Find(&tok).Error; err != nil {
+        return nil, err
+    }
+    if tok.ID == 0 || !tok.Active(time.Now()) {
+        return nil, ErrTokenNotFound
+    }
+    return &tok, nil
+}
+
+// HasActiveAPITokens reports whether any usable token exists
+func HasActiveAPITokens(db *gorm.DB) bool {
+    var count int64
+    err := db.Model(&APIToken{}).
+        Where("revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", time.Now()).
+        Count(&count).Error
+    return err == nil && count > 0
+}
+
+// APITokensIssued reports whether a token was ever created, revoked and
+// expired ones included. Once one was, the API stays closed without
+// configured credentials.
+func APITokensIssued(db *gorm.DB) (bool, error) {
+    var count int64
+    if err := db.Model(&APIToken{}).Count(&count).Error; err != nil {
+        return false, err
+    }
+    return count > 0, nil
+}
+
+// ListAPITokens returns all tokens, including revoked and expired ones
+func ListAPITokens(db *gorm.DB) ([]APIToken, error) {
+    var toks []APIToken
+    err := db.Order("id").Find(&toks).Error
+    return toks, err
+}
+
+// RevokeAPIToken revokes the token with the given ID or name
+func RevokeAPIToken(db *gorm.DB, idOrName string) (*APIToken, error) {
+    var tok APIToken
+    q := db.Where("name = ?", idOrName)
+    if id, err := strconv.ParseUint(idOrName, 10, 64); err == nil {
+        q = db.Where("id = ? OR name = ?", id, idOrName)
+    }
+    if err := q.Limit(1).Find(&tok).Error; err != nil {
+        return nil, err
+    }
+    if tok.ID == 0 {
+        return nil, ErrTokenNotFound
+    }
+    if tok.RevokedAt == nil {
+        now := time.Now()
+        tok.RevokedAt = &now
+        if err := db.Model(&tok).Update("revoked_at", now).Error; err != nil {
+            return nil, err
+        }
+    }
+    return &tok, nil
+}
+
+// TouchAPIToken records the last use of a token
+func TouchAPIToken(db *gorm.DB, id uint) {
+    _ = db.Model(&APIToken{}).Where("id = ?", id).UpdateColumn("last_used_at", time.Now()).Error
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+    "errors"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestAPIToken_ScopesAndZones(t *testing.T) {
+    tok := APIToken{Scopes: "write,sync", Zones: "example.com,*.corp.example"}
+    if !tok.HasScope(ScopeRead) || !tok.HasScope(ScopeWrite) || !tok.HasScope(ScopeSync) {
+        t.Fatalf("write should imply read, sync granted")
+    }
+    if tok.HasScope(ScopeAdmin) {
+        t.Fatalf("admin not granted")
+    }
+    for zone, want := range map[string]bool{
+        "example.com":          true,
+        "www.example.com":      false,
+        "corp.example.":        true,
+        "team.corp.example":    true,
+        "othercorp.example":    false,
+        "example.org":          false,
+    } {
+        if got := tok.AllowsZone(zone); got != want {
+            t.Errorf("AllowsZone(%q) = %v, want %v", zone, got, want)
+        }
+    }
+    if !(&APIToken{Scopes: "admin"}).HasScope(ScopeSync) {
+        t.Fatalf("admin implies every scope")
+    }
+    if _, err := ParseScopes([]string{"read", "root"}); err == nil {
+        t.Fatalf("unknown scope must be rejected")
+    }
+}
+
+func TestAPIToken_CreateFindRevoke(t *testing.T) {
+    db := newMemDB(t)
+    tok, plain, err := CreateAPIToken(db, "team-tokens", []string{"read"}, []string{"Example.COM."}, nil)
+    if err != nil {
+        t.Fatalf("create: %v", err)
+    }
+    if !strings.HasPrefix(plain, TokenPrefix) || tok.TokenHash == plain || tok.Zones != "example.com" {
+        t.Fatalf("unexpected token: %+v %s", tok, plain)
+    }
+    if !HasActiveAPITokens(db) {
+        t.Fatalf("expected an active token")
+    }
+    found, err := FindAPIToken(db, plain)
+    if err != nil || found.ID != tok.ID {
+        t.Fatalf("find: %v", err)
+    }
+    if _, err := FindAPIToken(db, plain+"x"); !errors.Is(err, ErrTokenNotFound) {
+        t.Fatalf("wrong token must not match, got %v", err)
+    }
+
+    if _, err := RevokeAPIToken(db, "team-tokens"); err != nil {
+        t.Fatalf("revoke: %v", err)
+    }
+    if _, err := FindAPIToken(db, plain); !errors.Is(err, ErrTokenNotFound) {
+        t.Fatalf("revoked token must not authenticate, got %v", err)
+    }
+
+    past := time.Now().Add(-time.Hour)
+    _, expired, err := CreateAPIToken(db, "expired-token", []string{"read"}, nil, &past)
+    if err != nil {
+        t.Fatalf("create expired: %v", err)
+    }
+    if _, err := FindAPIToken(db, expired); !errors.Is(err, ErrTokenNotFound) {
+        t.Fatalf("expired token must not authenticate, got %v", err)
+    }
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base32"
+    "encoding/hex"
+    "errors"
+    "strings"
+    "time"
+
+    "gorm.io/gorm"
+
+    "namedot/internal/totp"
+)
+
+// RecoveryCodeCount is the number of recovery codes issued at a time
+const RecoveryCodeCount = 10
+
+// ErrInvalidCode is returned for wrong, reused or expired second factor codes
+var ErrInvalidCode = errors.New("invalid authentication code")
+
+// BeginTOTPEnrollment returns the pending TOTP secret of a user without
+// two-factor authentication, creating one on first use
+func BeginTOTPEnrollment(db *gorm.DB, userID uint) (string, error) {
+    var u User
+    if err := db.First(&u, userID).Error; err != nil {
+        return "", err
+    }
+    if u.TOTPEnabled {
+        return "", errors.New("two-factor authentication is already enabled")
+    }
+    if u.TOTPSecret != "" {
+        return u.TOTPSecret, nil
+    }
+    secret := totp.GenerateSecret()
+    if err := db.Model(&u).Update("totp_secret", secret).Error; err != nil {
+        return "", err
+    }
+    return secret, nil
+}
+
+// EnableTOTP confirms the pending secret with a code from the authenticator
+// and returns fresh recovery codes
+func EnableTOTP(db *gorm.DB, userID uint, code string, now time.Time) ([]string, error) {
+    var u User
+    if err := db.First(&u, userID).Error; err != nil {
+        return nil, err
+    }
+    if u.TOTPEnabled || u.TOTPSecret == "" {
+        return nil, errors.New("no two-factor enrollment in progress")
+    }
+    step, ok := totp.Validate(u.TOTPSecret, code, now, 0)
+    if !ok {
+        return nil, ErrInvalidCode
+    }
+    var codes []string
+    err := db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Model(&u).Updates(map[string]any{"totp_enabled": true, "totp_last_step": step}).Error; err != nil {
+            return err
+        }
+        var err error
+        codes, err = replaceRecoveryCodes(tx, userID)
+        return err
+    })
+    return codes, err
+}
+
+// DisableTOTP removes the second factor and the recovery codes of a user
+func DisableTOTP(db *gorm.DB, userID uint) error {
+    return db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Model(&User{}).Where("id = ?", userID).
+            Updates(map[string]any{"totp_enabled": false, "totp_secret": "", "totp_last_step": 0}).Error; err != nil {
+            return err
+        }
+        return tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error
+    })
+}
+
+// VerifySecondFactor accepts a current TOTP code or an unused recovery code.
+// Each code is accepted once.
+func VerifySecondFactor(db *gorm.DB, u *User, code string, now time.Time) error {
+    if !u.TOTPEnabled {
+        return ErrInvalidCode
+    }
+    code = strings.TrimSpace(code)
+    if step, ok := totp.Validate(u.TOTPSecret, code, now, u.TOTPLastStep); ok {
+        // Conditional update, so concurrent logins cannot use the same code
+        res := db.Model(&User{}).Where("id = ? AND totp_last_step < ?", u.ID, step).Update("totp_last_step", step)
+        if res.Error != nil {
+            return res.Error
+        }
+        if res.RowsAffected == 1 {
+            u.TOTPLastStep = step
+            return nil
+        }
+        return ErrInvalidCode
+    }
+
+    res := db.Model(&RecoveryCode{}).
+        Where("user_id = ? AND code_hash = ? AND used_at IS NULL", u.ID, recoveryCodeHash(code)).
+        Update("used_at", now)
+    if res.Error != nil {
+        return res.Error
+    }
+    if res.RowsAffected != 1 {
+        return ErrInvalidCode
+    }
+    return nil
+}
+
+// RegenerateRecoveryCodes invalidates the recovery codes of a user and
+// returns new ones
+func RegenerateRecoveryCodes(db *gorm.DB, userID uint) ([]string, error) {
+    var codes []string
+    err := db.Transaction(func(tx *gorm.DB) error {
+        var err error
+        codes, err = replaceRecoveryCodes(tx, userID)
+        return err
+    })
+    return codes, err
+}
+
+// RemainingRecoveryCodes counts the unused recovery codes of a user
+func RemainingRecoveryCodes(db *gorm.DB, userID uint) int64 {
+    var n int64
+    db.Model(&RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&n)
+    return n
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+    if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+        return nil, err
+    }
+    codes := make([]string, RecoveryCodeCount)
+    rows := make([]RecoveryCode, RecoveryCodeCount)
+    for i := range codes {
+        b := make([]byte, 7)
+        if _, err := rand.Read(b); err != nil {
+            return nil, err
+        }
+        raw := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)[:10]
+        codes[i] = raw[:5] + "-" + raw[5:]
+        rows[i] = RecoveryCode{UserID: userID, CodeHash: recoveryCodeHash(codes[i])}
+    }
+    if err := tx.Create(&rows).Error; err != nil {
+        return nil, err
+    }
+    return codes, nil
+}
+
+// recoveryCodeHash ignores case, spaces and dashes, which users tend to mistype
+func recoveryCodeHash(code string) string {
+    code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+    sum := sha256.Sum256([]byte(code))
+    return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+    "errors"
+    "strings"
+    "testing"
+    "time"
+
+    "namedot/internal/totp"
+)
+
+func TestTOTP_EnrollVerifyAndRecover(t *testing.T) {
+    db := newMemDB(t)
+    u, err := CreateUser(db, "totp-user", "correct horse", RoleAdmin)
+    if err != nil { t.Fatalf("create user: %v", err) }
+    now := time.Unix(1700000000, 0)
+
+    secret, err := BeginTOTPEnrollment(db, u.ID)
+    if err != nil { t.Fatalf("begin: %v", err) }
+    if again, _ := BeginTOTPEnrollment(db, u.ID); again != secret {
+        t.Fatalf("pending secret should be reused until confirmed")
+    }
+    if _, err := EnableTOTP(db, u.ID, "000000", now); !errors.Is(err, ErrInvalidCode) {
+        t.Fatalf("wrong code must not enable TOTP, got %v", err)
+    }
+    code, _ := totp.Code(secret, now)
+    codes, err := EnableTOTP(db, u.ID, code, now)
+    if err != nil || len(codes) != RecoveryCodeCount { t.Fatalf("enable: %v %v", err, codes) }
+
+    db.First(&u, u.ID)
+    // The enrollment code is spent
+    if err := VerifySecondFactor(db, &u, code, now); !errors.Is(err, ErrInvalidCode) {
+        t.Fatalf("enrollment code must not be reusable, got %v", err)
+    }
+    next, _ := totp.Code(secret, now.Add(totp.Period))
+    if err := VerifySecondFactor(db, &u, next, now.Add(totp.Period)); err != nil {
+        t.Fatalf("next code: %v", err)
+    }
+
+    // Recovery codes work once, in any case and without the dash
+    rc := strings.ToLower(strings.ReplaceAll(codes[0], "-", ""))
+    if err := VerifySecondFactor(db, &u, rc, now); err != nil { t.Fatalf("recovery code: %v", err) }
+    if err := VerifySecondFactor(db, &u, codes[0], now); !errors.Is(err, ErrInvalidCode) {
+        t.Fatalf("used recovery code must be rejected, got %v", err)
+    }
+    if n := RemainingRecoveryCodes(db, u.ID); n != RecoveryCodeCount-1 { t.Fatalf("remaining = %d", n) }
+
+    fresh, err := RegenerateRecoveryCodes(db, u.ID)
+    if err != nil || len(fresh) != RecoveryCodeCount { t.Fatalf("regenerate: %v", err) }
+    if err := VerifySecondFactor(db, &u, codes[1], now); !errors.Is(err, ErrInvalidCode) {
+        t.Fatalf("old recovery codes must be invalidated")
+    }
+
+    if err := DisableTOTP(db, u.ID); err != nil { t.Fatalf("disable: %v", err) }
+    db.First(&u, u.ID)
+    if u.TOTPEnabled || u.TOTPSecret != "" || RemainingRecoveryCodes(db, u.ID) != 0 {
+        t.Fatalf("disable should clear the second factor: %+v", u)
+    }
+}
@@ -0,0 +1,159 @@
+package db
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+
+    "golang.org/x/crypto/bcrypt"
+    "gorm.io/gorm"
+)
+
+// Web admin roles, from least to most privileged
+const (
+    RoleViewer = "viewer"
+    RoleEditor = "editor"
+    RoleAdmin  = "admin"
+)
+
+// Roles lists the roles in order of privilege
+var Roles = []string{RoleViewer, RoleEditor, RoleAdmin}
+
+// ErrUserNotFound is returned for unknown or disabled users and wrong passwords
+var ErrUserNotFound = errors.New("user not found")
+
+func roleRank(role string) int {
+    for i, r := range Roles {
+        if r == role {
+            return i
+        }
+    }
+    return -1
+}
+
+// ParseRole normalizes role and rejects unknown roles
+func ParseRole(role string) (string, error) {
+    role = strings.ToLower(strings.TrimSpace(role))
+    if roleRank(role) < 0 {
+        return "", fmt.Errorf("unknown role %q (valid: %s)", role, strings.Join(Roles, ", "))
+    }
+    return role, nil
+}
+
+// HasRole reports whether the user has role or a more privileged one
+func (u *User) HasRole(role string) bool {
+    return !u.Disabled && roleRank(u.Role) >= roleRank(role) && roleRank(role) >= 0
+}
+
+// CreateUser stores a user with a bcrypt hash of password
+func CreateUser(db *gorm.DB, username, password, role string) (User, error) {
+    username = strings.TrimSpace(username)
+    if username == "" {
+        return User{}, fmt.Errorf("username is required")
+    }
+    if len(password) < 8 {
+        return User{}, fmt.Errorf("password must be at least 8 characters")
+    }
+    role, err := ParseRole(role)
+    if err != nil {
+        return User{}, err
+    }
+    hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    if err != nil {
+        return User{}, err
+    }
+    u := User{Username: username, PasswordHash: string(hash), Role: role}
+    if err := db.Create(&u).Error; err != nil {
+        return User{}, err
+    }
+    return u, nil
+}
+
+// SetUserPassword replaces the password of the user with id
+func SetUserPassword(db *gorm.DB, id uint, password string) error {
+    if len(password) < 8 {
+        return fmt.Errorf("password must be at least 8 characters")
+    }
+    hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    if err != nil {
+        return err
+    }
+    return db.Model(&User{}).Where("id = ?", id).Update("password_hash", string(hash)).Error
+}
+
+// AuthenticateUser returns the enabled user matching username and password
+func AuthenticateUser(db *gorm.DB, username, password string) (*User, error) {
+    var u User
+    if err := db.Where("username = ?", username).Limit(1).Find(&u).Error; err != nil {
+        return nil, err
+    }
+    if u.ID == 0 || u.Disabled || u.Source != "" {
+        return nil, ErrUserNotFound
+    }
+    if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
+        return nil, ErrUserNotFound
+    }
+    return &u, nil
+}
+
+// SyncExternalUser creates or updates a user signed in by an external
+// identity provider. The provider decides the role on every login; local
+// accounts with the same name are never taken over.
+func SyncExternalUser(db *gorm.DB, source, username, role string) (*User, error) {
+    role, err := ParseRole(role)
+    if err != nil {
+        return nil, err
+    }
+    var u User
+    if err := db.Where("username = ?", username).Limit(1).Find(&u).Error; err != nil {
+        return nil, err
+    }
+    if u.ID == 0 {
+        u = User{Username: username, Role: role, Source: source}
+        if err := db.Create(&u).Error; err != nil {
+            return nil, err
+        }
+        return &u, nil
+    }
+    if u.Source != source {
+        return nil, fmt.Errorf("user %q is not managed by %s", username, source)
+    }
+    if u.Disabled {
+        return nil, ErrUserNotFound
+    }
+    if u.Role != role {
+        if err := db.Model(&u).Update("role", role).Error; err != nil {
+            return nil, err
+        }
+    }
+    return &u, nil
+}
+
+// OwnedZoneIDs returns a subquery selecting the IDs of the zones owned by userID
+func OwnedZoneIDs(db *gorm.DB, userID uint) *gorm.DB {
+    return db.Table("user_zones").Select("zone_id").Where("user_id = ?", userID)
+}
+
+// OwnsZone reports whether userID owns zoneID
+func OwnsZone(db *gorm.DB, userID, zoneID uint) bool {
+    var count int64
+    err := db.Table("user_zones").Where("user_id = ? AND zone_id = ?", userID, zoneID).Count(&count).Error
+    return err == nil && count > 0
+}
+
+// GrantZone makes userID an owner of zoneID
+func GrantZone(db *gorm.DB, userID, zoneID uint) error {
+    if OwnsZone(db, userID, zoneID) {
+        return nil
+    }
+    return db.Model(&User{ID: userID}).Association("Zones").Append(&Zone{ID: zoneID})
+}
+
+// SetUserZones replaces the zones owned by userID
+func SetUserZones(db *gorm.DB, userID uint, zoneIDs []uint) error {
+    zones := make([]Zone, 0, len(zoneIDs))
+    for _, id := range zoneIDs {
+        zones = append(zones, Zone{ID: id})
+    }
+    return db.Model(&User{ID: userID}).Association("Zones").Replace(zones)
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+    "errors"
+    "testing"
+)
+
+func TestUser_RolesAndZoneOwnership(t *testing.T) {
+    db := newMemDB(t)
+    if _, err := CreateUser(db, "ed-owner", "short", RoleEditor); err == nil {
+        t.Fatalf("short password must be rejected")
+    }
+    if _, err := CreateUser(db, "ed-owner", "correct horse", "root"); err == nil {
+        t.Fatalf("unknown role must be rejected")
+    }
+    u, err := CreateUser(db, "ed-owner", "correct horse", " Editor ")
+    if err != nil { t.Fatalf("create user: %v", err) }
+    if u.Role != RoleEditor || !u.HasRole(RoleViewer) || !u.HasRole(RoleEditor) || u.HasRole(RoleAdmin) {
+        t.Fatalf("unexpected role checks for %+v", u)
+    }
+
+    if _, err := AuthenticateUser(db, "ed-owner", "wrong password"); !errors.Is(err, ErrUserNotFound) {
+        t.Fatalf("wrong password must fail, got %v", err)
+    }
+    got, err := AuthenticateUser(db, "ed-owner", "correct horse")
+    if err != nil || got.ID != u.ID { t.Fatalf("authenticate: %v %+v", err, got) }
+
+    z1 := Zone{Name: "owned-one.test"}
+    z2 := Zone{Name: "owned-two.test"}
+    db.Create(&z1)
+    db.Create(&z2)
+    if err := GrantZone(db, u.ID, z1.ID); err != nil { t.Fatalf("grant: %v", err) }
+    if err := GrantZone(db, u.ID, z1.ID); err != nil { t.Fatalf("grant twice: %v", err) }
+    if !OwnsZone(db, u.ID, z1.ID) || OwnsZone(db, u.ID, z2.ID) {
+        t.Fatalf("ownership mismatch after grant")
+    }
+    if err := SetUserZones(db, u.ID, []uint{z2.ID}); err != nil { t.Fatalf("set zones: %v", err) }
+    var ids []uint
+    db.Model(&Zone{}).Where("id IN (?)", OwnedZoneIDs(db, u.ID)).Pluck("id", &ids)
+    if len(ids) != 1 || ids[0] != z2.ID { t.Fatalf("owned zones = %v, want [%d]", ids, z2.ID) }
+    if err := SetUserZones(db, u.ID, nil); err != nil { t.Fatalf("clear zones: %v", err) }
+    if OwnsZone(db, u.ID, z2.ID) { t.Fatalf("zones should be cleared") }
+
+    db.Model(&User{}).Where("id = ?", u.ID).Update("disabled", true)
+    if _, err := AuthenticateUser(db, "ed-owner", "correct horse"); !errors.Is(err, ErrUserNotFound) {
+        t.Fatalf("disabled user must not log in, got %v", err)
+    }
+}
@@ -0,0 +1,221 @@
+package db
+
+import (
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+
+    "gorm.io/gorm"
+)
+
+// MaxZoneVersions is how many versions are kept per zone; older ones are pruned
+const MaxZoneVersions = 100
+
+// Kinds of RRSetChange
+const (
+    ChangeAdded   = "added"
+    ChangeRemoved = "removed"
+    ChangeChanged = "changed"
+)
+
+// RRSetChange is one difference between two versions of a zone
+type RRSetChange struct {
+    Name   string `json:"name"`
+    Type   string `json:"type"`
+    Change string `json:"change"` // added, removed or changed
+    Before *RRSet `json:"before,omitempty"`
+    After  *RRSet `json:"after,omitempty"`
+}
+
+// RRSets decodes the RRSets stored in the version
+func (v ZoneVersion) RRSets() ([]RRSet, error) {
+    var sets []RRSet
+    if v.Data == "" {
+        return sets, nil
+    }
+    if err := json.Unmarshal([]byte(v.Data), &sets); err != nil {
+        return nil, fmt.Errorf("decode version %d: %w", v.ID, err)
+    }
+    return sets, nil
+}
+
+// RecordVersion stores the current RRSets of a zone as a new version. Without
+// a note nothing is stored when they equal the latest version.
+func RecordVersion(db *gorm.DB, zoneID uint, note string) error {
+    _, err := recordVersion(db, zoneID, note)
+    return err
+}
+
+func recordVersion(db *gorm.DB, zoneID uint, note string) (*ZoneVersion, error) {
+    sets := ZoneSnapshot(db, zoneID)
+    if sets == nil {
+        sets = []RRSet{}
+    }
+    var last ZoneVersion
+    if err := db.Where("zone_id = ?", zoneID).Order("id DESC").Limit(1).Find(&last).Error; err != nil {
+        return nil, err
+    }
+    if last.ID != 0 && note == "" {
+        if prev, err := last.RRSets(); err == nil && SameRRSets(prev, sets) {
+            return &last, nil
+        }
+    }
+    data, err := json.Marshal(sets)
+    if err != nil {
+        return nil, err
+    }
+    v := ZoneVersion{ZoneID: zoneID, Serial: serialOf(sets), RRSetCount: len(sets), Note: note, Data: string(data)}
+    if err := db.Create(&v).Error; err != nil {
+        return nil, err
+    }
+
+    // Drop what falls out of the retained window
+    var cutoff ZoneVersion
+    if err := db.Where("zone_id = ?", zoneID).Order("id DESC").Offset(MaxZoneVersions).Limit(1).Find(&cutoff).Error; err != nil {
+        return nil, err
+    }
+    if cutoff.ID != 0 {
+        if err := db.Where("zone_id = ? AND id <= ?", zoneID, cutoff.ID).Delete(&ZoneVersion{}).Error; err != nil {
+            return nil, err
+        }
+    }
+    return &v, nil
+}
+
+// ListVersions returns the versions of a zone, newest first, and their total count
+func ListVersions(db *gorm.DB, zoneID uint, limit, offset int) ([]ZoneVersion, int64, error) {
+    var total int64
+    if err := db.Model(&ZoneVersion{}).Where("zone_id = ?", zoneID).Count(&total).Error; err != nil {
+        return nil, 0, err
+    }
+    if limit <= 0 || limit > MaxZoneVersions {
+        limit = MaxZoneVersions
+    }
+    var versions []ZoneVersion
+    err := db.Where("zone_id = ?", zoneID).Order("id DESC").Limit(limit).Offset(offset).Find(&versions).Error
+    return versions, total, err
+}
+
+// GetVersion loads one version of a zone; gorm.ErrRecordNotFound when it
+// does not exist or belongs to another zone
+func GetVersion(db *gorm.DB, zoneID, id uint) (ZoneVersion, error) {
+    var v ZoneVersion
+    err := db.Where("zone_id = ?", zoneID).First(&v, id).Error
+    return v, err
+}
+
+// DiffRRSets lists the RRSets added, removed or changed from one state of a
+// zone to another, ordered by name and type
+func DiffRRSets(from, to []RRSet) []RRSetChange {
+    key := func(rs RRSet) string { return strings.ToLower(rs.Name) + " " + rs.Type }
+    old := map[string]RRSet{}
+    for _, rs := range from {
+        old[key(rs)] = rs
+    }
+    changes := []RRSetChange{}
+    for _, rs := range to {
+        after := rs
+        prev, ok := old[key(rs)]
+        delete(old, key(rs))
+        switch {
+        case !ok:
+            changes = append(changes, RRSetChange{Name: rs.Name, Type: rs.Type, Change: ChangeAdded, After: &after})
+        case !SameRRSets([]RRSet{prev}, []RRSet{rs}):
+            changes = append(changes, RRSetChange{Name: rs.Name, Type: rs.Type, Change: ChangeChanged, Before: &prev, After: &after})
+        }
+    }
+    for _, rs := range old {
+        before := rs
+        changes = append(changes, RRSetChange{Name: rs.Name, Type: rs.Type, Change: ChangeRemoved, Before: &before})
+    }
+    sort.Slice(changes, func(i, j int) bool {
+        if changes[i].Name != changes[j].Name {
+            return changes[i].Name < changes[j].Name
+        }
+        return changes[i].Type < changes[j].Type
+    })
+    return changes
+}
+
+// RollbackZone replaces the RRSets of a zone with those of a version. The SOA
+// serial moves past both the current and the restored serial so secondaries
+// pick up the change, and the result is recorded as a new version.
+func RollbackZone(db *gorm.DB, zone Zone, versionID uint, serials SerialManager) (ZoneVersion, error) {
+    var created ZoneVersion
+    err := db.Transaction(func(tx *gorm.DB) error {
+        v, err := GetVersion(tx, zone.ID, versionID)
+        if err != nil {
+            return err
+        }
+        sets, err := v.RRSets()
+        if err != nil {
+            return err
+        }
+        current := serialOf(ZoneSnapshot(tx, zone.ID))
+
+        // Hard delete; soft-deleted rows would collide with the unique index
+        var ids []uint
+        if err := tx.Unscoped().Model(&RRSet{}).Where("zone_id = ?", zone.ID).Pluck("id", &ids).Error; err != nil {
+            return err
+        }
+        if len(ids) > 0 {
+            if err := tx.Unscoped().Where("rr_set_id IN ?", ids).Delete(&RData{}).Error; err != nil {
+                return err
+            }
+        }
+        if err := tx.Unscoped().Where("zone_id = ?", zone.ID).Delete(&RRSet{}).Error; err != nil {
+            return err
+        }
+
+        for _, rs := range sets {
+            restored := copyRRSet(zone.ID, rs)
+            if err := tx.Create(&restored).Error; err != nil {
+                return fmt.Errorf("restore %s %s: %w", rs.Name, rs.Type, err)
+            }
+        }
+        floor := serialOf(sets)
+        if SerialLess(floor, current) {
+            floor = current
+        }
+        // A restored version without SOA stays without one
+        serials.AutoSOA = false
+        if err := serials.advance(tx, zone, floor); err != nil {
+            return err
+        }
+
+        nv, err := recordVersion(tx, zone.ID, fmt.Sprintf("rollback to version %d", v.ID))
+        if err != nil {
+            return err
+        }
+        created = *nv
+        return nil
+    })
+    return created, err
+}
+
+// copyRRSet copies an RRSet and its records into a zone without IDs or timestamps
+func copyRRSet(zoneID uint, rs RRSet) RRSet {
+    out := RRSet{ZoneID: zoneID, Name: rs.Name, Type: rs.Type, TTL: rs.TTL}
+    for _, r := range rs.Records {
+        out.Records = append(out.Records, RData{Data: r.Data, Country: r.Country,
+            Continent: r.Continent, ASN: r.ASN, Subnet: r.Subnet, AutoPTR: r.AutoPTR})
+    }
+    return out
+}
+
+// serialOf returns the SOA serial found in a list of RRSets, 0 without SOA
+func serialOf(sets []RRSet) uint32 {
+    for _, rs := range sets {
+        if rs.Type != "SOA" || len(rs.Records) == 0 {
+            continue
+        }
+        if parts := strings.Fields(rs.Records[0].Data); len(parts) >= 7 {
+            if n, err := strconv.ParseUint(parts[2], 10, 32); err == nil {
+                return uint32(n)
+            }
+        }
+    }
+    return 0
+}
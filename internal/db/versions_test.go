@@ -0,0 +1,54 @@
+package db
+
+import (
+    "fmt"
+    "testing"
+)
+
+func TestVersions_DiffAndRollback(t *testing.T) {
+    db := newMemDB(t)
+    z := Zone{Name: "versions.test."}
+    if err := db.Create(&z).Error; err != nil { t.Fatalf("create zone: %v", err) }
+    soa := RRSet{ZoneID: z.ID, Name: "versions.test.", Type: "SOA", TTL: 3600,
+        Records: []RData{{Data: "ns1.versions.test. hostmaster.versions.test. 100 7200 3600 1209600 300"}}}
+    www := RRSet{ZoneID: z.ID, Name: "www.versions.test.", Type: "A", TTL: 60, Records: []RData{{Data: "192.0.2.1"}}}
+    db.Create(&soa)
+    db.Create(&www)
+    SerialManager{}.Bump(db, z)
+
+    // A bad edit: www changes, api is added, then www is deleted
+    db.Model(&RData{}).Where("rr_set_id = ?", www.ID).Update("data", "198.51.100.1")
+    db.Create(&RRSet{ZoneID: z.ID, Name: "api.versions.test.", Type: "A", TTL: 60, Records: []RData{{Data: "192.0.2.9"}}})
+    SerialManager{}.Bump(db, z)
+    db.Delete(&RRSet{}, www.ID) // soft delete stays in the table
+    SerialManager{}.Bump(db, z)
+
+    versions, total, err := ListVersions(db, z.ID, 0, 0)
+    if err != nil || total != 3 { t.Fatalf("want 3 versions, got %d (%v)", total, err) }
+    first := versions[2]
+    if first.Serial != 101 || first.RRSetCount != 2 { t.Fatalf("unexpected first version %+v", first) }
+
+    from, _ := first.RRSets()
+    to, _ := versions[0].RRSets()
+    changes := DiffRRSets(from, to)
+    kinds := map[string]string{}
+    for _, ch := range changes { kinds[ch.Name+" "+ch.Type] = ch.Change }
+    if len(changes) != 3 || kinds["api.versions.test. A"] != ChangeAdded || kinds["www.versions.test. A"] != ChangeRemoved ||
+        kinds["versions.test. SOA"] != ChangeChanged {
+        t.Fatalf("unexpected diff %v", kinds)
+    }
+
+    // A bump alone changes the serial; an unchanged zone records nothing
+    SerialManager{}.Bump(db, z)
+    if _, n, _ := ListVersions(db, z.ID, 0, 0); n != 4 { t.Fatalf("want 4 versions, got %d", n) }
+    RecordVersion(db, z.ID, "")
+    if _, n, _ := ListVersions(db, z.ID, 0, 0); n != 4 { t.Fatalf("identical state must not add a version, got %d", n) }
+
+    created, err := RollbackZone(db, z, first.ID, SerialManager{})
+    if err != nil { t.Fatalf("rollback: %v", err) }
+    if created.Serial != 105 || created.Note != fmt.Sprintf("rollback to version %d", first.ID) { t.Fatalf("unexpected rollback version %+v", created) }
+    restored := ZoneSnapshot(db, z.ID)
+    if len(DiffRRSets(from, restored)) != 1 { t.Fatalf("only the serial may differ after rollback: %+v", DiffRRSets(from, restored)) }
+
+    if _, err := RollbackZone(db, Zone{ID: z.ID + 1}, first.ID, SerialManager{}); err == nil { t.Fatalf("versions of other zones must not be restored") }
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+    "sort"
+    "strings"
+
+    "gorm.io/gorm"
+)
+
+// Zone kinds. Only the kind is kept; every kind is served from the database.
+const (
+    ZoneKindPrimary   = "primary"
+    ZoneKindSecondary = "secondary"
+    ZoneKindForward   = "forward"
+)
+
+// ZoneKinds lists the valid values of Zone.Kind
+var ZoneKinds = []string{ZoneKindPrimary, ZoneKindSecondary, ZoneKindForward}
+
+// TagList returns the tags of the zone
+func (z Zone) TagList() []string {
+    if z.Tags == "" {
+        return nil
+    }
+    return strings.Split(z.Tags, ",")
+}
+
+// JoinTags normalizes tags for Zone.Tags: trimmed, lowercased, without
+// duplicates and sorted
+func JoinTags(tags []string) string {
+    seen := map[string]bool{}
+    out := make([]string, 0, len(tags))
+    for _, t := range tags {
+        t = strings.ToLower(strings.TrimSpace(t))
+        if t == "" || seen[t] {
+            continue
+        }
+        seen[t] = true
+        out = append(out, t)
+    }
+    sort.Strings(out)
+    return strings.Join(out, ",")
+}
+
+// TTLDefault returns the TTL for new RRSets of the zone that come without
+// one: the default TTL of the zone, else fallback (default_ttl of the config)
+func (z Zone) TTLDefault(fallback uint32) uint32 {
+    if z.DefaultTTL > 0 {
+        return z.DefaultTTL
+    }
+    return fallback
+}
+
+// SetMeta copies the metadata of m to z; name, view and RRSets are kept. An
+// empty kind, as sent by older versions, means primary.
+func (z *Zone) SetMeta(m Zone) {
+    z.Kind = m.Kind
+    if z.Kind == "" {
+        z.Kind = ZoneKindPrimary
+    }
+    z.Description = m.Description
+    z.Owner = m.Owner
+    z.Tags = m.Tags
+    z.DefaultTTL = m.DefaultTTL
+    z.SOA = m.SOA
+    z.SerialPolicy = m.SerialPolicy
+    z.Disabled = m.Disabled
+}
+
+// UpdateZoneMeta stores the metadata of z. Zero values are written too, so a
+// description can be cleared or a zone enabled again.
+func UpdateZoneMeta(db *gorm.DB, z *Zone) error {
+    return db.Model(z).Select("kind", "description", "owner", "tags", "default_ttl",
+        "soa_primary", "soa_hostmaster", "soa_refresh", "soa_retry", "soa_expire", "soa_minimum", "soa_ttl",
+        "serial_policy", "disabled").Updates(z).Error
+}
+
+// tagCond matches a comma separated col containing tag
+func tagCond(db *gorm.DB, col, tag string) *gorm.DB {
+    tag = strings.ToLower(strings.TrimSpace(tag))
+    return db.Where(col+" = ?", tag).
+        Or(col+" LIKE ?", tag+",%").
+        Or(col+" LIKE ?", "%,"+tag).
+        Or(col+" LIKE ?", "%,"+tag+",%")
+}
@@ -0,0 +1,79 @@
+// Package idn converts internationalized domain names between the Unicode
+// form users type and read (пример.рф) and the ASCII form (xn--e1afmkfd.xn--p1ai)
+// that is stored and sent on the wire. Conversion follows IDNA2008 as
+// profiled by UTS #46 without transitional mappings.
+package idn
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// lookup maps and validates labels the way resolvers and browsers do;
+// underscores and "*" stay legal so service names and wildcards pass
+var lookup = idna.New(
+	idna.MapForLookup(),
+	idna.Transitional(false),
+	idna.BidiRule(),
+	idna.StrictDomainName(false),
+)
+
+// ToASCII lowercases name and converts its Unicode labels to punycode. ASCII
+// labels are kept as they are, as is a trailing dot.
+func ToASCII(name string) (string, error) {
+	if isASCII(name) {
+		return strings.ToLower(name), nil
+	}
+	labels := strings.Split(name, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			labels[i] = strings.ToLower(label)
+			continue
+		}
+		a, err := lookup.ToASCII(label)
+		if err != nil {
+			return "", fmt.Errorf("invalid internationalized label %q: %v", label, err)
+		}
+		labels[i] = a
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// ToUnicode converts the punycode labels of name for display. Labels that do
+// not decode are kept as they are.
+func ToUnicode(name string) string {
+	if !strings.Contains(name, "xn--") && !strings.Contains(name, "XN--") {
+		return name
+	}
+	labels := strings.Split(name, ".")
+	for i, label := range labels {
+		if len(label) > 4 && strings.EqualFold(label[:4], "xn--") {
+			if u, err := lookup.ToUnicode(label); err == nil {
+				labels[i] = u
+			}
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// Forms returns the lowercased term and, when it differs, its ASCII form, so
+// a search matches names typed in either form
+func Forms(term string) []string {
+	t := strings.ToLower(term)
+	forms := []string{t}
+	if a, err := ToASCII(t); err == nil && a != t {
+		forms = append(forms, a)
+	}
+	return forms
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,43 @@
+package idn
+
+import "testing"
+
+func TestToASCII(t *testing.T) {
+	tests := map[string]string{
+		"Пример.РФ.":          "xn--e1afmkfd.xn--p1ai.",
+		"www.Bücher.example":  "www.xn--bcher-kva.example",
+		"*.пример.рф":         "*.xn--e1afmkfd.xn--p1ai",
+		"_sip._tcp.пример.рф": "_sip._tcp.xn--e1afmkfd.xn--p1ai",
+		"WWW.Example.COM":     "www.example.com",
+	}
+	for in, want := range tests {
+		if got, err := ToASCII(in); err != nil || got != want {
+			t.Errorf("ToASCII(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ToASCII("a‍.рф"); err == nil {
+		t.Errorf("expected a joiner outside its context to fail")
+	}
+}
+
+func TestToUnicode(t *testing.T) {
+	tests := map[string]string{
+		"xn--e1afmkfd.xn--p1ai.": "пример.рф.",
+		"www.example.com":        "www.example.com",
+		"xn--zz.example":         "xn--zz.example",
+	}
+	for in, want := range tests {
+		if got := ToUnicode(in); got != want {
+			t.Errorf("ToUnicode(%q) = %q; want %q", in, got, want)
+		}
+	}
+}
+
+func TestForms(t *testing.T) {
+	if f := Forms("Пример"); len(f) != 2 || f[0] != "пример" || f[1] != "xn--e1afmkfd" {
+		t.Errorf("Forms: got %v", f)
+	}
+	if f := Forms("example"); len(f) != 1 {
+		t.Errorf("Forms of an ASCII term: got %v", f)
+	}
+}
@@ -41,7 +41,7 @@ func TestGeoDNS_WithECS_USCountry(t *testing.T) {
     tmpDB := filepath.Join(t.TempDir(), "geo_integration.db")
     cfg := &config.Config{
         Listen:           dnsAddr,
-        Forwarder:        "",
+        Forwarder:        nil,
         EnableDNSSEC:     false,
         APIToken:         "devtoken",
         RESTListen:       restAddr,
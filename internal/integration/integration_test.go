@@ -41,7 +41,7 @@ func TestEndToEnd_DNS_and_REST(t *testing.T) {
     tmpDB := filepath.Join(t.TempDir(), "integration_e2e.db")
     cfg := &config.Config{
         Listen:           dnsAddr,
-        Forwarder:        "",
+        Forwarder:        nil,
         EnableDNSSEC:     false,
         APIToken:         "devtoken",
         RESTListen:       restAddr,
@@ -0,0 +1,103 @@
+// Package metrics keeps process counters and renders them in the
+// Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	registryMu sync.Mutex
+	registry   []*CounterVec
+)
+
+// CounterVec is a monotonically increasing counter partitioned by labels
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]uint64 // label values joined by \xff
+}
+
+// NewCounterVec creates and registers a counter
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{name: name, help: help, labels: labels, values: map[string]uint64{}}
+	registryMu.Lock()
+	registry = append(registry, v)
+	registryMu.Unlock()
+	return v
+}
+
+// Inc adds one to the counter with the given label values
+func (v *CounterVec) Inc(values ...string) {
+	v.Add(1, values...)
+}
+
+// Add adds n to the counter with the given label values
+func (v *CounterVec) Add(n uint64, values ...string) {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.Lock()
+	v.values[key] += n
+	v.mu.Unlock()
+}
+
+// Value returns the counter with the given label values
+func (v *CounterVec) Value(values ...string) uint64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.values[strings.Join(values, "\xff")]
+}
+
+func (v *CounterVec) write(w io.Writer) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", v.name, v.help, v.name)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s%s %d\n", v.name, v.labelString(k), v.values[k])
+	}
+	v.mu.Unlock()
+}
+
+func (v *CounterVec) labelString(key string) string {
+	if len(v.labels) == 0 {
+		return ""
+	}
+	values := strings.Split(key, "\xff")
+	parts := make([]string, len(v.labels))
+	for i, l := range v.labels {
+		parts[i] = fmt.Sprintf("%s=%q", l, values[i])
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// WriteText writes all registered metrics in the Prometheus text format
+func WriteText(w io.Writer) {
+	registryMu.Lock()
+	all := append([]*CounterVec(nil), registry...)
+	registryMu.Unlock()
+	for _, v := range all {
+		v.write(w)
+	}
+}
+
+// Handler serves the registered metrics
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteText(w)
+	})
+}
@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCounterVec_WriteText(t *testing.T) {
+	v := NewCounterVec("namedot_test_total", "Test counter.", "source", "result")
+	v.Inc("web", "ok")
+	v.Add(2, "rest", "fail")
+	v.Inc("web", "ok")
+
+	if got := v.Value("web", "ok"); got != 2 {
+		t.Fatalf("value: want 2, got %d", got)
+	}
+
+	var b strings.Builder
+	WriteText(&b)
+	out := b.String()
+	for _, want := range []string{
+		"# HELP namedot_test_total Test counter.\n# TYPE namedot_test_total counter\n",
+		`namedot_test_total{source="rest",result="fail"} 2` + "\n",
+		`namedot_test_total{source="web",result="ok"} 2` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+}
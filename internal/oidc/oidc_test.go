@@ -0,0 +1,64 @@
+package oidc_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"namedot/internal/oidc"
+	"namedot/internal/oidc/oidctest"
+)
+
+func TestVerify_ChecksSignatureAndClaims(t *testing.T) {
+	idp := oidctest.New("client", "", nil)
+	defer idp.Close()
+	ctx := context.Background()
+	p, err := oidc.Discover(ctx, oidc.Config{Issuer: idp.URL, ClientID: "client", RedirectURL: "http://rp.test/cb"}, nil)
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+
+	valid := func() map[string]any {
+		now := time.Now()
+		return map[string]any{
+			"iss": idp.URL, "aud": []string{"client"}, "sub": "u-1", "nonce": "n-1",
+			"iat": now.Unix(), "exp": now.Add(time.Minute).Unix(), "groups": []string{"a", "b"},
+		}
+	}
+	claims, err := p.Verify(ctx, idp.Sign(valid()), "n-1")
+	if err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+	if claims.String("sub") != "u-1" || strings.Join(claims.Strings("groups"), ",") != "a,b" {
+		t.Fatalf("unexpected claims %v", claims)
+	}
+
+	for name, tc := range map[string]struct {
+		mutate func(map[string]any)
+		nonce  string
+		want   string
+	}{
+		"wrong audience": {func(c map[string]any) { c["aud"] = "other" }, "n-1", "not issued for this client"},
+		"wrong issuer":   {func(c map[string]any) { c["iss"] = "https://evil.test" }, "n-1", "unexpected issuer"},
+		"expired":        {func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, "n-1", "expired"},
+		"nonce mismatch": {func(c map[string]any) {}, "n-2", "nonce mismatch"},
+	} {
+		c := valid()
+		tc.mutate(c)
+		if _, err := p.Verify(ctx, idp.Sign(c), tc.nonce); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got %v, want %q", name, err, tc.want)
+		}
+	}
+
+	// Tampering with the payload breaks the signature
+	parts := strings.Split(idp.Sign(valid()), ".")
+	forged := strings.Split(idp.Sign(map[string]any{"sub": "admin"}), ".")
+	if _, err := p.Verify(ctx, parts[0]+"."+forged[1]+"."+parts[2], "n-1"); err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Fatalf("tampered token accepted: %v", err)
+	}
+	// Unsigned tokens are never accepted
+	if _, err := p.Verify(ctx, "eyJhbGciOiJub25lIn0."+parts[1]+".", "n-1"); err == nil {
+		t.Fatalf("alg=none token accepted")
+	}
+}
@@ -0,0 +1,256 @@
+// Package rdata validates record data at write time and converts it to the
+// canonical presentation form stored in the database.
+package rdata
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// TypeALIAS is the synthetic apex alias type; its data is a single host name.
+const TypeALIAS = "ALIAS"
+
+// Error describes record data rejected by Canonical.
+type Error struct {
+	Type   string
+	Data   string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid %s record %q: %s", e.Type, e.Data, e.Reason)
+}
+
+// Types that can never be stored in a zone
+var metaTypes = map[uint16]bool{
+	dns.TypeNone: true, dns.TypeOPT: true, dns.TypeTSIG: true, dns.TypeTKEY: true,
+	dns.TypeANY: true, dns.TypeAXFR: true, dns.TypeIXFR: true, dns.TypeMAILA: true,
+	dns.TypeMAILB: true,
+}
+
+// Supported reports whether records of rtype can be stored.
+func Supported(rtype string) bool {
+	rtype = strings.ToUpper(strings.TrimSpace(rtype))
+	if rtype == TypeALIAS {
+		return true
+	}
+	t, ok := dns.StringToType[rtype]
+	return ok && !metaTypes[t]
+}
+
+// Canonical validates data as the RDATA of an rtype record and returns it in
+// canonical form. Names in data are absolute even without a trailing dot; a
+// data of "@" for single-name types (CNAME, NS, PTR, DNAME) means the zone
+// apex. Unquoted TXT/SPF data is stored as quoted character-strings.
+func Canonical(rtype, data, zone string) (string, error) {
+	rtype = strings.ToUpper(strings.TrimSpace(rtype))
+	rr, err := Compile(rtype, data, zone)
+	if err != nil {
+		return "", err
+	}
+	if err := check(rr); err != nil {
+		return "", &Error{Type: rtype, Data: strings.TrimSpace(data), Reason: err.Error()}
+	}
+	out := strings.TrimPrefix(rr.String(), rr.Header().String())
+	if rtype == TypeALIAS {
+		out = strings.ToLower(out)
+	}
+	return out, nil
+}
+
+// Compile parses data into an RR owned by the root with TTL 0; callers copy
+// it and set the owner name and TTL. ALIAS data compiles to a CNAME holding
+// the target. Unlike Canonical it applies no semantic checks, so rows stored
+// before validation existed keep being served.
+func Compile(rtype, data, zone string) (dns.RR, error) {
+	rtype = strings.ToUpper(strings.TrimSpace(rtype))
+	data = strings.TrimSpace(data)
+	fail := func(format string, a ...any) (dns.RR, error) {
+		return nil, &Error{Type: rtype, Data: data, Reason: fmt.Sprintf(format, a...)}
+	}
+	if !Supported(rtype) {
+		return fail("unsupported record type")
+	}
+	if data == "" {
+		return fail("data is empty")
+	}
+	if strings.ContainsAny(data, "\r\n") {
+		return fail("data must be a single line")
+	}
+
+	parseType := rtype
+	raw := data
+	switch rtype {
+	case TypeALIAS:
+		if data == "@" || data == "." {
+			return fail("target must be a host name")
+		}
+		parseType = "CNAME"
+	case "CNAME", "NS", "PTR", "DNAME":
+		if data == "@" {
+			raw = dns.Fqdn(strings.ToLower(zone))
+		}
+	case "TXT", "SPF":
+		if !strings.HasPrefix(data, `"`) {
+			raw = quoteText(data)
+		}
+	}
+
+	rr, err := parse(parseType, raw)
+	if err != nil {
+		return fail("%s", err.Error())
+	}
+	rr.Header().Ttl = 0
+	return rr, nil
+}
+
+// parse reads a single record of rtype, relative names resolve against the root
+func parse(rtype, data string) (dns.RR, error) {
+	zp := dns.NewZoneParser(strings.NewReader(fmt.Sprintf(". 3600 IN %s %s", rtype, data)), ".", "")
+	rr, ok := zp.Next()
+	if err := zp.Err(); err != nil {
+		return nil, stripParserNoise(err)
+	}
+	if !ok || rr == nil {
+		return nil, fmt.Errorf("no record data")
+	}
+	if _, more := zp.Next(); more {
+		return nil, fmt.Errorf("more than one record")
+	}
+	return rr, nil
+}
+
+// stripParserNoise drops the synthetic line/column suffix of zone parser errors
+func stripParserNoise(err error) error {
+	msg := strings.TrimPrefix(err.Error(), "dns: ")
+	if i := strings.Index(msg, " at line:"); i > 0 {
+		msg = msg[:i]
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// quoteText splits free text into quoted character-strings of at most 255 bytes
+func quoteText(s string) string {
+	var parts []string
+	for len(s) > 0 || len(parts) == 0 {
+		n := len(s)
+		if n > 255 {
+			n = 255
+		}
+		chunk := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s[:n])
+		parts = append(parts, `"`+chunk+`"`)
+		s = s[n:]
+	}
+	return strings.Join(parts, " ")
+}
+
+var (
+	caaTag     = regexp.MustCompile(`^[a-z0-9]+$`)
+	naptrFlags = regexp.MustCompile(`^[A-Za-z0-9]*$`)
+)
+
+// check applies semantic rules the zone parser does not enforce and
+// normalizes case where the RFCs make it insignificant.
+func check(rr dns.RR) error {
+	switch v := rr.(type) {
+	case *dns.CAA:
+		if v.Flag != 0 && v.Flag != 128 {
+			return fmt.Errorf("flags must be 0 or 128 (critical)")
+		}
+		v.Tag = strings.ToLower(v.Tag)
+		if !caaTag.MatchString(v.Tag) {
+			return fmt.Errorf("tag must be alphanumeric")
+		}
+		if v.Tag == "iodef" {
+			if !strings.HasPrefix(v.Value, "mailto:") && !strings.HasPrefix(v.Value, "https://") && !strings.HasPrefix(v.Value, "http://") {
+				return fmt.Errorf("iodef value must be a mailto: or http(s):// URL")
+			}
+		}
+	case *dns.TLSA:
+		if v.Usage > 3 {
+			return fmt.Errorf("usage must be 0-3")
+		}
+		if v.Selector > 1 {
+			return fmt.Errorf("selector must be 0 or 1")
+		}
+		if v.MatchingType > 2 {
+			return fmt.Errorf("matching type must be 0-2")
+		}
+		cert, err := checkHex(v.Certificate, map[uint8]int{1: 32, 2: 64}[v.MatchingType])
+		if err != nil {
+			return fmt.Errorf("certificate association data: %w", err)
+		}
+		v.Certificate = cert
+	case *dns.SSHFP:
+		switch v.Algorithm {
+		case 1, 2, 3, 4, 6:
+		default:
+			return fmt.Errorf("algorithm must be 1 (RSA), 2 (DSA), 3 (ECDSA), 4 (Ed25519) or 6 (Ed448)")
+		}
+		size := map[uint8]int{1: 20, 2: 32}[v.Type]
+		if size == 0 {
+			return fmt.Errorf("fingerprint type must be 1 (SHA-1) or 2 (SHA-256)")
+		}
+		fp, err := checkHex(v.FingerPrint, size)
+		if err != nil {
+			return fmt.Errorf("fingerprint: %w", err)
+		}
+		v.FingerPrint = fp
+	case *dns.SVCB:
+		return checkSVCB(v.Priority, v.Value)
+	case *dns.HTTPS:
+		return checkSVCB(v.Priority, v.Value)
+	case *dns.NAPTR:
+		if !naptrFlags.MatchString(v.Flags) {
+			return fmt.Errorf("flags must be alphanumeric")
+		}
+		v.Flags = strings.ToUpper(v.Flags)
+		if v.Regexp != "" && v.Replacement != "." {
+			return fmt.Errorf("regexp and replacement are mutually exclusive")
+		}
+	}
+	return nil
+}
+
+// checkHex validates hex data, optionally of an exact byte size, and lowercases it
+func checkHex(s string, size int) (string, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return "", fmt.Errorf("must be hex")
+	}
+	if size > 0 && len(b) != size {
+		return "", fmt.Errorf("must be %d bytes, got %d", size, len(b))
+	}
+	return strings.ToLower(s), nil
+}
+
+func checkSVCB(priority uint16, params []dns.SVCBKeyValue) error {
+	if priority == 0 {
+		// Target "." says the service is not available (RFC 9460 §2.5.1)
+		if len(params) > 0 {
+			return fmt.Errorf("AliasMode (priority 0) must not have parameters")
+		}
+		return nil
+	}
+	present := map[dns.SVCBKey]bool{}
+	for _, p := range params {
+		present[p.Key()] = true
+	}
+	for _, p := range params {
+		if m, ok := p.(*dns.SVCBMandatory); ok {
+			for _, k := range m.Code {
+				if !present[k] {
+					return fmt.Errorf("mandatory key %s is missing", k.String())
+				}
+			}
+		}
+		if a, ok := p.(*dns.SVCBAlpn); ok && len(a.Alpn) == 0 {
+			return fmt.Errorf("alpn must not be empty")
+		}
+	}
+	return nil
+}
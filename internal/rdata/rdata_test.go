@@ -0,0 +1,95 @@
+package rdata
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCanonical_Valid(t *testing.T) {
+	sha256 := strings.Repeat("AB", 32)
+	tests := []struct {
+		rtype, data, want string
+	}{
+		{"A", " 192.0.2.1 ", "192.0.2.1"},
+		{"AAAA", "2001:DB8::1", "2001:db8::1"},
+		{"CNAME", "target.example.net", "target.example.net."},
+		{"CNAME", "@", "example.com."},
+		{"alias", "LB.Example.NET", "lb.example.net."},
+		{"MX", "10 mail.example.com", "10 mail.example.com."},
+		{"TXT", "v=DMARC1; p=none", `"v=DMARC1; p=none"`},
+		{"TXT", `"a" "b"`, `"a" "b"`},
+		{"CAA", `128 ISSUE "letsencrypt.org"`, `128 issue "letsencrypt.org"`},
+		{"CAA", `0 iodef "mailto:security@example.com"`, `0 iodef "mailto:security@example.com"`},
+		{"TLSA", "3 1 1 " + sha256, "3 1 1 " + strings.ToLower(sha256)},
+		{"SSHFP", "4 2 " + strings.ToLower(sha256), "4 2 " + sha256},
+		{"HTTPS", "1 . alpn=h2,h3 ipv4hint=192.0.2.1", `1 . alpn="h2,h3" ipv4hint="192.0.2.1"`},
+		{"SVCB", "0 svc.example.net", "0 svc.example.net."},
+		{"HTTPS", "0 .", "0 ."},
+		{"NAPTR", `100 10 "u" "E2U+sip" "!^.*$!sip:info@example.com!" .`, `100 10 "U" "E2U+sip" "!^.*$!sip:info@example.com!" .`},
+		{"LOC", "52 22 23.000 N 4 53 32.000 E -2.00m 0.00m 10000m 10m", "52 22 23.000 N 04 53 32.000 E -2m 0.00m 10000m 10m"},
+	}
+	for _, tt := range tests {
+		got, err := Canonical(tt.rtype, tt.data, "example.com")
+		if err != nil {
+			t.Errorf("%s %q: unexpected error: %v", tt.rtype, tt.data, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s %q: got %q, want %q", tt.rtype, tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestCanonical_Invalid(t *testing.T) {
+	tests := []struct {
+		rtype, data, reason string
+	}{
+		{"A", "192.0.2.1 extra", "garbage"},
+		{"A", "not-an-ip", "A"},
+		{"BOGUS", "x", "unsupported record type"},
+		{"AXFR", "x", "unsupported record type"},
+		{"MX", "", "data is empty"},
+		{"A", "192.0.2.1\n@ 300 IN A 192.0.2.2", "single line"},
+		{"ALIAS", "@", "host name"},
+		{"CAA", `1 issue "ca.example"`, "flags"},
+		{"CAA", `0 iodef "security@example.com"`, "iodef"},
+		{"TLSA", "4 1 1 " + strings.Repeat("ab", 32), "usage"},
+		{"TLSA", "3 1 1 abcd", "32 bytes"},
+		{"SSHFP", "5 2 " + strings.Repeat("ab", 32), "algorithm"},
+		{"SSHFP", "4 1 " + strings.Repeat("ab", 32), "20 bytes"},
+		{"HTTPS", "0 . alpn=h2", "AliasMode"},
+		{"SVCB", "1 . mandatory=alpn port=443", "mandatory key alpn"},
+		{"NAPTR", `100 10 "u" "E2U+sip" "!^.*$!sip:x@example.com!" sip.example.com.`, "mutually exclusive"},
+	}
+	for _, tt := range tests {
+		_, err := Canonical(tt.rtype, tt.data, "example.com")
+		if err == nil {
+			t.Errorf("%s %q: expected error", tt.rtype, tt.data)
+			continue
+		}
+		var rerr *Error
+		if !errors.As(err, &rerr) {
+			t.Errorf("%s %q: expected *Error, got %T", tt.rtype, tt.data, err)
+		}
+		if !strings.Contains(err.Error(), tt.reason) {
+			t.Errorf("%s %q: error %q does not mention %q", tt.rtype, tt.data, err, tt.reason)
+		}
+	}
+}
+
+func TestQuoteText_Splits(t *testing.T) {
+	long := strings.Repeat("x", 300)
+	got, err := Canonical("TXT", long, "example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `"` + strings.Repeat("x", 255) + `" "` + strings.Repeat("x", 45) + `"`
+	if got != want {
+		t.Fatalf("got %q", got)
+	}
+	got, err = Canonical("TXT", `say "hi"`, "example.com")
+	if err != nil || got != `"say \"hi\""` {
+		t.Fatalf("escaping: got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,117 @@
+package dns
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	dbm "namedot/internal/db"
+)
+
+// TypeALIAS is the synthetic record type resolved to A/AAAA at query time.
+// It is stored in RRSet.Type like any other type but never sent on the wire.
+const TypeALIAS = "ALIAS"
+
+// maxAliasDepth bounds ALIAS chains between hosted zones
+const maxAliasDepth = 8
+
+// aliasTarget is a cached resolution of an ALIAS target
+type aliasTarget struct {
+	rrs []dns.RR
+	ttl uint32
+}
+
+// answerAlias answers an A/AAAA query from an ALIAS rrset. Geo selection
+// picks the targets, each target is resolved (hosted zones first, then the
+// forwarder) and the results are returned under qname with the lowest TTL.
+func (s *Server) answerAlias(set *dbm.RRSet, qname string, qtype uint16, clientIP netip.Addr, view string, depth int) ([]dns.RR, uint32, error) {
+	if depth >= maxAliasDepth {
+		return nil, 0, fmt.Errorf("alias chain too deep at %s", qname)
+	}
+	g := s.geo.Lookup(clientIP)
+	recs, rule := selectGeoRecords(set.Records, clientIP, g)
+	s.lastRule = rule
+
+	ttl := set.TTL
+	var answers []dns.RR
+	for _, rec := range recs {
+		target := dns.Fqdn(strings.ToLower(strings.TrimSpace(rec.Data)))
+		res, err := s.resolveAliasTarget(target, qtype, clientIP, view, depth+1)
+		if err != nil {
+			continue
+		}
+		if res.ttl < ttl {
+			ttl = res.ttl
+		}
+		for _, rr := range res.rrs {
+			cp := dns.Copy(rr)
+			cp.Header().Name = qname
+			answers = append(answers, cp)
+		}
+	}
+	if len(answers) == 0 {
+		return nil, 0, fmt.Errorf("alias %s: no %s records for targets", qname, dns.TypeToString[qtype])
+	}
+	for _, rr := range answers {
+		rr.Header().Ttl = ttl
+	}
+	return answers, ttl, nil
+}
+
+// resolveAliasTarget resolves target to records of qtype, caching the result by the target's TTL.
+func (s *Server) resolveAliasTarget(target string, qtype uint16, clientIP netip.Addr, view string, depth int) (*aliasTarget, error) {
+	key := fmt.Sprintf("alias|%s|%d|%s", target, qtype, view)
+	if v, ok := s.cache.Get(key); ok {
+		if res, ok2 := v.(*aliasTarget); ok2 {
+			return res, nil
+		}
+	}
+
+	// Hosted target: answer locally so geo selection applies to the target as well.
+	// Local answers depend on the client, so they are not cached here.
+	q := dns.Question{Name: target, Qtype: qtype, Qclass: dns.ClassINET}
+	if rrs, ttl, err := s.lookupDepth(q, clientIP, view, depth); err == nil && len(rrs) > 0 {
+		return &aliasTarget{rrs: filterType(rrs, qtype), ttl: ttl}, nil
+	}
+
+	if !s.forwarder.Forwards(target) {
+		return nil, fmt.Errorf("alias target %s is not hosted and no forwarder serves it", target)
+	}
+	m := new(dns.Msg)
+	m.SetQuestion(target, qtype)
+	in, _, err := s.forwarder.Exchange(m)
+	if err != nil {
+		return nil, err
+	}
+	if in.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("alias target %s: %s", target, dns.RcodeToString[in.Rcode])
+	}
+	res := &aliasTarget{rrs: filterType(in.Answer, qtype)}
+	if len(res.rrs) == 0 {
+		return nil, fmt.Errorf("alias target %s has no %s records", target, dns.TypeToString[qtype])
+	}
+	// Minimum TTL across the whole chain (CNAMEs included)
+	for i, rr := range in.Answer {
+		if i == 0 || rr.Header().Ttl < res.ttl {
+			res.ttl = rr.Header().Ttl
+		}
+	}
+	if res.ttl > 0 {
+		s.cache.Set(key, res, time.Duration(res.ttl)*time.Second)
+	}
+	return res, nil
+}
+
+// filterType keeps records of qtype, dropping CNAMEs of a resolved chain
+func filterType(rrs []dns.RR, qtype uint16) []dns.RR {
+	out := make([]dns.RR, 0, len(rrs))
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == qtype {
+			out = append(out, rr)
+		}
+	}
+	return out
+}
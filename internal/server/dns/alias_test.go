@@ -0,0 +1,116 @@
+package dns
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/miekg/dns"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"namedot/internal/config"
+	dbm "namedot/internal/db"
+)
+
+func newAliasTestServer(t *testing.T, forwarders config.ForwarderList) (*Server, *gorm.DB, dbm.Zone) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := dbm.AutoMigrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	cfg := &config.Config{
+		Forwarder:   forwarders,
+		Forwarding:  config.ForwardingConfig{Strategy: config.StrategyFailover},
+		Performance: config.PerformanceConfig{CacheSize: 100, ForwarderTimeoutSec: 1},
+	}
+	s, err := NewServer(cfg, db)
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	z := dbm.Zone{Name: "example.com"}
+	if err := db.Create(&z).Error; err != nil {
+		t.Fatalf("create zone: %v", err)
+	}
+	return s, db, z
+}
+
+func TestAlias_ResolvesHostedTargetWithGeo(t *testing.T) {
+	s, db, z := newAliasTestServer(t, nil)
+	sets := []dbm.RRSet{
+		{ZoneID: z.ID, Name: "example.com.", Type: TypeALIAS, TTL: 300, Records: []dbm.RData{
+			{Data: "lb.example.com."},
+			{Data: "lb-eu.example.com.", Subnet: strPtr("198.51.100.0/24")},
+		}},
+		{ZoneID: z.ID, Name: "lb.example.com.", Type: "A", TTL: 60, Records: []dbm.RData{{Data: "192.0.2.1"}}},
+		{ZoneID: z.ID, Name: "lb-eu.example.com.", Type: "A", TTL: 120, Records: []dbm.RData{{Data: "192.0.2.2"}}},
+	}
+	for i := range sets {
+		if err := db.Create(&sets[i]).Error; err != nil {
+			t.Fatalf("create rrset: %v", err)
+		}
+	}
+
+	q := dns.Question{Name: "example.com.", Qtype: dns.TypeA, Qclass: dns.ClassINET}
+	ans, ttl, err := s.lookup(new(dns.Msg), q, netip.MustParseAddr("203.0.113.9"))
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if len(ans) != 1 || ans[0].Header().Name != "example.com." || ans[0].(*dns.A).A.String() != "192.0.2.1" {
+		t.Fatalf("unexpected answer: %v", ans)
+	}
+	if ttl != 60 || ans[0].Header().Ttl != 60 {
+		t.Fatalf("expected target TTL 60, got %d/%d", ttl, ans[0].Header().Ttl)
+	}
+
+	ans, _, err = s.lookup(new(dns.Msg), q, netip.MustParseAddr("198.51.100.7"))
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if len(ans) != 1 || ans[0].(*dns.A).A.String() != "192.0.2.2" {
+		t.Fatalf("geo selection not applied to ALIAS: %v", ans)
+	}
+}
+
+func TestAlias_ResolvesViaForwarder(t *testing.T) {
+	up := startUpstream(t, "198.51.100.80", false)
+	s, db, z := newAliasTestServer(t, config.ForwarderList{up})
+	set := dbm.RRSet{ZoneID: z.ID, Name: "example.com.", Type: TypeALIAS, TTL: 300, Records: []dbm.RData{{Data: "cdn.example.net."}}}
+	if err := db.Create(&set).Error; err != nil {
+		t.Fatalf("create rrset: %v", err)
+	}
+
+	q := dns.Question{Name: "example.com.", Qtype: dns.TypeA, Qclass: dns.ClassINET}
+	ans, ttl, err := s.lookup(new(dns.Msg), q, netip.Addr{})
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if len(ans) != 1 || ans[0].Header().Name != "example.com." || ans[0].(*dns.A).A.String() != "198.51.100.80" {
+		t.Fatalf("unexpected answer: %v", ans)
+	}
+	if ttl != 60 {
+		t.Fatalf("expected upstream TTL 60, got %d", ttl)
+	}
+	if _, ok := s.cache.Get("alias|cdn.example.net.|1|"); !ok {
+		t.Fatalf("target resolution should be cached")
+	}
+}
+
+func TestAlias_LoopIsBounded(t *testing.T) {
+	s, db, z := newAliasTestServer(t, nil)
+	sets := []dbm.RRSet{
+		{ZoneID: z.ID, Name: "a.example.com.", Type: TypeALIAS, TTL: 60, Records: []dbm.RData{{Data: "b.example.com."}}},
+		{ZoneID: z.ID, Name: "b.example.com.", Type: TypeALIAS, TTL: 60, Records: []dbm.RData{{Data: "a.example.com."}}},
+	}
+	for i := range sets {
+		if err := db.Create(&sets[i]).Error; err != nil {
+			t.Fatalf("create rrset: %v", err)
+		}
+	}
+	q := dns.Question{Name: "a.example.com.", Qtype: dns.TypeA, Qclass: dns.ClassINET}
+	if _, _, err := s.lookup(new(dns.Msg), q, netip.Addr{}); err == nil {
+		t.Fatalf("expected error for ALIAS loop")
+	}
+}
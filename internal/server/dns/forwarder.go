@@ -0,0 +1,262 @@
+package dns
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"namedot/internal/config"
+)
+
+// upstream tracks passive health and latency of one forwarder target.
+type upstream struct {
+	config.Upstream
+
+	mu        sync.Mutex
+	fails     int
+	downUntil time.Time
+	failedAt  time.Time     // last failure, ages out its RTT penalty
+	rtt       time.Duration // smoothed round-trip time, 0 = not measured yet
+}
+
+// failurePenalty is the round-trip time a failed query counts as when the
+// forwarder has no timeout of its own, the default of the DNS client
+const failurePenalty = 2 * time.Second
+
+func (u *upstream) healthy(now time.Time) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return !now.Before(u.downUntil)
+}
+
+// rankRTT is the RTT the fastest strategy sorts by: a failure penalty older
+// than expiry ranks as unmeasured, so the upstream gets probed again
+func (u *upstream) rankRTT(now time.Time, expiry time.Duration) time.Duration {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.fails > 0 && now.Sub(u.failedAt) >= expiry {
+		return 0
+	}
+	return u.rtt
+}
+
+func (u *upstream) markSuccess(rtt time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	// The first answer after failures replaces their penalty
+	if u.rtt == 0 || u.fails > 0 {
+		u.rtt = rtt
+	} else {
+		// EWMA with alpha = 1/4
+		u.rtt = (3*u.rtt + rtt) / 4
+	}
+	u.fails = 0
+	u.downUntil = time.Time{}
+}
+
+// markFailure counts a failed query, which also enters the RTT estimate as
+// penalty so the fastest strategy stops trying a dead upstream first
+func (u *upstream) markFailure(maxFails int, failTimeout, penalty time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.rtt < penalty {
+		u.rtt = penalty
+	}
+	u.failedAt = time.Now()
+	u.fails++
+	if maxFails > 0 && u.fails >= maxFails {
+		u.downUntil = time.Now().Add(failTimeout)
+	}
+}
+
+// upstreamPool selects upstreams according to a strategy.
+type upstreamPool struct {
+	strategy    string
+	upstreams   []*upstream
+	next        uint32
+	maxFails    int
+	failTimeout time.Duration
+}
+
+// penaltyExpiry is how long a failure ranks an upstream last for the
+// fastest strategy when the pool has no fail timeout
+const penaltyExpiry = 30 * time.Second
+
+// order returns upstreams in the order they should be tried.
+// Healthy upstreams come first; upstreams marked down are kept as a last resort.
+func (p *upstreamPool) order() []*upstream {
+	n := len(p.upstreams)
+	list := make([]*upstream, 0, n)
+	now := time.Now()
+	switch p.strategy {
+	case config.StrategyFailover:
+		list = append(list, p.upstreams...)
+	case config.StrategyFastest:
+		list = append(list, p.upstreams...)
+		// Unmeasured upstreams (rtt 0) sort first so they get probed; failed
+		// ones carry the penalty of markFailure and sort last until it
+		// expires with the fail timeout
+		expiry := p.failTimeout
+		if expiry <= 0 {
+			expiry = penaltyExpiry
+		}
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].rankRTT(now, expiry) < list[j].rankRTT(now, expiry)
+		})
+	default:
+		start := int(atomic.AddUint32(&p.next, 1)-1) % n
+		for i := 0; i < n; i++ {
+			list = append(list, p.upstreams[(start+i)%n])
+		}
+	}
+
+	healthy := make([]*upstream, 0, n)
+	var down []*upstream
+	for _, u := range list {
+		if u.healthy(now) {
+			healthy = append(healthy, u)
+		} else {
+			down = append(down, u)
+		}
+	}
+	return append(healthy, down...)
+}
+
+type zoneRoute struct {
+	zone string // lowercase FQDN
+	pool *upstreamPool
+}
+
+// Forwarder resolves cache misses through a pool of upstreams,
+// with optional per-zone conditional forwarding.
+type Forwarder struct {
+	timeout time.Duration
+	def     *upstreamPool
+	zones   []zoneRoute // longest zone first
+}
+
+// NewForwarder builds a forwarder from config. It returns a forwarder with
+// no upstreams when nothing is configured; Enabled reports that case.
+func NewForwarder(cfg *config.Config) (*Forwarder, error) {
+	f := &Forwarder{timeout: time.Duration(cfg.Performance.ForwarderTimeoutSec) * time.Second}
+	newPool := func(list config.ForwarderList, strategy string) (*upstreamPool, error) {
+		ups, err := list.Upstreams()
+		if err != nil {
+			return nil, err
+		}
+		if len(ups) == 0 {
+			return nil, nil
+		}
+		if strategy == "" {
+			strategy = cfg.Forwarding.Strategy
+		}
+		p := &upstreamPool{
+			strategy:    strategy,
+			maxFails:    cfg.Forwarding.MaxFails,
+			failTimeout: time.Duration(cfg.Forwarding.FailTimeoutSec) * time.Second,
+		}
+		for _, u := range ups {
+			p.upstreams = append(p.upstreams, &upstream{Upstream: u})
+		}
+		return p, nil
+	}
+
+	def, err := newPool(cfg.Forwarder, "")
+	if err != nil {
+		return nil, fmt.Errorf("forwarder: %w", err)
+	}
+	f.def = def
+	for _, z := range cfg.Forwarding.Zones {
+		p, err := newPool(z.Upstreams, z.Strategy)
+		if err != nil {
+			return nil, fmt.Errorf("forwarding zone %s: %w", z.Zone, err)
+		}
+		if p == nil {
+			continue
+		}
+		f.zones = append(f.zones, zoneRoute{zone: dns.Fqdn(strings.ToLower(z.Zone)), pool: p})
+	}
+	sort.SliceStable(f.zones, func(i, j int) bool { return len(f.zones[i].zone) > len(f.zones[j].zone) })
+	return f, nil
+}
+
+// Enabled reports whether any upstream is configured
+func (f *Forwarder) Enabled() bool {
+	return f != nil && (f.def != nil || len(f.zones) > 0)
+}
+
+// Forwards reports whether an upstream is responsible for qname; with only
+// conditional zones configured other names are not forwarded
+func (f *Forwarder) Forwards(qname string) bool {
+	return f.Enabled() && f.poolFor(qname) != nil
+}
+
+// poolFor returns the conditional pool for qname, or the default pool
+func (f *Forwarder) poolFor(qname string) *upstreamPool {
+	qname = dns.Fqdn(strings.ToLower(qname))
+	for _, z := range f.zones {
+		if qname == z.zone || strings.HasSuffix(qname, "."+z.zone) {
+			return z.pool
+		}
+	}
+	return f.def
+}
+
+// Exchange forwards m to the upstreams responsible for its question.
+// It returns the response and the upstream that produced it.
+func (f *Forwarder) Exchange(m *dns.Msg) (*dns.Msg, string, error) {
+	if len(m.Question) == 0 {
+		return nil, "", fmt.Errorf("empty question")
+	}
+	p := f.poolFor(m.Question[0].Name)
+	if p == nil {
+		return nil, "", fmt.Errorf("no upstream for %s", m.Question[0].Name)
+	}
+	var lastErr error
+	for _, u := range p.order() {
+		start := time.Now()
+		in, err := f.exchangeOne(u.Upstream, m)
+		if err != nil {
+			u.markFailure(p.maxFails, p.failTimeout, f.penalty())
+			lastErr = fmt.Errorf("%s: %w", u, err)
+			continue
+		}
+		u.markSuccess(time.Since(start))
+		return in, u.String(), nil
+	}
+	return nil, "", lastErr
+}
+
+// penalty is the RTT a failed query counts as: the query timeout
+func (f *Forwarder) penalty() time.Duration {
+	if f.timeout > 0 {
+		return f.timeout
+	}
+	return failurePenalty
+}
+
+// exchangeOne queries a single upstream, retrying over TCP when a UDP answer is truncated.
+func (f *Forwarder) exchangeOne(u config.Upstream, m *dns.Msg) (*dns.Msg, error) {
+	c := &dns.Client{Net: u.Net, Timeout: f.timeout}
+	if u.Net == "tcp-tls" {
+		c.TLSConfig = &tls.Config{ServerName: u.ServerName, MinVersion: tls.VersionTLS12}
+	}
+	in, _, err := c.Exchange(m, u.Addr)
+	if err != nil {
+		return nil, err
+	}
+	if in.Truncated && u.Net == "udp" {
+		tc := &dns.Client{Net: "tcp", Timeout: f.timeout}
+		in, _, err = tc.Exchange(m, u.Addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return in, nil
+}
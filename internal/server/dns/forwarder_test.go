@@ -0,0 +1,238 @@
+package dns
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"namedot/internal/config"
+)
+
+// startUpstream runs a local DNS server on UDP and TCP at the same port.
+// UDP answers are truncated when truncateUDP is set.
+func startUpstream(t *testing.T, answer string, truncateUDP bool) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+	addr := pc.LocalAddr().String()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		pc.Close()
+		t.Skipf("tcp port %s unavailable: %v", addr, err)
+	}
+
+	handler := func(isUDP bool) dns.HandlerFunc {
+		return func(w dns.ResponseWriter, r *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			if isUDP && truncateUDP {
+				m.Truncated = true
+			} else {
+				rr, _ := dns.NewRR(r.Question[0].Name + " 60 IN A " + answer)
+				m.Answer = append(m.Answer, rr)
+			}
+			_ = w.WriteMsg(m)
+		}
+	}
+	udp := &dns.Server{PacketConn: pc, Handler: handler(true)}
+	tcp := &dns.Server{Listener: ln, Handler: handler(false)}
+	go func() { _ = udp.ActivateAndServe() }()
+	go func() { _ = tcp.ActivateAndServe() }()
+	t.Cleanup(func() {
+		_ = udp.Shutdown()
+		_ = tcp.Shutdown()
+	})
+	return addr
+}
+
+// deadUpstream returns a UDP address nobody answers on.
+func deadUpstream(t *testing.T) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	return pc.LocalAddr().String()
+}
+
+func query(name string) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(name, dns.TypeA)
+	return m
+}
+
+func newTestForwarder(t *testing.T, cfg *config.Config) *Forwarder {
+	t.Helper()
+	cfg.Performance.ForwarderTimeoutSec = 1
+	if cfg.Forwarding.Strategy == "" {
+		cfg.Forwarding.Strategy = config.StrategyRoundRobin
+	}
+	f, err := NewForwarder(cfg)
+	if err != nil {
+		t.Fatalf("new forwarder: %v", err)
+	}
+	return f
+}
+
+func TestForwarder_TruncatedFallsBackToTCP(t *testing.T) {
+	addr := startUpstream(t, "192.0.2.10", true)
+	f := newTestForwarder(t, &config.Config{Forwarder: config.ForwarderList{addr}})
+
+	in, _, err := f.Exchange(query("example.org."))
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if in.Truncated || len(in.Answer) != 1 {
+		t.Fatalf("expected full TCP answer, got truncated=%v answers=%d", in.Truncated, len(in.Answer))
+	}
+}
+
+func TestForwarder_FailoverMarksDown(t *testing.T) {
+	dead := deadUpstream(t)
+	live := startUpstream(t, "192.0.2.20", false)
+	cfg := &config.Config{
+		Forwarder:  config.ForwarderList{dead, live},
+		Forwarding: config.ForwardingConfig{Strategy: config.StrategyFailover, MaxFails: 1, FailTimeoutSec: 60},
+	}
+	f := newTestForwarder(t, cfg)
+
+	_, used, err := f.Exchange(query("example.org."))
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if used != live {
+		t.Fatalf("expected live upstream %s, got %s", live, used)
+	}
+
+	// Dead upstream is now marked down and must be tried last
+	order := f.def.order()
+	if order[0].Addr != live {
+		t.Fatalf("expected healthy upstream first, got %s", order[0].Addr)
+	}
+
+	start := time.Now()
+	if _, _, err := f.Exchange(query("example.org.")); err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("down upstream was not skipped")
+	}
+}
+
+func TestForwarder_FastestSkipsDeadUpstream(t *testing.T) {
+	dead := deadUpstream(t)
+	live := startUpstream(t, "192.0.2.30", false)
+	// max_fails off: only the RTT estimate keeps the dead upstream back
+	cfg := &config.Config{
+		Forwarder:  config.ForwarderList{dead, live},
+		Forwarding: config.ForwardingConfig{Strategy: config.StrategyFastest},
+	}
+	f := newTestForwarder(t, cfg)
+
+	// Both are unmeasured, so the dead one is probed once and times out
+	if _, used, err := f.Exchange(query("example.org.")); err != nil || used != live {
+		t.Fatalf("first exchange: used %s, err %v", used, err)
+	}
+	if order := f.def.order(); order[0].Addr != live {
+		t.Fatalf("failed upstream must sort after the measured one, got %s first", order[0].Addr)
+	}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, used, err := f.Exchange(query("example.org.")); err != nil || used != live {
+			t.Fatalf("exchange %d: used %s, err %v", i, used, err)
+		}
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("dead upstream was tried again")
+	}
+}
+
+func TestForwarder_ConditionalZones(t *testing.T) {
+	pub := startUpstream(t, "192.0.2.1", false)
+	corp := startUpstream(t, "10.0.0.1", false)
+	cfg := &config.Config{
+		Forwarder: config.ForwarderList{pub},
+		Forwarding: config.ForwardingConfig{Zones: []config.ConditionalForward{
+			{Zone: "corp.example", Upstreams: config.ForwarderList{corp}},
+		}},
+	}
+	f := newTestForwarder(t, cfg)
+
+	cases := map[string]string{
+		"host.corp.example.": corp,
+		"corp.example.":      corp,
+		"notcorp.example.":   pub,
+		"example.org.":       pub,
+	}
+	for name, want := range cases {
+		_, used, err := f.Exchange(query(name))
+		if err != nil {
+			t.Fatalf("exchange %s: %v", name, err)
+		}
+		if used != want {
+			t.Errorf("%s: expected upstream %s, got %s", name, want, used)
+		}
+	}
+}
+
+func TestUpstreamPool_RoundRobin(t *testing.T) {
+	p := &upstreamPool{strategy: config.StrategyRoundRobin}
+	for _, a := range []string{"a:53", "b:53", "c:53"} {
+		p.upstreams = append(p.upstreams, &upstream{Upstream: config.Upstream{Addr: a, Net: "udp"}})
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		seen[p.order()[0].Addr] = true
+	}
+	if len(seen) != 3 {
+		t.Fatalf("round robin should rotate the first upstream, got %v", seen)
+	}
+}
+
+func TestUpstreamPool_Fastest(t *testing.T) {
+	slow := &upstream{Upstream: config.Upstream{Addr: "slow:53"}, rtt: 50 * time.Millisecond}
+	fast := &upstream{Upstream: config.Upstream{Addr: "fast:53"}, rtt: 5 * time.Millisecond}
+	p := &upstreamPool{strategy: config.StrategyFastest, upstreams: []*upstream{slow, fast}}
+	if got := p.order()[0].Addr; got != "fast:53" {
+		t.Fatalf("expected fastest upstream first, got %s", got)
+	}
+}
+
+func TestUpstreamPool_FastestProbesAfterPenaltyExpires(t *testing.T) {
+	dead := &upstream{Upstream: config.Upstream{Addr: "dead:53"}}
+	live := &upstream{Upstream: config.Upstream{Addr: "live:53"}, rtt: 5 * time.Millisecond}
+	p := &upstreamPool{strategy: config.StrategyFastest, upstreams: []*upstream{dead, live}, failTimeout: time.Minute}
+	dead.markFailure(0, 0, 2*time.Second)
+	if got := p.order()[0].Addr; got != "live:53" {
+		t.Fatalf("failed upstream must sort last while penalized, got %s first", got)
+	}
+	dead.failedAt = time.Now().Add(-2 * time.Minute)
+	if got := p.order()[0].Addr; got != "dead:53" {
+		t.Fatalf("expired penalty must let the upstream be probed first, got %s first", got)
+	}
+	dead.markSuccess(time.Millisecond)
+	if got := p.order()[0].Addr; got != "dead:53" || dead.rtt != time.Millisecond {
+		t.Fatalf("a successful probe must replace the penalty, got %s first, rtt %s", got, dead.rtt)
+	}
+}
+
+func TestForwarder_OnlyConditionalZones(t *testing.T) {
+	corp := startUpstream(t, "10.0.0.1", false)
+	cfg := &config.Config{
+		Forwarding: config.ForwardingConfig{Zones: []config.ConditionalForward{
+			{Zone: "corp.example", Upstreams: config.ForwarderList{corp}},
+		}},
+	}
+	f := newTestForwarder(t, cfg)
+	if !f.Forwards("host.corp.example.") {
+		t.Fatalf("conditional zone must be forwarded")
+	}
+	if f.Forwards("example.org.") {
+		t.Fatalf("names outside the conditional zones must not be forwarded without a default pool")
+	}
+}
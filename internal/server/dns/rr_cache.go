@@ -0,0 +1,131 @@
+package dns
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"gorm.io/gorm"
+
+	dbm "namedot/internal/db"
+	"namedot/internal/rdata"
+)
+
+// compiledRR is a parsed record, or the error that prevented parsing it
+type compiledRR struct {
+	updatedAt time.Time
+	rtype     string
+	rr        dns.RR
+	err       error
+}
+
+// RRCache keeps records compiled from their stored text, keyed by RData ID.
+// Entries are revalidated against UpdatedAt, so rows edited in place are
+// recompiled even without an explicit invalidation.
+type RRCache struct {
+	mu      sync.RWMutex
+	entries map[uint]compiledRR
+}
+
+// NewRRCache creates an empty compiled record cache
+func NewRRCache() *RRCache {
+	return &RRCache{entries: map[uint]compiledRR{}}
+}
+
+// CompileRecord compiles stored record text the way answers use it. The
+// zone health check calls it too, so a row it reports is exactly a row
+// left out of answers.
+func CompileRecord(rtype, data, zone string) (dns.RR, error) {
+	return rdata.Compile(rtype, data, zone)
+}
+
+// Get returns the compiled form of rec as an rtype record of zone. The
+// returned RR is shared; callers must copy it before changing the header.
+// Records are compiled when their zones are loaded (see Load); a row
+// written since then is compiled here.
+func (rc *RRCache) Get(rec dbm.RData, rtype, zone string) (dns.RR, error) {
+	rc.mu.RLock()
+	e, ok := rc.entries[rec.ID]
+	rc.mu.RUnlock()
+	if ok && e.updatedAt.Equal(rec.UpdatedAt) && e.rtype == rtype {
+		return e.rr, e.err
+	}
+	return rc.Compile(rec, rtype, zone)
+}
+
+// Compile compiles rec as an rtype record of zone and keeps the result.
+func (rc *RRCache) Compile(rec dbm.RData, rtype, zone string) (dns.RR, error) {
+	rr, err := CompileRecord(rtype, rec.Data, zone)
+	if err != nil {
+		// Logged once per row version; the row stays out of answers until fixed
+		log.Printf("invalid stored record id=%d in zone %s: %v", rec.ID, zone, err)
+	}
+	if rec.ID != 0 {
+		rc.mu.Lock()
+		rc.entries[rec.ID] = compiledRR{updatedAt: rec.UpdatedAt, rtype: rtype, rr: rr, err: err}
+		rc.mu.Unlock()
+	}
+	return rr, err
+}
+
+// Load compiles the records of zones that are not compiled in their current
+// version yet, so queries find them ready.
+func (rc *RRCache) Load(db *gorm.DB, zones []dbm.Zone) error {
+	if len(zones) == 0 {
+		return nil
+	}
+	names := make(map[uint]string, len(zones))
+	ids := make([]uint, 0, len(zones))
+	for _, z := range zones {
+		names[z.ID] = z.Name
+		ids = append(ids, z.ID)
+	}
+	var rows []struct {
+		ID        uint
+		Data      string
+		UpdatedAt time.Time
+		Type      string
+		ZoneID    uint
+	}
+	err := db.Table("r_data").
+		Select("r_data.id, r_data.data, r_data.updated_at, rr_sets.type, rr_sets.zone_id").
+		Joins("JOIN rr_sets ON rr_sets.id = r_data.rr_set_id AND rr_sets.deleted_at IS NULL").
+		Where("r_data.deleted_at IS NULL AND rr_sets.zone_id IN ?", ids).
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		rc.mu.RLock()
+		e, ok := rc.entries[row.ID]
+		rc.mu.RUnlock()
+		if ok && e.updatedAt.Equal(row.UpdatedAt) && e.rtype == row.Type {
+			continue
+		}
+		rc.Compile(dbm.RData{ID: row.ID, Data: row.Data, UpdatedAt: row.UpdatedAt}, row.Type, names[row.ZoneID])
+	}
+	return nil
+}
+
+// Invalidate drops all compiled records
+func (rc *RRCache) Invalidate() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.entries = map[uint]compiledRR{}
+}
+
+// Len returns the number of compiled records held
+func (rc *RRCache) Len() int {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	return len(rc.entries)
+}
+
+// answerRR returns a copy of rr owned by name with the given TTL
+func answerRR(rr dns.RR, name string, ttl uint32) dns.RR {
+	cp := dns.Copy(rr)
+	cp.Header().Name = name
+	cp.Header().Ttl = ttl
+	return cp
+}
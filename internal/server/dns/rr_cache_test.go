@@ -0,0 +1,92 @@
+package dns
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+
+	dbm "namedot/internal/db"
+)
+
+func TestRRCache_ReusesAndRecompiles(t *testing.T) {
+	rc := NewRRCache()
+	rec := dbm.RData{ID: 7, Data: "192.0.2.1", UpdatedAt: time.Unix(100, 0)}
+
+	first, err := rc.Get(rec, "A", "example.com")
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	second, _ := rc.Get(rec, "A", "example.com")
+	if first != second {
+		t.Fatalf("expected compiled RR to be reused")
+	}
+
+	rec.Data = "192.0.2.2"
+	rec.UpdatedAt = time.Unix(200, 0)
+	third, err := rc.Get(rec, "A", "example.com")
+	if err != nil || third.(*dns.A).A.String() != "192.0.2.2" {
+		t.Fatalf("expected recompiled record after update, got %v (%v)", third, err)
+	}
+
+	rc.Invalidate()
+	if rc.Len() != 0 {
+		t.Fatalf("invalidate should drop compiled records")
+	}
+}
+
+func TestLookup_CompilesRecordsWhenZonesLoad(t *testing.T) {
+	s, db, z := newAliasTestServer(t, nil)
+	sets := []dbm.RRSet{
+		{ZoneID: z.ID, Name: "a.example.com.", Type: "A", TTL: 60, Records: []dbm.RData{{Data: "192.0.2.1"}, {Data: "192.0.2.2"}}},
+		{ZoneID: z.ID, Name: "b.example.com.", Type: "TXT", TTL: 60, Records: []dbm.RData{{Data: `"hello"`}}},
+	}
+	for i := range sets {
+		if err := db.Create(&sets[i]).Error; err != nil {
+			t.Fatalf("create rrset: %v", err)
+		}
+	}
+	s.InvalidateZoneCache()
+
+	// The first query loads the zones and compiles all their records, not only the answered ones
+	if _, _, err := s.lookup(new(dns.Msg), dns.Question{Name: "a.example.com.", Qtype: dns.TypeA, Qclass: dns.ClassINET}, netip.Addr{}); err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if got := s.rrCache.Len(); got != 3 {
+		t.Fatalf("expected 3 compiled records after the zone load, got %d", got)
+	}
+}
+
+func TestLookup_SkipsInvalidRowsAndServesLegacyTXT(t *testing.T) {
+	s, db, z := newAliasTestServer(t, nil)
+	sets := []dbm.RRSet{
+		{ZoneID: z.ID, Name: "www.example.com.", Type: "A", TTL: 60, Records: []dbm.RData{
+			{Data: "192.0.2.1"},
+			{Data: "not-an-address"},
+		}},
+		// Stored before write-time validation: unquoted text containing ';'
+		{ZoneID: z.ID, Name: "_dmarc.example.com.", Type: "TXT", TTL: 60, Records: []dbm.RData{{Data: "v=DMARC1; p=none"}}},
+	}
+	for i := range sets {
+		if err := db.Create(&sets[i]).Error; err != nil {
+			t.Fatalf("create rrset: %v", err)
+		}
+	}
+
+	ans, _, err := s.lookup(new(dns.Msg), dns.Question{Name: "www.example.com.", Qtype: dns.TypeA, Qclass: dns.ClassINET}, netip.Addr{})
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if len(ans) != 1 || ans[0].Header().Name != "www.example.com." || ans[0].Header().Ttl != 60 {
+		t.Fatalf("expected only the valid row, got %v", ans)
+	}
+
+	ans, _, err = s.lookup(new(dns.Msg), dns.Question{Name: "_dmarc.example.com.", Qtype: dns.TypeTXT, Qclass: dns.ClassINET}, netip.Addr{})
+	if err != nil || len(ans) != 1 {
+		t.Fatalf("lookup TXT: %v %v", ans, err)
+	}
+	if txt := ans[0].(*dns.TXT).Txt; len(txt) != 1 || txt[0] != "v=DMARC1; p=none" {
+		t.Fatalf("TXT truncated: %q", txt)
+	}
+}
@@ -2,6 +2,7 @@ package dns
 
 import (
     "context"
+    "errors"
     "fmt"
     "log"
     "net"
@@ -23,21 +24,30 @@ type Server struct {
     db        *gorm.DB
     udpServer *dns.Server
     tcpServer *dns.Server
-    resolver  *dns.Client
+    viewSrvs  []*dns.Server // dedicated listeners of views
+    views     *viewMatcher
+    forwarder *Forwarder
     cache     *cache.Cache
     zoneCache *ZoneCache
+    rrCache   *RRCache
     geo       geoip.Provider
     geoStop   func()
     lastRule  string
 }
 
 func NewServer(cfg *config.Config, db *gorm.DB) (*Server, error) {
+    fwd, err := NewForwarder(cfg)
+    if err != nil {
+        return nil, err
+    }
     s := &Server{
         cfg:       cfg,
         db:        db,
-        resolver:  &dns.Client{Timeout: time.Duration(cfg.Performance.ForwarderTimeoutSec) * time.Second},
+        forwarder: fwd,
+        views:     newViewMatcher(cfg.Views),
         cache:     cache.New(cfg.Performance.CacheSize),
         zoneCache: NewZoneCache(5 * time.Minute),
+        rrCache:   NewRRCache(),
     }
     // GeoIP provider
     if cfg.GeoIP.Enabled && cfg.GeoIP.MMDBPath != "" {
@@ -62,8 +72,9 @@ func NewServer(cfg *config.Config, db *gorm.DB) (*Server, error) {
 
 func (s *Server) Start() error {
     dns.HandleFunc(".", s.serveDNS)
-    s.udpServer = &dns.Server{Addr: s.cfg.Listen, Net: "udp"}
-    s.tcpServer = &dns.Server{Addr: s.cfg.Listen, Net: "tcp"}
+    secrets := tsigSecrets(s.cfg.TSIGKeys)
+    s.udpServer = &dns.Server{Addr: s.cfg.Listen, Net: "udp", TsigSecret: secrets}
+    s.tcpServer = &dns.Server{Addr: s.cfg.Listen, Net: "tcp", TsigSecret: secrets}
 
     go func() {
         if err := s.udpServer.ListenAndServe(); err != nil {
@@ -75,6 +86,24 @@ func (s *Server) Start() error {
             log.Fatalf("failed to start TCP server: %v", err)
         }
     }()
+
+    // Dedicated listeners always answer from their view
+    for _, v := range s.cfg.Views {
+        view := v.Name
+        handler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) { s.serveView(w, r, view, true) })
+        for _, addr := range v.Listen {
+            for _, network := range []string{"udp", "tcp"} {
+                srv := &dns.Server{Addr: addr, Net: network, Handler: handler, TsigSecret: secrets}
+                s.viewSrvs = append(s.viewSrvs, srv)
+                go func(srv *dns.Server) {
+                    if err := srv.ListenAndServe(); err != nil {
+                        log.Fatalf("failed to start %s server for view %s on %s: %v", srv.Net, view, srv.Addr, err)
+                    }
+                }(srv)
+            }
+            log.Printf("DNS view %s listening on %s", view, addr)
+        }
+    }
     return nil
 }
 
@@ -87,20 +116,32 @@ func (s *Server) Shutdown() error {
     if s.tcpServer != nil {
         _ = s.tcpServer.ShutdownContext(ctx)
     }
+    for _, srv := range s.viewSrvs {
+        _ = srv.ShutdownContext(ctx)
+    }
     if s.geoStop != nil {
         s.geoStop()
     }
     return nil
 }
 
-// InvalidateZoneCache clears the zone cache, forcing a refresh on next DNS query
+// InvalidateZoneCache clears the zone and compiled record caches, forcing a refresh on next DNS query
 func (s *Server) InvalidateZoneCache() {
     if s.zoneCache != nil {
         s.zoneCache.Invalidate()
     }
+    if s.rrCache != nil {
+        s.rrCache.Invalidate()
+    }
 }
 
 func (s *Server) serveDNS(w dns.ResponseWriter, r *dns.Msg) {
+    s.serveView(w, r, "", false)
+}
+
+// serveView answers a query. When fixed is false the view is selected
+// from the client address and TSIG key; otherwise view is used as given.
+func (s *Server) serveView(w dns.ResponseWriter, r *dns.Msg, view string, fixed bool) {
     m := new(dns.Msg)
     m.SetReply(r)
     m.Authoritative = true
@@ -119,6 +160,26 @@ func (s *Server) serveDNS(w dns.ResponseWriter, r *dns.Msg) {
         useECS = s.cfg.GeoIP.UseECS
     }
     cip := clientIPFrom(r, w, useECS)
+    // Sign responses to verified TSIG requests and use the key for view selection;
+    // a signature that does not verify is refused, never served the default view
+    tsigKey := ""
+    if t := r.IsTsig(); t != nil {
+        status := w.TsigStatus()
+        if s.cfg == nil || len(s.cfg.TSIGKeys) == 0 {
+            // Without keys the server verifies nothing, so every key is unknown
+            status = dns.ErrSecret
+        }
+        if status != nil {
+            log.Printf("DNS QUERY tsig-failed q=%s key=%s from=%s err=%v id=%d", q.Name, t.Hdr.Name, w.RemoteAddr(), status, r.Id)
+            writeTsigError(w, r, t, status)
+            return
+        }
+        tsigKey = t.Hdr.Name
+        w = &tsigWriter{ResponseWriter: w, key: t.Hdr.Name, algo: t.Algorithm}
+    }
+    if !fixed {
+        view = s.views.match(remoteAddrFrom(w), tsigKey)
+    }
     prov := s.geo
     if prov == nil {
         prov = geoip.NewNoop()
@@ -131,12 +192,18 @@ func (s *Server) serveDNS(w dns.ResponseWriter, r *dns.Msg) {
     geoStr := ""
     if verbose {
         geoStr = fmt.Sprintf(" geo[c=%s,ct=%s,asn=%d]", ginfo.Country, ginfo.Continent, ginfo.ASN)
+        if view != "" {
+            geoStr += " view=" + view
+        }
     }
 
     // Cache key
     cacheScope := cip.String()
     if !cip.IsValid() { cacheScope = "" }
     key := fmt.Sprintf("%s|%d|%s", strings.ToLower(q.Name), q.Qtype, cacheScope)
+    if view != "" {
+        key += "|" + view
+    }
     if v, ok := s.cache.Get(key); ok {
         if cached, ok2 := v.(*dns.Msg); ok2 {
             log.Printf("DNS QUERY cache-hit q=%s type=%s from=%s%s id=%d", q.Name, dns.TypeToString[q.Qtype], w.RemoteAddr(), geoStr, r.Id)
@@ -150,7 +217,17 @@ func (s *Server) serveDNS(w dns.ResponseWriter, r *dns.Msg) {
     }
 
     // Resolve locally
-    answers, ttl, err := s.lookup(r, q, cip)
+    answers, ttl, err := s.lookupView(r, q, cip, view)
+    if errors.Is(err, errNXDomain) || errors.Is(err, errNoData) {
+        // A view zone is authoritative for its names: answer, do not forward
+        log.Printf("DNS QUERY view-negative q=%s type=%s from=%s%s err=%v id=%d", q.Name, dns.TypeToString[q.Qtype], w.RemoteAddr(), geoStr, err, r.Id)
+        if errors.Is(err, errNXDomain) {
+            m.Rcode = dns.RcodeNameError
+        }
+        _ = w.WriteMsg(m)
+        s.cache.Set(key, m.Copy(), 5*time.Minute)
+        return
+    }
     if err == nil && len(answers) > 0 {
         if verbose {
             log.Printf("DNS QUERY q=%s type=%s from=%s ecs=%s%s rule=%s answers=%d ttl=%d id=%d", q.Name, dns.TypeToString[q.Qtype], w.RemoteAddr(), cip, geoStr, s.lastRule, len(answers), ttl, r.Id)
@@ -167,12 +244,15 @@ func (s *Server) serveDNS(w dns.ResponseWriter, r *dns.Msg) {
     }
 
     // Forward on miss
-    if s.cfg.Forwarder != "" {
+    if s.forwarder.Forwards(q.Name) {
         fwd := new(dns.Msg)
         fwd.SetQuestion(dns.Fqdn(q.Name), q.Qtype)
-        in, _, ferr := s.resolver.Exchange(fwd, net.JoinHostPort(s.cfg.Forwarder, "53"))
+        in, upstream, ferr := s.forwarder.Exchange(fwd)
+        if ferr != nil {
+            log.Printf("DNS QUERY forward-failed q=%s type=%s from=%s err=%v id=%d", q.Name, dns.TypeToString[q.Qtype], w.RemoteAddr(), ferr, r.Id)
+        }
         if ferr == nil && in != nil {
-            log.Printf("DNS QUERY forward q=%s type=%s from=%s to=%s%s rcode=%d id=%d", q.Name, dns.TypeToString[q.Qtype], w.RemoteAddr(), s.cfg.Forwarder, geoStr, in.Rcode, r.Id)
+            log.Printf("DNS QUERY forward q=%s type=%s from=%s to=%s%s rcode=%d id=%d", q.Name, dns.TypeToString[q.Qtype], w.RemoteAddr(), upstream, geoStr, in.Rcode, r.Id)
             in.Id = r.Id
             _ = w.WriteMsg(in)
             // Cache negative responses (NXDOMAIN, NODATA, etc.) to prevent repeated upstream queries
@@ -191,39 +271,121 @@ func (s *Server) serveDNS(w dns.ResponseWriter, r *dns.Msg) {
     s.cache.Set(key, m.Copy(), 5*time.Minute)
 }
 
-// lookup resolves a question from DB applying Geo selection.
+// lookup resolves a question from DB applying Geo selection in the default view.
 func (s *Server) lookup(r *dns.Msg, q dns.Question, clientIP netip.Addr) (answers []dns.RR, ttl uint32, err error) {
+    return s.lookupView(r, q, clientIP, "")
+}
+
+// errNXDomain and errNoData are the negative answers of a view zone: the name
+// does not exist in it, or has no records of the type.
+var (
+    errNXDomain = errors.New("name not in view zone")
+    errNoData   = errors.New("no records of the type in view zone")
+)
+
+// lookupView resolves a question for the given view. The most specific zone
+// visible in the view wins; a view zone replaces a default-view zone of the
+// same name, so names missing from it get errNXDomain or errNoData rather
+// than the default zone's answer.
+func (s *Server) lookupView(r *dns.Msg, q dns.Question, clientIP netip.Addr, view string) (answers []dns.RR, ttl uint32, err error) {
+    return s.lookupDepth(q, clientIP, view, 0)
+}
+
+// lookupDepth is lookupView with the current ALIAS chain depth.
+func (s *Server) lookupDepth(q dns.Question, clientIP netip.Addr, view string, depth int) (answers []dns.RR, ttl uint32, err error) {
     qname := strings.ToLower(dns.Fqdn(q.Name))
-    qtype := dns.TypeToString[q.Qtype]
 
     // Find the best matching zone suffix (using cache)
     zones := s.zoneCache.Get()
     if zones == nil {
         // Cache miss or expired, fetch from database
-        // Important: filter deleted_at IS NULL to exclude soft-deleted zones from cache
-        if err := s.db.Where("deleted_at IS NULL").Order("length(name) desc").Find(&zones).Error; err != nil {
+        // Important: filter deleted_at IS NULL to exclude soft-deleted zones from cache;
+        // disabled zones are not served either
+        if err := s.db.Where("deleted_at IS NULL AND disabled = ?", false).Order("length(name) desc").Find(&zones).Error; err != nil {
             return nil, 0, err
         }
         // Store in cache for future use
         s.zoneCache.Set(zones)
+        // Compile the records of the loaded zones ahead of the queries
+        if err := s.rrCache.Load(s.db, zones); err != nil {
+            log.Printf("compile records: %v", err)
+        }
     }
-    var zone *dbm.Zone
+    var viewZone, baseZone *dbm.Zone
+    best := ""
     for i := range zones {
+        if zones[i].View != "" && zones[i].View != view {
+            continue
+        }
         name := dns.Fqdn(strings.ToLower(zones[i].Name))
-        if strings.HasSuffix(qname, name) {
-            zone = &zones[i]
+        if !strings.HasSuffix(qname, name) {
+            continue
+        }
+        if best == "" {
+            best = name
+        }
+        // zones are ordered by name length, so stop once a shorter name shows up
+        if name != best {
             break
         }
+        if zones[i].View != "" {
+            viewZone = &zones[i]
+        } else {
+            baseZone = &zones[i]
+        }
     }
-    if zone == nil {
+    if viewZone == nil && baseZone == nil {
         return nil, 0, fmt.Errorf("no zone")
     }
+    if viewZone == nil {
+        return s.answerFromZone(baseZone, qname, q.Qtype, clientIP, view, depth)
+    }
 
+    answers, ttl, err = s.answerFromZone(viewZone, qname, q.Qtype, clientIP, view, depth)
+    if err == nil {
+        return answers, ttl, nil
+    }
+    if s.nameExists(viewZone, qname) {
+        return nil, 0, errNoData
+    }
+    return nil, 0, errNXDomain
+}
+
+// nameExists reports whether qname owns records in zone or is an empty
+// non-terminal above some; the apex always exists.
+func (s *Server) nameExists(zone *dbm.Zone, qname string) bool {
+    if qname == dns.Fqdn(strings.ToLower(zone.Name)) {
+        return true
+    }
+    var names []string
+    s.db.Model(&dbm.RRSet{}).Where("zone_id = ? AND (name = ? OR name LIKE ?)", zone.ID, qname, "%."+qname).Pluck("name", &names)
+    for _, name := range names {
+        // LIKE treats _ in qname as a wildcard, so compare exactly
+        if name = strings.ToLower(name); name == qname || strings.HasSuffix(name, "."+qname) {
+            return true
+        }
+    }
+    return false
+}
+
+// answerFromZone builds the answer for qname/qtype from a single zone,
+// falling back to an ALIAS (for A/AAAA) or a CNAME at the same name.
+func (s *Server) answerFromZone(zone *dbm.Zone, qname string, qt uint16, clientIP netip.Addr, view string, depth int) (answers []dns.RR, ttl uint32, err error) {
+    qtype := dns.TypeToString[qt]
     // Find RRSet by FQDN name and type
     var set dbm.RRSet
     err = s.db.Preload("Records").
         Where("zone_id = ? AND name = ? AND type = ?", zone.ID, strings.ToLower(qname), strings.ToUpper(qtype)).
         First(&set).Error
+    if err != nil && (qt == dns.TypeA || qt == dns.TypeAAAA) {
+        // ALIAS is resolved into address records at query time
+        var aliasSet dbm.RRSet
+        if e2 := s.db.Preload("Records").
+            Where("zone_id = ? AND name = ? AND type = ?", zone.ID, strings.ToLower(qname), TypeALIAS).
+            Limit(1).Find(&aliasSet).Error; e2 == nil && aliasSet.ID != 0 {
+            return s.answerAlias(&aliasSet, qname, qt, clientIP, view, depth)
+        }
+    }
     if err != nil {
         // If exact type not found, try CNAME fallback for this name
         var cnameSet dbm.RRSet
@@ -232,13 +394,10 @@ func (s *Server) lookup(r *dns.Msg, q dns.Question, clientIP netip.Addr) (answer
             First(&cnameSet).Error; e2 == nil {
             // Return CNAME rrset as the answer; resolvers will chase it
             for _, rec := range cnameSet.Records {
-                // Support "@" shorthand in CNAME target to mean zone apex
-                target := rec.Data
-                if strings.TrimSpace(target) == "@" {
-                    target = dns.Fqdn(strings.ToLower(zone.Name))
+                // "@" shorthand in CNAME target means zone apex (resolved at compile time)
+                if rr, cerr := s.rrCache.Get(rec, "CNAME", zone.Name); cerr == nil {
+                    answers = append(answers, answerRR(rr, qname, cnameSet.TTL))
                 }
-                rr, perr := dns.NewRR(fmt.Sprintf("%s %d CNAME %s", qname, cnameSet.TTL, target))
-                if perr == nil { answers = append(answers, rr) }
             }
             return answers, cnameSet.TTL, nil
         }
@@ -251,14 +410,9 @@ func (s *Server) lookup(r *dns.Msg, q dns.Question, clientIP netip.Addr) (answer
     s.lastRule = rule
 
     for _, rec := range recs {
-        // If answering CNAME directly, support "@" shorthand for apex in target
-        data := rec.Data
-        if strings.EqualFold(qtype, "CNAME") && strings.TrimSpace(data) == "@" {
-            data = dns.Fqdn(strings.ToLower(zone.Name))
-        }
-        rr, perr := dns.NewRR(fmt.Sprintf("%s %d %s %s", qname, set.TTL, strings.ToUpper(qtype), data))
-        if perr == nil {
-            answers = append(answers, rr)
+        // Invalid rows are skipped here and reported by the zone health endpoint
+        if rr, cerr := s.rrCache.Get(rec, set.Type, zone.Name); cerr == nil {
+            answers = append(answers, answerRR(rr, qname, set.TTL))
         }
     }
     return answers, set.TTL, nil
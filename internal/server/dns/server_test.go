@@ -92,3 +92,30 @@ func TestLookup_CNAME_Fallback(t *testing.T) {
     if len(ans) == 0 { t.Fatalf("no answers") }
     if ans[0].Header().Rrtype != dns.TypeCNAME { t.Fatalf("want CNAME got %s", dns.TypeToString[ans[0].Header().Rrtype]) }
 }
+
+func TestLookup_DisabledZoneIsNotServed(t *testing.T) {
+    db, err := gorm.Open(sqlite.Open("file:disabled?mode=memory&cache=shared"), &gorm.Config{})
+    if err != nil { t.Fatalf("open db: %v", err) }
+    if err := db.AutoMigrate(&dbm.Zone{}, &dbm.RRSet{}, &dbm.RData{}); err != nil { t.Fatalf("migrate: %v", err) }
+
+    cfg := &config.Config{Listen: ":0", RESTListen: ":0", Performance: config.PerformanceConfig{CacheSize: 0, ForwarderTimeoutSec: 1}, GeoIP: config.GeoIPConfig{Enabled: false}}
+    s, err := NewServer(cfg, db)
+    if err != nil { t.Fatalf("new server: %v", err) }
+
+    z := dbm.Zone{Name: "off.example.", Disabled: true}
+    if err := db.Create(&z).Error; err != nil { t.Fatalf("create zone: %v", err) }
+    a := dbm.RRSet{ZoneID: z.ID, Name: "www.off.example.", Type: "A", TTL: 300, Records: []dbm.RData{{Data: "192.0.2.1"}}}
+    if err := db.Create(&a).Error; err != nil { t.Fatalf("create rrset: %v", err) }
+
+    q := dns.Question{Name: "www.off.example.", Qtype: dns.TypeA, Qclass: dns.ClassINET}
+    if ans, _, _ := s.lookup(new(dns.Msg), q, netip.Addr{}); len(ans) != 0 {
+        t.Fatalf("disabled zone answered: %v", ans)
+    }
+
+    // Enabling the zone serves it again after the cache is invalidated
+    db.Model(&z).Update("disabled", false)
+    s.InvalidateZoneCache()
+    if ans, _, err := s.lookup(new(dns.Msg), q, netip.Addr{}); err != nil || len(ans) != 1 {
+        t.Fatalf("enabled zone: %v %v", ans, err)
+    }
+}
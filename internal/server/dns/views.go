@@ -0,0 +1,130 @@
+package dns
+
+import (
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"namedot/internal/config"
+)
+
+type compiledView struct {
+	name     string
+	prefixes []netip.Prefix
+	keys     map[string]bool // lowercase FQDN key names
+}
+
+// viewMatcher selects the split-horizon view for a query.
+type viewMatcher struct {
+	views []compiledView
+}
+
+func newViewMatcher(views []config.ViewConfig) *viewMatcher {
+	vm := &viewMatcher{}
+	for _, v := range views {
+		// Views selected only by listener never match on criteria
+		if len(v.MatchClients) == 0 && len(v.TSIGKeys) == 0 {
+			continue
+		}
+		cv := compiledView{name: v.Name, keys: map[string]bool{}}
+		for _, cidr := range v.MatchClients {
+			if p, err := netip.ParsePrefix(cidr); err == nil {
+				cv.prefixes = append(cv.prefixes, p.Masked())
+			}
+		}
+		for _, k := range v.TSIGKeys {
+			cv.keys[dns.Fqdn(strings.ToLower(k))] = true
+		}
+		vm.views = append(vm.views, cv)
+	}
+	return vm
+}
+
+// match returns the first view whose criteria match, or "" for the default view.
+// tsigKey is the verified TSIG key name of the request, empty if unsigned.
+func (vm *viewMatcher) match(client netip.Addr, tsigKey string) string {
+	if vm == nil {
+		return ""
+	}
+	client = client.Unmap()
+	tsigKey = strings.ToLower(tsigKey)
+	for _, v := range vm.views {
+		if len(v.prefixes) > 0 {
+			ok := false
+			for _, p := range v.prefixes {
+				if client.IsValid() && p.Contains(client) {
+					ok = true
+					break
+				}
+			}
+			if !ok {
+				continue
+			}
+		}
+		if len(v.keys) > 0 && !v.keys[tsigKey] {
+			continue
+		}
+		return v.name
+	}
+	return ""
+}
+
+// tsigSecrets converts configured TSIG keys to the map used by dns.Server
+func tsigSecrets(keys map[string]string) map[string]string {
+	if len(keys) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(keys))
+	for name, secret := range keys {
+		out[dns.Fqdn(strings.ToLower(name))] = secret
+	}
+	return out
+}
+
+// tsigWriter signs every response with the TSIG key of the verified request.
+type tsigWriter struct {
+	dns.ResponseWriter
+	key, algo string
+}
+
+func (w *tsigWriter) WriteMsg(m *dns.Msg) error {
+	if m.IsTsig() == nil {
+		m.SetTsig(w.key, w.algo, 300, time.Now().Unix())
+	}
+	return w.ResponseWriter.WriteMsg(m)
+}
+
+// writeTsigError answers a request whose TSIG did not verify with NOTAUTH
+// and the TSIG error of RFC 8945 §5.2. The answer is not signed: the key is
+// unknown or the request MAC cannot be trusted.
+func writeTsigError(w dns.ResponseWriter, r *dns.Msg, t *dns.TSIG, status error) {
+	code := dns.RcodeBadSig
+	switch status {
+	case dns.ErrSecret:
+		code = dns.RcodeBadKey
+	case dns.ErrTime:
+		code = dns.RcodeBadTime
+	}
+	m := new(dns.Msg)
+	m.SetRcode(r, dns.RcodeNotAuth)
+	m.Extra = append(m.Extra, &dns.TSIG{
+		Hdr:        dns.RR_Header{Name: t.Hdr.Name, Rrtype: dns.TypeTSIG, Class: dns.ClassANY},
+		Algorithm:  t.Algorithm,
+		TimeSigned: uint64(time.Now().Unix()),
+		Fudge:      t.Fudge,
+		OrigId:     r.Id,
+		Error:      uint16(code),
+	})
+	// Packed by hand: WriteMsg would try to sign a message carrying a TSIG
+	if data, err := m.Pack(); err == nil {
+		_, _ = w.Write(data)
+	}
+}
+
+// remoteAddrFrom returns the transport source address, ignoring ECS.
+// Views are selected by the real peer so clients cannot pick one via ECS.
+func remoteAddrFrom(w dns.ResponseWriter) netip.Addr {
+	return clientIPFrom(nil, w, false)
+}
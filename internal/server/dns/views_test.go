@@ -0,0 +1,160 @@
+package dns
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"namedot/internal/config"
+	dbm "namedot/internal/db"
+)
+
+func TestViewMatcher(t *testing.T) {
+	vm := newViewMatcher([]config.ViewConfig{
+		{Name: "listener-only", Listen: []string{"127.0.0.1:5300"}},
+		{Name: "signed", MatchClients: []string{"10.0.0.0/8"}, TSIGKeys: []string{"xfr-key"}},
+		{Name: "internal", MatchClients: []string{"10.0.0.0/8", "fd00::/8"}},
+	})
+
+	tests := []struct {
+		ip, key, want string
+	}{
+		{"10.1.2.3", "", "internal"},
+		{"10.1.2.3", "xfr-key.", "signed"},
+		{"fd00::1", "", "internal"},
+		{"::ffff:10.1.2.3", "", "internal"},
+		{"192.0.2.1", "", ""},
+		{"192.0.2.1", "xfr-key.", ""},
+	}
+	for _, tt := range tests {
+		if got := vm.match(netip.MustParseAddr(tt.ip), tt.key); got != tt.want {
+			t.Errorf("match(%s, %q) = %q, want %q", tt.ip, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestLookupView_OverlayAndIsolation(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil { t.Fatalf("open db: %v", err) }
+	if err := dbm.AutoMigrate(db); err != nil { t.Fatalf("migrate: %v", err) }
+
+	cfg := &config.Config{Performance: config.PerformanceConfig{ForwarderTimeoutSec: 1}}
+	s, err := NewServer(cfg, db)
+	if err != nil { t.Fatalf("new server: %v", err) }
+
+	base := dbm.Zone{Name: "example.com"}
+	internal := dbm.Zone{Name: "example.com", View: "internal"}
+	private := dbm.Zone{Name: "corp.example.com", View: "internal"}
+	for _, z := range []*dbm.Zone{&base, &internal, &private} {
+		if err := db.Create(z).Error; err != nil { t.Fatalf("create zone: %v", err) }
+	}
+	sets := []dbm.RRSet{
+		{ZoneID: base.ID, Name: "www.example.com.", Type: "A", TTL: 60, Records: []dbm.RData{{Data: "203.0.113.10"}}},
+		{ZoneID: base.ID, Name: "mail.example.com.", Type: "A", TTL: 60, Records: []dbm.RData{{Data: "203.0.113.25"}}},
+		{ZoneID: internal.ID, Name: "www.example.com.", Type: "A", TTL: 60, Records: []dbm.RData{{Data: "10.0.0.10"}}},
+		{ZoneID: private.ID, Name: "git.corp.example.com.", Type: "A", TTL: 60, Records: []dbm.RData{{Data: "10.0.0.20"}}},
+	}
+	for i := range sets {
+		if err := db.Create(&sets[i]).Error; err != nil { t.Fatalf("create rrset: %v", err) }
+	}
+
+	answer := func(name, view string) string {
+		q := dns.Question{Name: name, Qtype: dns.TypeA, Qclass: dns.ClassINET}
+		ans, _, err := s.lookupView(new(dns.Msg), q, netip.Addr{}, view)
+		if err != nil || len(ans) == 0 {
+			return ""
+		}
+		return ans[0].(*dns.A).A.String()
+	}
+
+	if got := answer("www.example.com.", ""); got != "203.0.113.10" {
+		t.Errorf("default view www: got %q", got)
+	}
+	if got := answer("www.example.com.", "internal"); got != "10.0.0.10" {
+		t.Errorf("internal view www should be overridden: got %q", got)
+	}
+	if got := answer("mail.example.com.", ""); got != "203.0.113.25" {
+		t.Errorf("default view mail: got %q", got)
+	}
+	// The view zone is authoritative for its whole name
+	lookupErr := func(name string, qtype uint16) error {
+		_, _, err := s.lookupView(new(dns.Msg), dns.Question{Name: name, Qtype: qtype, Qclass: dns.ClassINET}, netip.Addr{}, "internal")
+		return err
+	}
+	if err := lookupErr("mail.example.com.", dns.TypeA); !errors.Is(err, errNXDomain) {
+		t.Errorf("internal view mail must be NXDOMAIN, not the default zone's: %v", err)
+	}
+	if err := lookupErr("www.example.com.", dns.TypeAAAA); !errors.Is(err, errNoData) {
+		t.Errorf("internal view www AAAA must be NODATA: %v", err)
+	}
+	if err := lookupErr("corp.example.com.", dns.TypeA); !errors.Is(err, errNoData) {
+		t.Errorf("apex of a view zone exists: %v", err)
+	}
+	if got := answer("git.corp.example.com.", "internal"); got != "10.0.0.20" {
+		t.Errorf("internal-only zone: got %q", got)
+	}
+	if got := answer("git.corp.example.com.", ""); got != "" {
+		t.Errorf("internal-only zone must not leak to default view: got %q", got)
+	}
+	if got := answer("git.corp.example.com.", "guest"); got != "" {
+		t.Errorf("internal-only zone must not leak to other views: got %q", got)
+	}
+}
+
+// tsigFailWriter records the raw answer to a request whose TSIG failed with status
+type tsigFailWriter struct {
+	status error
+	raw    []byte
+}
+
+func (w *tsigFailWriter) WriteMsg(m *dns.Msg) error  { w.raw, _ = m.Pack(); return nil }
+func (w *tsigFailWriter) LocalAddr() net.Addr         { return &net.UDPAddr{} }
+func (w *tsigFailWriter) RemoteAddr() net.Addr        { return &net.UDPAddr{IP: net.ParseIP("10.1.2.3"), Port: 53} }
+func (w *tsigFailWriter) Write(b []byte) (int, error) { w.raw = b; return len(b), nil }
+func (w *tsigFailWriter) Close() error                { return nil }
+func (w *tsigFailWriter) TsigStatus() error           { return w.status }
+func (w *tsigFailWriter) TsigTimersOnly(bool)         {}
+func (w *tsigFailWriter) Hijack()                     {}
+
+func TestServeView_BadTSIGIsRefused(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil { t.Fatalf("open db: %v", err) }
+	if err := dbm.AutoMigrate(db); err != nil { t.Fatalf("migrate: %v", err) }
+	cfg := &config.Config{
+		Performance: config.PerformanceConfig{ForwarderTimeoutSec: 1},
+		TSIGKeys:    map[string]string{"xfr-key": "c2VjcmV0"},
+		Views:       []config.ViewConfig{{Name: "signed", TSIGKeys: []string{"xfr-key"}}},
+	}
+	s, err := NewServer(cfg, db)
+	if err != nil { t.Fatalf("new server: %v", err) }
+
+	for _, tt := range []struct {
+		status error
+		want   uint16
+	}{
+		{dns.ErrSig, dns.RcodeBadSig},
+		{dns.ErrSecret, dns.RcodeBadKey},
+		{dns.ErrTime, dns.RcodeBadTime},
+	} {
+		req := new(dns.Msg)
+		req.SetQuestion("www.example.com.", dns.TypeA)
+		req.SetTsig("xfr-key.", dns.HmacSHA256, 300, time.Now().Unix())
+		w := &tsigFailWriter{status: tt.status}
+		s.serveDNS(w, req)
+
+		resp := new(dns.Msg)
+		if err := resp.Unpack(w.raw); err != nil { t.Fatalf("%v: unpack answer: %v", tt.status, err) }
+		if resp.Rcode != dns.RcodeNotAuth {
+			t.Errorf("%v: rcode %s, want NOTAUTH", tt.status, dns.RcodeToString[resp.Rcode])
+		}
+		if ts := resp.IsTsig(); ts == nil || ts.Error != tt.want || ts.MAC != "" {
+			t.Errorf("%v: TSIG %v, want unsigned error %s", tt.status, ts, dns.RcodeToString[int(tt.want)])
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package rest
+
+import (
+    "fmt"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "gorm.io/gorm"
+
+    dbm "namedot/internal/db"
+)
+
+// actor identifies the token of the request in the audit log
+func actor(c *gin.Context) dbm.Actor {
+    return dbm.Actor{Name: "token:" + currentToken(c).Name, IP: c.ClientIP()}
+}
+
+// audit records a change in tx, the transaction of the change itself, so no
+// change commits without its audit row
+func audit(tx *gorm.DB, c *gin.Context, action string, z dbm.Zone, before, after any) error {
+    if err := dbm.Audit(tx, actor(c), action, z, before, after); err != nil {
+        return fmt.Errorf("failed to write audit log: %w", err)
+    }
+    return nil
+}
+
+// auditFilter reads the filters of the audit endpoints from the query
+func auditFilter(c *gin.Context) (dbm.AuditFilter, error) {
+    f := dbm.AuditFilter{Zone: c.Query("zone"), Actor: c.Query("actor"), Action: c.Query("action")}
+    if v := c.Query("zone_id"); v != "" {
+        id, err := strconv.ParseUint(v, 10, 32)
+        if err != nil {
+            return f, fmt.Errorf("invalid zone_id %q", v)
+        }
+        f.ZoneID = uint(id)
+    }
+    for param, dst := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+        if v := c.Query(param); v != "" {
+            t, err := time.Parse(time.RFC3339, v)
+            if err != nil {
+                return f, fmt.Errorf("invalid %s %q (RFC 3339 expected)", param, v)
+            }
+            *dst = t
+        }
+    }
+    for param, dst := range map[string]*int{"limit": &f.Limit, "offset": &f.Offset} {
+        if v := c.Query(param); v != "" {
+            n, err := strconv.Atoi(v)
+            if err != nil || n < 0 {
+                return f, fmt.Errorf("invalid %s %q", param, v)
+            }
+            *dst = n
+        }
+    }
+    return f, nil
+}
+
+func (s *Server) writeAudit(c *gin.Context, f dbm.AuditFilter) {
+    entries, total, err := dbm.ListAudit(s.db, f)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    if entries == nil {
+        entries = []dbm.AuditLog{}
+    }
+    c.JSON(http.StatusOK, gin.H{"total": total, "entries": entries})
+}
+
+// listAudit returns audit entries of all zones
+func (s *Server) listAudit(c *gin.Context) {
+    f, err := auditFilter(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    s.writeAudit(c, f)
+}
+
+// zoneAudit returns the audit entries of one zone
+func (s *Server) zoneAudit(c *gin.Context) {
+    var z dbm.Zone
+    if err := s.db.First(&z, c.Param("id")).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "zone not found"})
+        return
+    }
+    f, err := auditFilter(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    f.ZoneID, f.Zone = z.ID, ""
+    s.writeAudit(c, f)
+}
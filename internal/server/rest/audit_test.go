@@ -0,0 +1,123 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"namedot/internal/config"
+	dbm "namedot/internal/db"
+)
+
+type auditResp struct {
+	Total   int64 `json:"total"`
+	Entries []struct {
+		Actor  string          `json:"actor"`
+		Action string          `json:"action"`
+		Zone   string          `json:"zone"`
+		Before json.RawMessage `json:"before"`
+		After  json.RawMessage `json:"after"`
+	} `json:"entries"`
+}
+
+func TestAudit_RecordsRRSetChanges(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server, gormDB, _ := setupZoneTestServer(t, &config.Config{APIToken: "admintoken"})
+	zone := dbm.Zone{Name: "audited.example"}
+	gormDB.Create(&zone)
+	other := dbm.Zone{Name: "quiet.example"}
+	gormDB.Create(&other)
+
+	w := doTokenRequest(server, "POST", "/tokens", "admintoken", `{"name":"ci","scopes":["write"],"zones":["audited.example"]}`)
+	var created tokenResp
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+	ci := created.Token
+
+	base := fmt.Sprintf("/zones/%d", zone.ID)
+	w = doTokenRequest(server, "POST", base+"/rrsets", ci, `{"name":"www","type":"A","ttl":300,"records":[{"data":"192.0.2.1"}]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", w.Code, w.Body.String())
+	}
+	var set dbm.RRSet
+	_ = json.Unmarshal(w.Body.Bytes(), &set)
+	rrset := fmt.Sprintf("%s/rrsets/%d", base, set.ID)
+	doTokenRequest(server, "PUT", rrset, ci, `{"name":"www","type":"A","ttl":600,"records":[{"data":"192.0.2.2"}]}`)
+	doTokenRequest(server, "DELETE", rrset, ci, "")
+
+	w = doTokenRequest(server, "GET", base+"/audit", ci, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("zone audit: %d %s", w.Code, w.Body.String())
+	}
+	var got auditResp
+	_ = json.Unmarshal(w.Body.Bytes(), &got)
+	if got.Total != 3 || len(got.Entries) != 3 {
+		t.Fatalf("want 3 entries, got %+v", got)
+	}
+	// Newest first
+	for i, action := range []string{dbm.AuditRRSetDelete, dbm.AuditRRSetUpdate, dbm.AuditRRSetCreate} {
+		if e := got.Entries[i]; e.Action != action || e.Actor != "token:ci" || e.Zone != "audited.example" {
+			t.Fatalf("entry %d: want %s by token:ci, got %+v", i, action, e)
+		}
+	}
+	update := got.Entries[1]
+	var before, after dbm.RRSet
+	if json.Unmarshal(update.Before, &before) != nil || json.Unmarshal(update.After, &after) != nil {
+		t.Fatalf("snapshots should be JSON objects: %s / %s", update.Before, update.After)
+	}
+	if before.TTL != 300 || after.TTL != 600 || after.Records[0].Data != "192.0.2.2" {
+		t.Fatalf("unexpected snapshots %+v -> %+v", before, after)
+	}
+	if got.Entries[0].After != nil || got.Entries[2].Before != nil {
+		t.Fatalf("delete has no after, create no before")
+	}
+
+	// The global log needs an unrestricted admin token and takes filters
+	if w := doTokenRequest(server, "GET", "/audit", ci, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("restricted token on /audit: want 403, got %d", w.Code)
+	}
+	w = doTokenRequest(server, "GET", "/audit?zone=audited.example.&action=rrset.&limit=1", "admintoken", "")
+	got = auditResp{}
+	_ = json.Unmarshal(w.Body.Bytes(), &got)
+	if w.Code != http.StatusOK || got.Total != 3 || len(got.Entries) != 1 {
+		t.Fatalf("filtered audit: %d %s", w.Code, w.Body.String())
+	}
+	if w := doTokenRequest(server, "GET", "/audit?since=yesterday", "admintoken", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("bad since: want 400, got %d", w.Code)
+	}
+	if w := doTokenRequest(server, "GET", fmt.Sprintf("/audit?zone_id=%d", other.ID), "admintoken", ""); w.Body.String() != `{"entries":[],"total":0}` {
+		t.Fatalf("untouched zone: %s", w.Body.String())
+	}
+}
+
+func TestAudit_FailureRollsBackTheChange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server, gormDB, _ := setupZoneTestServer(t, &config.Config{APIToken: "admintoken"})
+	zone := dbm.Zone{Name: "unaudited.example"}
+	gormDB.Create(&zone)
+	if err := gormDB.Migrator().DropTable(&dbm.AuditLog{}); err != nil {
+		t.Fatalf("drop audit table: %v", err)
+	}
+
+	w := doTokenRequest(server, "POST", fmt.Sprintf("/zones/%d/rrsets", zone.ID), "admintoken",
+		`{"name":"www","type":"A","ttl":300,"records":[{"data":"192.0.2.1"}]}`)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("want 500 when the audit fails, got %d %s", w.Code, w.Body.String())
+	}
+	var n int64
+	gormDB.Model(&dbm.RRSet{}).Where("zone_id = ?", zone.ID).Count(&n)
+	if n != 0 {
+		t.Fatalf("the rrset must not be created without its audit row, got %d", n)
+	}
+
+	w = doTokenRequest(server, "POST", "/zones", "admintoken", `{"name":"unaudited2.example"}`)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("want 500 when the audit fails, got %d %s", w.Code, w.Body.String())
+	}
+	gormDB.Model(&dbm.Zone{}).Where("name LIKE ?", "unaudited2.example%").Count(&n)
+	if n != 0 {
+		t.Fatalf("the zone must not be created without its audit row, got %d", n)
+	}
+}
@@ -1,8 +1,10 @@
 package rest
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -38,6 +40,10 @@ func setupTestServer(t *testing.T, cfg *config.Config) (*Server, *gin.Engine) {
 		&RData{},
 		&Template{},
 		&TemplateRecord{},
+		&dbm.APIToken{},
+		&dbm.AuditLog{},
+		&dbm.ZoneVersion{},
+		&dbm.Changeset{},
 	); err != nil {
 		t.Fatalf("failed to migrate db: %v", err)
 	}
@@ -297,3 +303,67 @@ func TestAuthMiddleware_CaseInsensitiveBearer(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthMiddleware_BruteForceBackoff(t *testing.T) {
+	cfg := &config.Config{APIToken: "plain-token-123"}
+	cfg.Admin.LoginProtection = config.LoginProtectionConfig{FreeAttempts: 2}
+	_, r := setupTestServer(t, cfg)
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		if w := get("/zones", "wrong-token"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: want 401, got %d", i+1, w.Code)
+		}
+	}
+	// Backoff started: even the right token waits
+	w := get("/zones", "plain-token-123")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("want 429 with Retry-After, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestAuthMiddleware_BackoffIgnoresForwardedFor(t *testing.T) {
+	cfg := &config.Config{APIToken: "plain-token-123"}
+	cfg.Admin.LoginProtection = config.LoginProtectionConfig{FreeAttempts: 2}
+	_, r := setupTestServer(t, cfg)
+
+	// A client rotating X-Forwarded-For is still one client without trusted proxies
+	get := func(i int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/zones", nil)
+		req.Header.Set("Authorization", "Bearer wrong-token")
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d", i))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	for i := 0; i < 3; i++ {
+		get(i)
+	}
+	if w := get(99); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("spoofed X-Forwarded-For escaped the backoff: got %d", w.Code)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	cfg := &config.Config{APIToken: "metrics-token"}
+	_, r := setupTestServer(t, cfg)
+
+	req := httptest.NewRequest("GET", "/zones", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer metrics-token")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `namedot_auth_failures_total{source="rest"}`) {
+		t.Fatalf("metrics: %d %s", w.Code, w.Body.String())
+	}
+}
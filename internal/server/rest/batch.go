@@ -0,0 +1,102 @@
+package rest
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "gorm.io/gorm"
+
+    dbm "namedot/internal/db"
+    "namedot/internal/validate"
+)
+
+// rrsetOpReq is one operation of PATCH /zones/:id/rrsets
+type rrsetOpReq struct {
+    ChangeType string `json:"changetype"`
+    rrsetReq
+}
+
+type rrsetBatchReq struct {
+    RRSets []rrsetOpReq `json:"rrsets"`
+}
+
+// patchRRSets applies a batch of REPLACE/DELETE/ADD-RECORD/REMOVE-RECORD
+// operations atomically, with one serial bump and cache invalidation
+func (s *Server) patchRRSets(c *gin.Context) {
+    var z dbm.Zone
+    if err := s.db.First(&z, c.Param("id")).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "zone not found"})
+        return
+    }
+    var req rrsetBatchReq
+    if err := c.ShouldBindJSON(&req); err != nil || len(req.RRSets) == 0 {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+        return
+    }
+
+    // Validate everything before touching the zone
+    ops := make([]dbm.RRSetOp, 0, len(req.RRSets))
+    for i, r := range req.RRSets {
+        op := dbm.RRSetOp{
+            ChangeType: strings.ToUpper(r.ChangeType),
+            Name:       strings.ToLower(fqdn(r.Name, z.Name)),
+            Type:       strings.ToUpper(r.Type),
+            TTL:        r.TTL,
+            Records:    r.recordsNormalized(),
+        }
+        if op.ChangeType != dbm.OpDelete && len(op.Records) > 0 {
+            set := dbm.RRSet{Name: op.Name, Type: op.Type, TTL: op.TTL, Records: op.Records}
+            if errs := validate.RRSet(&set, z.Name); len(errs) > 0 {
+                c.JSON(http.StatusBadRequest, gin.H{"error": errs.Error(), "fields": errs.Prefix(fmt.Sprintf("rrsets[%d]", i)), "index": i})
+                return
+            }
+        }
+        ops = append(ops, op)
+    }
+
+    // CNAME exclusivity and the single SOA are checked on the result. The
+    // audit, the serial bump and the auto PTRs commit with the change.
+    check := func(sets []dbm.RRSet) error { return validate.Zone(sets, z.Name).Err() }
+    var changes []dbm.RRSetChange
+    var skipped []string
+    err := s.db.Transaction(func(tx *gorm.DB) error {
+        before := dbm.ZoneSnapshot(tx, z.ID)
+        var err error
+        changes, err = dbm.ApplyRRSetOps(tx, z.ID, ops, z.TTLDefault(s.cfg.DefaultTTL), check)
+        if err != nil || len(changes) == 0 {
+            return err
+        }
+        after := dbm.ZoneSnapshot(tx, z.ID)
+        if err := audit(tx, c, dbm.AuditRRSetBatch, z, before, after); err != nil {
+            return err
+        }
+        bumps := s.serials().Batch(tx)
+        bumps.Touch(z)
+        if skipped, err = s.autoPTRs(tx, c, z, before, after, bumps); err != nil {
+            return err
+        }
+        return bumps.Flush()
+    })
+    if validationFailed(c, err) {
+        return
+    }
+    var opErr *dbm.OpError
+    if errors.As(err, &opErr) {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "index": opErr.Index})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    if len(changes) > 0 {
+        reportSkippedPTRs(c, z, skipped)
+        // Invalidate DNS cache after zone record change
+        if s.dnsServer != nil {
+            s.dnsServer.InvalidateZoneCache()
+        }
+    }
+    c.JSON(http.StatusOK, gin.H{"changes": changes})
+}
@@ -0,0 +1,106 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"namedot/internal/config"
+	dbm "namedot/internal/db"
+)
+
+func TestPatchRRSets_AtomicBatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server, gormDB, _ := setupZoneTestServer(t, &config.Config{APIToken: "admintoken", AutoSOAOnMissing: true, DefaultTTL: 120})
+	zone := dbm.Zone{Name: "batch.example"}
+	gormDB.Create(&zone)
+	path := fmt.Sprintf("/zones/%d/rrsets", zone.ID)
+
+	w := doTokenRequest(server, "POST", path, "admintoken", `{"name":"www","type":"A","ttl":300,"records":[{"data":"192.0.2.1"}]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", w.Code, w.Body.String())
+	}
+	versions := func() int64 {
+		var n int64
+		gormDB.Model(&dbm.ZoneVersion{}).Where("zone_id = ?", zone.ID).Count(&n)
+		return n
+	}
+	before := versions()
+
+	w = doTokenRequest(server, "PATCH", path, "admintoken", `{"rrsets":[
+		{"changetype":"ADD-RECORD","name":"www","type":"A","records":[{"data":"192.0.2.2"}]},
+		{"changetype":"REPLACE","name":"mail","type":"MX","records":[{"data":"10 mx.batch.example."}]},
+		{"changetype":"REMOVE-RECORD","name":"www","type":"A","records":[{"data":"192.0.2.1"}]}
+	]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("batch: %d %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Changes []dbm.RRSetChange `json:"changes"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Changes) != 2 {
+		t.Fatalf("want www and mail changed, got %s", w.Body.String())
+	}
+	if n := versions(); n != before+1 {
+		t.Fatalf("want one serial bump for the batch, got %d new versions", n-before)
+	}
+	var mx dbm.RRSet
+	gormDB.Preload("Records").Where("zone_id = ? AND type = ?", zone.ID, "MX").First(&mx)
+	if mx.Name != "mail.batch.example." || mx.TTL != 120 || len(mx.Records) != 1 {
+		t.Fatalf("unexpected MX %+v", mx)
+	}
+
+	// Invalid data in any operation rejects the batch before anything changes
+	w = doTokenRequest(server, "PATCH", path, "admintoken", `{"rrsets":[
+		{"changetype":"DELETE","name":"www","type":"A"},
+		{"changetype":"REPLACE","name":"bad","type":"A","records":[{"data":"not-an-ip"}]}
+	]}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("want 400 for invalid record, got %d %s", w.Code, w.Body.String())
+	}
+	w = doTokenRequest(server, "PATCH", path, "admintoken", `{"rrsets":[
+		{"changetype":"DELETE","name":"www","type":"A"},
+		{"changetype":"REMOVE-RECORD","name":"mail","type":"MX","records":[{"data":"20 other.batch.example."}]}
+	]}`)
+	var errResp struct {
+		Index int `json:"index"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &errResp)
+	if w.Code != http.StatusBadRequest || errResp.Index != 1 {
+		t.Fatalf("want 400 on operation 1, got %d %s", w.Code, w.Body.String())
+	}
+	var n int64
+	gormDB.Model(&dbm.RRSet{}).Where("zone_id = ? AND type = ?", zone.ID, "A").Count(&n)
+	if n != 1 || versions() != before+1 {
+		t.Fatalf("failed batch must leave the zone unchanged")
+	}
+}
+
+func TestPatchRRSets_AuditFailureRollsBack(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server, gormDB, _ := setupZoneTestServer(t, &config.Config{APIToken: "admintoken", AutoSOAOnMissing: true})
+	zone := dbm.Zone{Name: "batch-rollback.example"}
+	gormDB.Create(&zone)
+	path := fmt.Sprintf("/zones/%d/rrsets", zone.ID)
+
+	// Without the audit table the audit row cannot be written
+	if err := gormDB.Migrator().DropTable(&dbm.AuditLog{}); err != nil {
+		t.Fatalf("drop audit table: %v", err)
+	}
+	w := doTokenRequest(server, "PATCH", path, "admintoken", `{"rrsets":[
+		{"changetype":"REPLACE","name":"www","type":"A","records":[{"data":"192.0.2.1"}]}
+	]}`)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("want 500 when the audit fails, got %d %s", w.Code, w.Body.String())
+	}
+	var sets, versions int64
+	gormDB.Model(&dbm.RRSet{}).Where("zone_id = ?", zone.ID).Count(&sets)
+	gormDB.Model(&dbm.ZoneVersion{}).Where("zone_id = ?", zone.ID).Count(&versions)
+	if sets != 0 || versions != 0 {
+		t.Fatalf("the batch must roll back with its audit, got %d rrsets and %d versions", sets, versions)
+	}
+}
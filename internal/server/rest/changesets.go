@@ -0,0 +1,218 @@
+package rest
+
+import (
+    "errors"
+    "net/http"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+
+    dbm "namedot/internal/db"
+    "namedot/internal/validate"
+)
+
+// changesetZone loads the zone of a changeset request, answering 404 if missing
+func (s *Server) changesetZone(c *gin.Context) (dbm.Zone, bool) {
+    var z dbm.Zone
+    if err := s.db.First(&z, c.Param("id")).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "zone not found"})
+        return z, false
+    }
+    return z, true
+}
+
+// openChangeset loads the open changeset of z, answering 404 if there is none
+func (s *Server) openChangeset(c *gin.Context, z dbm.Zone) (dbm.Changeset, bool) {
+    cs, err := dbm.OpenChangeset(s.db, z.ID)
+    if errors.Is(err, dbm.ErrNoChangeset) {
+        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+        return cs, false
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return cs, false
+    }
+    return cs, true
+}
+
+// writeChangeset returns a changeset with its RRSets, the changes against the
+// state it was started from and the RRSets changed live in the meantime
+func (s *Server) writeChangeset(c *gin.Context, status int, cs dbm.Changeset) {
+    sets, err := cs.RRSets()
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    changes, _ := cs.Changes()
+    conflicts, _ := cs.Conflicts(dbm.ZoneSnapshot(s.db, cs.ZoneID))
+    if conflicts == nil {
+        conflicts = []string{}
+    }
+    c.JSON(status, gin.H{
+        "changeset":         cs,
+        "editors":           cs.EditorList(),
+        "rrsets":            sets,
+        "changes":           changes,
+        "conflicts":         conflicts,
+        "approval_required": s.cfg.ChangesetApproval,
+    })
+}
+
+func (s *Server) getChangeset(c *gin.Context) {
+    z, ok := s.changesetZone(c)
+    if !ok {
+        return
+    }
+    if cs, ok := s.openChangeset(c, z); ok {
+        s.writeChangeset(c, http.StatusOK, cs)
+    }
+}
+
+func (s *Server) startChangeset(c *gin.Context) {
+    z, ok := s.changesetZone(c)
+    if !ok {
+        return
+    }
+    cs, err := dbm.StartChangeset(s.db, z.ID, actor(c).Name)
+    if errors.Is(err, dbm.ErrChangesetExists) {
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    s.writeChangeset(c, http.StatusCreated, cs)
+}
+
+// putChangesetRRSet sets an RRSet in the draft; an empty record list removes it
+func (s *Server) putChangesetRRSet(c *gin.Context) {
+    z, ok := s.changesetZone(c)
+    if !ok {
+        return
+    }
+    var req rrsetReq
+    if err := c.ShouldBindJSON(&req); err != nil || req.Type == "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+        return
+    }
+    cs, ok := s.openChangeset(c, z)
+    if !ok {
+        return
+    }
+    set := dbm.RRSet{
+        Name:    strings.ToLower(fqdn(req.Name, z.Name)),
+        Type:    strings.ToUpper(req.Type),
+        TTL:     req.TTL,
+        Records: req.recordsNormalized(),
+    }
+    if set.TTL == 0 {
+        set.TTL = z.TTLDefault(s.cfg.DefaultTTL)
+    }
+    // Validated against the draft, where the other RRSets of the name live
+    draft, err := cs.RRSets()
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    if len(set.Records) > 0 {
+        errs := validate.RRSet(&set, z.Name)
+        if len(errs) == 0 {
+            errs = validate.Coexistence(set, draft)
+        }
+        if validationFailed(c, errs.Err()) {
+            return
+        }
+    }
+    if err := dbm.PutDraftRRSet(s.db, &cs, set, actor(c).Name); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    s.writeChangeset(c, http.StatusOK, cs)
+}
+
+// deleteChangesetRRSet removes an RRSet from the draft
+func (s *Server) deleteChangesetRRSet(c *gin.Context) {
+    z, ok := s.changesetZone(c)
+    if !ok {
+        return
+    }
+    cs, ok := s.openChangeset(c, z)
+    if !ok {
+        return
+    }
+    set := dbm.RRSet{Name: strings.ToLower(fqdn(c.Param("name"), z.Name)), Type: strings.ToUpper(c.Param("type"))}
+    if err := dbm.PutDraftRRSet(s.db, &cs, set, actor(c).Name); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    s.writeChangeset(c, http.StatusOK, cs)
+}
+
+func (s *Server) approveChangeset(c *gin.Context) {
+    z, ok := s.changesetZone(c)
+    if !ok {
+        return
+    }
+    cs, ok := s.openChangeset(c, z)
+    if !ok {
+        return
+    }
+    if err := dbm.ApproveChangeset(s.db, &cs, actor(c).Name); errors.Is(err, dbm.ErrSelfApproval) {
+        c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    s.writeChangeset(c, http.StatusOK, cs)
+}
+
+// publishChangeset applies the draft to the live zone with one serial bump
+func (s *Server) publishChangeset(c *gin.Context) {
+    z, ok := s.changesetZone(c)
+    if !ok {
+        return
+    }
+    cs, ok := s.openChangeset(c, z)
+    if !ok {
+        return
+    }
+    before := dbm.ZoneSnapshot(s.db, z.ID)
+    err := dbm.PublishChangeset(s.db, &cs, z, actor(c), s.cfg.ChangesetApproval, s.serials())
+    var conflict *dbm.ConflictError
+    switch {
+    case errors.As(err, &conflict):
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "conflicts": conflict.Keys})
+        return
+    case errors.Is(err, dbm.ErrApprovalRequired):
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    case errors.Is(err, dbm.ErrChangesetUnchanged):
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    case err != nil:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    s.syncPTRs(c, z, before, dbm.ZoneSnapshot(s.db, z.ID))
+    // Invalidate DNS cache after publishing
+    if s.dnsServer != nil {
+        s.dnsServer.InvalidateZoneCache()
+    }
+    c.JSON(http.StatusOK, cs)
+}
+
+func (s *Server) discardChangeset(c *gin.Context) {
+    z, ok := s.changesetZone(c)
+    if !ok {
+        return
+    }
+    cs, ok := s.openChangeset(c, z)
+    if !ok {
+        return
+    }
+    if err := dbm.DiscardChangeset(s.db, &cs); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,87 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"namedot/internal/config"
+	dbm "namedot/internal/db"
+)
+
+func TestChangesets_StagePublishWithApproval(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server, gormDB, _ := setupZoneTestServer(t, &config.Config{APIToken: "admintoken", ChangesetApproval: true})
+	zone := dbm.Zone{Name: "staged.example"}
+	gormDB.Create(&zone)
+	base := fmt.Sprintf("/zones/%d/changeset", zone.ID)
+
+	tokens := map[string]string{}
+	for name, scopes := range map[string]string{"author": `"write","approve"`, "coauthor": `"write","approve"`, "reviewer": `"approve"`, "writer": `"write"`} {
+		w := doTokenRequest(server, "POST", "/tokens", "admintoken", `{"name":"`+name+`","scopes":[`+scopes+`]}`)
+		var created tokenResp
+		_ = json.Unmarshal(w.Body.Bytes(), &created)
+		tokens[name] = created.Token
+	}
+	author, coauthor, reviewer := tokens["author"], tokens["coauthor"], tokens["reviewer"]
+
+	if w := doTokenRequest(server, "GET", base, author, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("no changeset yet: want 404, got %d", w.Code)
+	}
+	if w := doTokenRequest(server, "POST", base, author, ""); w.Code != http.StatusCreated {
+		t.Fatalf("start: %d %s", w.Code, w.Body.String())
+	}
+	if w := doTokenRequest(server, "PUT", base+"/rrsets", author, `{"name":"www","type":"A","records":[{"data":"not-an-ip"}]}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid data: want 400, got %d", w.Code)
+	}
+	w := doTokenRequest(server, "PUT", base+"/rrsets", author, `{"name":"www","type":"A","ttl":300,"records":[{"data":"192.0.2.1"}]}`)
+	var view struct {
+		Changes []dbm.RRSetChange `json:"changes"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &view)
+	if w.Code != http.StatusOK || len(view.Changes) != 1 || view.Changes[0].Change != dbm.ChangeAdded {
+		t.Fatalf("put: %d %s", w.Code, w.Body.String())
+	}
+	var live int64
+	gormDB.Model(&dbm.RRSet{}).Where("zone_id = ?", zone.ID).Count(&live)
+	if live != 0 {
+		t.Fatalf("staged records must not be live")
+	}
+
+	if w := doTokenRequest(server, "POST", base+"/publish", author, ""); w.Code != http.StatusConflict {
+		t.Fatalf("publish without approval: want 409, got %d", w.Code)
+	}
+	if w := doTokenRequest(server, "POST", base+"/approve", author, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("self approval: want 403, got %d", w.Code)
+	}
+	// Every editor counts as an author, not only the first and the last
+	if w := doTokenRequest(server, "PUT", base+"/rrsets", coauthor, `{"name":"mail","type":"A","ttl":300,"records":[{"data":"192.0.2.2"}]}`); w.Code != http.StatusOK {
+		t.Fatalf("coauthor put: %d %s", w.Code, w.Body.String())
+	}
+	if w := doTokenRequest(server, "PUT", base+"/rrsets", author, `{"name":"mail","type":"A","records":[]}`); w.Code != http.StatusOK {
+		t.Fatalf("author put: %d %s", w.Code, w.Body.String())
+	}
+	if w := doTokenRequest(server, "POST", base+"/approve", coauthor, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("approval by an earlier editor: want 403, got %d", w.Code)
+	}
+	// Approving takes the approve scope; write alone is not enough
+	if w := doTokenRequest(server, "POST", base+"/approve", tokens["writer"], ""); w.Code != http.StatusForbidden {
+		t.Fatalf("approval with the write scope: want 403, got %d", w.Code)
+	}
+	if w := doTokenRequest(server, "POST", base+"/approve", reviewer, ""); w.Code != http.StatusOK {
+		t.Fatalf("approve: %d %s", w.Code, w.Body.String())
+	}
+	if w := doTokenRequest(server, "POST", base+"/publish", author, ""); w.Code != http.StatusOK {
+		t.Fatalf("publish: %d %s", w.Code, w.Body.String())
+	}
+	gormDB.Model(&dbm.RRSet{}).Where("zone_id = ? AND name = ?", zone.ID, "www.staged.example.").Count(&live)
+	if live != 1 {
+		t.Fatalf("published RRSet missing")
+	}
+	if w := doTokenRequest(server, "GET", base, author, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("published changeset must be closed, got %d", w.Code)
+	}
+}
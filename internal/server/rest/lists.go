@@ -0,0 +1,81 @@
+package rest
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+
+    dbm "namedot/internal/db"
+)
+
+// pageParams reads limit, offset and sort of the list endpoints from the query
+func pageParams(c *gin.Context) (dbm.Page, error) {
+    p := dbm.Page{Sort: c.Query("sort")}
+    for param, dst := range map[string]*int{"limit": &p.Limit, "offset": &p.Offset} {
+        if v := c.Query(param); v != "" {
+            n, err := strconv.Atoi(v)
+            if err != nil || n < 0 {
+                return p, fmt.Errorf("invalid %s %q", param, v)
+            }
+            *dst = n
+        }
+    }
+    return p, nil
+}
+
+// setPageHeaders reports the total count in X-Total-Count and, when more rows
+// follow, links the next page; lists without a limit are paged too
+func setPageHeaders(c *gin.Context, p dbm.Page, n int, total int64) {
+    c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+    if int64(p.Offset+n) < total {
+        u := *c.Request.URL
+        q := u.Query()
+        q.Set("offset", strconv.Itoa(p.Offset+n))
+        q.Set("limit", strconv.Itoa(p.PageLimit()))
+        u.RawQuery = q.Encode()
+        c.Header("Link", fmt.Sprintf(`<%s>; rel="next"`, u.RequestURI()))
+    }
+}
+
+// listError answers 400 for an invalid sort field and 500 otherwise
+func listError(c *gin.Context, err error) {
+    if errors.Is(err, dbm.ErrInvalidSort) {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// searchRecords finds records by value across the zones the token may see,
+// e.g. GET /search/records?data=10.1.2.3&type=A
+func (s *Server) searchRecords(c *gin.Context) {
+    p, err := pageParams(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    f := dbm.RecordSearch{
+        Page:     p,
+        Data:     c.Query("data"),
+        Contains: c.Query("q"),
+        Type:     c.Query("type"),
+        Allowed:  currentToken(c).ZoneList(),
+    }
+    if f.Data == "" && f.Contains == "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "data or q is required"})
+        return
+    }
+    matches, total, err := dbm.SearchRecords(s.db, f)
+    if err != nil {
+        listError(c, err)
+        return
+    }
+    if p.Limit == 0 {
+        p.Limit = dbm.MaxListLimit
+    }
+    setPageHeaders(c, p, len(matches), total)
+    c.JSON(http.StatusOK, matches)
+}
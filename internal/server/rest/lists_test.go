@@ -0,0 +1,99 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"namedot/internal/config"
+	dbm "namedot/internal/db"
+)
+
+func TestListEndpoints_PagingFilteringAndSearch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server, gormDB, _ := setupZoneTestServer(t, &config.Config{APIToken: "admintoken"})
+	var first dbm.Zone
+	for i := 1; i <= 5; i++ {
+		z := dbm.Zone{Name: fmt.Sprintf("z%d.paged.example", i)}
+		gormDB.Create(&z)
+		if i == 1 {
+			first = z
+		}
+		gormDB.Create(&dbm.RRSet{ZoneID: z.ID, Name: "www." + z.Name + ".", Type: "A", TTL: 60, Records: []dbm.RData{{Data: "10.1.2.3"}}})
+	}
+	gormDB.Create(&dbm.Zone{Name: "unrelated.example"})
+	for _, name := range []string{"a", "b", "c"} {
+		gormDB.Create(&dbm.RRSet{ZoneID: first.ID, Name: name + ".z1.paged.example.", Type: "TXT", TTL: 60, Records: []dbm.RData{{Data: `"x"`}}})
+	}
+
+	w := doTokenRequest(server, "GET", "/zones?suffix=paged.example&sort=-name&limit=2", "admintoken", "")
+	var zones []dbm.Zone
+	_ = json.Unmarshal(w.Body.Bytes(), &zones)
+	if w.Code != http.StatusOK || len(zones) != 2 || zones[0].Name != "z5.paged.example" || w.Header().Get("X-Total-Count") != "5" {
+		t.Fatalf("zones page: %d %v %s", w.Code, w.Header(), w.Body.String())
+	}
+	if link := w.Header().Get("Link"); !strings.Contains(link, "offset=2") || !strings.Contains(link, `rel="next"`) {
+		t.Fatalf("want link to the next page, got %q", link)
+	}
+	if w := doTokenRequest(server, "GET", "/zones?sort=bogus", "admintoken", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("want 400 for an unknown sort field, got %d", w.Code)
+	}
+
+	w = doTokenRequest(server, "GET", fmt.Sprintf("/zones/%d/rrsets?type=txt&limit=2&offset=2", first.ID), "admintoken", "")
+	var sets []dbm.RRSet
+	_ = json.Unmarshal(w.Body.Bytes(), &sets)
+	if w.Code != http.StatusOK || len(sets) != 1 || sets[0].Name != "c.z1.paged.example." || w.Header().Get("X-Total-Count") != "3" {
+		t.Fatalf("rrsets page: %d %s", w.Code, w.Body.String())
+	}
+	w = doTokenRequest(server, "GET", fmt.Sprintf("/zones/%d?rrsets=false", first.ID), "admintoken", "")
+	var zone dbm.Zone
+	_ = json.Unmarshal(w.Body.Bytes(), &zone)
+	if w.Code != http.StatusOK || len(zone.RRSets) != 0 {
+		t.Fatalf("want zone without rrsets, got %s", w.Body.String())
+	}
+
+	w = doTokenRequest(server, "GET", "/search/records?data=10.1.2.3&type=A&sort=zone", "admintoken", "")
+	var matches []dbm.RecordMatch
+	_ = json.Unmarshal(w.Body.Bytes(), &matches)
+	if w.Code != http.StatusOK || len(matches) != 5 || matches[0].Name != "www.z1.paged.example." {
+		t.Fatalf("search: %d %s", w.Code, w.Body.String())
+	}
+	if w := doTokenRequest(server, "GET", "/search/records", "admintoken", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("want 400 without a search value, got %d", w.Code)
+	}
+
+	// Zone-restricted tokens only find records of their zones
+	w = doTokenRequest(server, "POST", "/tokens", "admintoken", `{"name":"z2-only","scopes":["read"],"zones":["z2.paged.example"]}`)
+	var tok tokenResp
+	_ = json.Unmarshal(w.Body.Bytes(), &tok)
+	w = doTokenRequest(server, "GET", "/search/records?data=10.1.2.3", tok.Token, "")
+	matches = nil
+	_ = json.Unmarshal(w.Body.Bytes(), &matches)
+	if w.Code != http.StatusOK || len(matches) != 1 || matches[0].Zone != "z2.paged.example" {
+		t.Fatalf("restricted search: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestListEndpoints_DefaultPageSize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server, gormDB, _ := setupZoneTestServer(t, &config.Config{APIToken: "admintoken"})
+	for i := 0; i < dbm.DefaultListLimit+5; i++ {
+		gormDB.Create(&dbm.Zone{Name: fmt.Sprintf("z%03d.default-page.example", i)})
+	}
+
+	for _, path := range []string{"/zones", "/zones?limit=0"} {
+		w := doTokenRequest(server, "GET", path, "admintoken", "")
+		var zones []dbm.Zone
+		_ = json.Unmarshal(w.Body.Bytes(), &zones)
+		if w.Code != http.StatusOK || len(zones) != dbm.DefaultListLimit || w.Header().Get("X-Total-Count") != fmt.Sprint(dbm.DefaultListLimit+5) {
+			t.Fatalf("%s: want a default page of %d, got %d zones (%d)", path, dbm.DefaultListLimit, len(zones), w.Code)
+		}
+		if link := w.Header().Get("Link"); !strings.Contains(link, fmt.Sprintf("offset=%d", dbm.DefaultListLimit)) {
+			t.Fatalf("%s: want link to the next page, got %q", path, link)
+		}
+	}
+}
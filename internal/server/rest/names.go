@@ -0,0 +1,237 @@
+package rest
+
+import (
+    "errors"
+    "net/http"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    dbm "namedot/internal/db"
+)
+
+// errPrecondition aborts a transaction whose If-Match/If-None-Match failed
+var errPrecondition = errors.New("precondition failed")
+
+// preconditionsMet applies the If-Match and If-None-Match headers to the
+// current state of an RRSet, nil if it does not exist
+func preconditionsMet(c *gin.Context, current *dbm.RRSet) bool {
+    tag := ""
+    if current != nil {
+        tag = dbm.RRSetETag(*current)
+    }
+    matches := func(header string) bool {
+        for _, t := range strings.Split(header, ",") {
+            t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+            if (t == "*" && current != nil) || (t == tag && tag != "") {
+                return true
+            }
+        }
+        return false
+    }
+    if h := c.GetHeader("If-Match"); h != "" && !matches(h) {
+        return false
+    }
+    if h := c.GetHeader("If-None-Match"); h != "" && matches(h) {
+        return false
+    }
+    return true
+}
+
+func preconditionFailed(c *gin.Context) {
+    c.JSON(http.StatusPreconditionFailed, gin.H{"error": "rrset was changed; fetch it again and retry"})
+}
+
+// lockRRSet loads an RRSet by name and type for update; nil if missing
+func lockRRSet(tx *gorm.DB, zoneID uint, name, rtype string) (*dbm.RRSet, error) {
+    return lockRRSetWhere(tx, "zone_id = ? AND name = ? AND type = ?", zoneID, name, rtype)
+}
+
+// lockRRSetByID loads an RRSet of a zone by ID for update; nil if missing
+func lockRRSetByID(tx *gorm.DB, zoneID uint, id string) (*dbm.RRSet, error) {
+    return lockRRSetWhere(tx, "zone_id = ? AND id = ?", zoneID, id)
+}
+
+func lockRRSetWhere(tx *gorm.DB, query string, args ...any) (*dbm.RRSet, error) {
+    q := tx.Preload("Records")
+    // SQLite locks the database for the transaction and has no FOR UPDATE
+    if tx.Dialector.Name() != "sqlite" {
+        q = q.Clauses(clause.Locking{Strength: "UPDATE"})
+    }
+    var set dbm.RRSet
+    if err := q.Where(query, args...).Limit(1).Find(&set).Error; err != nil {
+        return nil, err
+    }
+    if set.ID == 0 {
+        return nil, nil
+    }
+    return &set, nil
+}
+
+// rrsetByName loads the zone and the RRSet key of /zones/:id/rrsets/:rid/:type,
+// where :rid is a relative name, an FQDN or "@"
+func (s *Server) rrsetByName(c *gin.Context) (dbm.Zone, string, string, bool) {
+    var z dbm.Zone
+    if err := s.db.First(&z, c.Param("id")).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "zone not found"})
+        return z, "", "", false
+    }
+    return z, strings.ToLower(fqdn(c.Param("rid"), z.Name)), strings.ToUpper(c.Param("type")), true
+}
+
+func (s *Server) getRRSetByName(c *gin.Context) {
+    z, name, rtype, ok := s.rrsetByName(c)
+    if !ok {
+        return
+    }
+    set, err := lockRRSet(s.db, z.ID, name, rtype)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    if set == nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "rrset not found"})
+        return
+    }
+    c.Header("ETag", dbm.RRSetETag(*set))
+    if h := c.GetHeader("If-None-Match"); h != "" && !preconditionsMet(c, set) {
+        c.Status(http.StatusNotModified)
+        return
+    }
+    c.JSON(http.StatusOK, set)
+}
+
+// putRRSetByName creates or replaces an RRSet. It is idempotent; If-Match
+// guards against overwriting a concurrent change and If-None-Match: *
+// only creates.
+func (s *Server) putRRSetByName(c *gin.Context) {
+    z, name, rtype, ok := s.rrsetByName(c)
+    if !ok {
+        return
+    }
+    var req rrsetReq
+    if err := c.ShouldBindJSON(&req); err != nil || len(req.Records) == 0 {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+        return
+    }
+    set := dbm.RRSet{ZoneID: z.ID, Name: name, Type: rtype, TTL: req.TTL, Records: req.recordsNormalized()}
+    if set.TTL == 0 {
+        set.TTL = z.TTLDefault(s.cfg.DefaultTTL)
+    }
+    if err := s.checkRRSet(z, &set); err != nil {
+        respondInvalid(c, err)
+        return
+    }
+
+    var before *dbm.RRSet
+    changed := true
+    err := s.db.Transaction(func(tx *gorm.DB) error {
+        var err error
+        if before, err = lockRRSet(tx, z.ID, name, rtype); err != nil {
+            return err
+        }
+        if !preconditionsMet(c, before) {
+            return errPrecondition
+        }
+        if before == nil {
+            // A soft-deleted RRSet with the same key would collide with the unique index
+            var ids []uint
+            tx.Unscoped().Model(&dbm.RRSet{}).Where("zone_id = ? AND name = ? AND type = ? AND deleted_at IS NOT NULL", z.ID, name, rtype).Pluck("id", &ids)
+            if len(ids) > 0 {
+                if err := tx.Unscoped().Where("rr_set_id IN ?", ids).Delete(&dbm.RData{}).Error; err != nil {
+                    return err
+                }
+                if err := tx.Unscoped().Delete(&dbm.RRSet{}, ids).Error; err != nil {
+                    return err
+                }
+            }
+            if err := tx.Create(&set).Error; err != nil {
+                return err
+            }
+            return audit(tx, c, dbm.AuditRRSetCreate, z, nil, set)
+        }
+        if dbm.SameRRSets([]dbm.RRSet{*before}, []dbm.RRSet{set}) {
+            set, changed = *before, false
+            return nil
+        }
+        set.ID, set.CreatedAt = before.ID, before.CreatedAt
+        if err := tx.Where("rr_set_id = ?", set.ID).Delete(&dbm.RData{}).Error; err != nil {
+            return err
+        }
+        if err := tx.Save(&set).Error; err != nil {
+            return err
+        }
+        return audit(tx, c, dbm.AuditRRSetUpdate, z, *before, set)
+    })
+    if errors.Is(err, errPrecondition) {
+        preconditionFailed(c)
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    status := http.StatusOK
+    if before == nil {
+        status = http.StatusCreated
+    }
+    if changed {
+        s.bumpSerial(z)
+        var old []dbm.RRSet
+        if before != nil {
+            old = append(old, *before)
+        }
+        s.syncPTRs(c, z, old, []dbm.RRSet{set})
+        // Invalidate DNS cache after zone record change
+        if s.dnsServer != nil {
+            s.dnsServer.InvalidateZoneCache()
+        }
+    }
+    c.Header("ETag", dbm.RRSetETag(set))
+    c.JSON(status, set)
+}
+
+func (s *Server) deleteRRSetByName(c *gin.Context) {
+    z, name, rtype, ok := s.rrsetByName(c)
+    if !ok {
+        return
+    }
+    var before *dbm.RRSet
+    err := s.db.Transaction(func(tx *gorm.DB) error {
+        var err error
+        if before, err = lockRRSet(tx, z.ID, name, rtype); err != nil || before == nil {
+            return err
+        }
+        if !preconditionsMet(c, before) {
+            return errPrecondition
+        }
+        // Hard delete, so a later PUT can create the RRSet again
+        if err := tx.Unscoped().Where("rr_set_id = ?", before.ID).Delete(&dbm.RData{}).Error; err != nil {
+            return err
+        }
+        if err := tx.Unscoped().Delete(&dbm.RRSet{}, before.ID).Error; err != nil {
+            return err
+        }
+        return audit(tx, c, dbm.AuditRRSetDelete, z, *before, nil)
+    })
+    switch {
+    case errors.Is(err, errPrecondition):
+        preconditionFailed(c)
+        return
+    case err != nil:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    case before == nil:
+        c.JSON(http.StatusNotFound, gin.H{"error": "rrset not found"})
+        return
+    }
+    s.bumpSerial(z)
+    s.syncPTRs(c, z, []dbm.RRSet{*before}, nil)
+    // Invalidate DNS cache after zone record change
+    if s.dnsServer != nil {
+        s.dnsServer.InvalidateZoneCache()
+    }
+    c.Status(http.StatusNoContent)
+}
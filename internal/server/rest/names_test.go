@@ -0,0 +1,157 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"namedot/internal/config"
+	dbm "namedot/internal/db"
+)
+
+func TestRRSetByName_PutWithETags(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server, gormDB, _ := setupZoneTestServer(t, &config.Config{APIToken: "admintoken", DefaultTTL: 300})
+	gormDB.Create(&dbm.Zone{Name: "named.example"})
+	path := "/zones/named.example/rrsets/www/A"
+
+	do := func(method, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer admintoken")
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		server.r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("PUT", `{"records":[{"data":"192.0.2.1"}]}`, map[string]string{"If-None-Match": "*"})
+	if w.Code != http.StatusCreated || w.Header().Get("ETag") == "" {
+		t.Fatalf("create: %d %s", w.Code, w.Body.String())
+	}
+	etag := w.Header().Get("ETag")
+	if w := do("PUT", `{"records":[{"data":"192.0.2.1"}]}`, map[string]string{"If-None-Match": "*"}); w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("If-None-Match: * must not overwrite, got %d", w.Code)
+	}
+	// Repeating the same PUT is a no-op with the same ETag
+	if w := do("PUT", `{"records":[{"data":"192.0.2.1"}]}`, nil); w.Code != http.StatusOK || w.Header().Get("ETag") != etag {
+		t.Fatalf("idempotent put: %d %s", w.Code, w.Header().Get("ETag"))
+	}
+	if w := do("GET", "", nil); w.Code != http.StatusOK || w.Header().Get("ETag") != etag {
+		t.Fatalf("get: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("GET", "", map[string]string{"If-None-Match": etag}); w.Code != http.StatusNotModified {
+		t.Fatalf("want 304 for a current ETag, got %d", w.Code)
+	}
+
+	// Two pipelines start from the same ETag; the second one loses
+	if w := do("PUT", `{"records":[{"data":"192.0.2.2"}]}`, map[string]string{"If-Match": etag}); w.Code != http.StatusOK {
+		t.Fatalf("first update: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("PUT", `{"records":[{"data":"192.0.2.3"}]}`, map[string]string{"If-Match": etag}); w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("second update must fail, got %d", w.Code)
+	}
+	if w := do("DELETE", "", map[string]string{"If-Match": etag}); w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("stale delete must fail, got %d", w.Code)
+	}
+	if w := do("DELETE", "", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d", w.Code)
+	}
+	// The deleted RRSet can be created again
+	if w := do("PUT", `{"records":[{"data":"192.0.2.4"}]}`, nil); w.Code != http.StatusCreated {
+		t.Fatalf("recreate: %d %s", w.Code, w.Body.String())
+	}
+
+	// Zone names work on the existing zone routes as well
+	w = doTokenRequest(server, "GET", "/zones/named.example./rrsets?type=A", "admintoken", "")
+	if w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte("192.0.2.4")) {
+		t.Fatalf("list by zone name: %d %s", w.Code, w.Body.String())
+	}
+	if w := doTokenRequest(server, "GET", "/zones/missing.example", "admintoken", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("want 404 for an unknown zone name, got %d", w.Code)
+	}
+}
+
+func TestRRSetByID_IfMatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server, gormDB, _ := setupZoneTestServer(t, &config.Config{APIToken: "admintoken", DefaultTTL: 300})
+	z := dbm.Zone{Name: "byid.example"}
+	gormDB.Create(&z)
+	set := dbm.RRSet{ZoneID: z.ID, Name: "www.byid.example.", Type: "A", TTL: 300, Records: []dbm.RData{{Data: "192.0.2.1"}}}
+	gormDB.Create(&set)
+	path := fmt.Sprintf("/zones/%d/rrsets/%d", z.ID, set.ID)
+	etag := dbm.RRSetETag(set)
+
+	do := func(method, body, ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer admintoken")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", ifMatch)
+		w := httptest.NewRecorder()
+		server.r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Two clients update from the same ETag; the second one loses
+	if w := do("PUT", `{"name":"www","type":"A","records":[{"data":"192.0.2.2"}]}`, etag); w.Code != http.StatusOK {
+		t.Fatalf("first update: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("PUT", `{"name":"www","type":"A","records":[{"data":"192.0.2.3"}]}`, etag); w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("second update must fail, got %d", w.Code)
+	}
+	if w := do("DELETE", "", etag); w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("stale delete must fail, got %d", w.Code)
+	}
+	live := dbm.RRSetSnapshot(gormDB, set.ID)
+	if live == nil || len(live.Records) != 1 || live.Records[0].Data != "192.0.2.2" {
+		t.Fatalf("stale requests changed the RRSet: %+v", live)
+	}
+	if w := do("DELETE", "", dbm.RRSetETag(*live)); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("PUT", `{"name":"www","type":"A","records":[{"data":"192.0.2.4"}]}`, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("update of a deleted RRSet: %d", w.Code)
+	}
+}
+
+func TestIDN_UnicodeNames(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server, _, _ := setupZoneTestServer(t, &config.Config{APIToken: "admintoken", DefaultTTL: 300})
+
+	w := doTokenRequest(server, "POST", "/zones", "admintoken", `{"name":"Пример.РФ"}`)
+	var zone struct {
+		ID          uint   `json:"id"`
+		Name        string `json:"name"`
+		UnicodeName string `json:"unicode_name"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &zone)
+	if w.Code != http.StatusCreated || zone.Name != "xn--e1afmkfd.xn--p1ai" || zone.UnicodeName != "пример.рф" {
+		t.Fatalf("create zone: %d %s", w.Code, w.Body.String())
+	}
+
+	// Both forms address the zone and its RRSets
+	w = doTokenRequest(server, "PUT", "/zones/"+url.PathEscape("пример.рф")+"/rrsets/"+url.PathEscape("почта")+"/A", "admintoken",
+		`{"records":[{"data":"192.0.2.1"}]}`)
+	if w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"name":"xn--80a1acny.xn--e1afmkfd.xn--p1ai."`) ||
+		!strings.Contains(w.Body.String(), `"unicode_name":"почта.пример.рф."`) {
+		t.Fatalf("put by unicode name: %d %s", w.Code, w.Body.String())
+	}
+	if w := doTokenRequest(server, "GET", "/zones/xn--e1afmkfd.xn--p1ai/rrsets/xn--80a1acny/A", "admintoken", ""); w.Code != http.StatusOK {
+		t.Fatalf("get by punycode: %d %s", w.Code, w.Body.String())
+	}
+	for _, q := range []string{"name=" + url.QueryEscape("пример.рф"), "q=" + url.QueryEscape("пример"), "q=xn--e1afmkfd"} {
+		w = doTokenRequest(server, "GET", "/zones?"+q, "admintoken", "")
+		if !strings.Contains(w.Body.String(), fmt.Sprintf(`"id":%d`, zone.ID)) {
+			t.Fatalf("list zones by %s: %s", q, w.Body.String())
+		}
+	}
+}
@@ -33,6 +33,10 @@ func setupTestDB(t *testing.T) *gorm.DB {
 		&dbm.RData{},
 		&dbm.Template{},
 		&dbm.TemplateRecord{},
+		&dbm.APIToken{},
+		&dbm.AuditLog{},
+		&dbm.ZoneVersion{},
+		&dbm.Changeset{},
 	); err != nil {
 		t.Fatalf("failed to migrate db: %v", err)
 	}
@@ -550,6 +554,17 @@ func TestSyncImport(t *testing.T) {
 			wantStatus:  http.StatusBadRequest,
 			description: "Should reject invalid JSON payload",
 		},
+		{
+			name: "import zone of an unknown view",
+			setupExisting: func(db *gorm.DB) {
+				// No setup needed
+			},
+			importData: SyncData{
+				Zones: []dbm.Zone{{Name: "unknown-view.com", View: "internal"}},
+			},
+			wantStatus:  http.StatusBadRequest,
+			description: "Should reject zones of views the config does not have",
+		},
 	}
 
 	for _, tt := range tests {
@@ -589,3 +604,52 @@ func TestSyncImport(t *testing.T) {
 	}
 }
 
+func TestSyncImport_DryRun(t *testing.T) {
+	db := setupTestDB(t)
+	zone := dbm.Zone{Name: "dryrun-sync.test"}
+	if err := db.Create(&zone).Error; err != nil {
+		t.Fatalf("create zone: %v", err)
+	}
+	if err := db.Create(&dbm.RRSet{ZoneID: zone.ID, Name: "www.dryrun-sync.test.", Type: "A", TTL: 300, Records: []dbm.RData{{Data: "192.0.2.1"}}}).Error; err != nil {
+		t.Fatalf("create rrset: %v", err)
+	}
+	before := dbm.ZoneSnapshot(db, zone.ID)
+	server := NewServer(&config.Config{}, db, &mockDNSServer{})
+
+	body, _ := json.Marshal(SyncData{Zones: []dbm.Zone{
+		{Name: "dryrun-sync.test", RRSets: []dbm.RRSet{{Name: "www.dryrun-sync.test.", Type: "A", TTL: 300, Records: []dbm.RData{{Data: "192.0.2.2", Country: stringPtr("DE")}}}}},
+		{Name: "dryrun-new.test"},
+	}})
+	req := httptest.NewRequest("POST", "/sync/import?dry_run=true", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	server.r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		DryRun bool           `json:"dry_run"`
+		Diff   []dbm.ZoneDiff `json:"diff"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !resp.DryRun || len(resp.Diff) != 2 {
+		t.Fatalf("unexpected response %s", w.Body.String())
+	}
+	if d := resp.Diff[0]; d.Changed != 1 || d.Changes[0].After.Records[0].Country == nil {
+		t.Errorf("changed rrset not reported with its geo attributes: %+v", d)
+	}
+	if !resp.Diff[1].Created {
+		t.Errorf("new zone not reported as created: %+v", resp.Diff[1])
+	}
+	if !dbm.SameRRSets(before, dbm.ZoneSnapshot(db, zone.ID)) {
+		t.Error("dry run changed the zone")
+	}
+	var n int64
+	db.Model(&dbm.Zone{}).Where("name = ?", "dryrun-new.test").Count(&n)
+	if n != 0 {
+		t.Error("dry run created a zone")
+	}
+}
@@ -0,0 +1,265 @@
+package rest
+
+import (
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "slices"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "gorm.io/gorm"
+
+    dbm "namedot/internal/db"
+    "namedot/internal/server/rest/zoneio"
+    "namedot/internal/validate"
+)
+
+// reverseZoneReq is the body of POST /reverse-zones
+type reverseZoneReq struct {
+    CIDR        string   `json:"cidr"`
+    View        string   `json:"view"`
+    Nameservers []string `json:"nameservers"` // NS of the new zones and of RFC 2317 delegations
+    TTL         uint32   `json:"ttl"`         // of the NS and delegation records
+    zoneMetaReq
+}
+
+// createReverseZones creates the in-addr.arpa or ip6.arpa zones of a prefix.
+// For an IPv4 prefix longer than /24 it creates the RFC 2317 classless zone
+// and, when the /24 zone is hosted in the same view, adds the CNAMEs and the
+// NS delegation to it.
+func (s *Server) createReverseZones(c *gin.Context) {
+    var req reverseZoneReq
+    if err := c.ShouldBindJSON(&req); err != nil || req.CIDR == "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+        return
+    }
+    rzs, err := dbm.ReverseZonesFor(req.CIDR)
+    if err != nil {
+        validationFailed(c, validate.Errors{{Field: "cidr", Message: err.Error()}})
+        return
+    }
+    if !s.cfg.HasView(req.View) {
+        c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown view %q", req.View)})
+        return
+    }
+    var errs validate.Errors
+    nameservers := make([]string, 0, len(req.Nameservers))
+    for i, ns := range req.Nameservers {
+        n, err := validate.ZoneName(ns)
+        if err != nil {
+            errs.AddErr(fmt.Sprintf("nameservers[%d]", i), err)
+            continue
+        }
+        nameservers = append(nameservers, n+".")
+    }
+    if err := validate.TTL(req.TTL); err != nil {
+        errs.AddErr("ttl", err)
+    }
+    if len(errs) > 0 {
+        validationFailed(c, errs)
+        return
+    }
+    ttl := req.TTL
+    if ttl == 0 {
+        ttl = s.cfg.DefaultTTL
+    }
+    tok := currentToken(c)
+    for _, rz := range rzs {
+        if !tok.AllowsZone(rz.Name) || rz.Parent != "" && !tok.AllowsZone(rz.Parent) {
+            c.JSON(http.StatusForbidden, gin.H{"error": "zone not allowed for this token"})
+            return
+        }
+    }
+
+    var zones []dbm.Zone
+    var parent *dbm.Zone
+    var parentBefore []dbm.RRSet
+    err = s.db.Transaction(func(tx *gorm.DB) error {
+        for _, rz := range rzs {
+            var n int64
+            tx.Model(&dbm.Zone{}).Where("name IN ? AND view = ?", []string{rz.Name, rz.Name + "."}, req.View).Count(&n)
+            if n > 0 {
+                return fmt.Errorf("%w: %s", errZoneExists, rz.Name)
+            }
+            z := dbm.Zone{Name: rz.Name, View: req.View}
+            if err := req.apply(&z); err != nil {
+                return err
+            }
+            if err := tx.Create(&z).Error; err != nil {
+                return err
+            }
+            if len(nameservers) > 0 {
+                ns := dbm.RRSet{ZoneID: z.ID, Name: rz.Name + ".", Type: "NS", TTL: ttl}
+                for _, n := range nameservers {
+                    ns.Records = append(ns.Records, dbm.RData{Data: n})
+                }
+                if err := tx.Create(&ns).Error; err != nil {
+                    return err
+                }
+            }
+            if err := audit(tx, c, dbm.AuditZoneCreate, z, nil, z); err != nil {
+                return err
+            }
+            zones = append(zones, z)
+            if rz.Parent == "" {
+                continue
+            }
+            var p dbm.Zone
+            if tx.Where("name IN ? AND view = ?", []string{rz.Parent, rz.Parent + "."}, req.View).Limit(1).Find(&p).RowsAffected == 0 {
+                continue
+            }
+            parent, parentBefore = &p, dbm.ZoneSnapshot(tx, p.ID)
+            for _, rs := range dbm.ClasslessDelegation(rz, nameservers, ttl) {
+                rs.ZoneID = p.ID
+                // Replaced for good, soft-deleted rows would collide with the unique index
+                var ids []uint
+                tx.Unscoped().Model(&dbm.RRSet{}).Where("zone_id = ? AND name = ? AND type = ?", p.ID, rs.Name, rs.Type).Pluck("id", &ids)
+                if len(ids) > 0 {
+                    if err := tx.Unscoped().Where("rr_set_id IN ?", ids).Delete(&dbm.RData{}).Error; err != nil {
+                        return err
+                    }
+                    if err := tx.Unscoped().Delete(&dbm.RRSet{}, ids).Error; err != nil {
+                        return err
+                    }
+                }
+                if err := tx.Create(&rs).Error; err != nil {
+                    return err
+                }
+            }
+            after := dbm.ZoneSnapshot(tx, p.ID)
+            if errs := validate.Zone(after, p.Name); len(errs) > 0 {
+                return errs.Prefix(p.Name)
+            }
+            if err := audit(tx, c, dbm.AuditRRSetBatch, p, parentBefore, after); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+    switch {
+    case errors.Is(err, errZoneExists):
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    case validationFailed(c, err):
+        return
+    case err != nil:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    for _, z := range zones {
+        s.bumpSerial(z)
+    }
+    resp := gin.H{"zones": zones}
+    if parent != nil {
+        s.bumpSerial(*parent)
+        resp["delegated_in"] = parent.Name
+    }
+    // Invalidate DNS zone cache
+    if s.dnsServer != nil {
+        s.dnsServer.InvalidateZoneCache()
+    }
+    c.JSON(http.StatusCreated, resp)
+}
+
+// errZoneExists fails the creation of a zone that is already hosted
+var errZoneExists = errors.New("zone already exists")
+
+// generateRRSets fills a range of names as BIND's $GENERATE does
+func (s *Server) generateRRSets(c *gin.Context) {
+    var z dbm.Zone
+    if err := s.db.First(&z, c.Param("id")).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "zone not found"})
+        return
+    }
+    var spec zoneio.GenerateSpec
+    if err := c.ShouldBindJSON(&spec); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+        return
+    }
+    before := dbm.ZoneSnapshot(s.db, z.ID)
+    var sets []dbm.RRSet
+    var after []dbm.RRSet
+    var genErr error
+    err := s.db.Transaction(func(tx *gorm.DB) error {
+        if sets, genErr = zoneio.Generate(tx, &z, spec, z.TTLDefault(s.cfg.DefaultTTL)); genErr != nil {
+            return genErr
+        }
+        after = dbm.ZoneSnapshot(tx, z.ID)
+        return audit(tx, c, dbm.AuditRRSetGenerate, z, before, after)
+    })
+    if validationFailed(c, genErr) {
+        return
+    } else if genErr != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": genErr.Error()})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    s.bumpSerial(z)
+    s.syncPTRs(c, z, before, after)
+    // Invalidate DNS cache after zone record change
+    if s.dnsServer != nil {
+        s.dnsServer.InvalidateZoneCache()
+    }
+    c.JSON(http.StatusCreated, gin.H{"rrsets": sets})
+}
+
+// syncPTRs updates the PTR records of auto_ptr addresses in the hosted
+// reverse zones after the RRSets of z changed from before to after. Like the
+// audit, a failure is logged and does not fail the request. Reverse zones
+// outside the token restrictions are left alone and listed in the
+// X-Auto-PTR-Skipped header.
+func (s *Server) syncPTRs(c *gin.Context, z dbm.Zone, before, after []dbm.RRSet) {
+    var skipped []string
+    err := s.db.Transaction(func(tx *gorm.DB) error {
+        bumps := s.serials().Batch(tx)
+        var err error
+        if skipped, err = s.autoPTRs(tx, c, z, before, after, bumps); err != nil {
+            return err
+        }
+        return bumps.Flush()
+    })
+    if err != nil {
+        log.Printf("auto PTR update for %s failed: %v", z.Name, err)
+        return
+    }
+    reportSkippedPTRs(c, z, skipped)
+}
+
+// autoPTRs is syncPTRs inside the transaction tx of the change itself: the
+// changed reverse zones are touched in bumps and audited in tx. It returns
+// the reverse zones left alone because of the token restrictions.
+func (s *Server) autoPTRs(tx *gorm.DB, c *gin.Context, z dbm.Zone, before, after []dbm.RRSet, bumps *dbm.SerialBatch) ([]string, error) {
+    tok := currentToken(c)
+    allow := func(rz dbm.Zone) bool { return tok.AllowsZone(rz.Name) }
+    updates, err := dbm.SyncAutoPTR(tx, z.View, before, after, allow)
+    if err != nil {
+        return nil, err
+    }
+    var skipped []string
+    for _, u := range updates {
+        if u.Denied {
+            if name := strings.TrimSuffix(u.Zone.Name, "."); !slices.Contains(skipped, name) {
+                skipped = append(skipped, name)
+            }
+            continue
+        }
+        bumps.Touch(u.Zone)
+        if err := audit(tx, c, dbm.AuditRRSetAutoPTR, u.Zone, u.Before, u.After); err != nil {
+            return nil, err
+        }
+    }
+    return skipped, nil
+}
+
+// reportSkippedPTRs logs the reverse zones autoPTRs left alone and lists them
+// in the X-Auto-PTR-Skipped header
+func reportSkippedPTRs(c *gin.Context, z dbm.Zone, skipped []string) {
+    if len(skipped) > 0 {
+        log.Printf("auto PTR update for %s skipped reverse zones outside the token restrictions: %s", z.Name, strings.Join(skipped, ", "))
+        c.Header("X-Auto-PTR-Skipped", strings.Join(skipped, ","))
+    }
+}
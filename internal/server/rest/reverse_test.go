@@ -0,0 +1,167 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"namedot/internal/config"
+	"namedot/internal/db"
+)
+
+func TestReverseZones_ClasslessDelegationAndAutoPTR(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{APIToken: "testtoken", DefaultTTL: 300, AutoSOAOnMissing: true}
+	server, gormDB, _ := setupZoneTestServer(t, cfg)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer testtoken")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.r.ServeHTTP(w, req)
+		return w
+	}
+	rrset := func(zone, name, typ string) db.RRSet {
+		var z db.Zone
+		gormDB.Where("name = ?", zone).First(&z)
+		var rs db.RRSet
+		gormDB.Preload("Records").Where("zone_id = ? AND name = ? AND type = ?", z.ID, name, typ).Limit(1).Find(&rs)
+		return rs
+	}
+
+	if w := send("POST", "/reverse-zones", `{"cidr":"192.0.2.0/24","nameservers":["ns1.example.net"]}`); w.Code != http.StatusCreated {
+		t.Fatalf("create /24: %d %s", w.Code, w.Body.String())
+	}
+	if ns := rrset("2.0.192.in-addr.arpa", "2.0.192.in-addr.arpa.", "NS"); len(ns.Records) != 1 || ns.Records[0].Data != "ns1.example.net." {
+		t.Fatalf("unexpected NS %+v", ns)
+	}
+	if soa := rrset("2.0.192.in-addr.arpa", "2.0.192.in-addr.arpa.", "SOA"); len(soa.Records) != 1 {
+		t.Fatalf("reverse zone needs an SOA")
+	}
+
+	w := send("POST", "/reverse-zones", `{"cidr":"192.0.2.64/26","nameservers":["ns2.example.net"]}`)
+	if w.Code != http.StatusCreated || !bytes.Contains(w.Body.Bytes(), []byte(`"delegated_in":"2.0.192.in-addr.arpa"`)) {
+		t.Fatalf("create /26: %d %s", w.Code, w.Body.String())
+	}
+	if cname := rrset("2.0.192.in-addr.arpa", "65.2.0.192.in-addr.arpa.", "CNAME"); len(cname.Records) != 1 || cname.Records[0].Data != "65.64/26.2.0.192.in-addr.arpa." {
+		t.Fatalf("missing RFC 2317 CNAME %+v", cname)
+	}
+	if ns := rrset("2.0.192.in-addr.arpa", "64/26.2.0.192.in-addr.arpa.", "NS"); len(ns.Records) != 1 {
+		t.Fatalf("missing delegation %+v", ns)
+	}
+	if w := send("POST", "/reverse-zones", `{"cidr":"192.0.2.64/26"}`); w.Code != http.StatusConflict {
+		t.Fatalf("want conflict for an existing zone, got %d", w.Code)
+	}
+	if w := send("POST", "/reverse-zones", `{"cidr":"192.0.2.0/33"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("want 400 for a bad prefix, got %d", w.Code)
+	}
+
+	// auto_ptr records keep PTRs in the reverse zones
+	send("POST", "/zones", `{"name":"example.com"}`)
+	var z db.Zone
+	gormDB.Where("name = ?", "example.com").First(&z)
+	w = send("POST", fmt.Sprintf("/zones/%d/rrsets", z.ID), `{"name":"www","type":"A","records":[{"data":"192.0.2.10","auto_ptr":true},{"data":"192.0.2.70","auto_ptr":true}]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create rrset: %d %s", w.Code, w.Body.String())
+	}
+	var www db.RRSet
+	json.Unmarshal(w.Body.Bytes(), &www)
+	if ptr := rrset("2.0.192.in-addr.arpa", "10.2.0.192.in-addr.arpa.", "PTR"); len(ptr.Records) != 1 || ptr.Records[0].Data != "www.example.com." {
+		t.Fatalf("missing PTR %+v", ptr)
+	}
+	if ptr := rrset("64/26.2.0.192.in-addr.arpa", "70.64/26.2.0.192.in-addr.arpa.", "PTR"); len(ptr.Records) != 1 {
+		t.Fatalf("missing classless PTR %+v", ptr)
+	}
+	if _, total, _ := db.ListAudit(gormDB, db.AuditFilter{Action: db.AuditRRSetAutoPTR}); total != 2 {
+		t.Fatalf("PTR changes must be audited, got %d", total)
+	}
+	send("DELETE", fmt.Sprintf("/zones/%d/rrsets/%d", z.ID, www.ID), "")
+	if ptr := rrset("2.0.192.in-addr.arpa", "10.2.0.192.in-addr.arpa.", "PTR"); ptr.ID != 0 {
+		t.Fatalf("PTR must go with its record %+v", ptr)
+	}
+}
+
+func TestGenerateRRSets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{APIToken: "testtoken", DefaultTTL: 300, AutoSOAOnMissing: true}
+	server, gormDB, _ := setupZoneTestServer(t, cfg)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer testtoken")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.r.ServeHTTP(w, req)
+		return w
+	}
+	send("POST", "/reverse-zones", `{"cidr":"10.1.0.0/24"}`)
+	send("POST", "/zones", `{"name":"hosts.test"}`)
+	var z db.Zone
+	gormDB.Where("name = ?", "hosts.test").First(&z)
+
+	w := send("POST", fmt.Sprintf("/zones/%d/generate", z.ID), `{"range":"1-20/2","lhs":"host-${0,3,d}","type":"A","rhs":"10.1.0.$","auto_ptr":true}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("generate: %d %s", w.Code, w.Body.String())
+	}
+	var out struct{ RRSets []db.RRSet `json:"rrsets"` }
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil || len(out.RRSets) != 10 {
+		t.Fatalf("want 10 rrsets, got %s", w.Body.String())
+	}
+	var set db.RRSet
+	gormDB.Preload("Records").Where("zone_id = ? AND name = ?", z.ID, "host-019.hosts.test.").First(&set)
+	if set.TTL != 300 || len(set.Records) != 1 || set.Records[0].Data != "10.1.0.19" || !set.Records[0].AutoPTR {
+		t.Fatalf("unexpected generated rrset %+v", set)
+	}
+	var rev db.Zone
+	gormDB.Where("name = ?", "0.1.10.in-addr.arpa").First(&rev)
+	var ptrs int64
+	gormDB.Model(&db.RRSet{}).Where("zone_id = ? AND type = ?", rev.ID, "PTR").Count(&ptrs)
+	if ptrs != 10 {
+		t.Fatalf("want 10 PTRs, got %d", ptrs)
+	}
+
+	for _, body := range []string{
+		`{"range":"1-100000","lhs":"h$","type":"A","rhs":"10.1.0.1"}`,
+		`{"range":"1-2","lhs":"h$","type":"MX","rhs":"mail"}`,
+		`{"range":"1-2","lhs":"h$\n$INCLUDE /etc/passwd","type":"A","rhs":"10.1.0.1"}`,
+	} {
+		if w := send("POST", fmt.Sprintf("/zones/%d/generate", z.ID), body); w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: want 400, got %d %s", body, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestAutoPTR_SkipsReverseZonesOutsideToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server, gormDB, _ := setupZoneTestServer(t, &config.Config{APIToken: "admintoken", DefaultTTL: 300, AutoSOAOnMissing: true})
+
+	doTokenRequest(server, "POST", "/reverse-zones", "admintoken", `{"cidr":"10.2.0.0/24"}`)
+	doTokenRequest(server, "POST", "/zones", "admintoken", `{"name":"team.example"}`)
+	var z, rev db.Zone
+	gormDB.Where("name = ?", "team.example").First(&z)
+	gormDB.Where("name = ?", "0.2.10.in-addr.arpa").First(&rev)
+	gormDB.Create(&db.RRSet{ZoneID: rev.ID, Name: "5.0.2.10.in-addr.arpa.", Type: "PTR", TTL: 300, Records: []db.RData{{Data: "owner.other.example."}}})
+
+	w := doTokenRequest(server, "POST", "/tokens", "admintoken", `{"name":"team","scopes":["write"],"zones":["team.example"]}`)
+	var created tokenResp
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+
+	w = doTokenRequest(server, "POST", fmt.Sprintf("/zones/%d/rrsets", z.ID), created.Token, `{"name":"www","type":"A","records":[{"data":"10.2.0.5","auto_ptr":true}]}`)
+	if w.Code != http.StatusCreated || w.Header().Get("X-Auto-PTR-Skipped") != "0.2.10.in-addr.arpa" {
+		t.Fatalf("create rrset: %d %q %s", w.Code, w.Header().Get("X-Auto-PTR-Skipped"), w.Body.String())
+	}
+	var ptr db.RRSet
+	gormDB.Preload("Records").Where("zone_id = ? AND name = ? AND type = ?", rev.ID, "5.0.2.10.in-addr.arpa.", "PTR").First(&ptr)
+	if len(ptr.Records) != 1 || ptr.Records[0].Data != "owner.other.example." {
+		t.Fatalf("restricted token rewrote a PTR outside its zones: %+v", ptr)
+	}
+	if _, total, _ := db.ListAudit(gormDB, db.AuditFilter{Action: db.AuditRRSetAutoPTR}); total != 0 {
+		t.Fatalf("skipped PTR must not be audited, got %d", total)
+	}
+}
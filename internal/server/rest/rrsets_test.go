@@ -124,6 +124,61 @@ func TestCreateRRSet(t *testing.T) {
 			expectedStatus: http.StatusCreated,
 			description:    "Should create TXT record",
 		},
+		{
+			name:           "create ALIAS record at apex",
+			zoneID:         "1",
+			payload:        `{"name":"@","type":"ALIAS","ttl":300,"records":[{"data":"LB.Example.NET"}]}`,
+			expectedStatus: http.StatusCreated,
+			validateResult: func(t *testing.T, rr *db.RRSet) {
+				if rr.Name != "test.com." {
+					t.Errorf("Expected apex FQDN 'test.com.', got '%s'", rr.Name)
+				}
+				if len(rr.Records) != 1 || rr.Records[0].Data != "lb.example.net." {
+					t.Errorf("Expected normalized ALIAS target 'lb.example.net.', got %+v", rr.Records)
+				}
+			},
+			description: "Should store ALIAS target as lowercase FQDN",
+		},
+		{
+			name:           "reject ALIAS with invalid target",
+			zoneID:         "1",
+			payload:        `{"name":"bad-alias","type":"ALIAS","ttl":300,"records":[{"data":"192.0.2.1 extra"}]}`,
+			expectedStatus: http.StatusBadRequest,
+			description:    "Should reject ALIAS target that is not a host name",
+		},
+		{
+			name:           "create HTTPS record",
+			zoneID:         "1",
+			payload:        `{"name":"@","type":"HTTPS","ttl":300,"records":[{"data":"1 . alpn=h2,h3"}]}`,
+			expectedStatus: http.StatusCreated,
+			validateResult: func(t *testing.T, rr *db.RRSet) {
+				if len(rr.Records) != 1 || rr.Records[0].Data != `1 . alpn="h2,h3"` {
+					t.Errorf("Expected canonical HTTPS data, got %+v", rr.Records)
+				}
+			},
+			description: "Should store HTTPS record in canonical form",
+		},
+		{
+			name:           "reject CAA with invalid flags",
+			zoneID:         "1",
+			payload:        `{"name":"caa","type":"CAA","ttl":300,"records":[{"data":"5 issue \"ca.example\""}]}`,
+			expectedStatus: http.StatusBadRequest,
+			description:    "Should reject CAA flags other than 0 and 128",
+		},
+		{
+			name:           "reject A record with garbage",
+			zoneID:         "1",
+			payload:        `{"name":"bad-a","type":"A","ttl":300,"records":[{"data":"192.0.2.1 192.0.2.2"}]}`,
+			expectedStatus: http.StatusBadRequest,
+			description:    "Should reject record data that does not parse",
+		},
+		{
+			name:           "reject unsupported type",
+			zoneID:         "1",
+			payload:        `{"name":"bogus","type":"BOGUS","ttl":300,"records":[{"data":"x"}]}`,
+			expectedStatus: http.StatusBadRequest,
+			description:    "Should reject unknown record types",
+		},
 		{
 			name:           "create record with multiple RData",
 			zoneID:         "1",
@@ -3,19 +3,27 @@ package rest
 import (
     "context"
     "crypto/tls"
+    "errors"
     "fmt"
     "log"
     "net/http"
+    "strconv"
     "strings"
+    "sync/atomic"
     "time"
 
     "github.com/gin-gonic/gin"
-    "golang.org/x/crypto/bcrypt"
     "gorm.io/gorm"
 
+    "namedot/internal/authguard"
     "namedot/internal/config"
     dbm "namedot/internal/db"
+    "namedot/internal/idn"
+    "namedot/internal/metrics"
+    "namedot/internal/rdata"
+    dnssrv "namedot/internal/server/dns"
     "namedot/internal/server/rest/zoneio"
+    "namedot/internal/validate"
     "namedot/internal/web"
 )
 
@@ -31,11 +39,20 @@ type Server struct {
     httpServer *http.Server
     tlsStopCh  chan struct{}
     dnsServer  DNSServer
+    webAdmin   *web.Server
+    guard      *authguard.Guard
+
+    tokensSeen atomic.Bool // a database token exists or existed, see tokensIssued
 }
 
 func NewServer(cfg *config.Config, db *gorm.DB, dnsServer DNSServer) *Server {
     gin.SetMode(gin.ReleaseMode)
     r := gin.New()
+    // The client IP keys login throttling, the IP ACL and the audit log, so
+    // forwarding headers are only believed from configured proxies
+    if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+        log.Printf("trusted_proxies: %v", err)
+    }
     // Log all API requests to stdout
     r.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
         return fmt.Sprintf("API %s %s %d %s from %s\n",
@@ -53,68 +70,86 @@ func NewServer(cfg *config.Config, db *gorm.DB, dnsServer DNSServer) *Server {
         r.Use(ipACLMiddleware(cfg.AllowedCIDRs))
     }
 
-    s := &Server{cfg: cfg, db: db, r: r, dnsServer: dnsServer}
+    s := &Server{cfg: cfg, db: db, r: r, dnsServer: dnsServer, guard: authguard.New(cfg.Admin.LoginProtection)}
+    if issued, _ := dbm.APITokensIssued(db); issued {
+        s.tokensSeen.Store(true)
+    }
 
     // Public endpoints (no auth)
     r.GET("/health", s.health)
 
     // Web Admin UI
-    webAdmin, err := web.NewServer(cfg, db)
+    webAdmin, err := web.NewServer(cfg, db, dnsServer)
     if err != nil {
         log.Printf("Web admin initialization error: %v", err)
     } else if webAdmin != nil {
+        s.webAdmin = webAdmin
         webAdmin.RegisterRoutes(r)
         log.Printf("Web admin panel enabled at /admin")
     }
 
-    auth := func(c *gin.Context) {
-        token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
-
-        // Check if token is valid using either hash or plain text
-        authenticated := false
-
-        // Try hashed token first (recommended)
-        if s.cfg.APITokenHash != "" {
-            if err := bcrypt.CompareHashAndPassword([]byte(s.cfg.APITokenHash), []byte(token)); err == nil {
-                authenticated = true
-            }
-        } else if s.cfg.APIToken != "" {
-            // Fallback to plain text comparison (deprecated)
-            if token == s.cfg.APIToken {
-                authenticated = true
-            }
-        } else {
-            // No authentication configured, allow all
-            authenticated = true
-        }
-
-        if !authenticated {
-            c.AbortWithStatus(http.StatusUnauthorized)
-            return
-        }
-        c.Next()
-    }
+    read := requireScope(dbm.ScopeRead)
+    write := requireScope(dbm.ScopeWrite)
 
     api := r.Group("/")
-    api.Use(auth)
+    api.Use(s.authenticate)
     {
-        api.POST("/zones", s.createZone)
-        api.GET("/zones", s.listZones)
-        api.GET("/zones/:id", s.getZone)
-        api.DELETE("/zones/:id", s.deleteZone)
+        api.POST("/zones", write, s.createZone)
+        api.GET("/zones", read, s.listZones)
+        api.POST("/reverse-zones", write, s.createReverseZones)
+        api.GET("/zones/:id", read, s.zoneAccess, s.getZone)
+        api.PATCH("/zones/:id", write, s.zoneAccess, s.updateZone)
+        api.GET("/zones/:id/health", read, s.zoneAccess, s.zoneHealth)
+        api.DELETE("/zones/:id", write, s.zoneAccess, s.deleteZone)
+
+        api.POST("/zones/:id/rrsets", write, s.zoneAccess, s.createRRSet)
+        api.PUT("/zones/:id/rrsets/:rid", write, s.zoneAccess, s.updateRRSet)
+        api.PATCH("/zones/:id/rrsets/:rid", write, s.zoneAccess, s.patchRRSet)
+        api.DELETE("/zones/:id/rrsets/:rid", write, s.zoneAccess, s.deleteRRSet)
+        api.PATCH("/zones/:id/rrsets", write, s.zoneAccess, s.patchRRSets)
+        api.GET("/zones/:id/rrsets/:rid/:type", read, s.zoneAccess, s.getRRSetByName)
+        api.PUT("/zones/:id/rrsets/:rid/:type", write, s.zoneAccess, s.putRRSetByName)
+        api.DELETE("/zones/:id/rrsets/:rid/:type", write, s.zoneAccess, s.deleteRRSetByName)
+        api.GET("/zones/:id/rrsets", read, s.zoneAccess, s.listRRSets)
+        api.POST("/zones/:id/generate", write, s.zoneAccess, s.generateRRSets)
+        api.GET("/search/records", read, s.searchRecords)
+
+        api.GET("/zones/:id/export", read, s.zoneAccess, s.exportZone)
+        api.POST("/zones/:id/import", write, s.zoneAccess, s.importZone)
+        api.POST("/import/bind", write, s.importBulk)
+        api.GET("/zones/:id/audit", read, s.zoneAccess, s.zoneAudit)
+
+        // Zone versions
+        api.GET("/zones/:id/versions", read, s.zoneAccess, s.listVersions)
+        api.GET("/zones/:id/versions/:vid", read, s.zoneAccess, s.getVersion)
+        api.GET("/zones/:id/versions/:vid/diff", read, s.zoneAccess, s.diffVersion)
+        api.POST("/zones/:id/versions/:vid/rollback", write, s.zoneAccess, s.rollbackZone)
+
+        // Staged changesets
+        api.GET("/zones/:id/changeset", read, s.zoneAccess, s.getChangeset)
+        api.POST("/zones/:id/changeset", write, s.zoneAccess, s.startChangeset)
+        api.DELETE("/zones/:id/changeset", write, s.zoneAccess, s.discardChangeset)
+        api.PUT("/zones/:id/changeset/rrsets", write, s.zoneAccess, s.putChangesetRRSet)
+        api.DELETE("/zones/:id/changeset/rrsets/:name/:type", write, s.zoneAccess, s.deleteChangesetRRSet)
+        api.POST("/zones/:id/changeset/approve", requireScope(dbm.ScopeApprove), s.zoneAccess, s.approveChangeset)
+        api.POST("/zones/:id/changeset/publish", write, s.zoneAccess, s.publishChangeset)
 
-        api.POST("/zones/:id/rrsets", s.createRRSet)
-        api.PUT("/zones/:id/rrsets/:rid", s.updateRRSet)
-        api.PATCH("/zones/:id/rrsets/:rid", s.patchRRSet)
-        api.DELETE("/zones/:id/rrsets/:rid", s.deleteRRSet)
-        api.GET("/zones/:id/rrsets", s.listRRSets)
+        // Replication endpoints
+        sync := requireScope(dbm.ScopeSync)
+        api.GET("/sync/export", sync, requireAllZones, s.syncExport)
+        api.POST("/sync/import", sync, requireAllZones, s.syncImport)
 
-        api.GET("/zones/:id/export", s.exportZone)
-        api.POST("/zones/:id/import", s.importZone)
+        // API token management
+        admin := requireScope(dbm.ScopeAdmin)
+        api.POST("/tokens", admin, requireAllZones, s.createToken)
+        api.GET("/tokens", admin, requireAllZones, s.listTokens)
+        api.DELETE("/tokens/:id", admin, requireAllZones, s.revokeToken)
 
-        // Replication endpoints
-        api.GET("/sync/export", s.syncExport)
-        api.POST("/sync/import", s.syncImport)
+        // Audit log of all zones
+        api.GET("/audit", admin, requireAllZones, s.listAudit)
+
+        // Prometheus metrics
+        api.GET("/metrics", read, gin.WrapH(metrics.Handler()))
     }
     return s
 }
@@ -159,6 +194,7 @@ func (s *Server) Shutdown(ctx context.Context) error {
     if s.tlsStopCh != nil {
         close(s.tlsStopCh)
     }
+    s.webAdmin.Close()
 
     // Shutdown HTTP server gracefully
     if s.httpServer != nil {
@@ -198,6 +234,50 @@ func (s *Server) health(c *gin.Context) {
 
 type zoneReq struct {
     Name string `json:"name"`
+    View string `json:"view"`
+    zoneMetaReq
+}
+
+// zoneMetaReq holds the zone metadata of a request; fields left out keep
+// their current value
+type zoneMetaReq struct {
+    Kind         *string          `json:"kind"`
+    Description  *string          `json:"description"`
+    Owner        *string          `json:"owner"`
+    Tags         *[]string        `json:"tags"`
+    DefaultTTL   *uint32          `json:"default_ttl"`
+    SOA          *dbm.SOADefaults `json:"soa_defaults"`
+    SerialPolicy *string          `json:"serial_policy"`
+    Disabled     *bool            `json:"disabled"`
+}
+
+// apply sets the given metadata on z and validates the result
+func (m zoneMetaReq) apply(z *dbm.Zone) error {
+    if m.Kind != nil {
+        z.Kind = *m.Kind
+    }
+    if m.Description != nil {
+        z.Description = strings.TrimSpace(*m.Description)
+    }
+    if m.Owner != nil {
+        z.Owner = *m.Owner
+    }
+    if m.Tags != nil {
+        z.Tags = dbm.JoinTags(*m.Tags)
+    }
+    if m.DefaultTTL != nil {
+        z.DefaultTTL = *m.DefaultTTL
+    }
+    if m.SOA != nil {
+        z.SOA = *m.SOA
+    }
+    if m.SerialPolicy != nil {
+        z.SerialPolicy = *m.SerialPolicy
+    }
+    if m.Disabled != nil {
+        z.Disabled = *m.Disabled
+    }
+    return validate.ZoneMeta(z).Err()
 }
 
 func (s *Server) createZone(c *gin.Context) {
@@ -206,9 +286,36 @@ func (s *Server) createZone(c *gin.Context) {
         c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
         return
     }
-    z := dbm.Zone{Name: strings.ToLower(req.Name)}
-    if err := s.db.Create(&z).Error; err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    name, err := validate.ZoneName(req.Name)
+    if err != nil {
+        validationFailed(c, validate.Errors{{Field: "name", Message: err.Error()}})
+        return
+    }
+    if !currentToken(c).AllowsZone(name) {
+        c.JSON(http.StatusForbidden, gin.H{"error": "zone not allowed for this token"})
+        return
+    }
+    if !s.cfg.HasView(req.View) {
+        c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown view %q", req.View)})
+        return
+    }
+    z := dbm.Zone{Name: name, View: req.View}
+    if err := req.apply(&z); err != nil {
+        validationFailed(c, err)
+        return
+    }
+    var createErr error
+    err = s.db.Transaction(func(tx *gorm.DB) error {
+        if createErr = tx.Create(&z).Error; createErr != nil {
+            return createErr
+        }
+        return audit(tx, c, dbm.AuditZoneCreate, z, nil, z)
+    })
+    if createErr != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": createErr.Error()})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
     // Invalidate DNS zone cache
@@ -218,24 +325,127 @@ func (s *Server) createZone(c *gin.Context) {
     c.JSON(http.StatusCreated, z)
 }
 
+// listZones returns the zones the token may see. Filters: name, suffix, q
+// (substring), view, kind, tag; paging with limit, offset and sort (see pageParams).
 func (s *Server) listZones(c *gin.Context) {
-    var zs []dbm.Zone
-    if err := s.db.Find(&zs).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+    p, err := pageParams(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
+    f := dbm.ZoneFilter{
+        Page:    p,
+        Name:    c.Query("name"),
+        Suffix:  c.Query("suffix"),
+        Search:  c.Query("q"),
+        Kind:    c.Query("kind"),
+        Tag:     c.Query("tag"),
+        // Zone-restricted tokens only see their zones
+        Allowed: currentToken(c).ZoneList(),
+    }
+    // ?view=name limits the list to one view; ?view= (empty) selects the default view
+    if view, ok := c.GetQuery("view"); ok {
+        f.View = &view
+    }
+    zs, total, err := dbm.ListZones(s.db, f)
+    if err != nil {
+        listError(c, err)
+        return
+    }
+    setPageHeaders(c, p, len(zs), total)
     c.JSON(http.StatusOK, zs)
 }
 
+// getZone returns a zone with its RRSets; ?rrsets=false leaves them out for
+// large zones, which can be paged through GET /zones/:id/rrsets
 func (s *Server) getZone(c *gin.Context) {
     var z dbm.Zone
-    if err := s.db.Preload("RRSets").First(&z, c.Param("id")).Error; err != nil {
+    q := s.db
+    if c.Query("rrsets") != "false" {
+        q = q.Preload("RRSets")
+    }
+    if err := q.First(&z, c.Param("id")).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+        return
+    }
+    c.JSON(http.StatusOK, z)
+}
+
+// updateZone changes the metadata of a zone; name and view stay as they are
+func (s *Server) updateZone(c *gin.Context) {
+    var z dbm.Zone
+    if err := s.db.First(&z, c.Param("id")).Error; err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
         return
     }
+    var req zoneMetaReq
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+        return
+    }
+    before := z
+    if err := req.apply(&z); err != nil {
+        validationFailed(c, err)
+        return
+    }
+    if err := s.db.Transaction(func(tx *gorm.DB) error {
+        if err := dbm.UpdateZoneMeta(tx, &z); err != nil {
+            return err
+        }
+        return audit(tx, c, dbm.AuditZoneUpdate, z, before, z)
+    }); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    // Disabling a zone takes it out of DNS answers
+    if s.dnsServer != nil {
+        s.dnsServer.InvalidateZoneCache()
+    }
     c.JSON(http.StatusOK, z)
 }
 
+type invalidRecord struct {
+    RRSetID  uint   `json:"rrset_id"`
+    RecordID uint   `json:"record_id"`
+    Name     string `json:"name"`
+    Type     string `json:"type"`
+    Data     string `json:"data"`
+    Error    string `json:"error"`
+}
+
+// zoneHealth compiles every stored record of a zone the way DNS answers do
+// and lists the rows that fail; such rows are left out of DNS answers.
+func (s *Server) zoneHealth(c *gin.Context) {
+    var z dbm.Zone
+    if err := s.db.Preload("RRSets.Records").First(&z, c.Param("id")).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+        return
+    }
+    total := 0
+    invalid := []invalidRecord{}
+    for _, rs := range z.RRSets {
+        for _, rec := range rs.Records {
+            total++
+            if _, err := dnssrv.CompileRecord(rs.Type, rec.Data, z.Name); err != nil {
+                invalid = append(invalid, invalidRecord{
+                    RRSetID: rs.ID, RecordID: rec.ID, Name: rs.Name, Type: rs.Type, Data: rec.Data, Error: err.Error(),
+                })
+            }
+        }
+    }
+    status := "ok"
+    if len(invalid) > 0 {
+        status = "degraded"
+    }
+    c.JSON(http.StatusOK, gin.H{
+        "zone_id": z.ID,
+        "zone":    z.Name,
+        "status":  status,
+        "records": total,
+        "invalid": invalid,
+    })
+}
+
 func (s *Server) deleteZone(c *gin.Context) {
     var z dbm.Zone
     if err := s.db.First(&z, c.Param("id")).Error; err != nil {
@@ -243,13 +453,14 @@ func (s *Server) deleteZone(c *gin.Context) {
         return
     }
     if err := s.db.Transaction(func(tx *gorm.DB) error {
+        before := dbm.ZoneSnapshot(tx, z.ID)
         if err := tx.Where("zone_id = ?", z.ID).Delete(&dbm.RRSet{}).Error; err != nil {
             return err
         }
         if err := tx.Delete(&z).Error; err != nil {
             return err
         }
-        return nil
+        return audit(tx, c, dbm.AuditZoneDelete, z, before, nil)
     }); err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
@@ -269,10 +480,18 @@ type rrsetReq struct {
 }
 
 func fqdn(name, zone string) string {
-    n := strings.ToLower(name)
+    // Unicode labels are stored in punycode; names that fail to convert are
+    // left for validation to report
+    n, err := idn.ToASCII(name)
+    if err != nil {
+        n = strings.ToLower(name)
+    }
     // Support convenience syntax: trailing ".@" means "relative to zone apex"
     if strings.HasSuffix(n, ".@") {
         n = strings.TrimSuffix(n, ".@")
+    } else if n != "." && strings.HasSuffix(n, ".") {
+        // Absolute name; validation rejects it when outside the zone
+        return n
     }
     n = strings.TrimSuffix(n, ".")
     z := strings.TrimSuffix(strings.ToLower(zone), ".")
@@ -321,26 +540,35 @@ func (s *Server) createRRSet(c *gin.Context) {
         TTL:     req.TTL,
         Records: req.recordsNormalized(),
     }
-    if set.TTL == 0 && s.cfg.DefaultTTL > 0 {
-        set.TTL = s.cfg.DefaultTTL
+    if set.TTL == 0 {
+        set.TTL = z.TTLDefault(s.cfg.DefaultTTL)
     }
-    // Expand CNAME "@" shorthand in record data to apex FQDN before save
-    if strings.EqualFold(set.Type, "CNAME") {
-        for i := range set.Records {
-            if strings.TrimSpace(set.Records[i].Data) == "@" {
-                set.Records[i].Data = fqdn("@", z.Name)
-            }
-        }
+    // Validate and canonicalize record data ("@" in CNAME data becomes the apex FQDN)
+    if err := s.checkRRSet(z, &set); err != nil {
+        respondInvalid(c, err)
+        return
     }
-    if err := s.db.Create(&set).Error; err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    var createErr error
+    err = s.db.Transaction(func(tx *gorm.DB) error {
+        if createErr = tx.Create(&set).Error; createErr != nil {
+            return createErr
+        }
+        return audit(tx, c, dbm.AuditRRSetCreate, z, nil, set)
+    })
+    if createErr != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": createErr.Error()})
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
-    dbm.BumpSOASerialAuto(s.db, z, s.cfg.AutoSOAOnMissing)
+    s.bumpSerial(z)
+    s.syncPTRs(c, z, nil, []dbm.RRSet{set})
     // Invalidate DNS cache after zone record change
     if s.dnsServer != nil {
         s.dnsServer.InvalidateZoneCache()
     }
+    c.Header("ETag", dbm.RRSetETag(set))
     c.JSON(http.StatusCreated, set)
 }
 
@@ -351,7 +579,7 @@ func (s *Server) updateRRSet(c *gin.Context) {
         return
     }
     var set dbm.RRSet
-    if err := s.db.Preload("Records").Where("zone_id = ? AND id = ?", z.ID, c.Param("rid")).First(&set).Error; err != nil {
+    if err := s.db.Where("zone_id = ? AND id = ?", z.ID, c.Param("rid")).First(&set).Error; err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": "rrset not found"})
         return
     }
@@ -363,32 +591,52 @@ func (s *Server) updateRRSet(c *gin.Context) {
     set.Name = strings.ToLower(fqdn(req.Name, z.Name))
     set.Type = strings.ToUpper(req.Type)
     set.TTL = req.TTL
-    if set.TTL == 0 && s.cfg.DefaultTTL > 0 {
-        set.TTL = s.cfg.DefaultTTL
+    if set.TTL == 0 {
+        set.TTL = z.TTLDefault(s.cfg.DefaultTTL)
     }
-    // replace records
-    if err := s.db.Transaction(func(tx *gorm.DB) error {
+    set.Records = req.recordsNormalized()
+    if err := s.checkRRSet(z, &set); err != nil {
+        respondInvalid(c, err)
+        return
+    }
+    // The precondition is checked on the row locked by the write, so two
+    // clients sending the same If-Match cannot both succeed
+    var before *dbm.RRSet
+    err := s.db.Transaction(func(tx *gorm.DB) error {
+        var err error
+        if before, err = lockRRSetByID(tx, z.ID, c.Param("rid")); err != nil || before == nil {
+            return err
+        }
+        if !preconditionsMet(c, before) {
+            return errPrecondition
+        }
+        set.CreatedAt = before.CreatedAt
         if err := tx.Where("rr_set_id = ?", set.ID).Delete(&dbm.RData{}).Error; err != nil {
             return err
         }
-        set.Records = req.recordsNormalized()
-        if strings.EqualFold(set.Type, "CNAME") {
-            for i := range set.Records {
-                if strings.TrimSpace(set.Records[i].Data) == "@" {
-                    set.Records[i].Data = fqdn("@", z.Name)
-                }
-            }
+        if err := tx.Save(&set).Error; err != nil {
+            return err
         }
-        return tx.Save(&set).Error
-    }); err != nil {
+        return audit(tx, c, dbm.AuditRRSetUpdate, z, *before, set)
+    })
+    switch {
+    case errors.Is(err, errPrecondition):
+        preconditionFailed(c)
+        return
+    case err != nil:
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
+    case before == nil:
+        c.JSON(http.StatusNotFound, gin.H{"error": "rrset not found"})
+        return
     }
-    dbm.BumpSOASerialAuto(s.db, z, s.cfg.AutoSOAOnMissing)
+    s.bumpSerial(z)
+    s.syncPTRs(c, z, []dbm.RRSet{*before}, []dbm.RRSet{set})
     // Invalidate DNS cache after zone record change
     if s.dnsServer != nil {
         s.dnsServer.InvalidateZoneCache()
     }
+    c.Header("ETag", dbm.RRSetETag(set))
     c.JSON(http.StatusOK, set)
 }
 
@@ -400,11 +648,32 @@ func (s *Server) deleteRRSet(c *gin.Context) {
         c.JSON(http.StatusNotFound, gin.H{"error": "zone not found"})
         return
     }
-    if err := s.db.Delete(&dbm.RRSet{}, "zone_id = ? AND id = ?", z.ID, c.Param("rid")).Error; err != nil {
+    var before *dbm.RRSet
+    err := s.db.Transaction(func(tx *gorm.DB) error {
+        var err error
+        if before, err = lockRRSetByID(tx, z.ID, c.Param("rid")); err != nil || before == nil {
+            return err
+        }
+        if !preconditionsMet(c, before) {
+            return errPrecondition
+        }
+        if err := tx.Delete(&dbm.RRSet{}, before.ID).Error; err != nil {
+            return err
+        }
+        return audit(tx, c, dbm.AuditRRSetDelete, z, *before, nil)
+    })
+    switch {
+    case errors.Is(err, errPrecondition):
+        preconditionFailed(c)
+        return
+    case err != nil:
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
-    dbm.BumpSOASerial(s.db, z.ID)
+    if before != nil {
+        s.syncPTRs(c, z, []dbm.RRSet{*before}, nil)
+    }
+    s.bumpSerial(z)
     // Invalidate DNS cache after zone record change
     if s.dnsServer != nil {
         s.dnsServer.InvalidateZoneCache()
@@ -412,12 +681,29 @@ func (s *Server) deleteRRSet(c *gin.Context) {
     c.Status(http.StatusNoContent)
 }
 
+// listRRSets returns the RRSets of a zone. Filters: name (relative or FQDN),
+// suffix, type, q (substring of the name); paging with limit, offset and sort.
 func (s *Server) listRRSets(c *gin.Context) {
-    var sets []dbm.RRSet
-    if err := s.db.Preload("Records").Where("zone_id = ?", c.Param("id")).Find(&sets).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+    p, err := pageParams(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
+    var z dbm.Zone
+    if err := s.db.First(&z, c.Param("id")).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "zone not found"})
+        return
+    }
+    f := dbm.RRSetFilter{Page: p, ZoneID: z.ID, Suffix: c.Query("suffix"), Type: c.Query("type"), Search: c.Query("q")}
+    if name := c.Query("name"); name != "" {
+        f.Name = fqdn(name, z.Name)
+    }
+    sets, total, err := dbm.ListRRSets(s.db, f)
+    if err != nil {
+        listError(c, err)
+        return
+    }
+    setPageHeaders(c, p, len(sets), total)
     c.JSON(http.StatusOK, sets)
 }
 
@@ -432,7 +718,8 @@ func (s *Server) exportZone(c *gin.Context) {
     case "json":
         c.JSON(http.StatusOK, z)
     case "bind":
-        txt := zoneio.ToBind(&z)
+        geo, _ := strconv.ParseBool(c.Query("geo"))
+        txt := zoneio.ToBind(&z, zoneio.BindOptions{DefaultTTL: s.cfg.DefaultTTL, Geo: geo})
         c.String(http.StatusOK, txt)
     default:
         c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported format"})
@@ -448,6 +735,9 @@ func (s *Server) importZone(c *gin.Context) {
         c.JSON(http.StatusNotFound, gin.H{"error": "zone not found"})
         return
     }
+    before := append([]dbm.RRSet(nil), z.RRSets...)
+    ttl := z.TTLDefault(s.cfg.DefaultTTL)
+    var apply func(tx *gorm.DB) error
     switch format {
     case "json":
         var in dbm.Zone
@@ -455,30 +745,106 @@ func (s *Server) importZone(c *gin.Context) {
             c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
             return
         }
-        if err := zoneio.ImportJSON(s.db, &z, &in, mode, s.cfg.DefaultTTL); err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-            return
-        }
-        dbm.BumpSOASerialAuto(s.db, z, s.cfg.AutoSOAOnMissing)
-        // Invalidate DNS cache after zone import
-        if s.dnsServer != nil {
-            s.dnsServer.InvalidateZoneCache()
-        }
-        c.Status(http.StatusNoContent)
+        apply = func(tx *gorm.DB) error { return zoneio.ImportJSON(tx, &z, &in, mode, ttl) }
     case "bind":
-        if err := zoneio.ImportBIND(s.db, &z, c.Request.Body, mode, s.cfg.DefaultTTL); err != nil {
+        apply = func(tx *gorm.DB) error { return zoneio.ImportBIND(tx, &z, c.Request.Body, mode, ttl) }
+    default:
+        c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported format"})
+        return
+    }
+    failed := func(err error) {
+        var rerr *rdata.Error
+        switch {
+        case validationFailed(c, err):
+        case format == "bind" || errors.As(err, &rerr):
             c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        default:
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        }
+    }
+
+    // A dry run answers what the import would change and rolls it back
+    if c.Query("dry_run") == "true" {
+        diff, err := dbm.DryRun(s.db, z, apply)
+        if err != nil {
+            failed(err)
             return
         }
-        dbm.BumpSOASerialAuto(s.db, z, s.cfg.AutoSOAOnMissing)
-        // Invalidate DNS cache after zone import
-        if s.dnsServer != nil {
-            s.dnsServer.InvalidateZoneCache()
+        c.JSON(http.StatusOK, diff)
+        return
+    }
+    var after []dbm.RRSet
+    var applyErr error
+    err := s.db.Transaction(func(tx *gorm.DB) error {
+        if applyErr = apply(tx); applyErr != nil {
+            return applyErr
         }
-        c.Status(http.StatusNoContent)
-    default:
-        c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported format"})
+        after = dbm.ZoneSnapshot(tx, z.ID)
+        return audit(tx, c, dbm.AuditZoneImport, z, before, after)
+    })
+    if applyErr != nil {
+        failed(applyErr)
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    s.bumpSerial(z)
+    s.syncPTRs(c, z, before, after)
+    // Invalidate DNS cache after zone import
+    if s.dnsServer != nil {
+        s.dnsServer.InvalidateZoneCache()
+    }
+    c.Status(http.StatusNoContent)
+}
+
+// bulkResp is the report of a bulk import with the error that stopped it
+type bulkResp struct {
+    Error string `json:"error,omitempty"`
+    zoneio.BulkReport
+}
+
+// importBulk imports the BIND zone files of a tarball, gzip-compressed or
+// not, optionally with a named.conf; dry_run=true reports the changes only
+func (s *Server) importBulk(c *gin.Context) {
+    mode := strings.ToLower(c.DefaultQuery("mode", "upsert"))
+    if mode != "upsert" && mode != "replace" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be upsert or replace"})
+        return
+    }
+    view := c.Query("view")
+    if !s.cfg.HasView(view) {
+        c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown view %q", view)})
+        return
+    }
+    sb, err := zoneio.LoadTar(http.MaxBytesReader(c.Writer, c.Request.Body, zoneio.MaxBulkSize))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    tok := currentToken(c)
+    rep, err := zoneio.BulkImport(s.db, sb, zoneio.BulkOptions{
+        Mode:       mode,
+        View:       view,
+        DefaultTTL: s.cfg.DefaultTTL,
+        DryRun:     c.Query("dry_run") == "true",
+        HasView:    s.cfg.HasView,
+        Allow:      tok.AllowsZone,
+        Actor:      actor(c),
+        Serials:    s.serials(),
+    })
+    switch {
+    case errors.Is(err, zoneio.ErrBulkImport):
+        c.JSON(http.StatusBadRequest, bulkResp{Error: err.Error(), BulkReport: rep})
+        return
+    case err != nil:
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
     }
+    if !rep.DryRun && s.dnsServer != nil {
+        s.dnsServer.InvalidateZoneCache()
+    }
+    c.JSON(http.StatusOK, bulkResp{BulkReport: rep})
 }
 
 func (r rrsetReq) recordsNormalized() []dbm.RData {
@@ -489,6 +855,7 @@ func (r rrsetReq) recordsNormalized() []dbm.RData {
         rr.Continent = normalizePtr(x.Continent)
         rr.ASN = x.ASN
         rr.Subnet = normalizePtr(x.Subnet)
+        rr.AutoPTR = x.AutoPTR
         out = append(out, rr)
     }
     return out
@@ -532,7 +899,8 @@ func (s *Server) syncExport(c *gin.Context) {
     })
 }
 
-// syncImport imports all zones and templates from master
+// syncImport imports all zones and templates from master. With
+// dry_run=true it answers the zones that would change and changes nothing.
 func (s *Server) syncImport(c *gin.Context) {
     var data SyncData
     if err := c.ShouldBindJSON(&data); err != nil {
@@ -540,24 +908,43 @@ func (s *Server) syncImport(c *gin.Context) {
         return
     }
 
+    for _, zone := range data.Zones {
+        if !s.cfg.HasView(zone.View) {
+            c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("zone %s: unknown view %q", zone.Name, zone.View)})
+            return
+        }
+    }
+
+    dryRun := c.Query("dry_run") == "true"
+    diffs := []dbm.ZoneDiff{}
     err := s.db.Transaction(func(tx *gorm.DB) error {
         // Import zones
         for _, zone := range data.Zones {
             var existingZone dbm.Zone
-            err := tx.Where("name = ?", zone.Name).First(&existingZone).Error
+            err := tx.Where("name = ? AND view = ?", zone.Name, zone.View).First(&existingZone).Error
+            created := err == gorm.ErrRecordNotFound
 
             if err == gorm.ErrRecordNotFound {
                 // Create new zone
                 newZone := dbm.Zone{
                     Name: zone.Name,
+                    View: zone.View,
                 }
+                newZone.SetMeta(zone)
                 if err := tx.Create(&newZone).Error; err != nil {
                     return fmt.Errorf("create zone %s: %w", zone.Name, err)
                 }
                 existingZone = newZone
             } else if err != nil {
                 return fmt.Errorf("check zone %s: %w", zone.Name, err)
+            } else {
+                // Update metadata of existing zone
+                existingZone.SetMeta(zone)
+                if err := dbm.UpdateZoneMeta(tx, &existingZone); err != nil {
+                    return fmt.Errorf("update zone %s: %w", zone.Name, err)
+                }
             }
+            before := dbm.ZoneSnapshot(tx, existingZone.ID)
 
             // Delete old rrsets and their records for this zone (hard delete, not soft delete)
             // First, get all rrset IDs for this zone
@@ -593,12 +980,32 @@ func (s *Server) syncImport(c *gin.Context) {
                     return fmt.Errorf("create rrset %s/%s: %w", zone.Name, rrset.Name, err)
                 }
             }
+
+            after := dbm.ZoneSnapshot(tx, existingZone.ID)
+            if diff := dbm.NewZoneDiff(existingZone, before, after); created || !diff.Empty() {
+                diff.Created = created
+                diffs = append(diffs, diff)
+            }
+            // Unchanged zones are not logged, so repeated syncs stay quiet
+            if !dbm.SameRRSets(before, after) {
+                if err := dbm.Audit(tx, actor(c), dbm.AuditZoneSync, existingZone, before, after); err != nil {
+                    return fmt.Errorf("audit zone %s: %w", zone.Name, err)
+                }
+            }
         }
 
         // Import templates
         for _, tmpl := range data.Templates {
             var existingTmpl dbm.Template
             err := tx.Where("name = ?", tmpl.Name).First(&existingTmpl).Error
+            var before *dbm.Template
+            if err == nil {
+                var snapshot dbm.Template
+                if err := tx.Preload("Records").First(&snapshot, existingTmpl.ID).Error; err != nil {
+                    return fmt.Errorf("load template %s: %w", tmpl.Name, err)
+                }
+                before = &snapshot
+            }
 
             if err == gorm.ErrRecordNotFound {
                 // Create new template
@@ -642,11 +1049,28 @@ func (s *Server) syncImport(c *gin.Context) {
                     return fmt.Errorf("create template record for %s: %w", tmpl.Name, err)
                 }
             }
+
+            var after dbm.Template
+            if err := tx.Preload("Records").First(&after, existingTmpl.ID).Error; err != nil {
+                return fmt.Errorf("reload template %s: %w", tmpl.Name, err)
+            }
+            if before == nil || !dbm.SameTemplate(*before, after) {
+                if err := dbm.Audit(tx, actor(c), dbm.AuditTemplateSync, dbm.Zone{}, before, after); err != nil {
+                    return fmt.Errorf("audit template %s: %w", tmpl.Name, err)
+                }
+            }
         }
 
+        if dryRun {
+            return dbm.ErrDryRun
+        }
         return nil
     })
 
+    if errors.Is(err, dbm.ErrDryRun) {
+        c.JSON(http.StatusOK, gin.H{"dry_run": true, "diff": diffs, "templates": len(data.Templates)})
+        return
+    }
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
@@ -8,6 +8,9 @@ func TestFQDN(t *testing.T) {
         {"", "example.com", "example.com."},
         {"www", "example.com.", "www.example.com."},
         {"WWW", "Example.Com", "www.example.com."},
+        {"Mail.Example.com.", "example.com", "mail.example.com."},
+        {"www.@", "example.com", "www.example.com."},
+        {"Почта", "xn--e1afmkfd.xn--p1ai", "xn--80a1acny.xn--e1afmkfd.xn--p1ai."},
     }
     for _, tt := range tests {
         if got := fqdn(tt.name, tt.zone); got != tt.want {
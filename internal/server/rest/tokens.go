@@ -0,0 +1,230 @@
+package rest
+
+import (
+    "errors"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "golang.org/x/crypto/bcrypt"
+
+    "namedot/internal/authguard"
+    dbm "namedot/internal/db"
+)
+
+// ctxToken is the gin context key of the authenticated *dbm.APIToken
+const ctxToken = "api_token"
+
+// configToken stands for the api_token/api_token_hash credentials from the
+// config file, which keep full access.
+var configToken = dbm.APIToken{Name: "config", Scopes: dbm.ScopeAdmin}
+
+// authenticate resolves the bearer token to an API token and stores it in
+// the context. Without configured credentials the API stays open, as before
+// tokens existed, until the first database token is created; revoking or
+// expiring all tokens does not open it again.
+func (s *Server) authenticate(c *gin.Context) {
+    token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+
+    // Throttled clients are rejected before any token lookup or bcrypt comparison
+    attempt := authguard.Attempt{Source: "rest", IP: c.ClientIP()}
+    if wait := s.guard.Check(attempt); wait > 0 {
+        c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+        c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many failed authentication attempts"})
+        return
+    }
+
+    // Database tokens carry a prefix, so they skip the bcrypt comparison
+    if strings.HasPrefix(token, dbm.TokenPrefix) {
+        if tok, err := dbm.FindAPIToken(s.db, token); err == nil {
+            if tok.LastUsedAt == nil || time.Since(*tok.LastUsedAt) > time.Minute {
+                dbm.TouchAPIToken(s.db, tok.ID)
+            }
+            c.Set(ctxToken, tok)
+            c.Next()
+            return
+        }
+    }
+
+    authenticated := false
+    if s.cfg.APITokenHash != "" {
+        // Try hashed token first (recommended)
+        if err := bcrypt.CompareHashAndPassword([]byte(s.cfg.APITokenHash), []byte(token)); err == nil {
+            authenticated = true
+        }
+    } else if s.cfg.APIToken != "" {
+        // Fallback to plain text comparison (deprecated)
+        if token == s.cfg.APIToken {
+            authenticated = true
+        }
+    } else if !s.tokensIssued() {
+        // No authentication configured, allow all
+        authenticated = true
+    }
+
+    if !authenticated {
+        s.guard.Fail(attempt)
+        c.AbortWithStatus(http.StatusUnauthorized)
+        return
+    }
+    tok := configToken
+    c.Set(ctxToken, &tok)
+    c.Next()
+}
+
+// tokensIssued reports whether a database token was ever created. The answer
+// sticks once true, so a closed API does not query for it again; a failed
+// query counts as true for the request, keeping the API closed.
+func (s *Server) tokensIssued() bool {
+    if s.tokensSeen.Load() {
+        return true
+    }
+    issued, err := dbm.APITokensIssued(s.db)
+    if err != nil {
+        return true
+    }
+    if issued {
+        s.tokensSeen.Store(true)
+    }
+    return issued
+}
+
+// currentToken returns the authenticated token of the request
+func currentToken(c *gin.Context) *dbm.APIToken {
+    if v, ok := c.Get(ctxToken); ok {
+        if tok, ok2 := v.(*dbm.APIToken); ok2 {
+            return tok
+        }
+    }
+    return &dbm.APIToken{}
+}
+
+// requireScope rejects tokens without scope
+func requireScope(scope string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if !currentToken(c).HasScope(scope) {
+            c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient scope", "required": scope})
+            return
+        }
+        c.Next()
+    }
+}
+
+// requireAllZones rejects zone-restricted tokens from endpoints spanning all zones
+func requireAllZones(c *gin.Context) {
+    if len(currentToken(c).ZoneList()) > 0 {
+        c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "token is restricted to specific zones"})
+        return
+    }
+    c.Next()
+}
+
+// zoneAccess resolves a zone name in ":id" (e.g. /zones/example.com, with
+// ?view= for zones of a view) to the zone ID, so all zone routes accept names,
+// and rejects requests for a zone outside the token restrictions
+func (s *Server) zoneAccess(c *gin.Context) {
+    var z dbm.Zone
+    id := c.Param("id")
+    if _, err := strconv.ParseUint(id, 10, 32); err == nil {
+        s.db.Select("id", "name").Limit(1).Find(&z, id)
+    } else {
+        z, _ = dbm.ZoneByName(s.db, id, c.Query("view"))
+        // An unknown name becomes ID 0, which the handlers report as missing
+        setParam(c, "id", strconv.FormatUint(uint64(z.ID), 10))
+    }
+    if z.ID != 0 && !currentToken(c).AllowsZone(z.Name) {
+        c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "zone not allowed for this token"})
+        return
+    }
+    c.Next()
+}
+
+// setParam replaces the value of a path parameter
+func setParam(c *gin.Context, key, value string) {
+    for i := range c.Params {
+        if c.Params[i].Key == key {
+            c.Params[i].Value = value
+        }
+    }
+}
+
+type tokenReq struct {
+    Name          string     `json:"name"`
+    Scopes        []string   `json:"scopes"`
+    Zones         []string   `json:"zones"`
+    ExpiresAt     *time.Time `json:"expires_at"`
+    ExpiresInDays int        `json:"expires_in_days"`
+}
+
+type tokenResp struct {
+    ID         uint       `json:"id"`
+    Name       string     `json:"name"`
+    Token      string     `json:"token,omitempty"` // only returned on creation
+    Scopes     []string   `json:"scopes"`
+    Zones      []string   `json:"zones"`
+    ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+    LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+    RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+    CreatedAt  time.Time  `json:"created_at"`
+}
+
+func newTokenResp(t dbm.APIToken) tokenResp {
+    zones := t.ZoneList()
+    if zones == nil {
+        zones = []string{}
+    }
+    return tokenResp{
+        ID: t.ID, Name: t.Name, Scopes: t.ScopeList(), Zones: zones,
+        ExpiresAt: t.ExpiresAt, LastUsedAt: t.LastUsedAt, RevokedAt: t.RevokedAt, CreatedAt: t.CreatedAt,
+    }
+}
+
+func (s *Server) createToken(c *gin.Context) {
+    var req tokenReq
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+        return
+    }
+    expires := req.ExpiresAt
+    if expires == nil && req.ExpiresInDays > 0 {
+        t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+        expires = &t
+    }
+    tok, plain, err := dbm.CreateAPIToken(s.db, req.Name, req.Scopes, req.Zones, expires)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    s.tokensSeen.Store(true)
+    resp := newTokenResp(tok)
+    resp.Token = plain
+    c.JSON(http.StatusCreated, resp)
+}
+
+func (s *Server) listTokens(c *gin.Context) {
+    toks, err := dbm.ListAPITokens(s.db)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    out := make([]tokenResp, 0, len(toks))
+    for _, t := range toks {
+        out = append(out, newTokenResp(t))
+    }
+    c.JSON(http.StatusOK, out)
+}
+
+func (s *Server) revokeToken(c *gin.Context) {
+    tok, err := dbm.RevokeAPIToken(s.db, c.Param("id"))
+    if errors.Is(err, dbm.ErrTokenNotFound) {
+        c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    c.JSON(http.StatusOK, newTokenResp(*tok))
+}
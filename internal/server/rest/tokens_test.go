@@ -0,0 +1,134 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"namedot/internal/config"
+	dbm "namedot/internal/db"
+)
+
+func doTokenRequest(server *Server, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	server.r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPITokens_ScopesAndZoneRestrictions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server, gormDB, _ := setupZoneTestServer(t, &config.Config{APIToken: "admintoken"})
+
+	teamZone := dbm.Zone{Name: "app.team-a.example"}
+	otherZone := dbm.Zone{Name: "other.example"}
+	gormDB.Create(&teamZone)
+	gormDB.Create(&otherZone)
+
+	// Config token manages DB tokens
+	w := doTokenRequest(server, "POST", "/tokens", "admintoken", `{"name":"team-a","scopes":["write"],"zones":["*.team-a.example"],"expires_in_days":30}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create token: %d %s", w.Code, w.Body.String())
+	}
+	var created tokenResp
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+	if created.Token == "" || created.ExpiresAt == nil {
+		t.Fatalf("expected plain token and expiry: %+v", created)
+	}
+	team := created.Token
+
+	w = doTokenRequest(server, "POST", "/tokens", "admintoken", `{"name":"reader","scopes":["read"]}`)
+	var readerResp tokenResp
+	_ = json.Unmarshal(w.Body.Bytes(), &readerResp)
+	reader := readerResp.Token
+
+	// Zone restrictions
+	if w := doTokenRequest(server, "GET", fmt.Sprintf("/zones/%d", teamZone.ID), team, ""); w.Code != http.StatusOK {
+		t.Fatalf("own zone: expected 200, got %d", w.Code)
+	}
+	if w := doTokenRequest(server, "GET", fmt.Sprintf("/zones/%d", otherZone.ID), team, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("foreign zone: expected 403, got %d", w.Code)
+	}
+	if w := doTokenRequest(server, "DELETE", fmt.Sprintf("/zones/%d", otherZone.ID), team, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("delete foreign zone: expected 403, got %d", w.Code)
+	}
+	if w := doTokenRequest(server, "POST", "/zones", team, `{"name":"evil.example"}`); w.Code != http.StatusForbidden {
+		t.Fatalf("create foreign zone: expected 403, got %d", w.Code)
+	}
+	if w := doTokenRequest(server, "POST", "/zones", team, `{"name":"new.team-a.example"}`); w.Code != http.StatusCreated {
+		t.Fatalf("create own zone: expected 201, got %d", w.Code)
+	}
+	w = doTokenRequest(server, "GET", "/zones", team, "")
+	var zones []dbm.Zone
+	_ = json.Unmarshal(w.Body.Bytes(), &zones)
+	if len(zones) != 2 {
+		t.Fatalf("restricted list should contain only team zones, got %+v", zones)
+	}
+
+	// Scopes
+	if w := doTokenRequest(server, "GET", "/zones", reader, ""); w.Code != http.StatusOK {
+		t.Fatalf("reader list: expected 200, got %d", w.Code)
+	}
+	if w := doTokenRequest(server, "DELETE", fmt.Sprintf("/zones/%d", otherZone.ID), reader, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("reader delete: expected 403, got %d", w.Code)
+	}
+	if w := doTokenRequest(server, "POST", "/sync/import", team, `{}`); w.Code != http.StatusForbidden {
+		t.Fatalf("sync without scope: expected 403, got %d", w.Code)
+	}
+	if w := doTokenRequest(server, "GET", "/tokens", team, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("token list without admin: expected 403, got %d", w.Code)
+	}
+
+	// Revocation
+	if w := doTokenRequest(server, "DELETE", fmt.Sprintf("/tokens/%d", readerResp.ID), "admintoken", ""); w.Code != http.StatusOK {
+		t.Fatalf("revoke: expected 200, got %d", w.Code)
+	}
+	if w := doTokenRequest(server, "GET", "/zones", reader, ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("revoked token: expected 401, got %d", w.Code)
+	}
+}
+
+func TestAPITokens_CloseOpenModeOnceTokensExist(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server, gormDB, _ := setupZoneTestServer(t, &config.Config{})
+
+	if w := doTokenRequest(server, "GET", "/zones", "", ""); w.Code != http.StatusOK {
+		t.Fatalf("open mode: expected 200, got %d", w.Code)
+	}
+	_, plain, err := dbm.CreateAPIToken(gormDB, "ops", []string{"admin"}, nil, nil)
+	if err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	if w := doTokenRequest(server, "GET", "/zones", "", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous with tokens present: expected 401, got %d", w.Code)
+	}
+	if w := doTokenRequest(server, "GET", "/sync/export", plain, ""); w.Code != http.StatusOK {
+		t.Fatalf("admin token sync export: expected 200, got %d", w.Code)
+	}
+
+	// Revoking the last token does not open the API again, to its holder neither
+	if _, err := dbm.RevokeAPIToken(gormDB, "ops"); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	for _, token := range []string{"", plain} {
+		if w := doTokenRequest(server, "GET", "/zones", token, ""); w.Code != http.StatusUnauthorized {
+			t.Fatalf("after revoking the last token (%q): expected 401, got %d", token, w.Code)
+		}
+	}
+	// Nor does a restart
+	restarted := NewServer(&config.Config{}, gormDB, nil)
+	if w := doTokenRequest(restarted, "GET", "/zones", "", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("restart after revocation: expected 401, got %d", w.Code)
+	}
+}
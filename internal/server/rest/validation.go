@@ -0,0 +1,42 @@
+package rest
+
+import (
+    "errors"
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+
+    dbm "namedot/internal/db"
+    "namedot/internal/validate"
+)
+
+// validationFailed answers 400 with the field errors when err holds
+// validate.Errors and reports whether it did
+func validationFailed(c *gin.Context, err error) bool {
+    var errs validate.Errors
+    if !errors.As(err, &errs) {
+        return false
+    }
+    c.JSON(http.StatusBadRequest, gin.H{"error": errs.Error(), "fields": errs})
+    return true
+}
+
+// checkRRSet validates set for zone z and canonicalizes its record data. A
+// CNAME must not share its name with the other live RRSets of the zone.
+func (s *Server) checkRRSet(z dbm.Zone, set *dbm.RRSet) error {
+    if errs := validate.RRSet(set, z.Name); len(errs) > 0 {
+        return errs
+    }
+    var others []dbm.RRSet
+    if err := s.db.Preload("Records").Where("zone_id = ? AND name = ? AND type <> ?", z.ID, set.Name, set.Type).Find(&others).Error; err != nil {
+        return err
+    }
+    return validate.Coexistence(*set, others).Err()
+}
+
+// respondInvalid answers a failed checkRRSet
+func respondInvalid(c *gin.Context, err error) {
+    if !validationFailed(c, err) {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+    }
+}
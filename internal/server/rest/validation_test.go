@@ -0,0 +1,70 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"namedot/internal/config"
+	dbm "namedot/internal/db"
+	"namedot/internal/validate"
+)
+
+func TestValidation_FieldErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server, gormDB, _ := setupZoneTestServer(t, &config.Config{APIToken: "admintoken", AutoSOAOnMissing: true})
+	zone := dbm.Zone{Name: "valid.example"}
+	gormDB.Create(&zone)
+	path := fmt.Sprintf("/zones/%d/rrsets", zone.ID)
+
+	fieldsOf := func(body []byte) map[string]bool {
+		var resp struct {
+			Fields validate.Errors `json:"fields"`
+		}
+		_ = json.Unmarshal(body, &resp)
+		out := map[string]bool{}
+		for _, fe := range resp.Fields {
+			out[fe.Field] = true
+		}
+		return out
+	}
+
+	w := doTokenRequest(server, "POST", path, "admintoken",
+		`{"name":"www.other.example.","type":"A","ttl":4294967295,"records":[{"data":"192.0.2.1"},{"data":"x"}]}`)
+	fields := fieldsOf(w.Body.Bytes())
+	if w.Code != http.StatusBadRequest || !fields["name"] || !fields["ttl"] || !fields["records[1].data"] {
+		t.Fatalf("want field errors for name, ttl and records[1].data, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = doTokenRequest(server, "POST", path, "admintoken", `{"name":"www","type":"A","records":[{"data":"192.0.2.1"}]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create A: %d %s", w.Code, w.Body.String())
+	}
+	w = doTokenRequest(server, "POST", path, "admintoken", `{"name":"www","type":"CNAME","records":[{"data":"target.example."}]}`)
+	if w.Code != http.StatusBadRequest || !fieldsOf(w.Body.Bytes())["type"] {
+		t.Fatalf("want CNAME next to A rejected, got %d %s", w.Code, w.Body.String())
+	}
+
+	// The batch checks CNAME exclusivity on the result, so swapping works
+	w = doTokenRequest(server, "PATCH", path, "admintoken", `{"rrsets":[
+		{"changetype":"ADD-RECORD","name":"www","type":"CNAME","records":[{"data":"target.example."}]}
+	]}`)
+	if w.Code != http.StatusBadRequest || !fieldsOf(w.Body.Bytes())["www.valid.example. CNAME"] {
+		t.Fatalf("want batch CNAME conflict rejected, got %d %s", w.Code, w.Body.String())
+	}
+	w = doTokenRequest(server, "PATCH", path, "admintoken", `{"rrsets":[
+		{"changetype":"DELETE","name":"www","type":"A"},
+		{"changetype":"REPLACE","name":"www","type":"CNAME","records":[{"data":"target.example."}]}
+	]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("swap A for CNAME: %d %s", w.Code, w.Body.String())
+	}
+
+	w = doTokenRequest(server, "POST", "/zones", "admintoken", `{"name":"bad_-.example."}`)
+	if w.Code != http.StatusBadRequest || !fieldsOf(w.Body.Bytes())["name"] {
+		t.Fatalf("want invalid zone name rejected, got %d %s", w.Code, w.Body.String())
+	}
+}
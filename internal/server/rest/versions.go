@@ -0,0 +1,156 @@
+package rest
+
+import (
+    "errors"
+    "log"
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "gorm.io/gorm"
+
+    dbm "namedot/internal/db"
+)
+
+// serials returns the SOA serial manager configured for this server
+func (s *Server) serials() dbm.SerialManager {
+    return dbm.SerialManager{AutoSOA: s.cfg.AutoSOAOnMissing, Policy: s.cfg.SOASerialPolicy}
+}
+
+// bumpSerial bumps the SOA serial of a changed zone, which also records a new
+// zone version. The change is already committed, so a failure is only logged.
+func (s *Server) bumpSerial(z dbm.Zone) {
+    if err := s.serials().Bump(s.db, z); err != nil {
+        log.Printf("SOA serial bump of %s failed: %v", z.Name, err)
+    }
+}
+
+// versionResp is a version together with the RRSets it holds
+type versionResp struct {
+    dbm.ZoneVersion
+    RRSets []dbm.RRSet `json:"rrsets"`
+}
+
+// versionZone loads the zone of a versions request, answering 404 if missing
+func (s *Server) versionZone(c *gin.Context) (dbm.Zone, bool) {
+    var z dbm.Zone
+    if err := s.db.First(&z, c.Param("id")).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "zone not found"})
+        return z, false
+    }
+    return z, true
+}
+
+// loadVersion resolves a version ID of zone z together with its RRSets
+func (s *Server) loadVersion(z dbm.Zone, id string) (dbm.ZoneVersion, []dbm.RRSet, int, error) {
+    vid, err := strconv.ParseUint(id, 10, 32)
+    if err != nil {
+        return dbm.ZoneVersion{}, nil, http.StatusBadRequest, errors.New("invalid version id")
+    }
+    v, err := dbm.GetVersion(s.db, z.ID, uint(vid))
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return v, nil, http.StatusNotFound, errors.New("version not found")
+    } else if err != nil {
+        return v, nil, http.StatusInternalServerError, err
+    }
+    sets, err := v.RRSets()
+    if err != nil {
+        return v, nil, http.StatusInternalServerError, err
+    }
+    return v, sets, http.StatusOK, nil
+}
+
+// listVersions returns the versions of a zone, newest first
+func (s *Server) listVersions(c *gin.Context) {
+    z, ok := s.versionZone(c)
+    if !ok {
+        return
+    }
+    limit, _ := strconv.Atoi(c.Query("limit"))
+    offset, _ := strconv.Atoi(c.Query("offset"))
+    if offset < 0 {
+        offset = 0
+    }
+    versions, total, err := dbm.ListVersions(s.db, z.ID, limit, offset)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    if versions == nil {
+        versions = []dbm.ZoneVersion{}
+    }
+    c.JSON(http.StatusOK, gin.H{"total": total, "versions": versions})
+}
+
+// getVersion returns one version with its RRSets
+func (s *Server) getVersion(c *gin.Context) {
+    z, ok := s.versionZone(c)
+    if !ok {
+        return
+    }
+    v, sets, status, err := s.loadVersion(z, c.Param("vid"))
+    if err != nil {
+        c.JSON(status, gin.H{"error": err.Error()})
+        return
+    }
+    c.JSON(http.StatusOK, versionResp{ZoneVersion: v, RRSets: sets})
+}
+
+// diffVersion compares a version with ?to=<version id>, or with the current
+// state of the zone when to is omitted
+func (s *Server) diffVersion(c *gin.Context) {
+    z, ok := s.versionZone(c)
+    if !ok {
+        return
+    }
+    from, fromSets, status, err := s.loadVersion(z, c.Param("vid"))
+    if err != nil {
+        c.JSON(status, gin.H{"error": err.Error()})
+        return
+    }
+    var to any = "current"
+    toSets := dbm.ZoneSnapshot(s.db, z.ID)
+    if id := c.Query("to"); id != "" && id != "current" {
+        var v dbm.ZoneVersion
+        if v, toSets, status, err = s.loadVersion(z, id); err != nil {
+            c.JSON(status, gin.H{"error": err.Error()})
+            return
+        }
+        to = v.ID
+    }
+    c.JSON(http.StatusOK, gin.H{"from": from.ID, "to": to, "changes": dbm.DiffRRSets(fromSets, toSets)})
+}
+
+// rollbackZone restores the RRSets of a version and bumps the serial
+func (s *Server) rollbackZone(c *gin.Context) {
+    z, ok := s.versionZone(c)
+    if !ok {
+        return
+    }
+    v, _, status, err := s.loadVersion(z, c.Param("vid"))
+    if err != nil {
+        c.JSON(status, gin.H{"error": err.Error()})
+        return
+    }
+    before := dbm.ZoneSnapshot(s.db, z.ID)
+    var created dbm.ZoneVersion
+    var after []dbm.RRSet
+    err = s.db.Transaction(func(tx *gorm.DB) error {
+        var err error
+        if created, err = dbm.RollbackZone(tx, z, v.ID, s.serials()); err != nil {
+            return err
+        }
+        after = dbm.ZoneSnapshot(tx, z.ID)
+        return audit(tx, c, dbm.AuditZoneRollback, z, before, after)
+    })
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    s.syncPTRs(c, z, before, after)
+    // Invalidate DNS cache after zone rollback
+    if s.dnsServer != nil {
+        s.dnsServer.InvalidateZoneCache()
+    }
+    c.JSON(http.StatusOK, created)
+}
@@ -0,0 +1,75 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"namedot/internal/config"
+	dbm "namedot/internal/db"
+)
+
+func TestVersions_DiffAndRollback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server, gormDB, _ := setupZoneTestServer(t, &config.Config{APIToken: "admintoken", AutoSOAOnMissing: true})
+	zone := dbm.Zone{Name: "versioned.example"}
+	gormDB.Create(&zone)
+	base := fmt.Sprintf("/zones/%d", zone.ID)
+
+	w := doTokenRequest(server, "POST", base+"/rrsets", "admintoken", `{"name":"www","type":"A","ttl":300,"records":[{"data":"192.0.2.1"}]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", w.Code, w.Body.String())
+	}
+	var set dbm.RRSet
+	_ = json.Unmarshal(w.Body.Bytes(), &set)
+	doTokenRequest(server, "PUT", fmt.Sprintf("%s/rrsets/%d", base, set.ID), "admintoken", `{"name":"www","type":"A","ttl":300,"records":[{"data":"203.0.113.66"}]}`)
+
+	w = doTokenRequest(server, "GET", base+"/versions", "admintoken", "")
+	var list struct {
+		Total    int64             `json:"total"`
+		Versions []dbm.ZoneVersion `json:"versions"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &list)
+	if w.Code != http.StatusOK || list.Total != 2 {
+		t.Fatalf("versions: %d %s", w.Code, w.Body.String())
+	}
+	good := list.Versions[1]
+
+	w = doTokenRequest(server, "GET", fmt.Sprintf("%s/versions/%d/diff", base, good.ID), "admintoken", "")
+	var diff struct {
+		To      any               `json:"to"`
+		Changes []dbm.RRSetChange `json:"changes"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &diff)
+	if w.Code != http.StatusOK || diff.To != "current" || len(diff.Changes) != 2 {
+		t.Fatalf("diff with current: %d %s", w.Code, w.Body.String())
+	}
+	for _, ch := range diff.Changes {
+		if ch.Change != dbm.ChangeChanged {
+			t.Fatalf("want SOA and www changed, got %+v", ch)
+		}
+	}
+
+	w = doTokenRequest(server, "POST", fmt.Sprintf("%s/versions/%d/rollback", base, good.ID), "admintoken", "")
+	var created dbm.ZoneVersion
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+	if w.Code != http.StatusOK || created.Serial <= list.Versions[0].Serial {
+		t.Fatalf("rollback must move the serial forward: %d %s", w.Code, w.Body.String())
+	}
+	var data string
+	gormDB.Model(&dbm.RData{}).Joins("JOIN rr_sets ON rr_sets.id = r_data.rr_set_id").
+		Where("rr_sets.zone_id = ? AND rr_sets.type = ?", zone.ID, "A").Pluck("r_data.data", &data)
+	if data != "192.0.2.1" {
+		t.Fatalf("www not restored, got %q", data)
+	}
+	if _, total, _ := dbm.ListAudit(gormDB, dbm.AuditFilter{ZoneID: zone.ID, Action: dbm.AuditZoneRollback}); total != 1 {
+		t.Fatalf("rollback must be audited")
+	}
+
+	if w := doTokenRequest(server, "GET", base+"/versions/9999", "admintoken", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("missing version: want 404, got %d", w.Code)
+	}
+}
@@ -3,86 +3,324 @@ package zoneio
 import (
     "fmt"
     "io"
+    "net/netip"
+    "sort"
+    "strconv"
     "strings"
 
     "github.com/miekg/dns"
     "gorm.io/gorm"
 
     dbm "namedot/internal/db"
+    "namedot/internal/validate"
 )
 
-// ToBind serializes a zone to a simplistic BIND-like zonefile.
-func ToBind(z *dbm.Zone) string {
+// BindOptions control ToBind
+type BindOptions struct {
+    DefaultTTL uint32 // $TTL when the zone has no default TTL of its own
+    Geo        bool   // write geo attributes and auto_ptr as "; namedot:" comments
+}
+
+// geoComment marks the record comments that carry the attributes of a
+// record, e.g. "; namedot: country=RU asn=65001"
+const geoComment = "namedot:"
+
+// ToBind serializes a zone to an RFC 1035 master file that named-checkzone
+// reads: $ORIGIN and $TTL first, then the SOA, the apex NS and the other
+// names in DNS order, each name written once for all its records. ALIAS,
+// which has no wire format, is written as a comment, and so are the geo
+// variants of a CNAME or DNAME when the geo attributes are left out.
+func ToBind(z *dbm.Zone, opts BindOptions) string {
+    origin := strings.ToLower(strings.TrimSuffix(z.Name, ".")) + "."
+    sets := append([]dbm.RRSet(nil), z.RRSets...)
+    sort.SliceStable(sets, func(i, j int) bool {
+        a, b := strings.ToLower(sets[i].Name), strings.ToLower(sets[j].Name)
+        if a != b {
+            if a == origin || b == origin {
+                return a == origin
+            }
+            return canonicalLess(a, b)
+        }
+        return typeRank(sets[i].Type) < typeRank(sets[j].Type) ||
+            typeRank(sets[i].Type) == typeRank(sets[j].Type) && strings.ToUpper(sets[i].Type) < strings.ToUpper(sets[j].Type)
+    })
+
+    ttl := z.DefaultTTL
+    if ttl == 0 {
+        ttl = opts.DefaultTTL
+    }
+    if ttl == 0 {
+        ttl = 3600
+    }
     var b strings.Builder
-    b.WriteString("$ORIGIN ")
-    b.WriteString(strings.TrimSuffix(z.Name, "."))
-    b.WriteString(".\n")
-    for _, rs := range z.RRSets {
-        for _, r := range rs.Records {
-            line := fmt.Sprintf("%s %d IN %s %s\n", strings.TrimSuffix(rs.Name, "."), rs.TTL, strings.ToUpper(rs.Type), r.Data)
-            b.WriteString(line)
+    fmt.Fprintf(&b, "$ORIGIN %s\n$TTL %d\n", origin, ttl)
+    last := ""
+    for _, rs := range sets {
+        name := relativeName(rs.Name, origin)
+        typ := strings.ToUpper(rs.Type)
+        records := rs.Records
+        single := !opts.Geo && (typ == "CNAME" || typ == "DNAME") && len(records) > 1
+        if single {
+            records = defaultFirst(records)
+        }
+        for i, r := range records {
+            if single && i > 0 {
+                // A name has one CNAME; without the attributes the other
+                // selectors would read as extra targets
+                fmt.Fprintf(&b, "; %s\t%d\tIN\t%s\t%s ; %s %s\n", name, rs.TTL, typ, r.Data, geoComment, RecordAttrs(r))
+                continue
+            }
+            if typ == "ALIAS" {
+                // Not a wire type; keep it visible without breaking BIND parsers
+                fmt.Fprintf(&b, "; %s\t%d\tIN\t%s\t%s\n", name, rs.TTL, typ, r.Data)
+                continue
+            }
+            owner := name
+            if owner == last {
+                owner = ""
+            } else if last != "" {
+                b.WriteString("\n")
+            }
+            last = name
+            fmt.Fprintf(&b, "%s\t%d\tIN\t%s\t%s", owner, rs.TTL, typ, r.Data)
+            if attrs := RecordAttrs(r); opts.Geo && attrs != "" {
+                fmt.Fprintf(&b, " ; %s %s", geoComment, attrs)
+            }
+            b.WriteString("\n")
         }
     }
     return b.String()
 }
 
+// defaultFirst moves the record without geo selector, the answer for
+// clients no selector matches, to the front of recs
+func defaultFirst(recs []dbm.RData) []dbm.RData {
+    out := append([]dbm.RData(nil), recs...)
+    for i, r := range out {
+        if r.Country == nil && r.Continent == nil && r.ASN == nil && r.Subnet == nil {
+            out[0], out[i] = out[i], out[0]
+            break
+        }
+    }
+    return out
+}
+
+// typeRank puts the SOA and NS first at a name
+func typeRank(t string) int {
+    switch strings.ToUpper(t) {
+    case "SOA":
+        return 0
+    case "NS":
+        return 1
+    }
+    return 2
+}
+
+// canonicalLess orders absolute names as RFC 4034 section 6.1 does, by
+// their labels from the root, so a name is followed by the names below it
+func canonicalLess(a, b string) bool {
+    la := strings.Split(strings.TrimSuffix(a, "."), ".")
+    lb := strings.Split(strings.TrimSuffix(b, "."), ".")
+    for i := 1; i <= len(la) && i <= len(lb); i++ {
+        x, y := la[len(la)-i], lb[len(lb)-i]
+        if x != y {
+            return x < y
+        }
+    }
+    return len(la) < len(lb)
+}
+
+// relativeName writes name relative to origin: "@" for the apex, the labels
+// below it for names in the zone, else the absolute name
+func relativeName(name, origin string) string {
+    n := strings.ToLower(strings.TrimSuffix(name, ".")) + "."
+    switch {
+    case n == origin:
+        return "@"
+    case strings.HasSuffix(n, "."+origin):
+        return strings.TrimSuffix(n, "."+origin)
+    }
+    return n
+}
+
+// RecordAttrs describes the geo selector and auto_ptr of a record as the
+// key=value list of a "; namedot:" comment
+func RecordAttrs(r dbm.RData) string {
+    var attrs []string
+    if r.Country != nil && *r.Country != "" {
+        attrs = append(attrs, "country="+*r.Country)
+    }
+    if r.Continent != nil && *r.Continent != "" {
+        attrs = append(attrs, "continent="+*r.Continent)
+    }
+    if r.ASN != nil && *r.ASN != 0 {
+        attrs = append(attrs, "asn="+strconv.Itoa(*r.ASN))
+    }
+    if r.Subnet != nil && *r.Subnet != "" {
+        attrs = append(attrs, "subnet="+*r.Subnet)
+    }
+    if r.AutoPTR {
+        attrs = append(attrs, "auto_ptr")
+    }
+    return strings.Join(attrs, " ")
+}
+
+// parseAttrs sets the attributes of a "; namedot:" comment on r; other
+// comments are ignored
+func parseAttrs(comment string, r *dbm.RData) error {
+    c := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(comment), ";"))
+    if !strings.HasPrefix(c, geoComment) {
+        return nil
+    }
+    for _, f := range strings.Fields(strings.TrimPrefix(c, geoComment)) {
+        k, v, _ := strings.Cut(f, "=")
+        switch strings.ToLower(k) {
+        case "country":
+            v = strings.ToUpper(v)
+            r.Country = &v
+        case "continent":
+            v = strings.ToUpper(v)
+            r.Continent = &v
+        case "asn":
+            n, err := strconv.Atoi(v)
+            if err != nil || n < 0 {
+                return fmt.Errorf("invalid asn %q", v)
+            }
+            r.ASN = &n
+        case "subnet":
+            if _, err := netip.ParsePrefix(v); err != nil {
+                return fmt.Errorf("invalid subnet %q", v)
+            }
+            r.Subnet = &v
+        case "auto_ptr":
+            r.AutoPTR = true
+        default:
+            return fmt.Errorf("unknown attribute %q", k)
+        }
+    }
+    return nil
+}
+
 // ImportBIND parses BIND zone text and merges into zone according to mode.
 // mode: upsert | replace
 func ImportBIND(db *gorm.DB, zone *dbm.Zone, r io.Reader, mode string, defaultTTL uint32) error {
-    origin := dns.Fqdn(zone.Name)
-    zp := dns.NewZoneParser(r, origin, "import")
+    rrsets, err := parseBIND(r, zone, defaultTTL)
+    if err != nil {
+        return err
+    }
+    return db.Transaction(func(tx *gorm.DB) error {
+        if strings.ToLower(mode) == "replace" {
+            if err := tx.Where("zone_id = ?", zone.ID).Delete(&dbm.RRSet{}).Error; err != nil {
+                return err
+            }
+        }
+        return upsertRRSets(tx, zone, rrsets)
+    })
+}
+
+// parseBIND reads BIND zone text into validated RRSets grouped by name and
+// type, in the order they first appear
+func parseBIND(r io.Reader, zone *dbm.Zone, defaultTTL uint32) ([]*dbm.RRSet, error) {
+    zp := dns.NewZoneParser(r, dns.Fqdn(zone.Name), "import")
+    rrsets, err := readRRSets(zp, zone.ID, defaultTTL)
+    if err != nil {
+        return nil, err
+    }
+    if err := checkRRSets(rrsets, zone.Name); err != nil {
+        return nil, err
+    }
+    return rrsets, nil
+}
 
+// readRRSets collects the records of zp into RRSets grouped by name and type,
+// in the order they first appear
+func readRRSets(zp *dns.ZoneParser, zoneID uint, defaultTTL uint32) ([]*dbm.RRSet, error) {
     // accumulate rrsets grouped by name+type
     type key struct{ name, typ string }
-    rrsets := map[key]*dbm.RRSet{}
+    index := map[key]*dbm.RRSet{}
+    var rrsets []*dbm.RRSet
 
     for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
-        if err := zp.Err(); err != nil { return err }
         if rr == nil { continue }
         hdr := rr.Header()
         name := strings.ToLower(dns.Fqdn(hdr.Name))
         typ := strings.ToUpper(dns.TypeToString[hdr.Rrtype])
         k := key{name: name, typ: typ}
-        rs := rrsets[k]
+        rs := index[k]
         if rs == nil {
             ttl := hdr.Ttl
             if ttl == 0 && defaultTTL > 0 {
                 ttl = defaultTTL
             }
-            rs = &dbm.RRSet{ZoneID: zone.ID, Name: name, Type: typ, TTL: ttl}
-            rrsets[k] = rs
+            rs = &dbm.RRSet{ZoneID: zoneID, Name: name, Type: typ, TTL: ttl}
+            index[k] = rs
+            rrsets = append(rrsets, rs)
+        }
+        rec := dbm.RData{Data: rdataFromRR(rr)}
+        if err := parseAttrs(zp.Comment(), &rec); err != nil {
+            return nil, validate.Errors{{Field: name + " " + typ, Message: err.Error()}}
         }
-        data := rdataFromRR(rr)
-        rs.Records = append(rs.Records, dbm.RData{Data: data})
+        rs.Records = append(rs.Records, rec)
         // keep the first TTL if already set
     }
+    if err := zp.Err(); err != nil { return nil, err }
+    return rrsets, nil
+}
 
-    return db.Transaction(func(tx *gorm.DB) error {
-        if strings.ToLower(mode) == "replace" {
-            if err := tx.Where("zone_id = ?", zone.ID).Delete(&dbm.RRSet{}).Error; err != nil {
+// checkRRSets validates rrsets for zone; names outside the zone are rejected
+func checkRRSets(rrsets []*dbm.RRSet, zone string) error {
+    for _, rs := range rrsets {
+        if errs := validate.RRSet(rs, zone); len(errs) > 0 {
+            return errs.Prefix(rs.Name + " " + rs.Type)
+        }
+    }
+    return nil
+}
+
+// upsertRRSets replaces the records of existing RRSets with those of rrsets
+// and creates the others, then checks the rules spanning RRSets
+func upsertRRSets(tx *gorm.DB, zone *dbm.Zone, rrsets []*dbm.RRSet) error {
+    for _, rs := range rrsets {
+        var existing dbm.RRSet
+        _ = tx.Where("zone_id = ? AND name = ? AND type = ?", zone.ID, rs.Name, rs.Type).Limit(1).Find(&existing).Error
+        if existing.ID != 0 {
+            if err := tx.Where("rr_set_id = ?", existing.ID).Delete(&dbm.RData{}).Error; err != nil {
                 return err
             }
-        }
-        for _, rs := range rrsets {
-            var existing dbm.RRSet
-            _ = tx.Where("zone_id = ? AND name = ? AND type = ?", zone.ID, rs.Name, rs.Type).Limit(1).Find(&existing).Error
-            if existing.ID != 0 {
-                if err := tx.Where("rr_set_id = ?", existing.ID).Delete(&dbm.RData{}).Error; err != nil {
-                    return err
-                }
-                existing.TTL = rs.TTL
-                existing.Records = rs.Records
-                if err := tx.Save(&existing).Error; err != nil {
-                    return err
-                }
-            } else {
-                if err := tx.Create(rs).Error; err != nil {
-                    return err
-                }
+            existing.TTL = rs.TTL
+            existing.Records = rs.Records
+            if err := tx.Save(&existing).Error; err != nil {
+                return err
+            }
+            *rs = existing
+        } else {
+            if err := purgeDeleted(tx, rs); err != nil {
+                return err
+            }
+            if err := tx.Create(rs).Error; err != nil {
+                return err
             }
         }
+    }
+    // Rules spanning RRSets are checked on the merged zone
+    return validate.Zone(dbm.ZoneSnapshot(tx, zone.ID), zone.Name).Err()
+}
+
+// purgeDeleted hard-deletes a soft-deleted RRSet with the key of rs, which
+// would collide with the unique index when rs is created, e.g. on a replace
+// import
+func purgeDeleted(tx *gorm.DB, rs *dbm.RRSet) error {
+    var ids []uint
+    if err := tx.Unscoped().Model(&dbm.RRSet{}).Where("zone_id = ? AND name = ? AND type = ? AND deleted_at IS NOT NULL", rs.ZoneID, rs.Name, rs.Type).Pluck("id", &ids).Error; err != nil {
+        return err
+    }
+    if len(ids) == 0 {
         return nil
-    })
+    }
+    if err := tx.Unscoped().Where("rr_set_id IN ?", ids).Delete(&dbm.RData{}).Error; err != nil {
+        return err
+    }
+    return tx.Unscoped().Delete(&dbm.RRSet{}, ids).Error
 }
 
 func rdataFromRR(rr dns.RR) string {
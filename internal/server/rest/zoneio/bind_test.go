@@ -1,6 +1,7 @@
 package zoneio
 
 import (
+    "errors"
     "strings"
     "testing"
 
@@ -8,6 +9,7 @@ import (
     "gorm.io/gorm"
 
     dbm "namedot/internal/db"
+    "namedot/internal/rdata"
 )
 
 func newTestDB(t *testing.T) *gorm.DB {
@@ -50,8 +52,8 @@ www 300 IN A 192.0.2.2
 
     // Export back to BIND and check contains lines
     z2 := dbm.Zone{ID: z.ID, Name: z.Name, RRSets: sets}
-    out := ToBind(&z2)
-    if !strings.Contains(out, "www.example.com 300 IN A 192.0.2.1") {
+    out := ToBind(&z2, BindOptions{})
+    if !strings.Contains(out, "www\t300\tIN\tA\t192.0.2.1\n") {
         t.Fatalf("export missing A record: %s", out)
     }
 }
@@ -61,12 +63,12 @@ func TestImportJSON_DefaultTTL(t *testing.T) {
     z := dbm.Zone{Name: "example2.com"}
     if err := db.Create(&z).Error; err != nil { t.Fatalf("create zone: %v", err) }
 
-    src := dbm.Zone{RRSets: []dbm.RRSet{{Name: "www.example.com.", Type: "A", TTL: 0, Records: []dbm.RData{{Data: "192.0.2.5"}}}}}
+    src := dbm.Zone{RRSets: []dbm.RRSet{{Name: "www.example2.com.", Type: "A", TTL: 0, Records: []dbm.RData{{Data: "192.0.2.5"}}}}}
     if err := ImportJSON(db, &z, &src, "replace", 1234); err != nil {
         t.Fatalf("import json: %v", err)
     }
     var set dbm.RRSet
-    if err := db.Where("zone_id = ? AND name = ? AND type = ?", z.ID, "www.example.com.", "A").First(&set).Error; err != nil {
+    if err := db.Where("zone_id = ? AND name = ? AND type = ?", z.ID, "www.example2.com.", "A").First(&set).Error; err != nil {
         t.Fatalf("load set: %v", err)
     }
     if set.TTL != 1234 { t.Fatalf("expected ttl 1234, got %d", set.TTL) }
@@ -77,13 +79,111 @@ func TestImportJSON_NoDefaultTTL_KeepsZeroTTL(t *testing.T) {
     z := dbm.Zone{Name: "example3.com"}
     if err := db.Create(&z).Error; err != nil { t.Fatalf("create zone: %v", err) }
 
-    src := dbm.Zone{RRSets: []dbm.RRSet{{Name: "api.example.com.", Type: "A", TTL: 0, Records: []dbm.RData{{Data: "192.0.2.6"}}}}}
+    src := dbm.Zone{RRSets: []dbm.RRSet{{Name: "api.example3.com.", Type: "A", TTL: 0, Records: []dbm.RData{{Data: "192.0.2.6"}}}}}
     if err := ImportJSON(db, &z, &src, "replace", 0); err != nil {
         t.Fatalf("import json: %v", err)
     }
     var set dbm.RRSet
-    if err := db.Where("zone_id = ? AND name = ? AND type = ?", z.ID, "api.example.com.", "A").First(&set).Error; err != nil {
+    if err := db.Where("zone_id = ? AND name = ? AND type = ?", z.ID, "api.example3.com.", "A").First(&set).Error; err != nil {
         t.Fatalf("load set: %v", err)
     }
     if set.TTL != 0 { t.Fatalf("expected ttl 0 to be preserved, got %d", set.TTL) }
 }
+
+func TestImportJSON_RejectsInvalidRecords(t *testing.T) {
+    db := newTestDB(t)
+    z := dbm.Zone{Name: "example4.com"}
+    if err := db.Create(&z).Error; err != nil { t.Fatalf("create zone: %v", err) }
+
+    src := dbm.Zone{RRSets: []dbm.RRSet{
+        {Name: "ok.example4.com.", Type: "A", TTL: 60, Records: []dbm.RData{{Data: "192.0.2.7"}}},
+        {Name: "_443._tcp.example4.com.", Type: "TLSA", TTL: 60, Records: []dbm.RData{{Data: "3 1 1 abcd"}}},
+    }}
+    err := ImportJSON(db, &z, &src, "replace", 0)
+    var rerr *rdata.Error
+    if !errors.As(err, &rerr) { t.Fatalf("expected *rdata.Error, got %v", err) }
+
+    var count int64
+    db.Model(&dbm.RRSet{}).Where("zone_id = ?", z.ID).Count(&count)
+    if count != 0 { t.Fatalf("import must be atomic, found %d rrsets", count) }
+}
+
+func TestImportBIND_RejectsInvalidCAA(t *testing.T) {
+    db := newTestDB(t)
+    z := dbm.Zone{Name: "example5.com"}
+    if err := db.Create(&z).Error; err != nil { t.Fatalf("create zone: %v", err) }
+
+    zone := "$ORIGIN example5.com.\n@ 300 IN CAA 7 issue \"ca.example\"\n"
+    if err := ImportBIND(db, &z, strings.NewReader(zone), "upsert", 0); err == nil {
+        t.Fatalf("expected CAA flags to be rejected")
+    }
+}
+
+func TestToBind_IsAMasterFileAndRoundTripsGeo(t *testing.T) {
+    db := newTestDB(t)
+    ru, eu, subnet, asn := "RU", "EU", "10.0.0.0/8", 65001
+    z := dbm.Zone{Name: "geo-export.test", DefaultTTL: 600, RRSets: []dbm.RRSet{
+        {Name: "www.geo-export.test.", Type: "A", TTL: 60, Records: []dbm.RData{
+            {Data: "192.0.2.1"}, {Data: "192.0.2.2", Country: &ru}, {Data: "192.0.2.3", Continent: &eu, ASN: &asn},
+            {Data: "192.0.2.4", Subnet: &subnet, AutoPTR: true}}},
+        {Name: "a.www.geo-export.test.", Type: "TXT", TTL: 60, Records: []dbm.RData{{Data: `"v=spf1 -all; x"`}}},
+        {Name: "geo-export.test.", Type: "NS", TTL: 3600, Records: []dbm.RData{{Data: "ns1.geo-export.test."}}},
+        {Name: "geo-export.test.", Type: "ALIAS", TTL: 60, Records: []dbm.RData{{Data: "lb.example.net."}}},
+        {Name: "geo-export.test.", Type: "SOA", TTL: 3600, Records: []dbm.RData{{Data: "ns1.geo-export.test. hostmaster.geo-export.test. 7 7200 3600 1209600 300"}}},
+        {Name: "b.geo-export.test.", Type: "A", TTL: 60, Records: []dbm.RData{{Data: "192.0.2.9"}}},
+    }}
+    out := ToBind(&z, BindOptions{Geo: true})
+    lines := strings.Split(out, "\n")
+    if lines[0] != "$ORIGIN geo-export.test." || lines[1] != "$TTL 600" || !strings.HasPrefix(lines[2], "@\t3600\tIN\tSOA\t") ||
+        !strings.HasPrefix(lines[3], "\t3600\tIN\tNS\t") {
+        t.Fatalf("unexpected head:\n%s", out)
+    }
+    // Names below www follow it
+    if strings.Index(out, "\nb\t") > strings.Index(out, "\nwww\t") || strings.Index(out, "\na.www\t") < strings.Index(out, "\nwww\t") {
+        t.Fatalf("names out of order:\n%s", out)
+    }
+    if !strings.Contains(out, "; @\t60\tIN\tALIAS\tlb.example.net.") || !strings.Contains(out, "192.0.2.3 ; namedot: continent=EU asn=65001\n") {
+        t.Fatalf("missing ALIAS comment or geo attributes:\n%s", out)
+    }
+    if strings.Contains(ToBind(&z, BindOptions{}), "namedot:") { t.Fatalf("geo comments are optional") }
+
+    // Reading the export back keeps records and attributes
+    target := dbm.Zone{Name: "geo-export.test"}
+    if err := db.Create(&target).Error; err != nil { t.Fatalf("create zone: %v", err) }
+    if err := ImportBIND(db, &target, strings.NewReader(out), "replace", 0); err != nil { t.Fatalf("import export: %v\n%s", err, out) }
+    var www dbm.RRSet
+    db.Preload("Records").Where("zone_id = ? AND name = ? AND type = ?", target.ID, "www.geo-export.test.", "A").First(&www)
+    got := map[string]string{}
+    for _, r := range www.Records { got[r.Data] = RecordAttrs(r) }
+    want := map[string]string{"192.0.2.1": "", "192.0.2.2": "country=RU", "192.0.2.3": "continent=EU asn=65001", "192.0.2.4": "subnet=10.0.0.0/8 auto_ptr"}
+    for k, v := range want {
+        if got[k] != v { t.Fatalf("%s: want %q, got %q", k, v, got[k]) }
+    }
+
+    bad := "$ORIGIN geo-export.test.\nx 60 IN A 192.0.2.1 ; namedot: asn=many\n"
+    if err := ImportBIND(db, &target, strings.NewReader(bad), "upsert", 0); err == nil { t.Fatalf("malformed attributes must be rejected") }
+}
+
+func TestToBind_WritesOneCNAMEWithoutGeo(t *testing.T) {
+    db := newTestDB(t)
+    ru := "RU"
+    z := dbm.Zone{Name: "cname-export.test", RRSets: []dbm.RRSet{
+        {Name: "www.cname-export.test.", Type: "CNAME", TTL: 60, Records: []dbm.RData{
+            {Data: "ru.example.net.", Country: &ru}, {Data: "all.example.net."}}},
+    }}
+    out := ToBind(&z, BindOptions{})
+    if !strings.Contains(out, "www\t60\tIN\tCNAME\tall.example.net.\n; www\t60\tIN\tCNAME\tru.example.net. ; namedot: country=RU\n") {
+        t.Fatalf("want the default CNAME and the geo one as a comment:\n%s", out)
+    }
+
+    target := dbm.Zone{Name: "cname-export.test"}
+    if err := db.Create(&target).Error; err != nil { t.Fatalf("create zone: %v", err) }
+    if err := ImportBIND(db, &target, strings.NewReader(out), "replace", 0); err != nil { t.Fatalf("import export: %v\n%s", err, out) }
+    var www dbm.RRSet
+    db.Preload("Records").Where("zone_id = ? AND name = ?", target.ID, "www.cname-export.test.").First(&www)
+    if len(www.Records) != 1 || www.Records[0].Data != "all.example.net." { t.Fatalf("want only the default CNAME back, got %+v", www.Records) }
+
+    if out := ToBind(&z, BindOptions{Geo: true}); strings.Count(out, "IN\tCNAME") != 2 || strings.Contains(out, "; www") {
+        t.Fatalf("with geo both CNAMEs are records:\n%s", out)
+    }
+}
@@ -0,0 +1,536 @@
+package zoneio
+
+import (
+    "archive/tar"
+    "bufio"
+    "bytes"
+    "compress/gzip"
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "path"
+    "path/filepath"
+    "slices"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/miekg/dns"
+    "gorm.io/gorm"
+
+    dbm "namedot/internal/db"
+    "namedot/internal/validate"
+)
+
+// Limits of the file tree of a bulk import
+const (
+    MaxBulkSize  = 64 << 20
+    MaxBulkFiles = 10000
+)
+
+// ErrBulkImport fails a bulk import when a zone of the report has an error;
+// nothing was changed
+var ErrBulkImport = errors.New("bulk import failed")
+
+// Sandbox is an in-memory file tree that a bulk import reads zone files,
+// named.conf and $INCLUDE targets from, so nothing outside the imported tree
+// is reachable. Absolute paths below the directory option of named.conf
+// resolve into the tree.
+type Sandbox struct {
+    files map[string][]byte
+    size  int
+    dir   string
+}
+
+// Open implements fs.FS
+func (s *Sandbox) Open(name string) (fs.File, error) {
+    if !fs.ValidPath(name) {
+        return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+    }
+    data, ok := s.files[name]
+    if rel := strings.TrimPrefix("/"+name, s.dir+"/"); !ok && s.dir != "" && rel != "/"+name {
+        name = rel
+        data, ok = s.files[rel]
+    }
+    if !ok {
+        return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+    }
+    return &sandboxFile{name: path.Base(name), Reader: bytes.NewReader(data)}, nil
+}
+
+// add stores a file of the tree within MaxBulkSize and MaxBulkFiles
+func (s *Sandbox) add(name string, r io.Reader) error {
+    if s.files == nil {
+        s.files = map[string][]byte{}
+    }
+    if len(s.files) >= MaxBulkFiles {
+        return fmt.Errorf("more than %d files", MaxBulkFiles)
+    }
+    data, err := io.ReadAll(io.LimitReader(r, int64(MaxBulkSize-s.size+1)))
+    if err != nil {
+        return err
+    }
+    if s.size += len(data); s.size > MaxBulkSize {
+        return fmt.Errorf("larger than %d bytes", MaxBulkSize)
+    }
+    s.files[name] = data
+    return nil
+}
+
+// sandboxFile is an open file of a Sandbox
+type sandboxFile struct {
+    name string
+    *bytes.Reader
+}
+
+func (f *sandboxFile) Stat() (fs.FileInfo, error) { return f, nil }
+func (f *sandboxFile) Close() error               { return nil }
+func (f *sandboxFile) Name() string               { return f.name }
+func (f *sandboxFile) Mode() fs.FileMode          { return 0o444 }
+func (f *sandboxFile) ModTime() time.Time         { return time.Time{} }
+func (f *sandboxFile) IsDir() bool                { return false }
+func (f *sandboxFile) Sys() any                   { return nil }
+
+// LoadTar reads a tarball, gzip-compressed or not, into a Sandbox. Only
+// regular files are kept; links and paths leaving the tree are rejected.
+func LoadTar(r io.Reader) (*Sandbox, error) {
+    br := bufio.NewReader(r)
+    if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+        gz, err := gzip.NewReader(br)
+        if err != nil {
+            return nil, err
+        }
+        defer gz.Close()
+        r = gz
+    } else {
+        r = br
+    }
+    sb := &Sandbox{}
+    tr := tar.NewReader(r)
+    for {
+        hdr, err := tr.Next()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, fmt.Errorf("read tarball: %w", err)
+        }
+        switch hdr.Typeflag {
+        case tar.TypeDir:
+            continue
+        case tar.TypeReg:
+        default:
+            return nil, fmt.Errorf("%s: only regular files are imported", hdr.Name)
+        }
+        name := path.Clean(strings.TrimLeft(hdr.Name, "/"))
+        if !fs.ValidPath(name) || name == "." {
+            return nil, fmt.Errorf("%s: path leaves the tree", hdr.Name)
+        }
+        if err := sb.add(name, tr); err != nil {
+            return nil, fmt.Errorf("%s: %w", hdr.Name, err)
+        }
+    }
+    return sb, nil
+}
+
+// LoadDir reads the regular files below dir into a Sandbox; symbolic links
+// are skipped so they cannot point outside it
+func LoadDir(dir string) (*Sandbox, error) {
+    sb := &Sandbox{}
+    err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+        if err != nil || !d.Type().IsRegular() {
+            return err
+        }
+        rel, err := filepath.Rel(dir, p)
+        if err != nil {
+            return err
+        }
+        f, err := os.Open(p)
+        if err != nil {
+            return err
+        }
+        defer f.Close()
+        if err := sb.add(filepath.ToSlash(rel), f); err != nil {
+            return fmt.Errorf("%s: %w", p, err)
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return sb, nil
+}
+
+// Load reads the source of a bulk import: a directory, a named.conf with the
+// files of its directory, or a tarball. It returns the named.conf to use, ""
+// to look for one in the tree.
+func Load(src string) (*Sandbox, string, error) {
+    fi, err := os.Stat(src)
+    if err != nil {
+        return nil, "", err
+    }
+    if fi.IsDir() {
+        sb, err := LoadDir(src)
+        return sb, "", err
+    }
+    if strings.HasSuffix(src, ".conf") {
+        sb, err := LoadDir(filepath.Dir(src))
+        return sb, filepath.Base(src), err
+    }
+    f, err := os.Open(src)
+    if err != nil {
+        return nil, "", err
+    }
+    defer f.Close()
+    sb, err := LoadTar(f)
+    return sb, "", err
+}
+
+// BulkOptions control BulkImport
+type BulkOptions struct {
+    Mode       string // upsert (default) keeps RRSets missing from the files, replace deletes them
+    View       string // view of the zones not inside a view statement of named.conf
+    NamedConf  string // named.conf in the tree; one named so is looked for when empty
+    DefaultTTL uint32
+    DryRun     bool                   // report the changes without making them
+    HasView    func(view string) bool // views of the config; nil accepts only ""
+    Allow      func(zone string) bool // zones the caller may change; nil accepts all
+    Actor      dbm.Actor
+    Serials    dbm.SerialManager
+}
+
+// BulkZone is the outcome of a bulk import for one zone
+type BulkZone struct {
+    dbm.ZoneDiff
+    File       string   `json:"file"`
+    Error      string   `json:"error,omitempty"`
+    PTRSkipped []string `json:"ptr_skipped,omitempty"` // reverse zones outside Allow whose auto PTRs were left alone
+}
+
+// BulkReport lists what a bulk import did, or would do on a dry run
+type BulkReport struct {
+    DryRun  bool       `json:"dry_run"`
+    Zones   []BulkZone `json:"zones"`
+    Skipped []string   `json:"skipped,omitempty"` // files and zone statements not imported, with the reason
+}
+
+// bulkZone is a zone file to import
+type bulkZone struct {
+    BulkZone
+    kind   string
+    rrsets []*dbm.RRSet
+}
+
+// BulkImport imports the zones of a file tree in one transaction. With a
+// named.conf it takes the primary and secondary zones it declares, else every
+// file holding an SOA, named after the SOA owner or, without $ORIGIN, after
+// the file (db.example.com, example.com.zone...). Missing zones are created.
+// $GENERATE is expanded and $INCLUDE reads from the tree only. Records
+// outside their zone fail the import. When a zone fails, the report says why
+// and the error is ErrBulkImport; nothing is changed then, nor on a dry run.
+func BulkImport(db *gorm.DB, sb *Sandbox, opts BulkOptions) (BulkReport, error) {
+    rep := BulkReport{DryRun: opts.DryRun, Zones: []BulkZone{}}
+    zones, err := discoverZones(sb, opts, &rep)
+    if err != nil {
+        return rep, err
+    }
+    failed := false
+    seen := map[string]bool{}
+    for _, z := range zones {
+        switch key := z.Zone + " " + z.View; {
+        case z.Error != "":
+        case seen[key]:
+            z.Error = "zone is imported twice"
+        case opts.Allow != nil && !opts.Allow(z.Zone):
+            z.Error = "zone not allowed for this token"
+        default:
+            seen[key] = true
+        }
+        failed = failed || z.Error != ""
+    }
+    if failed {
+        rep.Zones = reportOf(zones)
+        return rep, ErrBulkImport
+    }
+
+    err = db.Transaction(func(tx *gorm.DB) error {
+        bumps := opts.Serials.Batch(tx)
+        for _, z := range zones {
+            if err := importZone(tx, z, opts, bumps); err != nil {
+                var errs validate.Errors
+                if !errors.As(err, &errs) {
+                    return err
+                }
+                z.Error, failed = err.Error(), true
+            }
+        }
+        switch {
+        case failed:
+            return ErrBulkImport
+        case opts.DryRun:
+            return dbm.ErrDryRun
+        }
+        if err := bumps.Flush(); err != nil {
+            return fmt.Errorf("failed to bump SOA serials: %w", err)
+        }
+        return nil
+    })
+    rep.Zones = reportOf(zones)
+    if errors.Is(err, dbm.ErrDryRun) {
+        err = nil
+    }
+    return rep, err
+}
+
+// importZone stores the RRSets of z, creating the zone when missing, and
+// fills in the changes of its report
+func importZone(tx *gorm.DB, z *bulkZone, opts BulkOptions, bumps *dbm.SerialBatch) error {
+    var zone dbm.Zone
+    if err := tx.Where("name IN ? AND view = ?", []string{z.Zone, z.Zone + "."}, z.View).Limit(1).Find(&zone).Error; err != nil {
+        return err
+    }
+    created := zone.ID == 0
+    if created {
+        zone = dbm.Zone{Name: z.Zone, View: z.View, Kind: z.kind}
+        if err := tx.Create(&zone).Error; err != nil {
+            return fmt.Errorf("failed to create zone %s: %w", z.Zone, err)
+        }
+    }
+    before := dbm.ZoneSnapshot(tx, zone.ID)
+    for _, rs := range z.rrsets {
+        rs.ZoneID = zone.ID
+        if rs.TTL == 0 {
+            rs.TTL = zone.TTLDefault(opts.DefaultTTL)
+        }
+    }
+    if strings.ToLower(opts.Mode) == "replace" {
+        if err := deleteOthers(tx, zone.ID, z.rrsets); err != nil {
+            return err
+        }
+    }
+    if err := upsertRRSets(tx, &zone, z.rrsets); err != nil {
+        return err
+    }
+    after := dbm.ZoneSnapshot(tx, zone.ID)
+    z.ZoneDiff = dbm.NewZoneDiff(zone, before, after)
+    z.Created = created
+    if opts.DryRun {
+        return nil
+    }
+    if created {
+        if err := dbm.Audit(tx, opts.Actor, dbm.AuditZoneCreate, zone, nil, zone); err != nil {
+            return fmt.Errorf("failed to write audit log: %w", err)
+        }
+    }
+    if err := dbm.Audit(tx, opts.Actor, dbm.AuditZoneImport, zone, before, after); err != nil {
+        return fmt.Errorf("failed to write audit log: %w", err)
+    }
+    if created {
+        if err := dbm.RecordVersion(tx, zone.ID, ""); err != nil {
+            return fmt.Errorf("failed to record version of zone %s: %w", zone.Name, err)
+        }
+    } else {
+        bumps.Touch(zone)
+    }
+    allow := func(rz dbm.Zone) bool { return opts.Allow == nil || opts.Allow(strings.TrimSuffix(rz.Name, ".")) }
+    updates, err := dbm.SyncAutoPTR(tx, zone.View, before, after, allow)
+    if err != nil {
+        return err
+    }
+    for _, u := range updates {
+        if u.Denied {
+            if name := strings.TrimSuffix(u.Zone.Name, "."); !slices.Contains(z.PTRSkipped, name) {
+                z.PTRSkipped = append(z.PTRSkipped, name)
+            }
+            continue
+        }
+        if err := dbm.Audit(tx, opts.Actor, dbm.AuditRRSetAutoPTR, u.Zone, u.Before, u.After); err != nil {
+            return fmt.Errorf("failed to write audit log: %w", err)
+        }
+        bumps.Touch(u.Zone)
+    }
+    return nil
+}
+
+// deleteOthers deletes the RRSets of a zone that keep is missing
+func deleteOthers(tx *gorm.DB, zoneID uint, keep []*dbm.RRSet) error {
+    wanted := map[string]bool{}
+    for _, rs := range keep {
+        wanted[rs.Name+" "+rs.Type] = true
+    }
+    var sets []dbm.RRSet
+    if err := tx.Where("zone_id = ?", zoneID).Find(&sets).Error; err != nil {
+        return err
+    }
+    for _, rs := range sets {
+        if wanted[strings.ToLower(dns.Fqdn(rs.Name))+" "+strings.ToUpper(rs.Type)] {
+            continue
+        }
+        if err := tx.Where("rr_set_id = ?", rs.ID).Delete(&dbm.RData{}).Error; err != nil {
+            return err
+        }
+        if err := tx.Delete(&dbm.RRSet{}, rs.ID).Error; err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// reportOf returns the reports of zones
+func reportOf(zones []*bulkZone) []BulkZone {
+    out := make([]BulkZone, 0, len(zones))
+    for _, z := range zones {
+        out = append(out, z.BulkZone)
+    }
+    return out
+}
+
+// discoverZones finds and parses the zone files of the tree. Errors of a
+// zone are kept in its report; the error returned is about the tree itself.
+func discoverZones(sb *Sandbox, opts BulkOptions, rep *BulkReport) ([]*bulkZone, error) {
+    conf := opts.NamedConf
+    if conf == "" {
+        conf = findNamedConf(sb)
+    }
+    if conf == "" {
+        return scanZones(sb, opts, rep), nil
+    }
+    decls, dir, err := parseNamedConf(sb, conf)
+    if err != nil {
+        return nil, fmt.Errorf("named.conf: %w", err)
+    }
+    sb.dir = dir
+    var zones []*bulkZone
+    for _, d := range decls {
+        z := &bulkZone{BulkZone: BulkZone{ZoneDiff: dbm.ZoneDiff{Zone: strings.TrimSuffix(d.Name, "."), View: d.View}}, kind: d.kind()}
+        if d.View == "" {
+            z.View = opts.View
+        }
+        switch {
+        case z.kind == "":
+            rep.Skipped = append(rep.Skipped, fmt.Sprintf("zone %s: type %s is not imported", d.Name, d.Type))
+            continue
+        case d.File == "" && z.kind == dbm.ZoneKindSecondary:
+            rep.Skipped = append(rep.Skipped, fmt.Sprintf("zone %s: secondary without file", d.Name))
+            continue
+        }
+        z.File = confPath(d.File, "")
+        zones = append(zones, z)
+        name, err := validate.ZoneName(z.Zone)
+        if err != nil {
+            z.Error = "invalid zone name: " + err.Error()
+            continue
+        }
+        z.Zone = name
+        if !hasView(opts, z.View) {
+            z.Error = fmt.Sprintf("unknown view %q", z.View)
+            continue
+        }
+        if z.rrsets, err = parseZoneFile(sb, z.File, z.Zone); err == nil {
+            err = checkRRSets(z.rrsets, z.Zone)
+        }
+        if err != nil {
+            z.Error = err.Error()
+        }
+    }
+    return zones, nil
+}
+
+// scanZones parses every file of a tree without named.conf as a zone file;
+// those without an SOA, like the targets of $INCLUDE, are skipped
+func scanZones(sb *Sandbox, opts BulkOptions, rep *BulkReport) []*bulkZone {
+    files := make([]string, 0, len(sb.files))
+    for name := range sb.files {
+        files = append(files, name)
+    }
+    sort.Strings(files)
+    var zones []*bulkZone
+    for _, file := range files {
+        z := &bulkZone{BulkZone: BulkZone{ZoneDiff: dbm.ZoneDiff{Zone: zoneNameOf(file), View: opts.View}, File: file}, kind: dbm.ZoneKindPrimary}
+        rrsets, err := parseZoneFile(sb, file, z.Zone)
+        if err == nil {
+            // $ORIGIN or an absolute SOA owner names the zone rather than the file
+            if apex := soaOwner(rrsets); apex == "" {
+                rep.Skipped = append(rep.Skipped, file+": no SOA record")
+                continue
+            } else if apex != dns.Fqdn(z.Zone) {
+                z.Zone = strings.TrimSuffix(apex, ".")
+                rrsets, err = parseZoneFile(sb, file, z.Zone)
+            }
+        }
+        zones = append(zones, z)
+        if err == nil {
+            z.Zone, err = validate.ZoneName(z.Zone)
+        }
+        if err == nil && !hasView(opts, z.View) {
+            err = fmt.Errorf("unknown view %q", z.View)
+        }
+        if err == nil {
+            err = checkRRSets(rrsets, z.Zone)
+        }
+        if err != nil {
+            z.Error = err.Error()
+            continue
+        }
+        z.rrsets = rrsets
+    }
+    return zones
+}
+
+// parseZoneFile reads a zone file of the tree; $INCLUDE stays inside it.
+// RRSets without TTL get the default TTL of their zone on import.
+func parseZoneFile(sb *Sandbox, file, origin string) ([]*dbm.RRSet, error) {
+    f, err := sb.Open(file)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    zp := dns.NewZoneParser(f, dns.Fqdn(origin), file)
+    zp.SetIncludeAllowed(true)
+    zp.SetIncludeFS(sb)
+    return readRRSets(zp, 0, 0)
+}
+
+// soaOwner is the owner of the first SOA of rrsets, "" without one
+func soaOwner(rrsets []*dbm.RRSet) string {
+    for _, rs := range rrsets {
+        if rs.Type == "SOA" {
+            return rs.Name
+        }
+    }
+    return ""
+}
+
+// zoneNameOf guesses a zone name from the name of its file
+func zoneNameOf(file string) string {
+    n := strings.ToLower(path.Base(file))
+    n = strings.TrimPrefix(n, "db.")
+    for _, ext := range []string{".zone", ".db", ".hosts"} {
+        n = strings.TrimSuffix(n, ext)
+    }
+    return n
+}
+
+// findNamedConf returns the least deep file of the tree named named.conf
+func findNamedConf(sb *Sandbox) string {
+    best := ""
+    for name := range sb.files {
+        if path.Base(name) != "named.conf" {
+            continue
+        }
+        if best == "" || strings.Count(name, "/") < strings.Count(best, "/") ||
+            strings.Count(name, "/") == strings.Count(best, "/") && name < best {
+            best = name
+        }
+    }
+    return best
+}
+
+func hasView(opts BulkOptions, view string) bool {
+    if opts.HasView == nil {
+        return view == ""
+    }
+    return opts.HasView(view)
+}
@@ -0,0 +1,137 @@
+package zoneio
+
+import (
+    "archive/tar"
+    "bytes"
+    "compress/gzip"
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    dbm "namedot/internal/db"
+)
+
+func tarball(t *testing.T, files map[string]string) *bytes.Buffer {
+    t.Helper()
+    var buf bytes.Buffer
+    gz := gzip.NewWriter(&buf)
+    tw := tar.NewWriter(gz)
+    for name, body := range files {
+        if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil { t.Fatalf("tar header: %v", err) }
+        if _, err := tw.Write([]byte(body)); err != nil { t.Fatalf("tar write: %v", err) }
+    }
+    if err := tw.Close(); err != nil { t.Fatalf("tar close: %v", err) }
+    if err := gz.Close(); err != nil { t.Fatalf("gzip close: %v", err) }
+    return &buf
+}
+
+func TestBulkImport_NamedConfDryRunAndApply(t *testing.T) {
+    db := newTestDB(t)
+    existing := dbm.Zone{Name: "bulk-old.test"}
+    if err := db.Create(&existing).Error; err != nil { t.Fatalf("create zone: %v", err) }
+    if err := db.Create(&dbm.RRSet{ZoneID: existing.ID, Name: "gone.bulk-old.test.", Type: "A", TTL: 60, Records: []dbm.RData{{Data: "192.0.2.9"}}}).Error; err != nil { t.Fatalf("create rrset: %v", err) }
+
+    files := map[string]string{
+        "named.conf": `options { directory "/var/named"; };
+// comment
+zone "bulk-new.test" { type master; file "/var/named/db.bulk-new.test"; };
+zone "bulk-old.test" IN { type primary; file "bulk-old.zone"; };
+zone "." { type hint; file "root.hints"; };
+`,
+        "db.bulk-new.test": `$TTL 300
+@ IN SOA ns1 hostmaster 1 7200 3600 1209600 300
+@ IN NS ns1
+$GENERATE 1-3 host$ A 192.0.2.$
+$INCLUDE inc/mail.inc
+`,
+        "inc/mail.inc": "mail 600 IN MX 10 mx.bulk-new.test. ; namedot: country=RU\n",
+        "bulk-old.zone": `$ORIGIN bulk-old.test.
+@ 300 IN SOA ns1 hostmaster 5 7200 3600 1209600 300
+www 300 IN A 192.0.2.10
+`,
+    }
+    sb, err := LoadTar(tarball(t, files))
+    if err != nil { t.Fatalf("load tar: %v", err) }
+
+    opts := BulkOptions{Mode: "replace", DryRun: true, Actor: dbm.Actor{Name: "test"}}
+    rep, err := BulkImport(db, sb, opts)
+    if err != nil { t.Fatalf("dry run: %v (%+v)", err, rep) }
+    if len(rep.Zones) != 2 || len(rep.Skipped) != 1 { t.Fatalf("report: %+v", rep) }
+    nz := rep.Zones[0]
+    if nz.Zone != "bulk-new.test" || !nz.Created || nz.Added != 6 { t.Fatalf("new zone report: %+v", nz) }
+    oz := rep.Zones[1]
+    if oz.Created || oz.Added != 2 || oz.Removed != 1 || oz.Changed != 0 { t.Fatalf("old zone report: %+v", oz) }
+    var n int64
+    db.Model(&dbm.Zone{}).Where("name = ?", "bulk-new.test").Count(&n)
+    if n != 0 { t.Fatalf("dry run created the zone") }
+
+    opts.DryRun = false
+    if rep, err = BulkImport(db, sb, opts); err != nil { t.Fatalf("import: %v (%+v)", err, rep) }
+    var z dbm.Zone
+    if err := db.Preload("RRSets.Records").Where("name = ?", "bulk-new.test").First(&z).Error; err != nil { t.Fatalf("new zone: %v", err) }
+    found := map[string]dbm.RRSet{}
+    for _, rs := range z.RRSets {
+        found[rs.Name+" "+rs.Type] = rs
+    }
+    if rs := found["host2.bulk-new.test. A"]; len(rs.Records) != 1 || rs.Records[0].Data != "192.0.2.2" { t.Fatalf("$GENERATE: %+v", found) }
+    if rs := found["mail.bulk-new.test. MX"]; len(rs.Records) != 1 || rs.Records[0].Country == nil || *rs.Records[0].Country != "RU" { t.Fatalf("$INCLUDE: %+v", found) }
+    if sets := dbm.ZoneSnapshot(db, existing.ID); len(sets) != 2 { t.Fatalf("replace kept %d rrsets", len(sets)) }
+
+    // Same tree again: only the serial bumped by the import differs
+    opts.DryRun = true
+    if rep, err = BulkImport(db, sb, opts); err != nil { t.Fatalf("second dry run: %v", err) }
+    for _, z := range rep.Zones {
+        for _, ch := range z.Changes {
+            if ch.Type != "SOA" { t.Fatalf("unexpected change in %s: %+v", z.Zone, ch) }
+        }
+    }
+}
+
+func TestBulkImport_RejectsOutOfZoneDataAndEscapes(t *testing.T) {
+    db := newTestDB(t)
+    cases := map[string]map[string]string{
+        "out of zone": {"bulk-bad.test.zone": "$ORIGIN bulk-bad.test.\n@ 300 IN SOA ns1 hostmaster 1 7200 3600 1209600 300\nwww.other.test. 300 IN A 192.0.2.1\n"},
+        "include escape": {"bulk-bad.test.zone": "$ORIGIN bulk-bad.test.\n@ 300 IN SOA ns1 hostmaster 1 7200 3600 1209600 300\n$INCLUDE ../../etc/hosts\n"},
+        "include absolute": {"bulk-bad.test.zone": "$ORIGIN bulk-bad.test.\n@ 300 IN SOA ns1 hostmaster 1 7200 3600 1209600 300\n$INCLUDE /etc/hosts\n"},
+    }
+    for name, files := range cases {
+        sb, err := LoadTar(tarball(t, files))
+        if err != nil { t.Fatalf("%s: load: %v", name, err) }
+        rep, err := BulkImport(db, sb, BulkOptions{})
+        if !errors.Is(err, ErrBulkImport) || len(rep.Zones) != 1 || rep.Zones[0].Error == "" { t.Fatalf("%s: err %v, report %+v", name, err, rep) }
+    }
+    var n int64
+    db.Model(&dbm.Zone{}).Where("name = ?", "bulk-bad.test").Count(&n)
+    if n != 0 { t.Fatalf("failed import created the zone") }
+
+    var buf bytes.Buffer
+    tw := tar.NewWriter(&buf)
+    _ = tw.WriteHeader(&tar.Header{Name: "../evil.zone", Mode: 0o644, Typeflag: tar.TypeReg})
+    _ = tw.Close()
+    if _, err := LoadTar(&buf); err == nil { t.Fatalf("path leaving the tree accepted") }
+}
+
+func TestLoadDir_ScansZonesAndSkipsLinks(t *testing.T) {
+    db := newTestDB(t)
+    dir := t.TempDir()
+    write := func(name, body string) {
+        if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil { t.Fatalf("write: %v", err) }
+    }
+    write("db.bulk-dir.test", "@ 300 IN SOA ns1 hostmaster 1 7200 3600 1209600 300\nwww 300 IN A 192.0.2.1\n")
+    write("db.192.0.2", "$ORIGIN 2.0.192.in-addr.arpa.\n@ 300 IN SOA ns1.bulk-dir.test. hostmaster.bulk-dir.test. 1 7200 3600 1209600 300\n1 300 IN PTR www.bulk-dir.test.\n")
+    write("fragment.inc", "mail 300 IN A 192.0.2.5\n")
+    if err := os.Symlink("/etc/hosts", filepath.Join(dir, "hosts.zone")); err != nil { t.Skipf("symlink: %v", err) }
+
+    sb, conf, err := Load(dir)
+    if err != nil || conf != "" { t.Fatalf("load: %v %q", err, conf) }
+    rep, err := BulkImport(db, sb, BulkOptions{DryRun: true})
+    if err != nil { t.Fatalf("dry run: %v (%+v)", err, rep) }
+    var names []string
+    for _, z := range rep.Zones {
+        names = append(names, z.Zone)
+    }
+    if strings.Join(names, ",") != "2.0.192.in-addr.arpa,bulk-dir.test" { t.Fatalf("zones: %v", names) }
+    if len(rep.Skipped) != 1 || !strings.HasPrefix(rep.Skipped[0], "fragment.inc") { t.Fatalf("skipped: %v", rep.Skipped) }
+}
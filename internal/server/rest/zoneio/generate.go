@@ -0,0 +1,111 @@
+package zoneio
+
+import (
+    "fmt"
+    "regexp"
+    "strconv"
+    "strings"
+
+    "gorm.io/gorm"
+
+    dbm "namedot/internal/db"
+    "namedot/internal/validate"
+)
+
+// MaxGenerate limits the number of iterations of one $GENERATE range
+const MaxGenerate = 65536
+
+// generateTypes are the record types BIND accepts in $GENERATE
+var generateTypes = []string{"A", "AAAA", "CNAME", "DNAME", "NS", "PTR"}
+
+var generateRange = regexp.MustCompile(`^(\d+)-(\d+)(?:/(\d+))?$`)
+
+// GenerateSpec describes records to fill a range with, as the BIND
+// directive "$GENERATE range lhs [ttl] type rhs": "$" in lhs and rhs is the
+// iterator, "${offset,width,base}" formats it and "\$" is a literal "$".
+type GenerateSpec struct {
+    Range   string `json:"range"` // start-stop[/step]
+    LHS     string `json:"lhs"`   // owner name, relative to the zone unless absolute
+    Type    string `json:"type"`  // A, AAAA, CNAME, DNAME, NS or PTR
+    RHS     string `json:"rhs"`
+    TTL     uint32 `json:"ttl"`
+    AutoPTR bool   `json:"auto_ptr"` // set auto_ptr on generated A/AAAA records
+}
+
+// check validates the spec; fields are named as in JSON
+func (g *GenerateSpec) check() validate.Errors {
+    var errs validate.Errors
+    g.Type = strings.ToUpper(strings.TrimSpace(g.Type))
+    m := generateRange.FindStringSubmatch(strings.TrimSpace(g.Range))
+    if m == nil {
+        errs.Add("range", "must be start-stop or start-stop/step")
+    } else {
+        start, _ := strconv.Atoi(m[1])
+        stop, _ := strconv.Atoi(m[2])
+        step := 1
+        if m[3] != "" {
+            step, _ = strconv.Atoi(m[3])
+        }
+        switch {
+        case start > stop:
+            errs.Add("range", "start must not be greater than stop")
+        case step < 1:
+            errs.Add("range", "step must be at least 1")
+        case (stop-start)/step+1 > MaxGenerate:
+            errs.Add("range", "generates more than %d records", MaxGenerate)
+        }
+    }
+    for field, v := range map[string]string{"lhs": g.LHS, "rhs": g.RHS} {
+        if strings.TrimSpace(v) == "" {
+            errs.Add(field, "is required")
+        } else if strings.ContainsAny(v, " \t\r\n;()\"") {
+            errs.Add(field, "must be a single name or address")
+        }
+    }
+    found := false
+    for _, t := range generateTypes {
+        found = found || t == g.Type
+    }
+    if !found {
+        errs.Add("type", "must be one of %s", strings.Join(generateTypes, ", "))
+    }
+    if err := validate.TTL(g.TTL); err != nil {
+        errs.AddErr("ttl", err)
+    }
+    return errs
+}
+
+// Generate expands spec as BIND's $GENERATE and stores the resulting RRSets
+// in zone, replacing the records of RRSets that exist. It returns the
+// generated RRSets.
+func Generate(db *gorm.DB, zone *dbm.Zone, spec GenerateSpec, defaultTTL uint32) ([]dbm.RRSet, error) {
+    if errs := spec.check(); len(errs) > 0 {
+        return nil, errs
+    }
+    ttl := spec.TTL
+    if ttl == 0 {
+        ttl = defaultTTL
+    }
+    text := fmt.Sprintf("$GENERATE %s %s %d IN %s %s\n", strings.TrimSpace(spec.Range), spec.LHS, ttl, spec.Type, spec.RHS)
+    rrsets, err := parseBIND(strings.NewReader(text), zone, defaultTTL)
+    if err != nil {
+        return nil, err
+    }
+    for _, rs := range rrsets {
+        if spec.AutoPTR && (rs.Type == "A" || rs.Type == "AAAA") {
+            for i := range rs.Records {
+                rs.Records[i].AutoPTR = true
+            }
+        }
+    }
+    if err := db.Transaction(func(tx *gorm.DB) error {
+        return upsertRRSets(tx, zone, rrsets)
+    }); err != nil {
+        return nil, err
+    }
+    out := make([]dbm.RRSet, len(rrsets))
+    for i, rs := range rrsets {
+        out[i] = *rs
+    }
+    return out, nil
+}
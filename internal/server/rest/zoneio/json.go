@@ -1,11 +1,13 @@
 package zoneio
 
 import (
+    "fmt"
     "strings"
 
     "gorm.io/gorm"
 
     dbm "namedot/internal/db"
+    "namedot/internal/validate"
 )
 
 // ImportJSON imports RRsets from src into dst zone.
@@ -17,13 +19,16 @@ func ImportJSON(db *gorm.DB, dst *dbm.Zone, src *dbm.Zone, mode string, defaultT
                 return err
             }
         }
-        for _, rs := range src.RRSets {
+        for i, rs := range src.RRSets {
             rs.ZoneID = dst.ID
             rs.Name = strings.ToLower(rs.Name)
             rs.Type = strings.ToUpper(rs.Type)
             if rs.TTL == 0 && defaultTTL > 0 {
                 rs.TTL = defaultTTL
             }
+            if errs := validate.RRSet(&rs, dst.Name); len(errs) > 0 {
+                return errs.Prefix(fmt.Sprintf("rrsets[%d]", i))
+            }
             // Upsert by name+type
             var existing dbm.RRSet
             if err := tx.Where("zone_id = ? AND name = ? AND type = ?", dst.ID, rs.Name, rs.Type).First(&existing).Error; err == nil {
@@ -37,11 +42,15 @@ func ImportJSON(db *gorm.DB, dst *dbm.Zone, src *dbm.Zone, mode string, defaultT
                     return err
                 }
             } else {
+                if err := purgeDeleted(tx, &rs); err != nil {
+                    return err
+                }
                 if err := tx.Create(&rs).Error; err != nil {
                     return err
                 }
             }
         }
-        return nil
+        // Rules spanning RRSets are checked on the merged zone
+        return validate.Zone(dbm.ZoneSnapshot(tx, dst.ID), dst.Name).Err()
     })
 }
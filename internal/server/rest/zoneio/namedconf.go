@@ -0,0 +1,220 @@
+package zoneio
+
+import (
+    "fmt"
+    "io/fs"
+    "path"
+    "strings"
+
+    dbm "namedot/internal/db"
+)
+
+// maxConfInclude limits the nesting of include statements in named.conf
+const maxConfInclude = 10
+
+// confZone is a zone statement of named.conf
+type confZone struct {
+    Name string
+    View string // the view statement the zone is in, "" outside views
+    Type string // as written: master, primary, slave, secondary, forward...
+    File string
+}
+
+// confToken is a word, a quoted string or one of "{", "}" and ";"
+type confToken struct {
+    text   string
+    quoted bool
+}
+
+func (t confToken) is(punct string) bool { return !t.quoted && t.text == punct }
+
+// confStmt is a statement: its words up to the block or ";", and the
+// statements of its block
+type confStmt struct {
+    args  []string
+    block []confStmt
+}
+
+// tokenizeConf splits named.conf text into tokens, dropping //, # and /* */
+// comments
+func tokenizeConf(src string) ([]confToken, error) {
+    var toks []confToken
+    for i := 0; i < len(src); {
+        c := src[i]
+        switch {
+        case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+            i++
+        case c == '#' || strings.HasPrefix(src[i:], "//"):
+            for i < len(src) && src[i] != '\n' {
+                i++
+            }
+        case strings.HasPrefix(src[i:], "/*"):
+            end := strings.Index(src[i+2:], "*/")
+            if end < 0 {
+                return nil, fmt.Errorf("unterminated comment")
+            }
+            i += end + 4
+        case c == '"':
+            end := strings.IndexByte(src[i+1:], '"')
+            if end < 0 {
+                return nil, fmt.Errorf("unterminated string")
+            }
+            toks = append(toks, confToken{text: src[i+1 : i+1+end], quoted: true})
+            i += end + 2
+        case c == '{' || c == '}' || c == ';':
+            toks = append(toks, confToken{text: string(c)})
+            i++
+        default:
+            j := i
+            for j < len(src) && !strings.ContainsRune(" \t\r\n{};\"#", rune(src[j])) && !strings.HasPrefix(src[j:], "//") && !strings.HasPrefix(src[j:], "/*") {
+                j++
+            }
+            toks = append(toks, confToken{text: src[i:j]})
+            i = j
+        }
+    }
+    return toks, nil
+}
+
+// parseConfStmts parses statements from toks[i] up to the "}" closing the
+// block, or the end at the top level; it returns the index after them
+func parseConfStmts(toks []confToken, i int, top bool) ([]confStmt, int, error) {
+    var stmts []confStmt
+    for i < len(toks) {
+        if toks[i].is("}") {
+            if top {
+                return nil, i, fmt.Errorf("unexpected }")
+            }
+            return stmts, i + 1, nil
+        }
+        var st confStmt
+        for i < len(toks) && !toks[i].is(";") && !toks[i].is("{") && !toks[i].is("}") {
+            st.args = append(st.args, toks[i].text)
+            i++
+        }
+        if i < len(toks) && toks[i].is("{") {
+            var err error
+            if st.block, i, err = parseConfStmts(toks, i+1, false); err != nil {
+                return nil, i, err
+            }
+        }
+        if i < len(toks) && toks[i].is(";") {
+            i++
+        }
+        if len(st.args) > 0 || st.block != nil {
+            stmts = append(stmts, st)
+        }
+    }
+    if !top {
+        return nil, i, fmt.Errorf("missing }")
+    }
+    return stmts, i, nil
+}
+
+// readConf parses a named.conf file of fsys with its include statements
+// spliced in
+func readConf(fsys fs.FS, file string, depth int) ([]confStmt, error) {
+    if depth > maxConfInclude {
+        return nil, fmt.Errorf("%s: too deeply nested include", file)
+    }
+    b, err := fs.ReadFile(fsys, file)
+    if err != nil {
+        return nil, err
+    }
+    toks, err := tokenizeConf(string(b))
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", file, err)
+    }
+    stmts, _, err := parseConfStmts(toks, 0, true)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", file, err)
+    }
+    var out []confStmt
+    for _, st := range stmts {
+        if len(st.args) == 2 && strings.EqualFold(st.args[0], "include") {
+            inc, err := readConf(fsys, confPath(st.args[1], path.Dir(file)), depth+1)
+            if err != nil {
+                return nil, err
+            }
+            out = append(out, inc...)
+            continue
+        }
+        out = append(out, st)
+    }
+    return out, nil
+}
+
+// confPath turns a path of named.conf into a path of the sandbox: absolute
+// paths lose their leading "/", which the sandbox then resolves against the
+// directory option, relative ones are taken from dir
+func confPath(p, dir string) string {
+    if path.IsAbs(p) {
+        return strings.TrimLeft(path.Clean(p), "/")
+    }
+    return path.Join(dir, p)
+}
+
+// parseNamedConf reads the zone statements of a named.conf in fsys and its
+// directory option
+func parseNamedConf(fsys fs.FS, file string) ([]confZone, string, error) {
+    stmts, err := readConf(fsys, file, 0)
+    if err != nil {
+        return nil, "", err
+    }
+    var zones []confZone
+    var directory string
+    for _, st := range stmts {
+        switch strings.ToLower(st.args[0]) {
+        case "options":
+            for _, o := range st.block {
+                if len(o.args) == 2 && strings.EqualFold(o.args[0], "directory") {
+                    directory = path.Clean(o.args[1])
+                }
+            }
+        case "zone":
+            zones = append(zones, confZoneOf(st, ""))
+        case "view":
+            if len(st.args) < 2 {
+                continue
+            }
+            for _, vs := range st.block {
+                if strings.EqualFold(vs.args[0], "zone") {
+                    zones = append(zones, confZoneOf(vs, st.args[1]))
+                }
+            }
+        }
+    }
+    return zones, directory, nil
+}
+
+// confZoneOf reads a zone statement
+func confZoneOf(st confStmt, view string) confZone {
+    z := confZone{View: view}
+    if len(st.args) > 1 {
+        z.Name = st.args[1]
+    }
+    for _, o := range st.block {
+        if len(o.args) != 2 {
+            continue
+        }
+        switch strings.ToLower(o.args[0]) {
+        case "type":
+            z.Type = strings.ToLower(o.args[1])
+        case "file":
+            z.File = o.args[1]
+        }
+    }
+    return z
+}
+
+// kind maps the zone type of named.conf to a zone kind; "" for the types
+// that hold no zone data namedot serves (hint, stub, forward...)
+func (z confZone) kind() string {
+    switch z.Type {
+    case "master", "primary":
+        return dbm.ZoneKindPrimary
+    case "slave", "secondary":
+        return dbm.ZoneKindSecondary
+    }
+    return ""
+}
@@ -1,6 +1,7 @@
 package rest
 
 import (
+	"archive/tar"
 	"bytes"
 	"encoding/json"
 	"net/http"
@@ -258,7 +259,7 @@ func TestImportZone_JSON(t *testing.T) {
 				"name":"import.test",
 				"rrsets":[
 					{
-						"name":"www.import.test.",
+						"name":"www.export.test.",
 						"type":"A",
 						"ttl":300,
 						"records":[{"data":"192.0.2.1"}]
@@ -285,7 +286,7 @@ func TestImportZone_JSON(t *testing.T) {
 				"name":"import.test",
 				"rrsets":[
 					{
-						"name":"new.import.test.",
+						"name":"new.export.test.",
 						"type":"A",
 						"ttl":300,
 						"records":[{"data":"192.0.2.2"}]
@@ -301,7 +302,7 @@ func TestImportZone_JSON(t *testing.T) {
 				// Should have the new record
 				found := false
 				for _, rr := range rrsets {
-					if rr.Name == "new.import.test." {
+					if rr.Name == "new.export.test." {
 						found = true
 						break
 					}
@@ -320,7 +321,7 @@ func TestImportZone_JSON(t *testing.T) {
 				"name":"import.test",
 				"rrsets":[
 					{
-						"name":"geo.import.test.",
+						"name":"geo.export.test.",
 						"type":"A",
 						"ttl":300,
 						"records":[
@@ -333,7 +334,7 @@ func TestImportZone_JSON(t *testing.T) {
 			expectedStatus: http.StatusNoContent,
 			validateResult: func(t *testing.T, db *gorm.DB, zoneID uint) {
 				var rrsets []RRSet
-				if err := db.Preload("Records").Where("zone_id = ? AND name = ?", zoneID, "geo.import.test.").Find(&rrsets).Error; err != nil {
+				if err := db.Preload("Records").Where("zone_id = ? AND name = ?", zoneID, "geo.export.test.").Find(&rrsets).Error; err != nil {
 					t.Fatalf("Failed to load rrsets: %v", err)
 				}
 				if len(rrsets) == 0 {
@@ -362,7 +363,7 @@ func TestImportZone_JSON(t *testing.T) {
 			if tt.existingData {
 				rrset := RRSet{
 					ZoneID: zoneID,
-					Name:   "old.import.test.",
+					Name:   "old.export.test.",
 					Type:   "A",
 					TTL:    300,
 					Records: []RData{
@@ -449,6 +450,130 @@ www A   192.0.2.1
 	}
 }
 
+func TestImportZone_DryRun(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server, gormDB, zoneID := setupZoneIOTestServer(t)
+	country := "RU"
+	for _, rs := range []RRSet{
+		{ZoneID: zoneID, Name: "www.export.test.", Type: "A", TTL: 300, Records: []RData{{Data: "192.0.2.1"}}},
+		{ZoneID: zoneID, Name: "old.export.test.", Type: "A", TTL: 300, Records: []RData{{Data: "192.0.2.9"}}},
+	} {
+		if err := gormDB.Create(&rs).Error; err != nil {
+			t.Fatalf("create rrset: %v", err)
+		}
+	}
+	before := dbm.ZoneSnapshot(gormDB, zoneID)
+
+	payload, _ := json.Marshal(Zone{RRSets: []RRSet{
+		{Name: "www.export.test.", Type: "A", TTL: 300, Records: []RData{{Data: "192.0.2.1"}, {Data: "192.0.2.2", Country: &country}}},
+		{Name: "new.export.test.", Type: "A", TTL: 300, Records: []RData{{Data: "192.0.2.3"}}},
+	}})
+	url := "/zones/" + strconv.FormatUint(uint64(zoneID), 10) + "/import?format=json&mode=replace&dry_run=true"
+	req := httptest.NewRequest("POST", url, bytes.NewReader(payload))
+	req.Header.Set("Authorization", "Bearer testtoken")
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	server.r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", w.Code, w.Body.String())
+	}
+
+	var diff dbm.ZoneDiff
+	if err := json.Unmarshal(w.Body.Bytes(), &diff); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if diff.Added != 1 || diff.Changed != 1 || diff.Removed != 1 {
+		t.Fatalf("diff: %s", w.Body.String())
+	}
+	for _, ch := range diff.Changes {
+		if ch.Change != dbm.ChangeChanged {
+			continue
+		}
+		if len(ch.Before.Records) != 1 || len(ch.After.Records) != 2 || ch.After.Records[1].Country == nil || *ch.After.Records[1].Country != "RU" {
+			t.Errorf("changed rrset lacks old/new records: %+v", ch)
+		}
+	}
+	if !dbm.SameRRSets(before, dbm.ZoneSnapshot(gormDB, zoneID)) {
+		t.Error("dry run changed the zone")
+	}
+
+	url = "/zones/" + strconv.FormatUint(uint64(zoneID), 10) + "/import?format=bind&dry_run=true"
+	req = httptest.NewRequest("POST", url, bytes.NewBufferString("www 300 IN A 192.0.2.7\n"))
+	req.Header.Set("Authorization", "Bearer testtoken")
+	w = httptest.NewRecorder()
+	server.r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"changed":1`) {
+		t.Errorf("bind dry run: status %d, body %s", w.Code, w.Body.String())
+	}
+}
+
+func TestImportBulk_DryRunThenApply(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server, gormDB, _ := setupZoneIOTestServer(t)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	files := map[string]string{
+		"named.conf":       `zone "bulk.test" { type master; file "bulk.test.zone"; };`,
+		"bulk.test.zone":   "$ORIGIN bulk.test.\n@ 300 IN SOA ns1 hostmaster 1 7200 3600 1209600 300\nwww 300 IN A 192.0.2.1\n",
+		"export.test.zone": "$ORIGIN export.test.\nwww 300 IN A 192.0.2.2\n",
+	}
+	for name, body := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatalf("tar write: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar close: %v", err)
+	}
+
+	post := func(query string) (*httptest.ResponseRecorder, bulkResp) {
+		req := httptest.NewRequest("POST", "/import/bind"+query, bytes.NewReader(buf.Bytes()))
+		req.Header.Set("Authorization", "Bearer testtoken")
+		req.Header.Set("Content-Type", "application/x-tar")
+		w := httptest.NewRecorder()
+		server.r.ServeHTTP(w, req)
+		var resp bulkResp
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp
+	}
+
+	w, resp := post("?dry_run=true")
+	if w.Code != http.StatusOK {
+		t.Fatalf("dry run: status %d, body %s", w.Code, w.Body.String())
+	}
+	if !resp.DryRun || len(resp.Zones) != 1 || !resp.Zones[0].Created || resp.Zones[0].Added != 2 {
+		t.Fatalf("dry run report: %s", w.Body.String())
+	}
+	var n int64
+	gormDB.Model(&Zone{}).Where("name = ?", "bulk.test").Count(&n)
+	if n != 0 {
+		t.Fatal("dry run created the zone")
+	}
+
+	if w, _ = post(""); w.Code != http.StatusOK {
+		t.Fatalf("import: status %d, body %s", w.Code, w.Body.String())
+	}
+	gormDB.Model(&Zone{}).Where("name = ?", "bulk.test").Count(&n)
+	if n != 1 {
+		t.Fatal("zone not created")
+	}
+	var audits int64
+	gormDB.Model(&dbm.AuditLog{}).Where("zone_name = ? AND action = ?", "bulk.test", dbm.AuditZoneImport).Count(&audits)
+	if audits != 1 {
+		t.Errorf("expected 1 import audit entry, got %d", audits)
+	}
+
+	if w, _ = post("?mode=bogus"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid mode: status %d", w.Code)
+	}
+}
+
 func TestImportZone_UnsupportedFormat(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -3,10 +3,13 @@ package rest
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/driver/sqlite"
@@ -518,3 +521,216 @@ func TestZoneOperations_WithoutAuthentication(t *testing.T) {
 func itoa(u uint) string {
 	return strconv.FormatUint(uint64(u), 10)
 }
+
+func TestCreateZone_Views(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		APIToken: "testtoken",
+		Views:    []config.ViewConfig{{Name: "internal", MatchClients: []string{"10.0.0.0/8"}}},
+	}
+	server, _, _ := setupZoneTestServer(t, cfg)
+
+	post := func(payload string) int {
+		req := httptest.NewRequest("POST", "/zones", bytes.NewBufferString(payload))
+		req.Header.Set("Authorization", "Bearer testtoken")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := post(`{"name":"example.com"}`); code != http.StatusCreated {
+		t.Fatalf("default view zone: expected 201, got %d", code)
+	}
+	if code := post(`{"name":"example.com","view":"internal"}`); code != http.StatusCreated {
+		t.Fatalf("same name in another view: expected 201, got %d", code)
+	}
+	if code := post(`{"name":"example.com","view":"internal"}`); code != http.StatusBadRequest {
+		t.Fatalf("duplicate zone in view: expected 400, got %d", code)
+	}
+	if code := post(`{"name":"example.net","view":"missing"}`); code != http.StatusBadRequest {
+		t.Fatalf("unknown view: expected 400, got %d", code)
+	}
+
+	req := httptest.NewRequest("GET", "/zones?view=internal", nil)
+	req.Header.Set("Authorization", "Bearer testtoken")
+	w := httptest.NewRecorder()
+	server.r.ServeHTTP(w, req)
+	var zones []db.Zone
+	if err := json.Unmarshal(w.Body.Bytes(), &zones); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(zones) != 1 || zones[0].View != "internal" {
+		t.Fatalf("expected one internal zone, got %+v", zones)
+	}
+}
+
+func TestZoneHealth_ReportsInvalidRows(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server, gormDB, _ := setupZoneTestServer(t, &config.Config{APIToken: "testtoken"})
+	zone := db.Zone{Name: "health.test", RRSets: []db.RRSet{
+		{Name: "www.health.test.", Type: "A", TTL: 60, Records: []db.RData{{Data: "192.0.2.1"}, {Data: "bogus"}}},
+		// Stored before write-time checks: DNS still answers it, so health passes it too
+		{Name: "health.test.", Type: "CAA", TTL: 60, Records: []db.RData{{Data: `0 issue "ca.example"`}, {Data: `5 issue "legacy.example"`}}},
+	}}
+	if err := gormDB.Create(&zone).Error; err != nil {
+		t.Fatalf("create zone: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("/zones/%d/health", zone.ID), nil)
+	req.Header.Set("Authorization", "Bearer testtoken")
+	w := httptest.NewRecorder()
+	server.r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	var resp struct {
+		Status  string          `json:"status"`
+		Records int             `json:"records"`
+		Invalid []invalidRecord `json:"invalid"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Status != "degraded" || resp.Records != 4 || len(resp.Invalid) != 1 {
+		t.Fatalf("unexpected health report: %+v", resp)
+	}
+	if bad := resp.Invalid[0]; bad.Data != "bogus" || bad.Type != "A" || bad.RecordID == 0 || bad.Error == "" {
+		t.Fatalf("unexpected invalid row: %+v", bad)
+	}
+}
+
+func TestZoneMetadata_CreatePatchAndDefaults(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{APIToken: "testtoken", DefaultTTL: 300, AutoSOAOnMissing: true}
+	server, gormDB, mockDNS := setupZoneTestServer(t, cfg)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer testtoken")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/zones", `{"name":"meta.test","description":"Customer A","tags":["Prod"," web","prod"],"default_ttl":900,
+		"soa_defaults":{"primary":"ns.meta.test","hostmaster":"dns@meta.test","minimum":60}}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", w.Code, w.Body.String())
+	}
+	var z db.Zone
+	if err := json.Unmarshal(w.Body.Bytes(), &z); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if z.Kind != db.ZoneKindPrimary || z.Tags != "prod,web" || z.SOA.Hostmaster != "dns.meta.test." {
+		t.Fatalf("unexpected zone: %+v", z)
+	}
+
+	// RRSets without TTL get the zone default; the SOA is built from the zone defaults
+	w = send("POST", fmt.Sprintf("/zones/%d/rrsets", z.ID), `{"name":"www","type":"A","records":[{"data":"192.0.2.1"}]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create rrset: %d %s", w.Code, w.Body.String())
+	}
+	var www db.RRSet
+	gormDB.Where("zone_id = ? AND type = ?", z.ID, "A").First(&www)
+	if www.TTL != 900 {
+		t.Fatalf("want zone default TTL 900, got %d", www.TTL)
+	}
+	var soa db.RRSet
+	gormDB.Preload("Records").Where("zone_id = ? AND type = ?", z.ID, "SOA").First(&soa)
+	if len(soa.Records) != 1 || !strings.HasPrefix(soa.Records[0].Data, "ns.meta.test. dns.meta.test. ") ||
+		!strings.HasSuffix(soa.Records[0].Data, " 60") {
+		t.Fatalf("unexpected SOA: %+v", soa.Records)
+	}
+
+	// PATCH changes only the given fields
+	mockDNS.invalidateCalled = false
+	w = send("PATCH", fmt.Sprintf("/zones/%d", z.ID), `{"kind":"secondary","disabled":true,"description":""}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("patch: %d %s", w.Code, w.Body.String())
+	}
+	var stored db.Zone
+	gormDB.First(&stored, z.ID)
+	if stored.Kind != db.ZoneKindSecondary || !stored.Disabled || stored.Description != "" || stored.Tags != "prod,web" || stored.DefaultTTL != 900 {
+		t.Fatalf("unexpected zone after patch: %+v", stored)
+	}
+	if !mockDNS.invalidateCalled {
+		t.Fatalf("patch must invalidate the zone cache")
+	}
+	if _, total, _ := db.ListAudit(gormDB, db.AuditFilter{ZoneID: z.ID, Action: db.AuditZoneUpdate}); total != 1 {
+		t.Fatalf("zone update must be audited")
+	}
+
+	// Invalid metadata is reported per field
+	w = send("PATCH", fmt.Sprintf("/zones/%d", z.ID), `{"kind":"stub","soa_defaults":{"primary":"bad..name"}}`)
+	if w.Code != http.StatusBadRequest || !bytes.Contains(w.Body.Bytes(), []byte(`"field":"kind"`)) ||
+		!bytes.Contains(w.Body.Bytes(), []byte(`"field":"soa_defaults.primary"`)) {
+		t.Fatalf("want field errors, got %d %s", w.Code, w.Body.String())
+	}
+
+	// Zones can be listed by tag and kind
+	send("POST", "/zones", `{"name":"other.test","tags":["web"]}`)
+	for query, want := range map[string]int{"tag=prod": 1, "tag=web": 2, "tag=we": 0, "kind=secondary": 1, "kind=primary": 1} {
+		w = send("GET", "/zones?"+query, "")
+		var zs []db.Zone
+		if err := json.Unmarshal(w.Body.Bytes(), &zs); err != nil || len(zs) != want {
+			t.Fatalf("%s: want %d zones, got %s", query, want, w.Body.String())
+		}
+	}
+}
+
+func TestZoneSerialPolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{APIToken: "testtoken", DefaultTTL: 300, AutoSOAOnMissing: true, SOASerialPolicy: db.SerialEpoch}
+	server, gormDB, _ := setupZoneTestServer(t, cfg)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer testtoken")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.r.ServeHTTP(w, req)
+		return w
+	}
+	serial := func(zoneID uint) uint32 {
+		var soa db.RRSet
+		gormDB.Preload("Records").Where("zone_id = ? AND type = ?", zoneID, "SOA").First(&soa)
+		n, _ := strconv.ParseUint(strings.Fields(soa.Records[0].Data)[2], 10, 32)
+		return uint32(n)
+	}
+
+	w := send("POST", "/zones", `{"name":"serial.test","serial_policy":"bogus"}`)
+	if w.Code != http.StatusBadRequest || !bytes.Contains(w.Body.Bytes(), []byte(`"field":"serial_policy"`)) {
+		t.Fatalf("want serial_policy field error, got %d %s", w.Code, w.Body.String())
+	}
+	w = send("POST", "/zones", `{"name":"serial.test","serial_policy":"date"}`)
+	var z db.Zone
+	if err := json.Unmarshal(w.Body.Bytes(), &z); err != nil || z.SerialPolicy != db.SerialDate {
+		t.Fatalf("create: %d %s", w.Code, w.Body.String())
+	}
+
+	// The zone policy wins over the configured epoch policy
+	send("POST", fmt.Sprintf("/zones/%d/rrsets", z.ID), `{"name":"www","type":"A","records":[{"data":"192.0.2.1"}]}`)
+	first := serial(z.ID)
+	today, _ := strconv.ParseUint(time.Now().UTC().Format("20060102")+"00", 10, 32)
+	if first != uint32(today) {
+		t.Fatalf("want date serial %d, got %d", today, first)
+	}
+	send("POST", fmt.Sprintf("/zones/%d/rrsets", z.ID), `{"name":"api","type":"A","records":[{"data":"192.0.2.2"}]}`)
+	if got := serial(z.ID); got != first+1 {
+		t.Fatalf("want counter %d, got %d", first+1, got)
+	}
+
+	// Clearing the policy falls back to the configured one
+	if w = send("PATCH", fmt.Sprintf("/zones/%d", z.ID), `{"serial_policy":""}`); w.Code != http.StatusOK {
+		t.Fatalf("patch: %d %s", w.Code, w.Body.String())
+	}
+	send("POST", fmt.Sprintf("/zones/%d/rrsets", z.ID), `{"name":"mail","type":"A","records":[{"data":"192.0.2.3"}]}`)
+	if got := serial(z.ID); got < uint32(time.Now().Unix())-60 {
+		t.Fatalf("want epoch serial, got %d", got)
+	}
+}
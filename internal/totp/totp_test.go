@@ -0,0 +1,66 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 appendix B test vectors
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode_RFC6238Vectors(t *testing.T) {
+	// Appendix B lists 8 digit codes; the 6 digit codes are their last digits
+	for unix, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		got, err := Code(rfcSecret, time.Unix(unix, 0))
+		if err != nil {
+			t.Fatalf("code: %v", err)
+		}
+		if got != want {
+			t.Errorf("t=%d: want %s, got %s", unix, want, got)
+		}
+	}
+}
+
+func TestValidate_SkewAndReplay(t *testing.T) {
+	secret := rfcSecret
+	now := time.Unix(1111111111, 0)
+	prev, _ := Code(secret, now.Add(-Period))
+	next, _ := Code(secret, now.Add(Period))
+	far, _ := Code(secret, now.Add(-3*Period))
+
+	step, ok := Validate(secret, prev, now, 0)
+	if !ok || step != Step(now)-1 {
+		t.Fatalf("previous step should be accepted: %d %v", step, ok)
+	}
+	if _, ok := Validate(secret, prev, now, step); ok {
+		t.Fatalf("a used code must not be accepted again")
+	}
+	if _, ok := Validate(secret, next, now, step); !ok {
+		t.Fatalf("next step should be accepted")
+	}
+	if _, ok := Validate(secret, far, now, 0); ok {
+		t.Fatalf("codes outside the skew must be rejected")
+	}
+	if _, ok := Validate(secret, "12345", now, 0); ok {
+		t.Fatalf("short codes must be rejected")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("namedot", "alice@example.com", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/namedot:alice@example.com?") ||
+		!strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") || !strings.Contains(uri, "issuer=namedot") {
+		t.Fatalf("unexpected URI %s", uri)
+	}
+	if _, err := Code("not base32!", time.Now()); err == nil {
+		t.Fatalf("invalid secret should fail")
+	}
+}
@@ -0,0 +1,343 @@
+// Package validate checks zone names, owner names, TTLs and record data
+// before they are stored. It is shared by the REST API, the web admin, zone
+// imports and template application, and reports problems per input field.
+package validate
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+
+	dbm "namedot/internal/db"
+	"namedot/internal/idn"
+	"namedot/internal/rdata"
+)
+
+// MaxTTL is the largest TTL allowed by RFC 2181
+const MaxTTL = 1<<31 - 1
+
+// FieldError is a problem with one input field, e.g. "ttl" or
+// "records[1].data"
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	Err     error  `json:"-"` // cause, e.g. an *rdata.Error
+}
+
+// Errors lists field errors; a non-empty Errors is an error
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Field + ": " + fe.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Add records a problem with field
+func (e *Errors) Add(field, format string, a ...any) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, a...)})
+}
+
+// AddErr records err as the problem with field; errors.As still finds err
+func (e *Errors) AddErr(field string, err error) {
+	*e = append(*e, FieldError{Field: field, Message: err.Error(), Err: err})
+}
+
+// Unwrap returns the causes of the errors
+func (e Errors) Unwrap() []error {
+	var out []error
+	for _, fe := range e {
+		if fe.Err != nil {
+			out = append(out, fe.Err)
+		}
+	}
+	return out
+}
+
+// Prefix returns the errors with prefix in front of their field names, for
+// errors of an element of a list like "rrsets[2]"
+func (e Errors) Prefix(prefix string) Errors {
+	out := make(Errors, len(e))
+	for i, fe := range e {
+		out[i] = FieldError{Field: prefix + "." + fe.Field, Message: fe.Message, Err: fe.Err}
+	}
+	return out
+}
+
+// Err returns e as an error, nil when empty
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// idnaProfile checks labels the way registries do for IDNs; ASCII labels
+// keep underscores, which service names like _dmarc need
+var idnaProfile = idna.New(idna.ValidateForRegistration(), idna.StrictDomainName(false))
+
+// checkName validates the labels of a domain name without trailing dot: at
+// most 63 octets per label and 253 in total, letters, digits, hyphens,
+// underscores and the "/" of RFC 2317 reverse names, no hyphen at either
+// end, and well-formed xn-- labels. A leading "*" label is allowed when
+// wildcard is set.
+func checkName(name string, wildcard bool) error {
+	if name == "" {
+		return fmt.Errorf("name is empty")
+	}
+	if len(name) > 253 {
+		return fmt.Errorf("name is longer than 253 characters")
+	}
+	for i, label := range strings.Split(name, ".") {
+		switch {
+		case label == "":
+			return fmt.Errorf("name has an empty label")
+		case label == "*" && i == 0 && wildcard:
+			continue
+		case len(label) > 63:
+			return fmt.Errorf("label %q is longer than 63 characters", label)
+		case strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-"):
+			return fmt.Errorf("label %q starts or ends with a hyphen", label)
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '/') {
+				return fmt.Errorf("label %q contains %q", label, r)
+			}
+		}
+		if strings.HasPrefix(label, "xn--") {
+			if _, err := idnaProfile.ToUnicode(label); err != nil {
+				return fmt.Errorf("label %q is not a valid IDN: %v", label, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ZoneName validates a zone name and returns it lowercased without
+// trailing dot, with Unicode labels converted to punycode
+func ZoneName(name string) (string, error) {
+	n, err := idn.ToASCII(strings.TrimSpace(name))
+	if err != nil {
+		return "", err
+	}
+	n = strings.TrimSuffix(n, ".")
+	if err := checkName(n, false); err != nil {
+		return "", err
+	}
+	return n, nil
+}
+
+// OwnerName validates an absolute owner name, in ASCII or Unicode form,
+// against the zone it belongs to: the zone apex or a name below it
+func OwnerName(name, zone string) error {
+	n, err := idn.ToASCII(name)
+	if err != nil {
+		return err
+	}
+	n = strings.TrimSuffix(n, ".")
+	z := strings.TrimSuffix(strings.ToLower(zone), ".")
+	if err := checkName(n, true); err != nil {
+		return err
+	}
+	if n != z && !strings.HasSuffix(n, "."+z) {
+		return fmt.Errorf("%s is outside zone %s", dnsName(n), dnsName(z))
+	}
+	return nil
+}
+
+// TTL checks a TTL against MaxTTL
+func TTL(ttl uint32) error {
+	if ttl > MaxTTL {
+		return fmt.Errorf("must be at most %d", MaxTTL)
+	}
+	return nil
+}
+
+// ZoneMeta validates the metadata of a zone and normalizes it: an empty kind
+// becomes primary and SOA names become absolute ASCII names. A hostmaster
+// may be given as an email address.
+func ZoneMeta(z *dbm.Zone) Errors {
+	var errs Errors
+	z.Kind = strings.ToLower(strings.TrimSpace(z.Kind))
+	if z.Kind == "" {
+		z.Kind = dbm.ZoneKindPrimary
+	}
+	if !slices.Contains(dbm.ZoneKinds, z.Kind) {
+		errs.Add("kind", "must be one of %s", strings.Join(dbm.ZoneKinds, ", "))
+	}
+	z.Owner = strings.TrimSpace(z.Owner)
+	if len(z.Owner) > 255 {
+		errs.Add("owner", "is longer than 255 characters")
+	}
+	if len(z.Tags) > 255 {
+		errs.Add("tags", "are longer than 255 characters in total")
+	}
+	for _, t := range z.TagList() {
+		for _, r := range t {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == ':' || r == '.' || r == '/') {
+				errs.Add("tags", "tag %q contains %q", t, r)
+				break
+			}
+		}
+	}
+	z.SerialPolicy = strings.ToLower(strings.TrimSpace(z.SerialPolicy))
+	if z.SerialPolicy != "" && !slices.Contains(dbm.SerialPolicies, z.SerialPolicy) {
+		errs.Add("serial_policy", "must be one of %s", strings.Join(dbm.SerialPolicies, ", "))
+	}
+	if err := TTL(z.DefaultTTL); err != nil {
+		errs.Add("default_ttl", "%s", err)
+	}
+	soaName := func(field string, name *string) {
+		if *name == "" {
+			return
+		}
+		n, err := idn.ToASCII(strings.TrimSpace(*name))
+		if err == nil {
+			n = strings.TrimSuffix(n, ".")
+			err = checkName(n, false)
+		}
+		if err != nil {
+			errs.Add(field, "%s", err)
+			return
+		}
+		*name = dnsName(n)
+	}
+	soaName("soa_defaults.primary", &z.SOA.Primary)
+	z.SOA.Hostmaster = strings.Replace(strings.TrimSpace(z.SOA.Hostmaster), "@", ".", 1)
+	soaName("soa_defaults.hostmaster", &z.SOA.Hostmaster)
+	timers := []struct {
+		field string
+		value uint32
+	}{{"refresh", z.SOA.Refresh}, {"retry", z.SOA.Retry}, {"expire", z.SOA.Expire}, {"minimum", z.SOA.Minimum}, {"ttl", z.SOA.TTL}}
+	for _, t := range timers {
+		if err := TTL(t.value); err != nil {
+			errs.Add("soa_defaults."+t.field, "%s", err)
+		}
+	}
+	return errs
+}
+
+// RRSet validates an RRSet of zone and stores its name in ASCII form and its
+// record data in canonical form. The name must already be absolute.
+func RRSet(set *dbm.RRSet, zone string) Errors {
+	var errs Errors
+	set.Type = strings.ToUpper(strings.TrimSpace(set.Type))
+	name, err := idn.ToASCII(set.Name)
+	if err == nil {
+		set.Name = name
+		err = OwnerName(name, zone)
+	}
+	if err != nil {
+		errs.Add("name", "%s", err)
+	}
+	if !rdata.Supported(set.Type) {
+		errs.Add("type", "unsupported record type %q", set.Type)
+		return errs
+	}
+	if err := TTL(set.TTL); err != nil {
+		errs.Add("ttl", "%s", err)
+	}
+	switch set.Type {
+	case "SOA":
+		if !strings.EqualFold(strings.TrimSuffix(set.Name, "."), strings.TrimSuffix(zone, ".")) {
+			errs.Add("name", "SOA is only allowed at the zone apex")
+		}
+		if len(set.Records) > 1 {
+			errs.Add("records", "a zone has a single SOA record")
+		}
+	case "CNAME", "DNAME":
+		// Several records only make sense with different geo selectors,
+		// as a resolver may get just one of them
+		seen := map[string]bool{}
+		for _, r := range set.Records {
+			key := selectorKey(r)
+			if seen[key] {
+				errs.Add("records", "a %s RRSet holds a single record per geo selector", set.Type)
+				break
+			}
+			seen[key] = true
+		}
+	}
+	for i := range set.Records {
+		data, err := rdata.Canonical(set.Type, set.Records[i].Data, zone)
+		if err != nil {
+			errs.AddErr(fmt.Sprintf("records[%d].data", i), err)
+			continue
+		}
+		set.Records[i].Data = data
+	}
+	return errs
+}
+
+// Coexistence checks that set can live next to the other RRSets at its name:
+// a CNAME excludes every other type (RFC 1034 3.6.2)
+func Coexistence(set dbm.RRSet, others []dbm.RRSet) Errors {
+	var errs Errors
+	for _, o := range others {
+		if !strings.EqualFold(o.Name, set.Name) || strings.EqualFold(o.Type, set.Type) || len(o.Records) == 0 {
+			continue
+		}
+		if strings.EqualFold(set.Type, "CNAME") || strings.EqualFold(o.Type, "CNAME") {
+			errs.Add("type", "%s cannot be combined with %s at %s; a CNAME excludes other data", set.Type, strings.ToUpper(o.Type), set.Name)
+			break
+		}
+	}
+	return errs
+}
+
+// Zone checks the rules that span RRSets of a whole zone: CNAME exclusivity
+// and a single SOA at the apex. Field names point at "name TYPE" keys.
+func Zone(sets []dbm.RRSet, zone string) Errors {
+	var errs Errors
+	byName := map[string][]dbm.RRSet{}
+	for _, rs := range sets {
+		if len(rs.Records) == 0 {
+			continue
+		}
+		n := strings.ToLower(rs.Name)
+		byName[n] = append(byName[n], rs)
+		if strings.EqualFold(rs.Type, "SOA") {
+			if !strings.EqualFold(strings.TrimSuffix(n, "."), strings.TrimSuffix(zone, ".")) {
+				errs.Add(rs.Name+" SOA", "SOA is only allowed at the zone apex")
+			} else if len(rs.Records) > 1 {
+				errs.Add(rs.Name+" SOA", "a zone has a single SOA record")
+			}
+		}
+	}
+	names := make([]string, 0, len(byName))
+	for n := range byName {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		for _, rs := range byName[n] {
+			if strings.EqualFold(rs.Type, "CNAME") && len(byName[n]) > 1 {
+				errs.Add(rs.Name+" CNAME", "a CNAME excludes other data at the same name")
+			}
+		}
+	}
+	return errs
+}
+
+func selectorKey(r dbm.RData) string {
+	key := func(p *string) string {
+		if p == nil {
+			return ""
+		}
+		return strings.ToUpper(*p)
+	}
+	asn := ""
+	if r.ASN != nil {
+		asn = fmt.Sprint(*r.ASN)
+	}
+	return key(r.Country) + "|" + key(r.Continent) + "|" + asn + "|" + key(r.Subnet)
+}
+
+func dnsName(n string) string {
+	return n + "."
+}
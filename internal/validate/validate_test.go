@@ -0,0 +1,109 @@
+package validate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	dbm "namedot/internal/db"
+	"namedot/internal/rdata"
+)
+
+func TestZoneName(t *testing.T) {
+	good := map[string]string{
+		"Example.COM.":              "example.com",
+		"xn--bcher-kva.example":     "xn--bcher-kva.example",
+		"2/25.2.0.192.in-addr.arpa": "2/25.2.0.192.in-addr.arpa",
+	}
+	for in, want := range good {
+		got, err := ZoneName(in)
+		if err != nil || got != want {
+			t.Errorf("ZoneName(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	bad := []string{"", "a..b", "-a.com", "a-.com", "exa mple.com", "*.example.com", "xn--zz.example",
+		strings.Repeat("a", 64) + ".com", strings.Repeat("abcdefghi.", 26) + "com"}
+	for _, in := range bad {
+		if _, err := ZoneName(in); err == nil {
+			t.Errorf("ZoneName(%q): expected error", in)
+		}
+	}
+}
+
+func TestOwnerName(t *testing.T) {
+	for _, n := range []string{"example.com.", "www.example.com.", "*.example.com.", "_dmarc.example.com"} {
+		if err := OwnerName(n, "example.com"); err != nil {
+			t.Errorf("OwnerName(%q): %v", n, err)
+		}
+	}
+	for _, n := range []string{"example.net.", "badexample.com.", "www.*.example.com."} {
+		if err := OwnerName(n, "example.com"); err == nil {
+			t.Errorf("OwnerName(%q): expected error", n)
+		}
+	}
+}
+
+func TestRRSet(t *testing.T) {
+	set := dbm.RRSet{Name: "www.example.com.", Type: "cname", TTL: 60, Records: []dbm.RData{{Data: "@"}}}
+	if errs := RRSet(&set, "example.com"); len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if set.Type != "CNAME" || set.Records[0].Data != "example.com." {
+		t.Fatalf("not canonicalized: %s %q", set.Type, set.Records[0].Data)
+	}
+
+	set = dbm.RRSet{Name: "www.example.net.", Type: "A", TTL: MaxTTL + 1, Records: []dbm.RData{{Data: "192.0.2.1"}, {Data: "bad"}}}
+	errs := RRSet(&set, "example.com")
+	fields := map[string]bool{}
+	for _, fe := range errs {
+		fields[fe.Field] = true
+	}
+	for _, f := range []string{"name", "ttl", "records[1].data"} {
+		if !fields[f] {
+			t.Errorf("expected an error for %s, got %v", f, errs)
+		}
+	}
+	var rerr *rdata.Error
+	if !errors.As(errs.Err(), &rerr) {
+		t.Errorf("expected the record error to unwrap to *rdata.Error")
+	}
+
+	set = dbm.RRSet{Name: "www.example.com.", Type: "SOA", Records: []dbm.RData{{Data: "ns1.example.com. admin.example.com. 1 7200 3600 1209600 300"}}}
+	if errs := RRSet(&set, "example.com"); len(errs) == 0 {
+		t.Errorf("expected SOA below the apex to fail")
+	}
+
+	us, eu := "US", "EU"
+	set = dbm.RRSet{Name: "www.example.com.", Type: "CNAME", Records: []dbm.RData{{Data: "a.example.net.", Country: &us}, {Data: "b.example.net.", Continent: &eu}}}
+	if errs := RRSet(&set, "example.com"); len(errs) > 0 {
+		t.Errorf("geo CNAMEs: unexpected errors: %v", errs)
+	}
+	set.Records = append(set.Records, dbm.RData{Data: "c.example.net.", Country: &us})
+	if errs := RRSet(&set, "example.com"); len(errs) == 0 {
+		t.Errorf("expected two CNAMEs for the same country to fail")
+	}
+}
+
+func TestCoexistenceAndZone(t *testing.T) {
+	cname := dbm.RRSet{Name: "www.example.com.", Type: "CNAME", Records: []dbm.RData{{Data: "example.net."}}}
+	a := dbm.RRSet{Name: "www.example.com.", Type: "A", Records: []dbm.RData{{Data: "192.0.2.1"}}}
+	txt := dbm.RRSet{Name: "other.example.com.", Type: "TXT", Records: []dbm.RData{{Data: `"x"`}}}
+
+	if errs := Coexistence(cname, []dbm.RRSet{txt}); len(errs) > 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	if errs := Coexistence(cname, []dbm.RRSet{a}); len(errs) == 0 {
+		t.Errorf("expected CNAME next to A to fail")
+	}
+	if errs := Coexistence(a, []dbm.RRSet{cname}); len(errs) == 0 {
+		t.Errorf("expected A next to CNAME to fail")
+	}
+	if errs := Zone([]dbm.RRSet{cname, a, txt}, "example.com"); len(errs) != 1 || errs[0].Field != "www.example.com. CNAME" {
+		t.Errorf("Zone: got %v", errs)
+	}
+	soa := "ns1.example.com. admin.example.com. 1 7200 3600 1209600 300"
+	sets := []dbm.RRSet{{Name: "example.com.", Type: "SOA", Records: []dbm.RData{{Data: soa}, {Data: soa}}}}
+	if errs := Zone(sets, "example.com"); len(errs) != 1 {
+		t.Errorf("expected two SOA records to fail, got %v", errs)
+	}
+}
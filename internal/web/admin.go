@@ -6,34 +6,50 @@ import (
 	"encoding/base64"
 	"html/template"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
+	"namedot/internal/authguard"
 	"namedot/internal/config"
+	"namedot/internal/db"
+	"namedot/internal/oidc"
 )
 
 //go:embed templates/*.html
 var templatesFS embed.FS
 
+// ZoneCache is the answer cache of the DNS server, dropped after the web
+// admin changes zones
+type ZoneCache interface {
+	InvalidateZoneCache()
+}
+
 type Server struct {
 	cfg      *config.Config
 	db       *gorm.DB
+	cache    ZoneCache
 	tmpl     *template.Template
-	sessions map[string]*Session // sessionID -> Session
-}
+	sessions SessionStore
+	guard    *authguard.Guard
+
+	janitorStop chan struct{} // closed by Close to stop the session janitor
+	closeOnce   sync.Once
 
-type Session struct {
-	Username  string
-	CreatedAt time.Time
-	ExpiresAt time.Time
-	CSRFToken string
+	totpMu        sync.Mutex
+	adminTOTPStep int64 // last accepted step of admin.totp_secret
+
+	oidcMu      sync.Mutex
+	oidc        *oidc.Provider          // discovered on first SSO login
+	oidcPending map[string]oidcPending // state -> login in progress
 }
 
-func NewServer(cfg *config.Config, db *gorm.DB) (*Server, error) {
+func NewServer(cfg *config.Config, db *gorm.DB, cache ZoneCache) (*Server, error) {
     if !cfg.Admin.Enabled {
         return nil, nil
     }
@@ -47,14 +63,31 @@ func NewServer(cfg *config.Config, db *gorm.DB) (*Server, error) {
         return nil, err
     }
 
+	sessions := NewSessionStore(cfg.Admin.SessionStore, db)
+	janitorStop := make(chan struct{})
+	go runSessionJanitor(sessions, janitorInterval, cfg.Admin.SessionIdleTimeout(), janitorStop)
+
 	return &Server{
 		cfg:      cfg,
 		db:       db,
+		cache:    cache,
 		tmpl:     tmpl,
-		sessions: make(map[string]*Session),
+		sessions: sessions,
+		guard:    authguard.New(cfg.Admin.LoginProtection),
+
+		janitorStop: janitorStop,
+		oidcPending: make(map[string]oidcPending),
 	}, nil
 }
 
+// Close stops the background work of the web admin
+func (s *Server) Close() {
+	if s == nil {
+		return
+	}
+	s.closeOnce.Do(func() { close(s.janitorStop) })
+}
+
 func (s *Server) RegisterRoutes(r *gin.Engine) {
 	if s == nil || !s.cfg.Admin.Enabled {
 		return
@@ -64,6 +97,12 @@ func (s *Server) RegisterRoutes(r *gin.Engine) {
     r.GET("/admin/login", s.loginPage)
     r.POST("/admin/login", s.loginSubmit)
     r.GET("/admin/lang/:code", s.setLang)
+    r.GET("/admin/login/2fa", s.twoFactorPage)
+    r.POST("/admin/login/2fa", s.twoFactorSubmit)
+    if s.oidcEnabled() {
+        r.GET("/admin/oidc/login", s.oidcLogin)
+        r.GET("/admin/oidc/callback", s.oidcCallback)
+    }
 
 	// Protected routes
 	admin := r.Group("/admin")
@@ -72,33 +111,71 @@ func (s *Server) RegisterRoutes(r *gin.Engine) {
 		admin.GET("/", s.dashboard)
 		admin.GET("/logout", s.logout)
 
-		// Zones
+		editor := s.requireRole(db.RoleEditor)
+		adminOnly := s.requireRole(db.RoleAdmin)
+
+		// Zones (handlers also check zone ownership)
 		admin.GET("/zones", s.listZones)
-		admin.GET("/zones/new", s.newZoneForm)
-		admin.POST("/zones", s.csrfMiddleware(), s.createZone)
-		admin.DELETE("/zones/delete/:id", s.csrfMiddleware(), s.deleteZone)
+		admin.GET("/zones/new", editor, s.newZoneForm)
+		admin.POST("/zones", editor, s.csrfMiddleware(), s.createZone)
+		admin.GET("/zones/:id/edit", editor, s.editZoneForm)
+		admin.PUT("/zones/:id", editor, s.csrfMiddleware(), s.updateZone)
+		admin.DELETE("/zones/delete/:id", editor, s.csrfMiddleware(), s.deleteZone)
 
 		// Records
 		admin.GET("/zones/:id/records", s.listRecords)
-		admin.GET("/zones/:id/records/new", s.newRecordForm)
-		admin.POST("/zones/:id/records", s.csrfMiddleware(), s.createRecord)
-		admin.GET("/records/:id/edit", s.editRecordForm)
-		admin.PUT("/records/:id", s.csrfMiddleware(), s.updateRecord)
-		admin.DELETE("/records/:id", s.csrfMiddleware(), s.deleteRecord)
-
-		// Templates
+		admin.GET("/zones/:id/audit", s.zoneAudit)
+		admin.GET("/zones/:id/versions", s.zoneVersions)
+		admin.GET("/zones/:id/versions/diff", s.versionDiff)
+		admin.POST("/zones/:id/versions/:vid/rollback", editor, s.csrfMiddleware(), s.rollbackZone)
+		admin.GET("/zones/:id/changeset", s.zoneChangeset)
+		admin.POST("/zones/:id/changeset", editor, s.csrfMiddleware(), s.startChangeset)
+		admin.DELETE("/zones/:id/changeset", editor, s.csrfMiddleware(), s.discardChangeset)
+		admin.POST("/zones/:id/changeset/rrsets", editor, s.csrfMiddleware(), s.setChangesetRRSet)
+		admin.DELETE("/zones/:id/changeset/rrsets", editor, s.csrfMiddleware(), s.removeChangesetRRSet)
+		admin.POST("/zones/:id/changeset/approve", editor, s.csrfMiddleware(), s.approveChangeset)
+		admin.POST("/zones/:id/changeset/publish", editor, s.csrfMiddleware(), s.publishChangeset)
+		admin.GET("/zones/:id/records/new", editor, s.newRecordForm)
+		admin.POST("/zones/:id/records", editor, s.csrfMiddleware(), s.createRecord)
+		admin.GET("/records/fields", s.recordDataFields)
+		admin.GET("/records/:id/edit", editor, s.editRecordForm)
+		admin.PUT("/records/:id", editor, s.csrfMiddleware(), s.updateRecord)
+		admin.DELETE("/records/:id", editor, s.csrfMiddleware(), s.deleteRecord)
+
+		// Templates are shared by all zones, so only admins change them
 		admin.GET("/templates", s.listTemplates)
-		admin.GET("/templates/new", s.newTemplateForm)
-		admin.POST("/templates", s.csrfMiddleware(), s.createTemplate)
+		admin.GET("/templates/new", adminOnly, s.newTemplateForm)
+		admin.POST("/templates", adminOnly, s.csrfMiddleware(), s.createTemplate)
 		admin.GET("/templates/:id/view", s.viewTemplate)
-		admin.GET("/templates/:id/edit", s.editTemplateForm)
-		admin.PUT("/templates/:id", s.csrfMiddleware(), s.updateTemplate)
-		admin.DELETE("/templates/:id", s.csrfMiddleware(), s.deleteTemplate)
-		admin.GET("/templates/:id/records/new", s.newTemplateRecordForm)
-		admin.POST("/templates/:id/records", s.csrfMiddleware(), s.createTemplateRecord)
-		admin.DELETE("/templates/records/:id", s.csrfMiddleware(), s.deleteTemplateRecord)
-		admin.GET("/templates/:id/apply", s.applyTemplateForm)
-		admin.POST("/templates/:id/apply", s.csrfMiddleware(), s.applyTemplate)
+		admin.GET("/templates/:id/edit", adminOnly, s.editTemplateForm)
+		admin.PUT("/templates/:id", adminOnly, s.csrfMiddleware(), s.updateTemplate)
+		admin.DELETE("/templates/:id", adminOnly, s.csrfMiddleware(), s.deleteTemplate)
+		admin.GET("/templates/:id/records/new", adminOnly, s.newTemplateRecordForm)
+		admin.POST("/templates/:id/records", adminOnly, s.csrfMiddleware(), s.createTemplateRecord)
+		admin.DELETE("/templates/records/:id", adminOnly, s.csrfMiddleware(), s.deleteTemplateRecord)
+		admin.GET("/templates/:id/apply", editor, s.applyTemplateForm)
+		admin.POST("/templates/:id/apply", editor, s.csrfMiddleware(), s.applyTemplate)
+
+		// Users
+		admin.GET("/users", adminOnly, s.listUsers)
+		admin.GET("/users/new", adminOnly, s.newUserForm)
+		admin.POST("/users", adminOnly, s.csrfMiddleware(), s.createUser)
+		admin.GET("/users/:id/edit", adminOnly, s.editUserForm)
+		admin.PUT("/users/:id", adminOnly, s.csrfMiddleware(), s.updateUser)
+		admin.DELETE("/users/:id", adminOnly, s.csrfMiddleware(), s.deleteUser)
+
+		admin.DELETE("/users/:id/2fa", adminOnly, s.csrfMiddleware(), s.resetUserTOTP)
+
+		// Two-factor authentication of the current user
+		admin.GET("/2fa", s.twoFactorSettings)
+		admin.POST("/2fa/enable", s.csrfMiddleware(), s.enableTOTP)
+		admin.POST("/2fa/disable", s.csrfMiddleware(), s.disableTOTP)
+		admin.POST("/2fa/recovery-codes", s.csrfMiddleware(), s.regenerateRecoveryCodes)
+
+		// Sessions
+		admin.GET("/sessions", s.listSessions)
+		admin.POST("/sessions/logout-all", s.csrfMiddleware(), s.logoutOtherSessions)
+		admin.DELETE("/sessions/:key", s.csrfMiddleware(), s.revokeSession)
 	}
 }
 
@@ -112,63 +189,171 @@ func (s *Server) authMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		session, exists := s.sessions[cookie]
-		if !exists || time.Now().After(session.ExpiresAt) {
-			delete(s.sessions, cookie)
+		key := sessionKey(cookie)
+		now := time.Now()
+		session, err := s.sessions.Get(key)
+		if err != nil || session.Expired(now, s.cfg.Admin.SessionIdleTimeout()) {
+			s.sessions.Delete(key)
 			c.Redirect(http.StatusFound, "/admin/login")
 			c.Abort()
 			return
 		}
+		if session.MFAPending {
+			c.Redirect(http.StatusFound, "/admin/login/2fa")
+			c.Abort()
+			return
+		}
+
+		// Reload the account so role changes and disabling apply at once
+		user := s.sessionUser(session)
+		if user == nil {
+			s.sessions.Delete(key)
+			c.Redirect(http.StatusFound, "/admin/login")
+			c.Abort()
+			return
+		}
+		if now.Sub(session.LastSeen) > touchInterval {
+			s.sessions.Touch(key, now)
+		}
 
+		c.Set("user", user)
+		c.Set("session_key", key)
 		c.Set("username", session.Username)
 		c.Set("csrf_token", session.CSRFToken)
+
+		// With require_2fa, local users see nothing but the enrollment page
+		if s.mustEnroll(user) && !enrollmentPaths[c.Request.URL.Path] {
+			if c.GetHeader("HX-Request") != "" {
+				c.Header("HX-Redirect", "/admin/2fa")
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Redirect(http.StatusFound, "/admin/2fa")
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
 
 // Login handlers
 func (s *Server) loginPage(c *gin.Context) {
+    s.renderLogin(c, http.StatusOK, "")
+}
+
+// renderLogin shows the login page, with an error for full page flows such as SSO
+func (s *Server) renderLogin(c *gin.Context, status int, errMsg string) {
+    s.renderLoginStep(c, status, errMsg, false)
+}
+
+// renderLoginStep shows the password/SSO step or the second factor step
+func (s *Server) renderLoginStep(c *gin.Context, status int, errMsg string, twoFactor bool) {
+    label := s.cfg.Admin.OIDC.ButtonLabel
+    if label == "" {
+        label = s.tr(c, "Sign in with SSO")
+    }
     c.Header("Content-Type", "text/html; charset=utf-8")
-    s.tmpl.ExecuteTemplate(c.Writer, "login.html", gin.H{ "Lang": s.getLang(c) })
+    c.Status(status)
+    s.tmpl.ExecuteTemplate(c.Writer, "login.html", gin.H{
+        "Lang": s.getLang(c),
+        "Error": errMsg,
+        "OIDC": s.oidcEnabled(),
+        "OIDCLabel": label,
+        "PasswordLogin": s.passwordLoginEnabled(),
+        "TwoFactor": twoFactor,
+    })
 }
 
 func (s *Server) loginSubmit(c *gin.Context) {
 	username := c.PostForm("username")
 	password := c.PostForm("password")
 
-	// Validate credentials
-    if username != s.cfg.Admin.Username {
+    if !s.passwordLoginEnabled() {
         c.Header("HX-Retarget", "#error")
         c.Header("HX-Reswap", "innerHTML")
-        c.String(http.StatusUnauthorized, `<div class="error">`+s.tr(c, "Invalid username or password")+`</div>`)
+        c.String(http.StatusForbidden, `<div class="error">`+s.tr(c, "Password login is disabled, use single sign-on")+`</div>`)
         return
     }
 
-    if err := bcrypt.CompareHashAndPassword([]byte(s.cfg.Admin.PasswordHash), []byte(password)); err != nil {
+    // Throttled attempts are rejected before any bcrypt comparison
+    attempt := authguard.Attempt{Source: "web", IP: c.ClientIP(), Username: username}
+    if wait := s.guard.Check(attempt); wait > 0 {
+        c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+        c.Header("HX-Retarget", "#error")
+        c.Header("HX-Reswap", "innerHTML")
+        c.String(http.StatusTooManyRequests, `<div class="error">`+s.trf(c, "Too many failed attempts, try again in %d seconds", int(wait.Seconds())+1)+`</div>`)
+        return
+    }
+
+	// Validate credentials: users from the database first, then the config admin
+    var userID uint
+    secondFactor := s.cfg.Admin.TOTPSecret != ""
+    if u, err := db.AuthenticateUser(s.db, username, password); err == nil {
+        userID = u.ID
+        secondFactor = u.TOTPEnabled
+    } else if username == "" || username != s.cfg.Admin.Username ||
+        bcrypt.CompareHashAndPassword([]byte(s.cfg.Admin.PasswordHash), []byte(password)) != nil {
+        s.guard.Fail(attempt)
         c.Header("HX-Retarget", "#error")
         c.Header("HX-Reswap", "innerHTML")
         c.String(http.StatusUnauthorized, `<div class="error">`+s.tr(c, "Invalid username or password")+`</div>`)
         return
     }
 
-	// Create session with CSRF token
-	sessionID := s.generateSessionID()
-	csrfToken := s.generateSessionID()
-	s.sessions[sessionID] = &Session{
-		Username:  username,
-		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(24 * time.Hour),
-		CSRFToken: csrfToken,
+	if secondFactor {
+		// The password is right; the guard is reset once the code is too
+		if err := s.saveSession(c, userID, username, mfaLoginTTL, true); err != nil {
+			c.String(http.StatusInternalServerError, `<div class="error">`+s.tr(c, "Error creating session")+`</div>`)
+			return
+		}
+		c.Header("HX-Redirect", "/admin/login/2fa")
+		c.Status(http.StatusOK)
+		return
 	}
+	s.guard.Succeed(attempt)
 
-	s.setSecureCookie(c, "session", sessionID, 86400, "/admin")
+	if err := s.startSession(c, userID, username); err != nil {
+		c.String(http.StatusInternalServerError, `<div class="error">`+s.tr(c, "Error creating session")+`</div>`)
+		return
+	}
 	c.Header("HX-Redirect", "/admin")
 	c.Status(http.StatusOK)
 }
 
+// startSession creates a session with a CSRF token and sets its cookie
+func (s *Server) startSession(c *gin.Context, userID uint, username string) error {
+	return s.saveSession(c, userID, username, s.cfg.Admin.SessionMaxAge(), false)
+}
+
+// saveSession stores a new session under a fresh cookie. A pending session
+// only allows the second login step.
+func (s *Server) saveSession(c *gin.Context, userID uint, username string, maxAge time.Duration, pending bool) error {
+	sessionID := s.generateSessionID()
+	now := time.Now()
+	err := s.sessions.Save(&Session{
+		Key:       sessionKey(sessionID),
+		UserID:    userID,
+		Username:  username,
+		CSRFToken: s.generateSessionID(),
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		CreatedAt: now,
+		LastSeen:  now,
+		ExpiresAt: now.Add(maxAge),
+
+		MFAPending: pending,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.setSecureCookie(c, "session", sessionID, int(maxAge.Seconds()), "/admin")
+	return nil
+}
+
 func (s *Server) logout(c *gin.Context) {
 	cookie, _ := c.Cookie("session")
-	delete(s.sessions, cookie)
+	s.sessions.Delete(sessionKey(cookie))
 	s.setSecureCookie(c, "session", "", -1, "/admin")
 	c.Redirect(http.StatusFound, "/admin/login")
 }
@@ -176,14 +361,29 @@ func (s *Server) logout(c *gin.Context) {
 func (s *Server) dashboard(c *gin.Context) {
     username, _ := c.Get("username")
     csrfToken, _ := c.Get("csrf_token")
+    user := currentUser(c)
     c.Header("Content-Type", "text/html; charset=utf-8")
     s.tmpl.ExecuteTemplate(c.Writer, "dashboard.html", gin.H{
         "Username": username,
+        "Role": user.Role,
+        "CanEdit": user.HasRole(db.RoleEditor),
+        "IsAdmin": user.HasRole(db.RoleAdmin),
         "Lang": s.getLang(c),
         "CSRFToken": csrfToken,
     })
 }
 
+// setParam overrides a route parameter before handing over to another handler
+func setParam(c *gin.Context, key, value string) {
+	for i := range c.Params {
+		if c.Params[i].Key == key {
+			c.Params[i].Value = value
+			return
+		}
+	}
+	c.Params = append(c.Params, gin.Param{Key: key, Value: value})
+}
+
 func (s *Server) generateSessionID() string {
 	b := make([]byte, 32)
 	rand.Read(b)
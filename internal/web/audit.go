@@ -0,0 +1,166 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"namedot/internal/db"
+	"namedot/internal/idn"
+)
+
+// auditPerPage is the page size of the zone history
+const auditPerPage = 30
+
+// audit records a change made by the current user. A failure is logged and
+// does not fail the request.
+func (s *Server) audit(c *gin.Context, action string, zoneID uint, before, after any) {
+	var zone db.Zone
+	if zoneID != 0 {
+		// Deleted zones keep their name in the log
+		s.db.Unscoped().Select("id", "name").Limit(1).Find(&zone, zoneID)
+	}
+	actor := db.Actor{Name: "user:" + currentUser(c).Username, IP: c.ClientIP()}
+	if err := db.Audit(s.db, actor, action, zone, before, after); err != nil {
+		log.Printf("AUDIT %s on %s failed: %v", action, zone.Name, err)
+	}
+}
+
+// templateSnapshot loads a template with its records for the audit log
+func (s *Server) templateSnapshot(id uint) *db.Template {
+	var t db.Template
+	if err := s.db.Preload("Records").First(&t, id).Error; err != nil {
+		return nil
+	}
+	return &t
+}
+
+// auditObject names the RRSet or template of an entry from its snapshots
+func auditObject(e db.AuditLog) string {
+	var obj struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	}
+	for _, snap := range []string{e.After, e.Before} {
+		if json.Unmarshal([]byte(snap), &obj) == nil && obj.Name != "" {
+			if obj.Type == "" {
+				return obj.Name
+			}
+			return obj.Name + " " + obj.Type
+		}
+	}
+	return e.ZoneName
+}
+
+// prettySnapshot indents a JSON snapshot for display
+func prettySnapshot(snap string) string {
+	var v any
+	if json.Unmarshal([]byte(snap), &v) != nil {
+		return snap
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return snap
+	}
+	return string(b)
+}
+
+// zoneAudit shows the change history of a zone
+func (s *Server) zoneAudit(c *gin.Context) {
+	zoneID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, s.tr(c, "Invalid zone ID"))
+		return
+	}
+	if !s.authorizeZone(c, uint(zoneID), db.RoleViewer) {
+		return
+	}
+	var zone db.Zone
+	if err := s.db.First(&zone, zoneID).Error; err != nil {
+		c.String(http.StatusNotFound, s.tr(c, "Zone not found"))
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	entries, total, err := db.ListAudit(s.db, db.AuditFilter{
+		ZoneID: zone.ID,
+		Action: c.Query("action"),
+		Limit:  auditPerPage,
+		Offset: (page - 1) * auditPerPage,
+	})
+	if err != nil {
+		c.String(http.StatusInternalServerError, s.tr(c, "Error loading history"))
+		return
+	}
+
+	out := fmt.Sprintf(`
+	<div style="margin-bottom: 1rem;">
+		<button class="btn" style="background: #718096;" hx-get="/admin/zones/%d/records" hx-target="#zones-list" hx-swap="innerHTML">
+			%s
+		</button>
+		<h2 style="margin-top: 1rem;">%s</h2>
+	</div>`, zone.ID, s.tr(c, "← Back to Records"), html.EscapeString(s.trf(c, "History of %s", idn.ToUnicode(zone.Name))))
+
+	if len(entries) == 0 {
+		out += `<div class="empty-state">` + s.tr(c, "No changes recorded yet") + `</div>`
+	} else {
+		out += `<table>
+        <thead>
+            <tr>
+                <th>` + s.tr(c, "Time") + `</th>
+                <th>` + s.tr(c, "Actor") + `</th>
+                <th>` + s.tr(c, "IP Address") + `</th>
+                <th>` + s.tr(c, "Action") + `</th>
+                <th>` + s.tr(c, "Object") + `</th>
+                <th>` + s.tr(c, "Changes") + `</th>
+            </tr>
+        </thead>
+        <tbody>`
+		for _, e := range entries {
+			changes := ""
+			if e.Before != "" {
+				changes += `<strong>` + s.tr(c, "Before") + `</strong><pre style="font-size: 0.75rem; white-space: pre-wrap;">` + html.EscapeString(prettySnapshot(e.Before)) + `</pre>`
+			}
+			if e.After != "" {
+				changes += `<strong>` + s.tr(c, "After") + `</strong><pre style="font-size: 0.75rem; white-space: pre-wrap;">` + html.EscapeString(prettySnapshot(e.After)) + `</pre>`
+			}
+			if changes != "" {
+				changes = `<details><summary>` + s.tr(c, "Show") + `</summary>` + changes + `</details>`
+			}
+			out += fmt.Sprintf(`
+            <tr>
+                <td>%s</td>
+                <td>%s</td>
+                <td>%s</td>
+                <td><code>%s</code></td>
+                <td><strong>%s</strong></td>
+                <td>%s</td>
+            </tr>`, e.CreatedAt.Format("2006-01-02 15:04:05"), html.EscapeString(e.Actor), html.EscapeString(e.IP),
+				html.EscapeString(e.Action), html.EscapeString(auditObject(e)), changes)
+		}
+		out += `</tbody></table>`
+	}
+
+	if totalPages := int((total + auditPerPage - 1) / auditPerPage); totalPages > 1 {
+		out += `<div style="display: flex; justify-content: center; gap: 0.5rem; margin-top: 1rem;">`
+		if page > 1 {
+			out += fmt.Sprintf(`<button class="btn btn-sm" hx-get="/admin/zones/%d/audit?page=%d" hx-target="#zones-list" hx-swap="innerHTML">« `+s.tr(c, "Prev")+`</button>`, zone.ID, page-1)
+		}
+		if page < totalPages {
+			out += fmt.Sprintf(`<button class="btn btn-sm" hx-get="/admin/zones/%d/audit?page=%d" hx-target="#zones-list" hx-swap="innerHTML">`+s.tr(c, "Next")+` »</button>`, zone.ID, page+1)
+		}
+		out += `</div>`
+		out += fmt.Sprintf(`<div style="text-align: center; margin-top: 0.5rem; color: #718096; font-size: 0.875rem;">`+s.tr(c, "Page %d of %d")+` (%d `+s.tr(c, "total")+`)</div>`, page, totalPages, total)
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.String(http.StatusOK, out)
+}
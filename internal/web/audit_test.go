@@ -0,0 +1,41 @@
+package web
+
+import (
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+    "testing"
+
+    dbm "namedot/internal/db"
+)
+
+func TestAudit_ZoneHistory(t *testing.T) {
+    s, r := newTestWeb(t)
+    editor, sid := loginAs(t, s, "audit-editor", dbm.RoleEditor)
+    _, otherSid := loginAs(t, s, "audit-outsider", dbm.RoleEditor)
+    zone := dbm.Zone{Name: "audit-history.test."}
+    s.db.Create(&zone)
+    // The database is shared; later tests expect an empty zone list
+    t.Cleanup(func() { s.db.Unscoped().Delete(&zone) })
+    if err := dbm.GrantZone(s.db, editor.ID, zone.ID); err != nil { t.Fatalf("grant: %v", err) }
+
+    form := url.Values{"name": {"www"}, "type": {"A"}, "ttl": {"300"}, "data": {"192.0.2.10"}}
+    if w := postRecordForm(r, sid, fmt.Sprintf("/admin/zones/%d/records", zone.ID), form); w.Code != http.StatusOK {
+        t.Fatalf("create record: %d %s", w.Code, w.Body.String())
+    }
+
+    entries, _, _ := dbm.ListAudit(s.db, dbm.AuditFilter{ZoneID: zone.ID})
+    if len(entries) != 1 || entries[0].Action != dbm.AuditRRSetCreate || entries[0].Actor != "user:audit-editor" || entries[0].IP == "" {
+        t.Fatalf("unexpected audit entries %+v", entries)
+    }
+
+    path := fmt.Sprintf("/admin/zones/%d/audit", zone.ID)
+    w := getAs(r, sid, path)
+    if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "www.audit-history.test. A") || !strings.Contains(w.Body.String(), "user:audit-editor") {
+        t.Fatalf("history page: %d %s", w.Code, w.Body.String())
+    }
+    if w := getAs(r, otherSid, path); w.Code != http.StatusForbidden {
+        t.Fatalf("history of a foreign zone: want 403, got %d", w.Code)
+    }
+}
@@ -0,0 +1,353 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"namedot/internal/db"
+	"namedot/internal/idn"
+	"namedot/internal/validate"
+)
+
+// changesetActor names the current user like the audit log does
+func changesetActor(c *gin.Context) string {
+	return "user:" + currentUser(c).Username
+}
+
+// formatDraftRecord renders a record as one line of the draft editor:
+// the data, then " | " and the geo selectors if any
+func formatDraftRecord(r db.RData) string {
+	var geo []string
+	if r.Country != nil && *r.Country != "" {
+		geo = append(geo, "country="+*r.Country)
+	}
+	if r.Continent != nil && *r.Continent != "" {
+		geo = append(geo, "continent="+*r.Continent)
+	}
+	if r.ASN != nil && *r.ASN != 0 {
+		geo = append(geo, "asn="+strconv.Itoa(*r.ASN))
+	}
+	if r.Subnet != nil && *r.Subnet != "" {
+		geo = append(geo, "subnet="+*r.Subnet)
+	}
+	if len(geo) == 0 {
+		return r.Data
+	}
+	return r.Data + " | " + strings.Join(geo, " ")
+}
+
+// parseDraftRecord reads a line written by formatDraftRecord. A suffix that
+// is not a list of geo selectors stays part of the data.
+func parseDraftRecord(line string) db.RData {
+	r := db.RData{Data: line}
+	i := strings.LastIndex(line, " | ")
+	if i < 0 {
+		return r
+	}
+	geo := db.RData{Data: strings.TrimSpace(line[:i])}
+	for _, field := range strings.Fields(line[i+3:]) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || value == "" {
+			return r
+		}
+		switch key {
+		case "country":
+			geo.Country = stringPtr(strings.ToUpper(value))
+		case "continent":
+			geo.Continent = stringPtr(strings.ToUpper(value))
+		case "asn":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return r
+			}
+			geo.ASN = intPtr(n)
+		case "subnet":
+			geo.Subnet = stringPtr(value)
+		default:
+			return r
+		}
+	}
+	return geo
+}
+
+// zoneChangeset shows the open changeset of a zone
+func (s *Server) zoneChangeset(c *gin.Context) {
+	zone, ok := s.authorizedZone(c, db.RoleViewer)
+	if !ok {
+		return
+	}
+	s.renderChangeset(c, zone, "")
+}
+
+// renderChangeset renders the changeset page with an optional error banner
+func (s *Server) renderChangeset(c *gin.Context, zone db.Zone, errMsg string) {
+	out := fmt.Sprintf(`
+	<div style="margin-bottom: 1rem;">
+		<button class="btn" style="background: #718096;" hx-get="/admin/zones/%d/records" hx-target="#zones-list" hx-swap="innerHTML">
+			%s
+		</button>
+		<h2 style="margin-top: 1rem;">%s</h2>
+	</div>`, zone.ID, s.tr(c, "← Back to Records"), html.EscapeString(s.trf(c, "Changeset for %s", idn.ToUnicode(zone.Name))))
+	if errMsg != "" {
+		out += `<div class="error">` + html.EscapeString(errMsg) + `</div>`
+	}
+	canEdit := currentUser(c).HasRole(db.RoleEditor)
+	base := fmt.Sprintf("/admin/zones/%d/changeset", zone.ID)
+
+	cs, err := db.OpenChangeset(s.db, zone.ID)
+	if errors.Is(err, db.ErrNoChangeset) {
+		out += `<div class="empty-state">` + s.tr(c, "No open changeset. Edits made here are staged and go live together when published.") + `</div>`
+		if canEdit {
+			out += fmt.Sprintf(`<button class="btn" hx-post="%s" hx-target="#zones-list" hx-swap="innerHTML">%s</button>`, base, s.tr(c, "Start Changeset"))
+		}
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.String(http.StatusOK, out)
+		return
+	}
+	sets, err2 := cs.RRSets()
+	changes, err3 := cs.Changes()
+	if err != nil || err2 != nil || err3 != nil {
+		c.String(http.StatusInternalServerError, s.tr(c, "Error loading changeset"))
+		return
+	}
+	conflicts, _ := cs.Conflicts(db.ZoneSnapshot(s.db, zone.ID))
+
+	status := s.trf(c, "Started by %s", cs.CreatedBy)
+	if cs.ApprovedBy != "" {
+		status += " · " + s.trf(c, "Approved by %s", cs.ApprovedBy)
+	} else if s.cfg.ChangesetApproval {
+		status += " · " + s.tr(c, "Needs approval by a second user")
+	}
+	out += `<p style="color: #718096; margin-bottom: 1rem;">` + html.EscapeString(status) + `</p>`
+	if len(conflicts) > 0 {
+		out += `<div class="error">` + html.EscapeString(s.trf(c, "Changed live since the changeset was started: %s", strings.Join(conflicts, ", "))) + `</div>`
+	}
+
+	out += `<h3>` + s.tr(c, "Pending Changes") + `</h3>` + s.diffTable(c, changes)
+
+	if canEdit {
+		out += `<div style="margin-bottom: 1rem; display: flex; gap: 0.5rem;">`
+		if s.cfg.ChangesetApproval && cs.ApprovedBy == "" {
+			out += fmt.Sprintf(`<button class="btn" style="background: #48bb78;" hx-post="%s/approve" hx-target="#zones-list" hx-swap="innerHTML">%s</button>`, base, s.tr(c, "Approve"))
+		}
+		out += fmt.Sprintf(`<button class="btn" hx-post="%s/publish" hx-confirm="%s" hx-target="#zones-list" hx-swap="innerHTML">%s</button>`,
+			base, html.EscapeString(s.trf(c, "Publish %d changes to %s?", len(changes), idn.ToUnicode(zone.Name))), s.tr(c, "Publish"))
+		out += fmt.Sprintf(`<button class="btn btn-danger" hx-delete="%s" hx-confirm="%s" hx-target="#zones-list" hx-swap="innerHTML">%s</button>`,
+			base, s.tr(c, "Discard this changeset?"), s.tr(c, "Discard"))
+		out += `</div>`
+
+		out += fmt.Sprintf(`
+	<div style="background: #f7fafc; padding: 1rem; border-radius: 4px; margin-bottom: 1rem;">
+		<h3>%s</h3>
+		<form hx-post="%s/rrsets" hx-target="#zones-list" hx-swap="innerHTML" style="display: grid; grid-template-columns: 2fr 1fr 1fr; gap: 0.5rem; margin-top: 0.5rem;">
+			<input type="text" name="name" placeholder="www" required style="padding: 0.5rem; border: 1px solid #cbd5e0; border-radius: 4px;">
+			<input type="text" name="type" placeholder="A" required style="padding: 0.5rem; border: 1px solid #cbd5e0; border-radius: 4px;">
+			<input type="number" name="ttl" placeholder="300" style="padding: 0.5rem; border: 1px solid #cbd5e0; border-radius: 4px;">
+			<textarea name="records" rows="3" placeholder="%s" style="grid-column: 1 / -1; padding: 0.5rem; border: 1px solid #cbd5e0; border-radius: 4px; font-family: monospace;"></textarea>
+			<button type="submit" class="btn" style="grid-column: 1 / -1;">%s</button>
+		</form>
+	</div>`, s.tr(c, "Set Record Set"), base, html.EscapeString(s.tr(c, "One record per line, e.g. 192.0.2.1 | country=US. Leave empty to remove the record set.")), s.tr(c, "Save to Changeset"))
+	}
+
+	out += `<h3>` + s.tr(c, "Draft Records") + `</h3><table>
+        <thead>
+            <tr>
+                <th>` + s.tr(c, "Name") + `</th>
+                <th>` + s.tr(c, "Type") + `</th>
+                <th>` + s.tr(c, "TTL") + `</th>
+                <th>` + s.tr(c, "Data") + `</th>
+                <th>` + s.tr(c, "Actions") + `</th>
+            </tr>
+        </thead>
+        <tbody>`
+	for _, rs := range sets {
+		lines := make([]string, 0, len(rs.Records))
+		for _, r := range rs.Records {
+			lines = append(lines, html.EscapeString(formatDraftRecord(r)))
+		}
+		actions := ""
+		if canEdit {
+			actions = fmt.Sprintf(`<button class="btn btn-sm btn-danger" hx-delete="%s/rrsets?name=%s&type=%s" hx-target="#zones-list" hx-swap="innerHTML">%s</button>`,
+				base, html.EscapeString(rs.Name), html.EscapeString(rs.Type), s.tr(c, "Remove"))
+		}
+		out += fmt.Sprintf(`
+            <tr>
+                <td><strong>%s</strong></td>
+                <td>%s</td>
+                <td>%d</td>
+                <td><code>%s</code></td>
+                <td class="actions">%s</td>
+            </tr>`, displayName(rs.Name), html.EscapeString(rs.Type), rs.TTL, strings.Join(lines, "<br>"), actions)
+	}
+	out += `</tbody></table>`
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.String(http.StatusOK, out)
+}
+
+// startChangeset opens a changeset on the live state of a zone
+func (s *Server) startChangeset(c *gin.Context) {
+	zone, ok := s.authorizedZone(c, db.RoleEditor)
+	if !ok {
+		return
+	}
+	if _, err := db.StartChangeset(s.db, zone.ID, changesetActor(c)); err != nil && !errors.Is(err, db.ErrChangesetExists) {
+		c.String(http.StatusInternalServerError, s.trf(c, "Error saving changeset: %s", err.Error()))
+		return
+	}
+	s.renderChangeset(c, zone, "")
+}
+
+// openChangeset loads the open changeset, rendering the page with an error if none
+func (s *Server) openChangeset(c *gin.Context, zone db.Zone) (db.Changeset, bool) {
+	cs, err := db.OpenChangeset(s.db, zone.ID)
+	if err != nil {
+		s.renderChangeset(c, zone, s.tr(c, "No open changeset"))
+		return cs, false
+	}
+	return cs, true
+}
+
+// setChangesetRRSet sets or removes an RRSet in the draft
+func (s *Server) setChangesetRRSet(c *gin.Context) {
+	zone, ok := s.authorizedZone(c, db.RoleEditor)
+	if !ok {
+		return
+	}
+	cs, ok := s.openChangeset(c, zone)
+	if !ok {
+		return
+	}
+	set := db.RRSet{
+		Name: toFQDN(c.PostForm("name"), zone.Name),
+		Type: strings.ToUpper(strings.TrimSpace(c.PostForm("type"))),
+	}
+	if set.Type == "" {
+		s.renderChangeset(c, zone, s.tr(c, "Name, type, and data are required"))
+		return
+	}
+	ttl, _ := strconv.Atoi(c.PostForm("ttl"))
+	if ttl <= 0 {
+		ttl = 300
+	}
+	set.TTL = uint32(ttl)
+	for _, line := range strings.Split(c.PostForm("records"), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		set.Records = append(set.Records, parseDraftRecord(line))
+	}
+	if len(set.Records) > 0 {
+		// Validated against the draft, where the other RRSets of the name live
+		draft, err := cs.RRSets()
+		if err != nil {
+			c.String(http.StatusInternalServerError, s.trf(c, "Error saving changeset: %s", err.Error()))
+			return
+		}
+		errs := validate.RRSet(&set, zone.Name)
+		if len(errs) == 0 {
+			errs = validate.Coexistence(set, draft)
+		}
+		if len(errs) > 0 {
+			s.renderChangeset(c, zone, s.trf(c, "Invalid record: %s", errs.Error()))
+			return
+		}
+	}
+	if err := db.PutDraftRRSet(s.db, &cs, set, changesetActor(c)); err != nil {
+		c.String(http.StatusInternalServerError, s.trf(c, "Error saving changeset: %s", err.Error()))
+		return
+	}
+	s.renderChangeset(c, zone, "")
+}
+
+// removeChangesetRRSet removes an RRSet from the draft
+func (s *Server) removeChangesetRRSet(c *gin.Context) {
+	zone, ok := s.authorizedZone(c, db.RoleEditor)
+	if !ok {
+		return
+	}
+	cs, ok := s.openChangeset(c, zone)
+	if !ok {
+		return
+	}
+	set := db.RRSet{Name: c.Query("name"), Type: c.Query("type")}
+	if err := db.PutDraftRRSet(s.db, &cs, set, changesetActor(c)); err != nil {
+		c.String(http.StatusInternalServerError, s.trf(c, "Error saving changeset: %s", err.Error()))
+		return
+	}
+	s.renderChangeset(c, zone, "")
+}
+
+// approveChangeset approves the changeset of another user
+func (s *Server) approveChangeset(c *gin.Context) {
+	zone, ok := s.authorizedZone(c, db.RoleEditor)
+	if !ok {
+		return
+	}
+	cs, ok := s.openChangeset(c, zone)
+	if !ok {
+		return
+	}
+	if err := db.ApproveChangeset(s.db, &cs, changesetActor(c)); errors.Is(err, db.ErrSelfApproval) {
+		s.renderChangeset(c, zone, s.tr(c, "You cannot approve your own changes"))
+		return
+	} else if err != nil {
+		c.String(http.StatusInternalServerError, s.trf(c, "Error saving changeset: %s", err.Error()))
+		return
+	}
+	s.renderChangeset(c, zone, "")
+}
+
+// publishChangeset applies the changeset and shows the live records
+func (s *Server) publishChangeset(c *gin.Context) {
+	zone, ok := s.authorizedZone(c, db.RoleEditor)
+	if !ok {
+		return
+	}
+	cs, ok := s.openChangeset(c, zone)
+	if !ok {
+		return
+	}
+	actor := db.Actor{Name: changesetActor(c), IP: c.ClientIP()}
+	err := db.PublishChangeset(s.db, &cs, zone, actor, s.cfg.ChangesetApproval, s.serials())
+	var conflict *db.ConflictError
+	switch {
+	case errors.As(err, &conflict):
+		s.renderChangeset(c, zone, s.trf(c, "Changed live since the changeset was started: %s", strings.Join(conflict.Keys, ", ")))
+		return
+	case errors.Is(err, db.ErrApprovalRequired):
+		s.renderChangeset(c, zone, s.tr(c, "Needs approval by a second user"))
+		return
+	case errors.Is(err, db.ErrChangesetUnchanged):
+		s.renderChangeset(c, zone, s.tr(c, "No differences"))
+		return
+	case err != nil:
+		c.String(http.StatusInternalServerError, s.trf(c, "Error saving changeset: %s", err.Error()))
+		return
+	}
+	s.invalidateCache()
+	s.listRecords(c)
+}
+
+// discardChangeset drops the changeset
+func (s *Server) discardChangeset(c *gin.Context) {
+	zone, ok := s.authorizedZone(c, db.RoleEditor)
+	if !ok {
+		return
+	}
+	cs, ok := s.openChangeset(c, zone)
+	if !ok {
+		return
+	}
+	if err := db.DiscardChangeset(s.db, &cs); err != nil {
+		c.String(http.StatusInternalServerError, s.trf(c, "Error saving changeset: %s", err.Error()))
+		return
+	}
+	s.renderChangeset(c, zone, "")
+}
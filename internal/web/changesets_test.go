@@ -0,0 +1,49 @@
+package web
+
+import (
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+    "testing"
+
+    dbm "namedot/internal/db"
+)
+
+func TestChangesets_StageAndPublish(t *testing.T) {
+    s, r := newTestWeb(t)
+    editor, sid := loginAs(t, s, "changeset-editor", dbm.RoleEditor)
+    zone := dbm.Zone{Name: "changeset.test."}
+    s.db.Create(&zone)
+    // The database is shared; later tests expect an empty zone list
+    t.Cleanup(func() { s.db.Unscoped().Delete(&zone) })
+    if err := dbm.GrantZone(s.db, editor.ID, zone.ID); err != nil { t.Fatalf("grant: %v", err) }
+
+    base := fmt.Sprintf("/admin/zones/%d/changeset", zone.ID)
+    if w := sendAs(r, "POST", sid, base, nil); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "changeset-editor") {
+        t.Fatalf("start: %d %s", w.Code, w.Body.String())
+    }
+    form := url.Values{"name": {"geo"}, "type": {"A"}, "ttl": {"60"}, "records": {"192.0.2.1 | country=us\n192.0.2.2"}}
+    w := sendAs(r, "POST", sid, base+"/rrsets", form)
+    if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "192.0.2.1 | country=US") {
+        t.Fatalf("stage: %d %s", w.Code, w.Body.String())
+    }
+    if n := len(dbm.ZoneSnapshot(s.db, zone.ID)); n != 0 { t.Fatalf("staged records must not be live, got %d RRSets", n) }
+
+    w = sendAs(r, "POST", sid, base+"/publish", nil)
+    if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "192.0.2.2") {
+        t.Fatalf("publish: %d %s", w.Code, w.Body.String())
+    }
+    live := dbm.ZoneSnapshot(s.db, zone.ID)
+    if len(live) != 1 || len(live[0].Records) != 2 || live[0].Records[0].Country == nil || *live[0].Records[0].Country != "US" {
+        t.Fatalf("unexpected live zone %+v", live)
+    }
+}
+
+func TestParseDraftRecord_KeepsPipesInData(t *testing.T) {
+    if r := parseDraftRecord(`"a | b"`); r.Data != `"a | b"` { t.Fatalf("got %+v", r) }
+    r := parseDraftRecord("192.0.2.1 | asn=65001 subnet=10.0.0.0/8")
+    if r.Data != "192.0.2.1" || *r.ASN != 65001 || *r.Subnet != "10.0.0.0/8" || formatDraftRecord(r) != "192.0.2.1 | asn=65001 subnet=10.0.0.0/8" {
+        t.Fatalf("round trip failed: %+v", r)
+    }
+}
@@ -126,6 +126,218 @@ var translations = map[string]map[string]string{
         "Data is required": "Data is required",
         "Error updating record: %s": "Error updating record: %s",
         "Error updating TTL: %s": "Error updating TTL: %s",
+
+        // Views
+        "DNS View": "View",
+        "All views (default)": "All views (default)",
+        "All Views": "All Views",
+        "Default view": "Default view",
+        "Unknown view %s": "Unknown view %s",
+
+
+        // Typed record editor
+        "Flags": "Flags",
+        "Tag": "Tag",
+        "Value": "Value",
+        "Usage": "Usage",
+        "Selector": "Selector",
+        "Matching Type": "Matching Type",
+        "Certificate Data (hex)": "Certificate Data (hex)",
+        "Algorithm": "Algorithm",
+        "Fingerprint Type": "Fingerprint Type",
+        "Fingerprint (hex)": "Fingerprint (hex)",
+        "Priority": "Priority",
+        "Target": "Target",
+        "ALPN": "ALPN",
+        "Port": "Port",
+        "IPv4 Hints": "IPv4 Hints",
+        "IPv6 Hints": "IPv6 Hints",
+        "Other Parameters": "Other Parameters",
+
+        // Users
+        "Users": "Users",
+        "+ New User": "+ New User",
+        "Role": "Role",
+        "Zones": "Zones",
+        "Status": "Status",
+        "viewer": "viewer",
+        "editor": "editor",
+        "admin": "admin",
+        "All zones": "All zones",
+        "Config file": "Config file",
+        "Active": "Active",
+        "Disabled": "Disabled",
+        "Save": "Save",
+        "Delete user %s?": "Delete user %s?",
+        "Create New User": "Create New User",
+        "Edit User %s": "Edit User %s",
+        "New Password": "New Password",
+        "Leave empty to keep": "Leave empty to keep",
+        "Owned Zones": "Owned Zones",
+        "Admins can access all zones": "Admins can access all zones",
+        "Error loading users": "Error loading users",
+        "Error creating user: %s": "Error creating user: %s",
+        "Error updating user: %s": "Error updating user: %s",
+        "Error deleting user": "Error deleting user",
+        "Invalid user ID": "Invalid user ID",
+        "User not found": "User not found",
+        "Username is taken by the config file admin": "Username is taken by the config file admin",
+        "You cannot demote or disable yourself": "You cannot demote or disable yourself",
+        "You cannot delete yourself": "You cannot delete yourself",
+        "You do not have permission for this action": "You do not have permission for this action",
+
+        // Single sign-on
+        "Sign in with SSO": "Sign in with SSO",
+        "or": "or",
+        "Continue": "Continue",
+        "Single sign-on provider is unavailable": "Single sign-on provider is unavailable",
+        "Single sign-on failed: %s": "Single sign-on failed: %s",
+        "Login session expired, please try again": "Login session expired, please try again",
+        "Your account has no access to this panel": "Your account has no access to this panel",
+        "Password login is disabled, use single sign-on": "Password login is disabled, use single sign-on",
+
+        // Sessions
+        "Sessions": "Sessions",
+        "Active Sessions": "Active Sessions",
+        "Log out other sessions": "Log out other sessions",
+        "Log out all other sessions?": "Log out all other sessions?",
+        "IP Address": "IP Address",
+        "Browser": "Browser",
+        "Signed in": "Signed in",
+        "Last activity": "Last activity",
+        "This session": "This session",
+        "Revoke": "Revoke",
+        "Revoke this session?": "Revoke this session?",
+        "Session not found": "Session not found",
+        "Error loading sessions": "Error loading sessions",
+        "Error revoking session": "Error revoking session",
+        "Error creating session": "Error creating session",
+
+        // Login protection
+        "Too many failed attempts, try again in %d seconds": "Too many failed attempts, try again in %d seconds",
+
+        // Two-factor authentication
+        "2FA": "2FA",
+        "Dashboard": "Dashboard",
+        "Two-factor authentication": "Two-factor authentication",
+        "Enter the code from your authenticator app or a recovery code.": "Enter the code from your authenticator app or a recovery code.",
+        "Authentication code": "Authentication code",
+        "Verify": "Verify",
+        "Invalid authentication code": "Invalid authentication code",
+        "Reset 2FA": "Reset 2FA",
+        "Reset two-factor authentication of %s?": "Reset two-factor authentication of %s?",
+        "Two-factor authentication reset": "Two-factor authentication reset",
+        "Error starting two-factor enrollment": "Error starting two-factor enrollment",
+        "Two-factor authentication is required for all users": "Two-factor authentication is required for all users",
+        "Two-factor authentication disabled": "Two-factor authentication disabled",
+        "Two-factor authentication is enabled.": "Two-factor authentication is enabled.",
+        "%d unused recovery codes left.": "%d unused recovery codes left.",
+        "Save these recovery codes in a safe place. Each code can be used once instead of an authentication code. They will not be shown again.": "Save these recovery codes in a safe place. Each code can be used once instead of an authentication code. They will not be shown again.",
+        "Two-factor authentication of this account is configured with admin.totp_secret in the config file.": "Two-factor authentication of this account is configured with admin.totp_secret in the config file.",
+        "Set admin.totp_secret in the config file to enable two-factor authentication for this account.": "Set admin.totp_secret in the config file to enable two-factor authentication for this account.",
+        "This account signs in through single sign-on. Two-factor authentication is managed by the identity provider.": "This account signs in through single sign-on. Two-factor authentication is managed by the identity provider.",
+        "Generate new recovery codes": "Generate new recovery codes",
+        "Generate": "Generate",
+        "Disable two-factor authentication": "Disable two-factor authentication",
+        "Disable": "Disable",
+        "Add the key to an authenticator app, by opening the otpauth link on the device or typing the key, then confirm with the code it shows.": "Add the key to an authenticator app, by opening the otpauth link on the device or typing the key, then confirm with the code it shows.",
+        "Enable": "Enable",
+
+        // Audit log
+        "History": "History",
+        "← Back to Records": "← Back to Records",
+        "History of %s": "History of %s",
+        "No changes recorded yet": "No changes recorded yet",
+        "Error loading history": "Error loading history",
+        "Time": "Time",
+        "Actor": "Actor",
+        "Action": "Action",
+        "Object": "Object",
+        "Changes": "Changes",
+        "Before": "Before",
+        "After": "After",
+        "Show": "Show",
+
+        // Zone versions
+        "Versions": "Versions",
+        "Versions of %s": "Versions of %s",
+        "No versions recorded yet": "No versions recorded yet",
+        "Error loading versions": "Error loading versions",
+        "Current": "Current",
+        "From": "From",
+        "To": "To",
+        "Compare": "Compare",
+        "Version": "Version",
+        "Serial": "Serial",
+        "Note": "Note",
+        "Diff with current": "Diff with current",
+        "Restore version #%d of %s?": "Restore version #%d of %s?",
+        "Rollback": "Rollback",
+        "Version not found": "Version not found",
+        "Error restoring version: %s": "Error restoring version: %s",
+        "No differences": "No differences",
+        "Change": "Change",
+        "added": "added",
+        "removed": "removed",
+        "changed": "changed",
+
+        // Changesets
+        "Changeset": "Changeset",
+        "open": "open",
+        "Changeset for %s": "Changeset for %s",
+        "No open changeset. Edits made here are staged and go live together when published.": "No open changeset. Edits made here are staged and go live together when published.",
+        "Start Changeset": "Start Changeset",
+        "Error loading changeset": "Error loading changeset",
+        "Error saving changeset: %s": "Error saving changeset: %s",
+        "Started by %s": "Started by %s",
+        "Approved by %s": "Approved by %s",
+        "Needs approval by a second user": "Needs approval by a second user",
+        "Changed live since the changeset was started: %s": "Changed live since the changeset was started: %s",
+        "Pending Changes": "Pending Changes",
+        "Approve": "Approve",
+        "Publish": "Publish",
+        "Publish %d changes to %s?": "Publish %d changes to %s?",
+        "Discard": "Discard",
+        "Discard this changeset?": "Discard this changeset?",
+        "Set Record Set": "Set Record Set",
+        "One record per line, e.g. 192.0.2.1 | country=US. Leave empty to remove the record set.": "One record per line, e.g. 192.0.2.1 | country=US. Leave empty to remove the record set.",
+        "Save to Changeset": "Save to Changeset",
+        "Draft Records": "Draft Records",
+        "Remove": "Remove",
+        "No open changeset": "No open changeset",
+        "You cannot approve your own changes": "You cannot approve your own changes",
+
+        // Validation
+        "Invalid record: %s": "Invalid record: %s",
+        "Invalid zone name: %s": "Invalid zone name: %s",
+
+        // Zone settings
+        "Edit Zone: %s": "Edit Zone: %s",
+        "Kind": "Kind",
+        "Owner": "Owner",
+        "Tags": "Tags",
+        "Default TTL (seconds)": "Default TTL (seconds)",
+        "SOA defaults (used when the SOA is created automatically)": "SOA defaults (used when the SOA is created automatically)",
+        "Primary name server": "Primary name server",
+        "Hostmaster": "Hostmaster",
+        "Refresh": "Refresh",
+        "Retry": "Retry",
+        "Expire": "Expire",
+        "Minimum TTL": "Minimum TTL",
+        "SOA TTL": "SOA TTL",
+        "Disabled (kept but not served)": "Disabled (kept but not served)",
+        "disabled": "disabled",
+        "zone kind primary": "zone kind primary",
+        "zone kind secondary": "zone kind secondary",
+        "zone kind forward": "zone kind forward",
+        "Invalid zone settings: %s": "Invalid zone settings: %s",
+        "Error updating zone: %s": "Error updating zone: %s",
+        "Serial policy": "Serial policy",
+        "Keep a PTR in the hosted reverse zone (A/AAAA)": "Keep a PTR in the hosted reverse zone (A/AAAA)",
+        "serial policy ": "Server default",
+        "serial policy increment": "Increment (+1)",
+        "serial policy date": "Date (YYYYMMDDnn)",
+        "serial policy epoch": "Unix time",
     },
     "ru": {
         // General
@@ -250,6 +462,218 @@ var translations = map[string]map[string]string{
         "Data is required": "Требуются данные",
         "Error updating record: %s": "Ошибка обновления записи: %s",
         "Error updating TTL: %s": "Ошибка обновления TTL: %s",
+
+        // Views
+        "DNS View": "Представление",
+        "All views (default)": "Все представления (по умолчанию)",
+        "All Views": "Все представления",
+        "Default view": "Представление по умолчанию",
+        "Unknown view %s": "Неизвестное представление %s",
+
+
+        // Typed record editor
+        "Flags": "Флаги",
+        "Tag": "Тег",
+        "Value": "Значение",
+        "Usage": "Использование",
+        "Selector": "Селектор",
+        "Matching Type": "Тип сопоставления",
+        "Certificate Data (hex)": "Данные сертификата (hex)",
+        "Algorithm": "Алгоритм",
+        "Fingerprint Type": "Тип отпечатка",
+        "Fingerprint (hex)": "Отпечаток (hex)",
+        "Priority": "Приоритет",
+        "Target": "Цель",
+        "ALPN": "ALPN",
+        "Port": "Порт",
+        "IPv4 Hints": "Подсказки IPv4",
+        "IPv6 Hints": "Подсказки IPv6",
+        "Other Parameters": "Прочие параметры",
+
+        // Users
+        "Users": "Пользователи",
+        "+ New User": "+ Новый пользователь",
+        "Role": "Роль",
+        "Zones": "Зоны",
+        "Status": "Статус",
+        "viewer": "просмотр",
+        "editor": "редактор",
+        "admin": "администратор",
+        "All zones": "Все зоны",
+        "Config file": "Файл конфигурации",
+        "Active": "Активен",
+        "Disabled": "Отключён",
+        "Save": "Сохранить",
+        "Delete user %s?": "Удалить пользователя %s?",
+        "Create New User": "Создать пользователя",
+        "Edit User %s": "Пользователь %s",
+        "New Password": "Новый пароль",
+        "Leave empty to keep": "Оставьте пустым, чтобы не менять",
+        "Owned Zones": "Зоны пользователя",
+        "Admins can access all zones": "Администраторам доступны все зоны",
+        "Error loading users": "Ошибка загрузки пользователей",
+        "Error creating user: %s": "Ошибка создания пользователя: %s",
+        "Error updating user: %s": "Ошибка обновления пользователя: %s",
+        "Error deleting user": "Ошибка удаления пользователя",
+        "Invalid user ID": "Некорректный ID пользователя",
+        "User not found": "Пользователь не найден",
+        "Username is taken by the config file admin": "Логин занят администратором из файла конфигурации",
+        "You cannot demote or disable yourself": "Нельзя понизить или отключить себя",
+        "You cannot delete yourself": "Нельзя удалить себя",
+        "You do not have permission for this action": "Недостаточно прав для этого действия",
+
+        // Single sign-on
+        "Sign in with SSO": "Войти через SSO",
+        "or": "или",
+        "Continue": "Продолжить",
+        "Single sign-on provider is unavailable": "Провайдер единого входа недоступен",
+        "Single sign-on failed: %s": "Ошибка единого входа: %s",
+        "Login session expired, please try again": "Сеанс входа истёк, попробуйте ещё раз",
+        "Your account has no access to this panel": "У вашей учётной записи нет доступа к панели",
+        "Password login is disabled, use single sign-on": "Вход по паролю отключён, используйте единый вход",
+
+        // Sessions
+        "Sessions": "Сеансы",
+        "Active Sessions": "Активные сеансы",
+        "Log out other sessions": "Завершить другие сеансы",
+        "Log out all other sessions?": "Завершить все другие сеансы?",
+        "IP Address": "IP-адрес",
+        "Browser": "Браузер",
+        "Signed in": "Вход выполнен",
+        "Last activity": "Последняя активность",
+        "This session": "Текущий сеанс",
+        "Revoke": "Завершить",
+        "Revoke this session?": "Завершить этот сеанс?",
+        "Session not found": "Сеанс не найден",
+        "Error loading sessions": "Ошибка загрузки сеансов",
+        "Error revoking session": "Ошибка завершения сеанса",
+        "Error creating session": "Ошибка создания сеанса",
+
+        // Login protection
+        "Too many failed attempts, try again in %d seconds": "Слишком много неудачных попыток, повторите через %d с",
+
+        // Two-factor authentication
+        "2FA": "2FA",
+        "Dashboard": "Панель",
+        "Two-factor authentication": "Двухфакторная аутентификация",
+        "Enter the code from your authenticator app or a recovery code.": "Введите код из приложения-аутентификатора или код восстановления.",
+        "Authentication code": "Код аутентификации",
+        "Verify": "Проверить",
+        "Invalid authentication code": "Неверный код аутентификации",
+        "Reset 2FA": "Сбросить 2FA",
+        "Reset two-factor authentication of %s?": "Сбросить двухфакторную аутентификацию пользователя %s?",
+        "Two-factor authentication reset": "Двухфакторная аутентификация сброшена",
+        "Error starting two-factor enrollment": "Ошибка подключения двухфакторной аутентификации",
+        "Two-factor authentication is required for all users": "Двухфакторная аутентификация обязательна для всех пользователей",
+        "Two-factor authentication disabled": "Двухфакторная аутентификация отключена",
+        "Two-factor authentication is enabled.": "Двухфакторная аутентификация включена.",
+        "%d unused recovery codes left.": "Осталось неиспользованных кодов восстановления: %d.",
+        "Save these recovery codes in a safe place. Each code can be used once instead of an authentication code. They will not be shown again.": "Сохраните эти коды восстановления в надёжном месте. Каждый код можно использовать один раз вместо кода аутентификации. Повторно они показаны не будут.",
+        "Two-factor authentication of this account is configured with admin.totp_secret in the config file.": "Двухфакторная аутентификация этой учётной записи задаётся параметром admin.totp_secret в файле конфигурации.",
+        "Set admin.totp_secret in the config file to enable two-factor authentication for this account.": "Укажите admin.totp_secret в файле конфигурации, чтобы включить двухфакторную аутентификацию для этой учётной записи.",
+        "This account signs in through single sign-on. Two-factor authentication is managed by the identity provider.": "Эта учётная запись входит через единый вход (SSO). Двухфакторной аутентификацией управляет провайдер удостоверений.",
+        "Generate new recovery codes": "Создать новые коды восстановления",
+        "Generate": "Создать",
+        "Disable two-factor authentication": "Отключить двухфакторную аутентификацию",
+        "Disable": "Отключить",
+        "Add the key to an authenticator app, by opening the otpauth link on the device or typing the key, then confirm with the code it shows.": "Добавьте ключ в приложение-аутентификатор, открыв ссылку otpauth на устройстве или введя ключ вручную, затем подтвердите кодом, который оно покажет.",
+        "Enable": "Включить",
+
+        // Audit log
+        "History": "История",
+        "← Back to Records": "← Назад к записям",
+        "History of %s": "История %s",
+        "No changes recorded yet": "Изменений пока нет",
+        "Error loading history": "Ошибка загрузки истории",
+        "Time": "Время",
+        "Actor": "Кто",
+        "Action": "Действие",
+        "Object": "Объект",
+        "Changes": "Изменения",
+        "Before": "До",
+        "After": "После",
+        "Show": "Показать",
+
+        // Zone versions
+        "Versions": "Версии",
+        "Versions of %s": "Версии %s",
+        "No versions recorded yet": "Версий пока нет",
+        "Error loading versions": "Ошибка загрузки версий",
+        "Current": "Текущая",
+        "From": "С",
+        "To": "По",
+        "Compare": "Сравнить",
+        "Version": "Версия",
+        "Serial": "Серийный номер",
+        "Note": "Примечание",
+        "Diff with current": "Сравнить с текущей",
+        "Restore version #%d of %s?": "Восстановить версию #%d зоны %s?",
+        "Rollback": "Откатить",
+        "Version not found": "Версия не найдена",
+        "Error restoring version: %s": "Ошибка восстановления версии: %s",
+        "No differences": "Различий нет",
+        "Change": "Изменение",
+        "added": "добавлено",
+        "removed": "удалено",
+        "changed": "изменено",
+
+        // Changesets
+        "Changeset": "Набор изменений",
+        "open": "открыт",
+        "Changeset for %s": "Набор изменений для %s",
+        "No open changeset. Edits made here are staged and go live together when published.": "Нет открытого набора изменений. Правки здесь накапливаются и вступают в силу вместе при публикации.",
+        "Start Changeset": "Начать набор изменений",
+        "Error loading changeset": "Ошибка загрузки набора изменений",
+        "Error saving changeset: %s": "Ошибка сохранения набора изменений: %s",
+        "Started by %s": "Начат: %s",
+        "Approved by %s": "Одобрен: %s",
+        "Needs approval by a second user": "Требуется одобрение другого пользователя",
+        "Changed live since the changeset was started: %s": "Изменено в рабочей зоне после начала набора изменений: %s",
+        "Pending Changes": "Ожидающие изменения",
+        "Approve": "Одобрить",
+        "Publish": "Опубликовать",
+        "Publish %d changes to %s?": "Опубликовать изменения (%d) в %s?",
+        "Discard": "Отменить",
+        "Discard this changeset?": "Отменить этот набор изменений?",
+        "Set Record Set": "Задать набор записей",
+        "One record per line, e.g. 192.0.2.1 | country=US. Leave empty to remove the record set.": "Одна запись на строку, например 192.0.2.1 | country=US. Оставьте пустым, чтобы удалить набор записей.",
+        "Save to Changeset": "Сохранить в набор изменений",
+        "Draft Records": "Записи черновика",
+        "Remove": "Удалить",
+        "No open changeset": "Нет открытого набора изменений",
+        "You cannot approve your own changes": "Нельзя одобрить собственные изменения",
+
+        // Validation
+        "Invalid record: %s": "Некорректная запись: %s",
+        "Invalid zone name: %s": "Некорректное имя зоны: %s",
+
+        // Zone settings
+        "Edit Zone: %s": "Редактирование зоны: %s",
+        "Kind": "Тип",
+        "Owner": "Владелец",
+        "Tags": "Метки",
+        "Default TTL (seconds)": "TTL по умолчанию (секунды)",
+        "SOA defaults (used when the SOA is created automatically)": "Параметры SOA (используются при автоматическом создании SOA)",
+        "Primary name server": "Первичный сервер имён",
+        "Hostmaster": "Администратор зоны",
+        "Refresh": "Refresh",
+        "Retry": "Retry",
+        "Expire": "Expire",
+        "Minimum TTL": "Минимальный TTL",
+        "SOA TTL": "TTL записи SOA",
+        "Disabled (kept but not served)": "Отключена (хранится, но не обслуживается)",
+        "disabled": "отключена",
+        "zone kind primary": "первичная",
+        "zone kind secondary": "вторичная",
+        "zone kind forward": "перенаправление",
+        "Invalid zone settings: %s": "Некорректные параметры зоны: %s",
+        "Error updating zone: %s": "Ошибка обновления зоны: %s",
+        "Serial policy": "Политика серийного номера",
+        "Keep a PTR in the hosted reverse zone (A/AAAA)": "Поддерживать PTR в обслуживаемой обратной зоне (A/AAAA)",
+        "serial policy ": "По умолчанию сервера",
+        "serial policy increment": "Увеличение (+1)",
+        "serial policy date": "Дата (YYYYMMDDnn)",
+        "serial policy epoch": "Unix-время",
     },
 }
 
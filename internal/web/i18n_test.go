@@ -19,7 +19,7 @@ func newTestDB(t *testing.T) *gorm.DB {
     t.Helper()
     db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
     if err != nil { t.Fatalf("open db: %v", err) }
-    if err := db.AutoMigrate(&dbm.Zone{}, &dbm.RRSet{}, &dbm.RData{}, &dbm.Template{}, &dbm.TemplateRecord{}); err != nil {
+    if err := db.AutoMigrate(&dbm.Zone{}, &dbm.RRSet{}, &dbm.RData{}, &dbm.Template{}, &dbm.TemplateRecord{}, &dbm.User{}, &dbm.RecoveryCode{}, &dbm.WebSession{}, &dbm.AuditLog{}, &dbm.ZoneVersion{}, &dbm.Changeset{}); err != nil {
         t.Fatalf("migrate: %v", err)
     }
     return db
@@ -31,13 +31,25 @@ func newTestWeb(t *testing.T) (*Server, *gin.Engine) {
         Admin: config.AdminConfig{Enabled: true, Username: "admin", PasswordHash: "$2a$10$abcdefghijklmnopqrstuv"},
     }
     db := newTestDB(t)
-    s, err := NewServer(cfg, db)
+    s, err := NewServer(cfg, db, nil)
     if err != nil { t.Fatalf("new web: %v", err) }
+    t.Cleanup(s.Close)
     r := gin.New()
     s.RegisterRoutes(r)
     return s, r
 }
 
+// addSession stores a session for the cookie value sid
+func addSession(s *Server, sid string, sess *Session) {
+    sess.Key = sessionKey(sid)
+    if sess.LastSeen.IsZero() {
+        sess.LastSeen = time.Now()
+    }
+    if err := s.sessions.Save(sess); err != nil {
+        panic(err)
+    }
+}
+
 func TestLoginPage_LanguageRU(t *testing.T) {
     s, r := newTestWeb(t)
     _ = s
@@ -56,7 +68,7 @@ func TestDashboard_LanguageSwitchEN_RU(t *testing.T) {
     s, r := newTestWeb(t)
     // Inject fake session
     sid := "testsession"
-    s.sessions[sid] = &Session{Username: "admin", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+    addSession(s, sid, &Session{Username: "admin", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)})
 
     // EN
     req := httptest.NewRequest("GET", "/admin/", nil)
@@ -86,7 +98,7 @@ func TestDashboard_LanguageSwitchEN_RU(t *testing.T) {
 func TestZonesList_LocalizedEmptyStateRU(t *testing.T) {
     s, r := newTestWeb(t)
     sid := "sess2"
-    s.sessions[sid] = &Session{Username: "admin", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+    addSession(s, sid, &Session{Username: "admin", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)})
 
     req := httptest.NewRequest("GET", "/admin/zones", nil)
     req.AddCookie(&http.Cookie{Name: "session", Value: sid, Path: "/admin"})
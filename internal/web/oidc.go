@@ -0,0 +1,174 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"namedot/internal/db"
+	"namedot/internal/oidc"
+)
+
+// oidcLoginTTL bounds the time between the redirect to the provider and the callback
+const oidcLoginTTL = 10 * time.Minute
+
+// oidcPending is a login started at the provider, keyed by its state
+type oidcPending struct {
+	nonce     string
+	verifier  string
+	expiresAt time.Time
+}
+
+func (s *Server) oidcEnabled() bool {
+	return s.cfg.Admin.OIDC.Enabled
+}
+
+// passwordLoginEnabled reports whether the local username/password form is offered
+func (s *Server) passwordLoginEnabled() bool {
+	return !(s.oidcEnabled() && s.cfg.Admin.OIDC.DisablePasswordLogin)
+}
+
+// oidcProvider discovers the provider on first use, so the admin panel
+// starts even while the provider is unreachable.
+func (s *Server) oidcProvider(ctx context.Context) (*oidc.Provider, error) {
+	s.oidcMu.Lock()
+	defer s.oidcMu.Unlock()
+	if s.oidc != nil {
+		return s.oidc, nil
+	}
+	o := s.cfg.Admin.OIDC
+	p, err := oidc.Discover(ctx, oidc.Config{
+		Issuer:       o.Issuer,
+		ClientID:     o.ClientID,
+		ClientSecret: o.ClientSecret,
+		RedirectURL:  o.RedirectURL,
+		Scopes:       o.Scopes,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.oidc = p
+	return p, nil
+}
+
+// oidcLogin redirects the browser to the provider
+func (s *Server) oidcLogin(c *gin.Context) {
+	p, err := s.oidcProvider(c.Request.Context())
+	if err != nil {
+		s.renderLogin(c, http.StatusBadGateway, s.tr(c, "Single sign-on provider is unavailable"))
+		return
+	}
+	state, nonce, verifier := oidc.RandomString(), oidc.RandomString(), oidc.RandomString()
+
+	s.oidcMu.Lock()
+	now := time.Now()
+	for k, v := range s.oidcPending {
+		if now.After(v.expiresAt) {
+			delete(s.oidcPending, k)
+		}
+	}
+	s.oidcPending[state] = oidcPending{nonce: nonce, verifier: verifier, expiresAt: now.Add(oidcLoginTTL)}
+	s.oidcMu.Unlock()
+
+	// The state cookie binds the callback to this browser. It must survive the
+	// cross-site redirect back from the provider, hence SameSite=Lax.
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     "oidc_state",
+		Value:    state,
+		MaxAge:   int(oidcLoginTTL.Seconds()),
+		Path:     "/admin/oidc",
+		Secure:   s.cfg.IsTLSEnabled(),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	c.Redirect(http.StatusFound, p.AuthCodeURL(state, nonce, verifier))
+}
+
+// oidcCallback finishes the login started by oidcLogin
+func (s *Server) oidcCallback(c *gin.Context) {
+	if e := c.Query("error"); e != "" {
+		s.renderLogin(c, http.StatusUnauthorized, s.trf(c, "Single sign-on failed: %s", e))
+		return
+	}
+	state := c.Query("state")
+	cookie, _ := c.Cookie("oidc_state")
+	http.SetCookie(c.Writer, &http.Cookie{Name: "oidc_state", Value: "", MaxAge: -1, Path: "/admin/oidc"})
+
+	s.oidcMu.Lock()
+	pending, ok := s.oidcPending[state]
+	delete(s.oidcPending, state)
+	s.oidcMu.Unlock()
+	if state == "" || cookie != state || !ok || time.Now().After(pending.expiresAt) {
+		s.renderLogin(c, http.StatusBadRequest, s.tr(c, "Login session expired, please try again"))
+		return
+	}
+
+	p, err := s.oidcProvider(c.Request.Context())
+	if err != nil {
+		s.renderLogin(c, http.StatusBadGateway, s.tr(c, "Single sign-on provider is unavailable"))
+		return
+	}
+	raw, err := p.Exchange(c.Request.Context(), c.Query("code"), pending.verifier)
+	if err != nil {
+		s.renderLogin(c, http.StatusUnauthorized, s.trf(c, "Single sign-on failed: %s", err.Error()))
+		return
+	}
+	claims, err := p.Verify(c.Request.Context(), raw, pending.nonce)
+	if err != nil {
+		s.renderLogin(c, http.StatusUnauthorized, s.trf(c, "Single sign-on failed: %s", err.Error()))
+		return
+	}
+
+	username := s.oidcUsername(claims)
+	role := s.oidcRole(claims)
+	if role == "" {
+		s.renderLogin(c, http.StatusForbidden, s.tr(c, "Your account has no access to this panel"))
+		return
+	}
+	user, err := db.SyncExternalUser(s.db, "oidc", username, role)
+	if err != nil {
+		s.renderLogin(c, http.StatusForbidden, s.tr(c, "Your account has no access to this panel"))
+		return
+	}
+
+	if err := s.startSession(c, user.ID, user.Username); err != nil {
+		s.renderLogin(c, http.StatusInternalServerError, s.tr(c, "Error creating session"))
+		return
+	}
+	// The session cookie is SameSite=Strict and is not sent on a redirect
+	// chain that started at the provider, so navigate from our own page.
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.String(http.StatusOK, `<!DOCTYPE html><html><head><meta http-equiv="refresh" content="0;url=/admin/"></head><body><a href="/admin/">`+s.tr(c, "Continue")+`</a></body></html>`)
+}
+
+// oidcUsername picks the configured username claim, then email, then subject
+func (s *Server) oidcUsername(claims oidc.Claims) string {
+	for _, name := range []string{s.cfg.Admin.OIDC.UsernameClaim, "email", "sub"} {
+		if v := claims.String(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// oidcRole maps the role claim to the most privileged matching role, or
+// the default role when nothing matches.
+func (s *Server) oidcRole(claims oidc.Claims) string {
+	o := s.cfg.Admin.OIDC
+	best := ""
+	for _, v := range claims.Strings(o.RoleClaim) {
+		role, ok := o.RoleMap[v]
+		if !ok {
+			continue
+		}
+		if best == "" || (&db.User{Role: role}).HasRole(best) {
+			best = role
+		}
+	}
+	if best == "" {
+		best = o.DefaultRole
+	}
+	return best
+}
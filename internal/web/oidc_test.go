@@ -0,0 +1,126 @@
+package web
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "strings"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+
+    "namedot/internal/config"
+    dbm "namedot/internal/db"
+    "namedot/internal/oidc/oidctest"
+)
+
+func newOIDCTestWeb(t *testing.T, idp *oidctest.IdP, disablePassword bool) (*Server, *gin.Engine) {
+    t.Helper()
+    cfg := &config.Config{Admin: config.AdminConfig{
+        Enabled: true, Username: "admin", PasswordHash: "$2a$10$abcdefghijklmnopqrstuv",
+        OIDC: config.OIDCConfig{
+            Enabled: true, Issuer: idp.URL, ClientID: idp.ClientID, ClientSecret: idp.ClientSecret,
+            RedirectURL: "http://namedot.test/admin/oidc/callback", Scopes: []string{"openid", "profile"},
+            UsernameClaim: "preferred_username", RoleClaim: "groups",
+            RoleMap: map[string]string{"dns-viewers": "viewer", "dns-admins": "admin"},
+            DisablePasswordLogin: disablePassword,
+        },
+    }}
+    s, err := NewServer(cfg, newTestDB(t), nil)
+    if err != nil { t.Fatalf("new web: %v", err) }
+    r := gin.New()
+    s.RegisterRoutes(r)
+    return s, r
+}
+
+// ssoLogin drives the code flow: namedot -> IdP authorize -> namedot callback
+func ssoLogin(t *testing.T, r *gin.Engine) *httptest.ResponseRecorder {
+    t.Helper()
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/oidc/login", nil))
+    if w.Code != http.StatusFound { t.Fatalf("login redirect: %d %s", w.Code, w.Body.String()) }
+    authURL, _ := url.Parse(w.Header().Get("Location"))
+    q := authURL.Query()
+    if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" || q.Get("nonce") == "" {
+        t.Fatalf("authorization request lacks PKCE or nonce: %s", authURL)
+    }
+    var stateCookie *http.Cookie
+    for _, ck := range w.Result().Cookies() {
+        if ck.Name == "oidc_state" { stateCookie = ck }
+    }
+    if stateCookie == nil { t.Fatalf("state cookie not set") }
+
+    client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+    resp, err := client.Get(authURL.String())
+    if err != nil { t.Fatalf("authorize: %v", err) }
+    resp.Body.Close()
+    back, _ := url.Parse(resp.Header.Get("Location"))
+
+    req := httptest.NewRequest("GET", "/admin/oidc/callback?"+back.RawQuery, nil)
+    req.AddCookie(stateCookie)
+    w = httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+    return w
+}
+
+func TestOIDC_LoginProvisionsUserWithMappedRole(t *testing.T) {
+    idp := oidctest.New("namedot", "s3cret", map[string]any{
+        "sub": "u-1", "preferred_username": "alice.sso", "groups": []string{"staff", "dns-admins"},
+    })
+    defer idp.Close()
+    s, r := newOIDCTestWeb(t, idp, false)
+
+    w := ssoLogin(t, r)
+    if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "url=/admin/") {
+        t.Fatalf("callback: %d %s", w.Code, w.Body.String())
+    }
+    var sid string
+    for _, ck := range w.Result().Cookies() {
+        if ck.Name == "session" { sid = ck.Value }
+    }
+    if sid == "" { t.Fatalf("session cookie not set") }
+
+    var u dbm.User
+    if err := s.db.Where("username = ?", "alice.sso").First(&u).Error; err != nil { t.Fatalf("user not provisioned: %v", err) }
+    if u.Role != dbm.RoleAdmin || u.Source != "oidc" { t.Fatalf("unexpected user %+v", u) }
+    if w := getAs(r, sid, "/admin/users"); w.Code != http.StatusOK { t.Fatalf("admin from SSO should manage users, got %d", w.Code) }
+
+    // The provider is authoritative for the role on the next login
+    idp.SetClaims(map[string]any{"sub": "u-1", "preferred_username": "alice.sso", "groups": "dns-viewers"})
+    if w := ssoLogin(t, r); w.Code != http.StatusOK { t.Fatalf("second login: %d", w.Code) }
+    s.db.First(&u, u.ID)
+    if u.Role != dbm.RoleViewer { t.Fatalf("role should follow the provider, got %s", u.Role) }
+
+    // SSO users cannot log in with a password
+    if _, err := dbm.AuthenticateUser(s.db, "alice.sso", ""); err == nil { t.Fatalf("SSO user must not log in locally") }
+}
+
+func TestOIDC_RejectsUnmappedUsersAndForgedState(t *testing.T) {
+    idp := oidctest.New("namedot", "", map[string]any{"sub": "u-2", "preferred_username": "mallory.sso", "groups": []string{"staff"}})
+    defer idp.Close()
+    s, r := newOIDCTestWeb(t, idp, true)
+
+    if w := ssoLogin(t, r); w.Code != http.StatusForbidden {
+        t.Fatalf("user without mapped role should be denied, got %d", w.Code)
+    }
+    var count int64
+    s.db.Model(&dbm.User{}).Where("username = ?", "mallory.sso").Count(&count)
+    if count != 0 { t.Fatalf("denied user must not be provisioned") }
+
+    // A callback without the matching state cookie is rejected
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/oidc/callback?code=x&state=forged", nil))
+    if w.Code != http.StatusBadRequest { t.Fatalf("forged state: got %d", w.Code) }
+
+    // Password login is switched off
+    w = httptest.NewRecorder()
+    r.ServeHTTP(w, httptest.NewRequest("GET", "/admin/login", nil))
+    if strings.Contains(w.Body.String(), `name="password"`) || !strings.Contains(w.Body.String(), "/admin/oidc/login") {
+        t.Fatalf("login page should offer SSO only: %s", w.Body.String())
+    }
+    req := httptest.NewRequest("POST", "/admin/login", strings.NewReader("username=admin&password=admin"))
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    w = httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+    if w.Code != http.StatusForbidden { t.Fatalf("password login should be disabled, got %d", w.Code) }
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"namedot/internal/db"
+	"namedot/internal/idn"
 )
 
 // Helper functions for pointer conversion
@@ -32,6 +33,9 @@ func (s *Server) listRecords(c *gin.Context) {
         c.String(http.StatusBadRequest, s.tr(c, "Invalid zone ID"))
         return
     }
+    if !s.authorizeZone(c, uint(zoneID), db.RoleViewer) {
+        return
+    }
 
     var zone db.Zone
     if err := s.db.First(&zone, zoneID).Error; err != nil {
@@ -53,7 +57,7 @@ func (s *Server) listRecords(c *gin.Context) {
 	// Build query
 	query := s.db.Model(&db.RRSet{}).Where("zone_id = ?", zoneID)
 	if search != "" {
-		query = query.Where("name LIKE ? OR type LIKE ?", "%"+search+"%", "%"+search+"%")
+		query = query.Where(db.NameContains(s.db, s.db.Model(&db.RRSet{}).Where("zone_id = ?", zoneID), search).Or("type LIKE ?", "%"+search+"%"))
 	}
 	if filterType != "" && filterType != "ALL" {
 		query = query.Where("type = ?", filterType)
@@ -74,7 +78,7 @@ func (s *Server) listRecords(c *gin.Context) {
 	totalPages := int((total + int64(perPage) - 1) / int64(perPage))
 
 	// Build filter and search form
-	recordTypes := []string{"ALL", "A", "AAAA", "CNAME", "MX", "TXT", "NS", "SOA", "SRV", "PTR", "CAA"}
+	recordTypes := []string{"ALL", "A", "AAAA", "ALIAS", "CNAME", "MX", "TXT", "NS", "SOA", "SRV", "PTR", "CAA", "HTTPS", "SVCB", "TLSA", "SSHFP", "NAPTR", "LOC"}
 	filterForm := fmt.Sprintf(`
 	<div style="margin-bottom: 1rem; display: flex; gap: 0.5rem; flex-wrap: wrap;">
 		<form hx-get="/admin/zones/%d/records" hx-target="#zones-list" hx-swap="innerHTML" style="display: flex; gap: 0.5rem; flex: 1;">
@@ -104,6 +108,12 @@ func (s *Server) listRecords(c *gin.Context) {
 		</form>
 	</div>`
 
+	// Point out a pending changeset of the zone
+	changesetLabel := s.tr(c, "Changeset")
+	if _, err := db.OpenChangeset(s.db, zone.ID); err == nil {
+		changesetLabel += " (" + s.tr(c, "open") + ")"
+	}
+
 	html := fmt.Sprintf(`
 	<div style="margin-bottom: 1rem;">
 		<button class="btn" style="background: #718096;" hx-get="/admin/zones" hx-target="#zones-list" hx-swap="innerHTML">
@@ -119,10 +129,19 @@ func (s *Server) listRecords(c *gin.Context) {
 			onclick="showTemplateSelector(%d)">
 			%s
 		</button>
+		<button class="btn" style="background: #718096;" hx-get="/admin/zones/%d/audit" hx-target="#zones-list" hx-swap="innerHTML">
+			%s
+		</button>
+		<button class="btn" style="background: #718096;" hx-get="/admin/zones/%d/versions" hx-target="#zones-list" hx-swap="innerHTML">
+			%s
+		</button>
+		<button class="btn" style="background: #ed8936;" hx-get="/admin/zones/%d/changeset" hx-target="#zones-list" hx-swap="innerHTML">
+			%s
+		</button>
 	</div>
 	<div id="template-selector-%d"></div>
 	%s
-	<div id="records-list">`, s.tr(c, "← Back to Zones"), s.trf(c, "Records for %s", zone.Name), zoneID, s.tr(c, "+ Add Record"), zoneID, s.tr(c, "📋 Apply Template"), zoneID, filterForm)
+	<div id="records-list">`, s.tr(c, "← Back to Zones"), s.trf(c, "Records for %s", idn.ToUnicode(zone.Name)), zoneID, s.tr(c, "+ Add Record"), zoneID, s.tr(c, "📋 Apply Template"), zoneID, s.tr(c, "History"), zoneID, s.tr(c, "Versions"), zoneID, changesetLabel, zoneID, filterForm)
 
 	if len(rrsets) == 0 {
 		if search != "" || filterType != "" {
@@ -168,7 +187,7 @@ func (s *Server) listRecords(c *gin.Context) {
 						%s
 					</button>
 				</td>
-				</tr>`, rr.Name, rr.Type, rr.TTL, geoInfo, record.Data, record.ID, s.tr(c, "Edit"), record.ID, s.tr(c, "Delete this record?"), s.tr(c, "Delete"))
+				</tr>`, displayName(rr.Name), rr.Type, rr.TTL, geoInfo, record.Data, record.ID, s.tr(c, "Edit"), record.ID, s.tr(c, "Delete this record?"), s.tr(c, "Delete"))
 			}
 		}
 
@@ -214,6 +233,15 @@ func (s *Server) listRecords(c *gin.Context) {
 
 func (s *Server) newRecordForm(c *gin.Context) {
 	zoneID := c.Param("id")
+	if id, err := strconv.ParseUint(zoneID, 10, 32); err != nil || !s.authorizeZone(c, uint(id), db.RoleEditor) {
+		if err != nil {
+			c.String(http.StatusBadRequest, s.tr(c, "Invalid zone ID"))
+		}
+		return
+	}
+	// New RRSets start with the default TTL of the zone
+	var zone db.Zone
+	s.db.Limit(1).Find(&zone, zoneID)
 
 html := fmt.Sprintf(`
     <div style="background: #f7fafc; padding: 1rem; border-radius: 4px; margin-bottom: 1rem;">
@@ -231,9 +259,11 @@ html := fmt.Sprintf(`
             <div>
                 <label>%s</label>
                 <select name="type" required
+                    hx-get="/admin/records/fields" hx-trigger="change" hx-target="#record-data-fields" hx-swap="innerHTML"
                     style="width: 100%%; padding: 0.5rem; border: 1px solid #cbd5e0; border-radius: 4px;">
                     <option value="A">A - IPv4 Address</option>
                     <option value="AAAA">AAAA - IPv6 Address</option>
+                    <option value="ALIAS">ALIAS - Apex Alias (resolved to A/AAAA)</option>
                     <option value="CNAME">CNAME - Canonical Name</option>
                     <option value="MX">MX - Mail Exchange</option>
                     <option value="TXT">TXT - Text Record</option>
@@ -241,20 +271,26 @@ html := fmt.Sprintf(`
                     <option value="SRV">SRV - Service Record</option>
                     <option value="PTR">PTR - Pointer Record</option>
                     <option value="CAA">CAA - Certificate Authority</option>
+                    <option value="HTTPS">HTTPS - HTTPS Service Binding</option>
+                    <option value="SVCB">SVCB - Service Binding</option>
+                    <option value="TLSA">TLSA - DANE Certificate Association</option>
+                    <option value="SSHFP">SSHFP - SSH Key Fingerprint</option>
+                    <option value="NAPTR">NAPTR - Naming Authority Pointer</option>
+                    <option value="LOC">LOC - Location</option>
                     <option value="SOA">SOA - Start of Authority</option>
                 </select>
             </div>
 
             <div>
                 <label>%s</label>
-                <input type="number" name="ttl" value="300" required
+                <input type="number" name="ttl" value="%d" required
                     style="width: 100%%; padding: 0.5rem; border: 1px solid #cbd5e0; border-radius: 4px;">
             </div>
 
-            <div>
-                <label>%s</label>
-                <input type="text" name="data" placeholder="192.0.2.1" required
-                    style="width: 100%%; padding: 0.5rem; border: 1px solid #cbd5e0; border-radius: 4px;">
+            <div id="record-data-fields" style="grid-column: span 2;">%s</div>
+
+            <div style="grid-column: span 2;">
+                <label><input type="checkbox" name="auto_ptr" value="1"> %s</label>
             </div>
 
             <div style="grid-column: span 2;">
@@ -293,7 +329,7 @@ html := fmt.Sprintf(`
                 </button>
             </div>
         </form>
-    </div>`, s.tr(c, "Add New Record"), zoneID, s.tr(c, "Name"), s.tr(c, "Use '@' for zone apex"), s.tr(c, "Type"), s.tr(c, "TTL (seconds)"), s.tr(c, "Data (IP/Value)"), s.tr(c, "GeoIP Targeting (optional)"), s.tr(c, "Country Code"), s.tr(c, "Continent Code"), s.tr(c, "ASN"), s.tr(c, "Subnet"), s.tr(c, "Add Record"), zoneID, s.tr(c, "Cancel"))
+    </div>`, s.tr(c, "Add New Record"), zoneID, s.tr(c, "Name"), s.tr(c, "Use '@' for zone apex"), s.tr(c, "Type"), s.tr(c, "TTL (seconds)"), zone.TTLDefault(300), s.typedRecordFields(c, "A", ""), s.tr(c, "Keep a PTR in the hosted reverse zone (A/AAAA)"), s.tr(c, "GeoIP Targeting (optional)"), s.tr(c, "Country Code"), s.tr(c, "Continent Code"), s.tr(c, "ASN"), s.tr(c, "Subnet"), s.tr(c, "Add Record"), zoneID, s.tr(c, "Cancel"))
 
 	c.Header("Content-Type", "text/html; charset=utf-8")
 	c.String(http.StatusOK, html)
@@ -305,6 +341,9 @@ func (s *Server) createRecord(c *gin.Context) {
         c.String(http.StatusBadRequest, s.tr(c, "Invalid zone ID"))
         return
     }
+    if !s.authorizeZone(c, uint(zoneID), db.RoleEditor) {
+        return
+    }
 
     // Load zone for FQDN normalization
     var zone db.Zone
@@ -315,7 +354,7 @@ func (s *Server) createRecord(c *gin.Context) {
 
     name := c.PostForm("name")
 	recType := strings.ToUpper(c.PostForm("type"))
-	data := c.PostForm("data")
+	data := recordDataFromForm(c, recType)
 	ttlStr := c.PostForm("ttl")
 	country := c.PostForm("country")
 	continent := c.PostForm("continent")
@@ -330,14 +369,9 @@ func (s *Server) createRecord(c *gin.Context) {
     // Normalize name to FQDN; handle @/empty as zone apex
     name = toFQDN(name, zone.Name)
 
-    // For CNAME data, treat "@" as zone apex and store FQDN
-    if strings.EqualFold(recType, "CNAME") && strings.TrimSpace(data) == "@" {
-        data = toFQDN("@", zone.Name)
-    }
-
 	ttl, _ := strconv.Atoi(ttlStr)
 	if ttl <= 0 {
-		ttl = 300
+		ttl = int(zone.TTLDefault(300))
 	}
 
 	asn := 0
@@ -345,10 +379,36 @@ func (s *Server) createRecord(c *gin.Context) {
 		asn, _ = strconv.Atoi(asnStr)
 	}
 
-	// Find or create RRSet
+	record := db.RData{
+		Data:      data,
+		Country:   stringPtr(country),
+		Continent: stringPtr(continent),
+		ASN:       intPtr(asn),
+		Subnet:    stringPtr(subnet),
+		AutoPTR:   c.PostForm("auto_ptr") != "" && (recType == "A" || recType == "AAAA"),
+	}
+
+	// Validate the RRSet as it will be with the new record; this also
+	// canonicalizes the data, e.g. "@" in CNAME data becomes the apex FQDN
 	var rrset db.RRSet
-    result := s.db.Where("zone_id = ? AND name = ? AND type = ?", zoneID, name, recType).First(&rrset)
-	if result.Error != nil {
+	found := s.db.Preload("Records").Where("zone_id = ? AND name = ? AND type = ?", zoneID, name, recType).First(&rrset).Error == nil
+	prospective := db.RRSet{Name: name, Type: recType, TTL: uint32(ttl)}
+	if found {
+		prospective.TTL = rrset.TTL
+		prospective.Records = append(prospective.Records, rrset.Records...)
+	}
+	prospective.Records = append(prospective.Records, record)
+	if err := s.checkRRSet(zone, &prospective); err != nil {
+		c.String(http.StatusBadRequest, s.invalidMessage(c, err))
+		return
+	}
+	record.Data = prospective.Records[len(prospective.Records)-1].Data
+
+	// Find or create RRSet
+	var before *db.RRSet
+	if found {
+		before = db.RRSetSnapshot(s.db, rrset.ID)
+	} else {
 		// Create new RRSet
 		rrset = db.RRSet{
 			ZoneID: uint(zoneID),
@@ -361,24 +421,23 @@ func (s *Server) createRecord(c *gin.Context) {
             return
         }
     }
-
-	// Add record data
-	record := db.RData{
-		RRSetID:   rrset.ID,
-		Data:      data,
-		Country:   stringPtr(country),
-		Continent: stringPtr(continent),
-		ASN:       intPtr(asn),
-		Subnet:    stringPtr(subnet),
-	}
+	record.RRSetID = rrset.ID
 
     if err := s.db.Create(&record).Error; err != nil {
         c.String(http.StatusInternalServerError, fmt.Sprintf(s.tr(c, "Error creating record: %s"), err.Error()))
         return
     }
+    action := db.AuditRRSetUpdate
+    if before == nil {
+        action = db.AuditRRSetCreate
+    }
+    after := db.RRSetSnapshot(s.db, rrset.ID)
+    s.audit(c, action, zone.ID, before, after)
+    s.bumpSerial(zone.ID)
+    s.syncPTRs(c, zone.ID, before, after)
 
 	// Return updated records list
-	c.Params = append(c.Params, gin.Param{Key: "id", Value: fmt.Sprintf("%d", zoneID)})
+	setParam(c, "id", fmt.Sprintf("%d", zoneID))
 	s.listRecords(c)
 }
 
@@ -389,10 +448,29 @@ func (s *Server) deleteRecord(c *gin.Context) {
         return
     }
 
-    if err := s.db.Delete(&db.RData{}, id).Error; err != nil {
+    var record db.RData
+    if err := s.db.First(&record, id).Error; err != nil {
+        c.String(http.StatusNotFound, s.tr(c, "Record not found"))
+        return
+    }
+    var rrset db.RRSet
+    if err := s.db.First(&rrset, record.RRSetID).Error; err != nil {
+        c.String(http.StatusNotFound, s.tr(c, "RRSet not found"))
+        return
+    }
+    if !s.authorizeZone(c, rrset.ZoneID, db.RoleEditor) {
+        return
+    }
+
+    before := db.RRSetSnapshot(s.db, rrset.ID)
+    if err := s.db.Delete(&record).Error; err != nil {
         c.String(http.StatusInternalServerError, s.tr(c, "Error deleting record"))
         return
     }
+    after := db.RRSetSnapshot(s.db, rrset.ID)
+    s.audit(c, db.AuditRRSetUpdate, rrset.ZoneID, before, after)
+    s.bumpSerial(rrset.ZoneID)
+    s.syncPTRs(c, rrset.ZoneID, before, after)
 
 	c.Status(http.StatusOK)
 }
@@ -400,7 +478,12 @@ func (s *Server) deleteRecord(c *gin.Context) {
 // toFQDN normalizes a relative name to FQDN within the given zone name.
 // If name is empty or "@", returns the zone origin with trailing dot.
 func toFQDN(name, zone string) string {
-    n := strings.TrimSpace(strings.ToLower(name))
+    // Unicode labels are stored in punycode; names that fail to convert are
+    // left for validation to report
+    n, err := idn.ToASCII(strings.TrimSpace(name))
+    if err != nil {
+        n = strings.TrimSpace(strings.ToLower(name))
+    }
     // Treat trailing ".@" as convenience suffix for "relative to zone apex"
     if strings.HasSuffix(n, ".@") {
         n = strings.TrimSuffix(n, ".@")
@@ -4,10 +4,10 @@ import (
     "fmt"
     "net/http"
     "strconv"
-    "strings"
 
     "github.com/gin-gonic/gin"
     "namedot/internal/db"
+    "namedot/internal/idn"
 )
 
 func (s *Server) editRecordForm(c *gin.Context) {
@@ -29,6 +29,9 @@ func (s *Server) editRecordForm(c *gin.Context) {
         c.String(http.StatusNotFound, s.tr(c, "RRSet not found"))
         return
     }
+    if !s.authorizeZone(c, rrset.ZoneID, db.RoleEditor) {
+        return
+    }
 
 	// Get values with nil checks
 	country := ""
@@ -48,6 +51,16 @@ func (s *Server) editRecordForm(c *gin.Context) {
 		subnet = *record.Subnet
 	}
 
+	autoPTRField := ""
+	if rrset.Type == "A" || rrset.Type == "AAAA" {
+		checked := ""
+		if record.AutoPTR {
+			checked = " checked"
+		}
+		autoPTRField = fmt.Sprintf(`<div style="grid-column: span 2;"><label><input type="checkbox" name="auto_ptr" value="1"%s> %s</label></div>`,
+			checked, s.tr(c, "Keep a PTR in the hosted reverse zone (A/AAAA)"))
+	}
+
 html := fmt.Sprintf(`
     <div style="background: #f7fafc; padding: 1rem; border-radius: 4px; margin-bottom: 1rem;">
         <h3>%s</h3>
@@ -74,11 +87,8 @@ html := fmt.Sprintf(`
                     style="width: 100%%; padding: 0.5rem; border: 1px solid #cbd5e0; border-radius: 4px;">
             </div>
 
-            <div>
-                <label>%s</label>
-                <input type="text" name="data" value="%s" required
-                    style="width: 100%%; padding: 0.5rem; border: 1px solid #cbd5e0; border-radius: 4px;">
-            </div>
+            <div id="record-data-fields" style="grid-column: span 2;">%s</div>
+            %s
 
             <div style="grid-column: span 2;">
                 <strong>%s</strong>
@@ -123,15 +133,15 @@ html := fmt.Sprintf(`
         s.tr(c, "Edit Record"),
         recordID,
         s.tr(c, "Name"),
-        rrset.Name,
+        idn.ToUnicode(rrset.Name),
         s.tr(c, "Name cannot be changed"),
         s.tr(c, "Type"),
         rrset.Type,
         s.tr(c, "Type cannot be changed"),
         s.tr(c, "TTL (seconds)"),
         rrset.TTL,
-        s.tr(c, "Data (IP/Value)"),
-        record.Data,
+        s.typedRecordFields(c, rrset.Type, record.Data),
+        autoPTRField,
         s.tr(c, "GeoIP Targeting (optional)"),
         s.tr(c, "Country Code"),
         country,
@@ -166,19 +176,11 @@ func (s *Server) updateRecord(c *gin.Context) {
     }
 
 	// Get form data
-	data := c.PostForm("data")
 	ttlStr := c.PostForm("ttl")
 	country := c.PostForm("country")
 	continent := c.PostForm("continent")
 	asnStr := c.PostForm("asn")
 	subnet := c.PostForm("subnet")
-	zoneIDStr := c.PostForm("zone_id")
-	rrsetIDStr := c.PostForm("rrset_id")
-
-    if data == "" {
-        c.String(http.StatusBadRequest, `<div class="error">`+s.tr(c, "Data is required")+`</div>`)
-        return
-    }
 
 	ttl, _ := strconv.Atoi(ttlStr)
 	if ttl <= 0 {
@@ -190,18 +192,50 @@ func (s *Server) updateRecord(c *gin.Context) {
 		asn, _ = strconv.Atoi(asnStr)
 	}
 
-    // If this RRSet is CNAME and data is "@", store apex FQDN
+    // The record decides the RRSet and zone; posted IDs are not trusted
     var rrset db.RRSet
-    rrsetID, _ := strconv.ParseUint(rrsetIDStr, 10, 32)
-    if err := s.db.First(&rrset, rrsetID).Error; err == nil {
-        if strings.EqualFold(rrset.Type, "CNAME") && strings.TrimSpace(data) == "@" {
-            // Need zone name for apex
-            var zone db.Zone
-            if err := s.db.First(&zone, rrset.ZoneID).Error; err == nil {
-                data = toFQDN("@", zone.Name)
-            }
+    if err := s.db.First(&rrset, record.RRSetID).Error; err != nil {
+        c.String(http.StatusNotFound, s.tr(c, "RRSet not found"))
+        return
+    }
+    if !s.authorizeZone(c, rrset.ZoneID, db.RoleEditor) {
+        return
+    }
+    var zone db.Zone
+    if err := s.db.First(&zone, rrset.ZoneID).Error; err != nil {
+        c.String(http.StatusNotFound, s.tr(c, "Zone not found"))
+        return
+    }
+
+    data := recordDataFromForm(c, rrset.Type)
+    if data == "" {
+        c.String(http.StatusBadRequest, `<div class="error">`+s.tr(c, "Data is required")+`</div>`)
+        return
+    }
+    // Validate the RRSet as it will be after the change; this also
+    // canonicalizes the data, e.g. "@" in CNAME data becomes the apex FQDN
+    var current []db.RData
+    s.db.Where("rr_set_id = ?", rrset.ID).Find(&current)
+    prospective := db.RRSet{Name: rrset.Name, Type: rrset.Type, TTL: uint32(ttl)}
+    at := -1
+    for _, r := range current {
+        if r.ID == record.ID {
+            at = len(prospective.Records)
+            r.Data, r.Country, r.Continent, r.ASN, r.Subnet = data, stringPtr(country), stringPtr(continent), intPtr(asn), stringPtr(subnet)
         }
+        prospective.Records = append(prospective.Records, r)
     }
+    if at < 0 {
+        c.String(http.StatusNotFound, s.tr(c, "Record not found"))
+        return
+    }
+    if err := s.checkRRSet(zone, &prospective); err != nil {
+        c.String(http.StatusBadRequest, s.invalidMessage(c, err))
+        return
+    }
+    data = prospective.Records[at].Data
+
+    before := db.RRSetSnapshot(s.db, rrset.ID)
 
     // Update record data
 	record.Data = data
@@ -209,6 +243,7 @@ func (s *Server) updateRecord(c *gin.Context) {
 	record.Continent = stringPtr(continent)
 	record.ASN = intPtr(asn)
 	record.Subnet = stringPtr(subnet)
+	record.AutoPTR = c.PostForm("auto_ptr") != "" && (rrset.Type == "A" || rrset.Type == "AAAA")
 
     if err := s.db.Save(&record).Error; err != nil {
         c.String(http.StatusInternalServerError, fmt.Sprintf(s.tr(c, "Error updating record: %s"), err.Error()))
@@ -216,18 +251,19 @@ func (s *Server) updateRecord(c *gin.Context) {
     }
 
     // Update RRSet TTL if changed
-	if err := s.db.First(&rrset, rrsetID).Error; err == nil {
-		if uint32(ttl) != rrset.TTL {
-			rrset.TTL = uint32(ttl)
-            if err := s.db.Save(&rrset).Error; err != nil {
-                c.String(http.StatusInternalServerError, fmt.Sprintf(s.tr(c, "Error updating TTL: %s"), err.Error()))
-                return
-            }
-		}
+	if uint32(ttl) != rrset.TTL {
+		rrset.TTL = uint32(ttl)
+        if err := s.db.Save(&rrset).Error; err != nil {
+            c.String(http.StatusInternalServerError, fmt.Sprintf(s.tr(c, "Error updating TTL: %s"), err.Error()))
+            return
+        }
 	}
+	after := db.RRSetSnapshot(s.db, rrset.ID)
+	s.audit(c, db.AuditRRSetUpdate, zone.ID, before, after)
+	s.bumpSerial(zone.ID)
+	s.syncPTRs(c, zone.ID, before, after)
 
 	// Return updated records list
-	zoneID, _ := strconv.ParseUint(zoneIDStr, 10, 32)
-	c.Params = append(c.Params, gin.Param{Key: "id", Value: fmt.Sprintf("%d", zoneID)})
+	setParam(c, "id", fmt.Sprintf("%d", rrset.ZoneID))
 	s.listRecords(c)
 }
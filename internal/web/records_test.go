@@ -0,0 +1,119 @@
+package web
+
+import (
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    dbm "namedot/internal/db"
+)
+
+func postRecordForm(r *gin.Engine, sid, path string, form url.Values) *httptest.ResponseRecorder {
+    form.Set("csrf_token", "csrf-"+sid)
+    req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    req.Header.Set("Origin", "http://example.com")
+    req.Host = "example.com"
+    req.AddCookie(&http.Cookie{Name: "session", Value: sid, Path: "/admin"})
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+    return w
+}
+
+func TestCreateRecord_TypedCAAIsCanonicalized(t *testing.T) {
+    s, r := newTestWeb(t)
+    sid := "sess-typed"
+    addSession(s, sid, &Session{Username: "admin", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour), CSRFToken: "csrf-" + sid})
+    zone := dbm.Zone{Name: "typed-caa.test"}
+    if err := s.db.Create(&zone).Error; err != nil { t.Fatalf("create zone: %v", err) }
+
+    form := url.Values{
+        "name": {"@"}, "type": {"CAA"}, "ttl": {"300"},
+        "caa_flags": {"128"}, "caa_tag": {"issue"}, "caa_value": {"letsencrypt.org"},
+    }
+    w := postRecordForm(r, sid, fmt.Sprintf("/admin/zones/%d/records", zone.ID), form)
+    if w.Code != http.StatusOK { t.Fatalf("status %d: %s", w.Code, w.Body.String()) }
+
+    var set dbm.RRSet
+    if err := s.db.Preload("Records").Where("zone_id = ? AND type = ?", zone.ID, "CAA").First(&set).Error; err != nil {
+        t.Fatalf("rrset not created: %v", err)
+    }
+    if len(set.Records) != 1 || set.Records[0].Data != `128 issue "letsencrypt.org"` {
+        t.Fatalf("unexpected stored data: %+v", set.Records)
+    }
+}
+
+func TestCreateRecord_InvalidDataRejected(t *testing.T) {
+    s, r := newTestWeb(t)
+    sid := "sess-invalid"
+    addSession(s, sid, &Session{Username: "admin", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour), CSRFToken: "csrf-" + sid})
+    zone := dbm.Zone{Name: "typed-tlsa.test"}
+    if err := s.db.Create(&zone).Error; err != nil { t.Fatalf("create zone: %v", err) }
+
+    form := url.Values{
+        "name": {"_443._tcp"}, "type": {"TLSA"}, "ttl": {"300"},
+        "tlsa_usage": {"3"}, "tlsa_selector": {"1"}, "tlsa_matching": {"1"}, "tlsa_data": {"abcd"},
+    }
+    w := postRecordForm(r, sid, fmt.Sprintf("/admin/zones/%d/records", zone.ID), form)
+    if w.Code != http.StatusBadRequest { t.Fatalf("expected 400, got %d", w.Code) }
+    if !strings.Contains(w.Body.String(), "32 bytes") { t.Fatalf("error should explain the problem: %s", w.Body.String()) }
+
+    var count int64
+    s.db.Model(&dbm.RData{}).Joins("JOIN rr_sets ON rr_sets.id = r_data.rr_set_id").Where("rr_sets.zone_id = ?", zone.ID).Count(&count)
+    if count != 0 { t.Fatalf("invalid record must not be stored, found %d", count) }
+}
+
+func TestRecordDataFields_PrefillsHTTPS(t *testing.T) {
+    s, r := newTestWeb(t)
+    sid := "sess-fields"
+    addSession(s, sid, &Session{Username: "admin", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)})
+
+    q := url.Values{"type": {"HTTPS"}, "data": {`1 . alpn="h2,h3" port="8443"`}}
+    req := httptest.NewRequest("GET", "/admin/records/fields?"+q.Encode(), nil)
+    req.AddCookie(&http.Cookie{Name: "session", Value: sid, Path: "/admin"})
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+    if w.Code != http.StatusOK { t.Fatalf("status %d", w.Code) }
+    body := w.Body.String()
+    for _, want := range []string{`name="svcb_alpn" value="h2,h3"`, `name="svcb_port" value="8443"`, `name="svcb_priority" value="1"`} {
+        if !strings.Contains(body, want) { t.Fatalf("missing %s in %s", want, body) }
+    }
+}
+
+func TestIDN_ZoneAndRecordNames(t *testing.T) {
+    s, r := newTestWeb(t)
+    sid := "sess-idn"
+    addSession(s, sid, &Session{Username: "admin", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour), CSRFToken: "csrf-" + sid})
+
+    w := postRecordForm(r, sid, "/admin/zones", url.Values{"name": {"Пример-idn.рф"}})
+    if w.Code != http.StatusOK { t.Fatalf("create zone: %d %s", w.Code, w.Body.String()) }
+    var zone dbm.Zone
+    if err := s.db.Where("name = ?", "xn---idn-u4dr2awjd.xn--p1ai.").First(&zone).Error; err != nil {
+        t.Fatalf("zone not stored in punycode: %v", err)
+    }
+
+    form := url.Values{"name": {"Почта"}, "type": {"A"}, "ttl": {"300"}, "a_address": {"192.0.2.1"}, "data": {"192.0.2.1"}}
+    w = postRecordForm(r, sid, fmt.Sprintf("/admin/zones/%d/records", zone.ID), form)
+    if w.Code != http.StatusOK { t.Fatalf("create record: %d %s", w.Code, w.Body.String()) }
+    var set dbm.RRSet
+    if err := s.db.Where("zone_id = ? AND name = ?", zone.ID, "xn--80a1acny.xn---idn-u4dr2awjd.xn--p1ai.").First(&set).Error; err != nil {
+        t.Fatalf("record name not stored in punycode: %v", err)
+    }
+
+    // Both forms find the zone, which is shown in Unicode
+    for _, q := range []string{"пример-idn", "xn---idn-u4dr2awjd"} {
+        req := httptest.NewRequest("GET", "/admin/zones?search="+url.QueryEscape(q), nil)
+        req.AddCookie(&http.Cookie{Name: "session", Value: sid, Path: "/admin"})
+        w = httptest.NewRecorder()
+        r.ServeHTTP(w, req)
+        if !strings.Contains(w.Body.String(), "пример-idn.рф.") {
+            t.Fatalf("search %q: zone not listed in Unicode: %s", q, w.Body.String())
+        }
+    }
+}
@@ -0,0 +1,183 @@
+package web
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/miekg/dns"
+)
+
+const inputStyle = `width: 100%; padding: 0.5rem; border: 1px solid #cbd5e0; border-radius: 4px;`
+
+type option struct{ value, label string }
+
+// recordDataFields renders the data inputs for the type picked in the record form
+func (s *Server) recordDataFields(c *gin.Context) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.String(http.StatusOK, s.typedRecordFields(c, strings.ToUpper(c.Query("type")), c.Query("data")))
+}
+
+// typedRecordFields renders the data inputs for rtype, prefilled from data.
+// Types with structured RDATA get one input per field; the rest get a single
+// free-form data input.
+func (s *Server) typedRecordFields(c *gin.Context, rtype, data string) string {
+	var rr dns.RR
+	if data != "" {
+		rr, _ = dns.NewRR(". 0 IN " + rtype + " " + data)
+	}
+
+	var fields []string
+	switch rtype {
+	case "CAA":
+		v, _ := rr.(*dns.CAA)
+		if v == nil {
+			v = &dns.CAA{Tag: "issue"}
+		}
+		fields = []string{
+			s.formField(c, "Flags", selectInput("caa_flags", strconv.Itoa(int(v.Flag)),
+				option{"0", "0"}, option{"128", "128 (critical)"})),
+			s.formField(c, "Tag", selectInput("caa_tag", v.Tag,
+				option{"issue", "issue"}, option{"issuewild", "issuewild"}, option{"issuemail", "issuemail"}, option{"iodef", "iodef"})),
+			s.formField(c, "Value", textInput("caa_value", v.Value, "letsencrypt.org", true)),
+		}
+	case "TLSA":
+		v, _ := rr.(*dns.TLSA)
+		if v == nil {
+			v = &dns.TLSA{Usage: 3, Selector: 1, MatchingType: 1}
+		}
+		fields = []string{
+			s.formField(c, "Usage", selectInput("tlsa_usage", strconv.Itoa(int(v.Usage)),
+				option{"0", "0 - PKIX-TA"}, option{"1", "1 - PKIX-EE"}, option{"2", "2 - DANE-TA"}, option{"3", "3 - DANE-EE"})),
+			s.formField(c, "Selector", selectInput("tlsa_selector", strconv.Itoa(int(v.Selector)),
+				option{"0", "0 - Full certificate"}, option{"1", "1 - SubjectPublicKeyInfo"})),
+			s.formField(c, "Matching Type", selectInput("tlsa_matching", strconv.Itoa(int(v.MatchingType)),
+				option{"0", "0 - Exact match"}, option{"1", "1 - SHA-256"}, option{"2", "2 - SHA-512"})),
+			s.formField(c, "Certificate Data (hex)", textInput("tlsa_data", v.Certificate, "", true)),
+		}
+	case "SSHFP":
+		v, _ := rr.(*dns.SSHFP)
+		if v == nil {
+			v = &dns.SSHFP{Algorithm: 4, Type: 2}
+		}
+		fields = []string{
+			s.formField(c, "Algorithm", selectInput("sshfp_algorithm", strconv.Itoa(int(v.Algorithm)),
+				option{"1", "1 - RSA"}, option{"2", "2 - DSA"}, option{"3", "3 - ECDSA"}, option{"4", "4 - Ed25519"}, option{"6", "6 - Ed448"})),
+			s.formField(c, "Fingerprint Type", selectInput("sshfp_type", strconv.Itoa(int(v.Type)),
+				option{"1", "1 - SHA-1"}, option{"2", "2 - SHA-256"})),
+			s.formField(c, "Fingerprint (hex)", textInput("sshfp_fingerprint", v.FingerPrint, "", true)),
+		}
+	case "HTTPS", "SVCB":
+		priority, target := uint16(1), "."
+		var params []dns.SVCBKeyValue
+		switch v := rr.(type) {
+		case *dns.HTTPS:
+			priority, target, params = v.Priority, v.Target, v.Value
+		case *dns.SVCB:
+			priority, target, params = v.Priority, v.Target, v.Value
+		}
+		known := map[dns.SVCBKey]string{}
+		var other []string
+		for _, p := range params {
+			switch p.Key() {
+			case dns.SVCB_ALPN, dns.SVCB_PORT, dns.SVCB_IPV4HINT, dns.SVCB_IPV6HINT:
+				known[p.Key()] = p.String()
+			default:
+				other = append(other, p.Key().String()+"="+p.String())
+			}
+		}
+		fields = []string{
+			s.formField(c, "Priority", textInput("svcb_priority", strconv.Itoa(int(priority)), "1", true)),
+			s.formField(c, "Target", textInput("svcb_target", target, ".", true)),
+			s.formField(c, "ALPN", textInput("svcb_alpn", known[dns.SVCB_ALPN], "h2,h3", false)),
+			s.formField(c, "Port", textInput("svcb_port", known[dns.SVCB_PORT], "443", false)),
+			s.formField(c, "IPv4 Hints", textInput("svcb_ipv4hint", known[dns.SVCB_IPV4HINT], "192.0.2.1,192.0.2.2", false)),
+			s.formField(c, "IPv6 Hints", textInput("svcb_ipv6hint", known[dns.SVCB_IPV6HINT], "2001:db8::1", false)),
+			s.formField(c, "Other Parameters", textInput("svcb_params", strings.Join(other, " "), "ech=...", false)),
+		}
+	default:
+		return s.formField(c, "Data (IP/Value)", textInput("data", data, "192.0.2.1", true))
+	}
+	return `<div style="display: grid; grid-template-columns: 1fr 1fr; gap: 1rem;">` + strings.Join(fields, "") + `</div>`
+}
+
+func (s *Server) formField(c *gin.Context, label, input string) string {
+	return `<div><label>` + s.tr(c, label) + `</label>` + input + `</div>`
+}
+
+func textInput(name, value, placeholder string, required bool) string {
+	req := ""
+	if required {
+		req = " required"
+	}
+	return fmt.Sprintf(`<input type="text" name="%s" value="%s" placeholder="%s"%s style="%s">`,
+		name, html.EscapeString(value), html.EscapeString(placeholder), req, inputStyle)
+}
+
+func selectInput(name, current string, opts ...option) string {
+	out := fmt.Sprintf(`<select name="%s" style="%s">`, name, inputStyle)
+	for _, o := range opts {
+		selected := ""
+		if o.value == current {
+			selected = " selected"
+		}
+		out += fmt.Sprintf(`<option value="%s"%s>%s</option>`, o.value, selected, html.EscapeString(o.label))
+	}
+	return out + `</select>`
+}
+
+// recordDataFromForm returns the record data posted by the free-form or the
+// typed editor. It returns "" when required typed fields are missing.
+func recordDataFromForm(c *gin.Context, rtype string) string {
+	if data := strings.TrimSpace(c.PostForm("data")); data != "" {
+		return data
+	}
+	f := func(name string) string { return strings.TrimSpace(c.PostForm(name)) }
+	switch rtype {
+	case "CAA":
+		if f("caa_tag") == "" || f("caa_value") == "" {
+			return ""
+		}
+		return fmt.Sprintf("%s %s %s", orDefault(f("caa_flags"), "0"), f("caa_tag"), quoteValue(f("caa_value")))
+	case "TLSA":
+		if f("tlsa_data") == "" {
+			return ""
+		}
+		return fmt.Sprintf("%s %s %s %s", f("tlsa_usage"), f("tlsa_selector"), f("tlsa_matching"), f("tlsa_data"))
+	case "SSHFP":
+		if f("sshfp_fingerprint") == "" {
+			return ""
+		}
+		return fmt.Sprintf("%s %s %s", f("sshfp_algorithm"), f("sshfp_type"), f("sshfp_fingerprint"))
+	case "HTTPS", "SVCB":
+		if f("svcb_priority") == "" {
+			return ""
+		}
+		parts := []string{f("svcb_priority"), orDefault(f("svcb_target"), ".")}
+		for _, key := range []string{"alpn", "port", "ipv4hint", "ipv6hint"} {
+			if v := f("svcb_" + key); v != "" {
+				parts = append(parts, key+"="+strings.ReplaceAll(v, " ", ""))
+			}
+		}
+		if v := f("svcb_params"); v != "" {
+			parts = append(parts, v)
+		}
+		return strings.Join(parts, " ")
+	}
+	return ""
+}
+
+func orDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// quoteValue quotes a free-text field as a DNS character-string
+func quoteValue(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+}
@@ -0,0 +1,262 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"namedot/internal/db"
+)
+
+// ErrSessionNotFound is returned for unknown sessions
+var ErrSessionNotFound = errors.New("session not found")
+
+// touchInterval limits how often the last activity of a session is written
+const touchInterval = time.Minute
+
+// janitorInterval is how often expired sessions are removed
+const janitorInterval = 5 * time.Minute
+
+// Session is a logged in browser. Key is the SHA-256 digest of the session
+// cookie; stores never see the cookie value itself.
+type Session struct {
+	Key       string
+	UserID    uint // 0 for the admin from the config file
+	Username  string
+	CSRFToken string
+	IP        string
+	UserAgent string
+	CreatedAt time.Time
+	LastSeen  time.Time
+	ExpiresAt time.Time // absolute timeout
+
+	// MFAPending marks a login that passed the password check and waits
+	// for the second factor; it grants no access to the panel
+	MFAPending bool
+}
+
+// Expired reports whether the session hit its absolute or idle timeout at now
+func (s *Session) Expired(now time.Time, idle time.Duration) bool {
+	return now.After(s.ExpiresAt) || (idle > 0 && now.After(s.LastSeen.Add(idle)))
+}
+
+// belongsTo reports whether the session is owned by the given account
+func (s *Session) belongsTo(userID uint, username string) bool {
+	if userID != 0 {
+		return s.UserID == userID
+	}
+	return s.UserID == 0 && s.Username == username
+}
+
+// SessionStore keeps web admin sessions. Implementations must be safe for
+// concurrent use.
+type SessionStore interface {
+	Save(sess *Session) error
+	Get(key string) (*Session, error)
+	Touch(key string, at time.Time) error
+	Delete(key string) error
+	// DeleteUser removes every session of an account except exceptKey.
+	// The config file admin is matched by username with userID 0.
+	DeleteUser(userID uint, username, exceptKey string) (int, error)
+	// List returns all sessions, most recently active first
+	List() ([]Session, error)
+	// Prune removes the sessions expired at now
+	Prune(now time.Time, idle time.Duration) (int, error)
+}
+
+// sessionKey returns the store key of a session cookie value
+func sessionKey(cookie string) string {
+	sum := sha256.Sum256([]byte(cookie))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewSessionStore returns the store selected by the admin.session_store setting
+func NewSessionStore(kind string, gdb *gorm.DB) SessionStore {
+	if kind == "db" {
+		return &dbSessionStore{db: gdb}
+	}
+	return NewMemorySessionStore()
+}
+
+// runSessionJanitor removes expired sessions every interval until stop is
+// closed
+func runSessionJanitor(store SessionStore, interval, idle time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_, _ = store.Prune(time.Now(), idle)
+		case <-stop:
+			return
+		}
+	}
+}
+
+type memorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+}
+
+// NewMemorySessionStore returns a store that keeps sessions in process
+// memory; a restart logs everyone out.
+func NewMemorySessionStore() SessionStore {
+	return &memorySessionStore{sessions: map[string]Session{}}
+}
+
+func (m *memorySessionStore) Save(sess *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[sess.Key] = *sess
+	return nil
+}
+
+func (m *memorySessionStore) Get(key string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sess, ok := m.sessions[key]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return &sess, nil
+}
+
+func (m *memorySessionStore) Touch(key string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if sess, ok := m.sessions[key]; ok {
+		sess.LastSeen = at
+		m.sessions[key] = sess
+	}
+	return nil
+}
+
+func (m *memorySessionStore) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, key)
+	return nil
+}
+
+func (m *memorySessionStore) DeleteUser(userID uint, username, exceptKey string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for key, sess := range m.sessions {
+		if key != exceptKey && sess.belongsTo(userID, username) {
+			delete(m.sessions, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *memorySessionStore) List() ([]Session, error) {
+	m.mu.Lock()
+	out := make([]Session, 0, len(m.sessions))
+	for _, sess := range m.sessions {
+		out = append(out, sess)
+	}
+	m.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+	return out, nil
+}
+
+func (m *memorySessionStore) Prune(now time.Time, idle time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for key, sess := range m.sessions {
+		if sess.Expired(now, idle) {
+			delete(m.sessions, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// dbSessionStore keeps sessions in the web_sessions table, so they survive
+// restarts and are shared by instances using the same database.
+type dbSessionStore struct {
+	db *gorm.DB
+}
+
+func (d *dbSessionStore) Save(sess *Session) error {
+	ua := sess.UserAgent
+	if len(ua) > 255 {
+		ua = ua[:255]
+	}
+	row := db.WebSession{
+		ID: sess.Key, UserID: sess.UserID, Username: sess.Username, CSRFToken: sess.CSRFToken,
+		IP: sess.IP, UserAgent: ua, CreatedAt: sess.CreatedAt, LastSeen: sess.LastSeen, ExpiresAt: sess.ExpiresAt,
+		MFAPending: sess.MFAPending,
+	}
+	return d.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&row).Error
+}
+
+func (d *dbSessionStore) Get(key string) (*Session, error) {
+	var row db.WebSession
+	if err := d.db.Where("id = ?", key).Limit(1).Find(&row).Error; err != nil {
+		return nil, err
+	}
+	if row.ID == "" {
+		return nil, ErrSessionNotFound
+	}
+	sess := sessionFromRow(row)
+	return &sess, nil
+}
+
+func (d *dbSessionStore) Touch(key string, at time.Time) error {
+	return d.db.Model(&db.WebSession{}).Where("id = ?", key).Update("last_seen", at).Error
+}
+
+func (d *dbSessionStore) Delete(key string) error {
+	return d.db.Where("id = ?", key).Delete(&db.WebSession{}).Error
+}
+
+func (d *dbSessionStore) DeleteUser(userID uint, username, exceptKey string) (int, error) {
+	q := d.db.Where("id <> ?", exceptKey)
+	if userID != 0 {
+		q = q.Where("user_id = ?", userID)
+	} else {
+		q = q.Where("user_id = 0 AND username = ?", username)
+	}
+	res := q.Delete(&db.WebSession{})
+	return int(res.RowsAffected), res.Error
+}
+
+func (d *dbSessionStore) List() ([]Session, error) {
+	var rows []db.WebSession
+	if err := d.db.Order("last_seen DESC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]Session, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, sessionFromRow(row))
+	}
+	return out, nil
+}
+
+func (d *dbSessionStore) Prune(now time.Time, idle time.Duration) (int, error) {
+	q := d.db.Where("expires_at < ?", now)
+	if idle > 0 {
+		q = q.Or("last_seen < ?", now.Add(-idle))
+	}
+	res := q.Delete(&db.WebSession{})
+	return int(res.RowsAffected), res.Error
+}
+
+func sessionFromRow(row db.WebSession) Session {
+	return Session{
+		Key: row.ID, UserID: row.UserID, Username: row.Username, CSRFToken: row.CSRFToken,
+		IP: row.IP, UserAgent: row.UserAgent, CreatedAt: row.CreatedAt, LastSeen: row.LastSeen, ExpiresAt: row.ExpiresAt,
+		MFAPending: row.MFAPending,
+	}
+}
@@ -0,0 +1,119 @@
+package web
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"namedot/internal/db"
+)
+
+// currentSessionKey returns the store key of the caller's session
+func currentSessionKey(c *gin.Context) string {
+	return c.GetString("session_key")
+}
+
+// visibleSessions returns the live sessions of the caller; admins see all
+func (s *Server) visibleSessions(c *gin.Context) ([]Session, error) {
+	all, err := s.sessions.List()
+	if err != nil {
+		return nil, err
+	}
+	u := currentUser(c)
+	if u.HasRole(db.RoleAdmin) {
+		return all, nil
+	}
+	own := all[:0]
+	for _, sess := range all {
+		if sess.belongsTo(u.ID, u.Username) {
+			own = append(own, sess)
+		}
+	}
+	return own, nil
+}
+
+func (s *Server) listSessions(c *gin.Context) {
+	sessions, err := s.visibleSessions(c)
+	if err != nil {
+		c.String(http.StatusInternalServerError, s.tr(c, "Error loading sessions"))
+		return
+	}
+
+	current := currentSessionKey(c)
+	idle := s.cfg.Admin.SessionIdleTimeout()
+	now := time.Now()
+	out := `<table>
+        <thead>
+            <tr>
+                <th>` + s.tr(c, "Username") + `</th>
+                <th>` + s.tr(c, "IP Address") + `</th>
+                <th>` + s.tr(c, "Browser") + `</th>
+                <th>` + s.tr(c, "Signed in") + `</th>
+                <th>` + s.tr(c, "Last activity") + `</th>
+                <th>` + s.tr(c, "Actions") + `</th>
+            </tr>
+        </thead>
+        <tbody>`
+	for _, sess := range sessions {
+		if sess.Expired(now, idle) || sess.MFAPending {
+			continue
+		}
+		action := fmt.Sprintf(`<button class="btn btn-sm btn-danger"
+                        hx-delete="/admin/sessions/%s"
+                        hx-confirm="%s"
+                        hx-target="closest tr"
+                        hx-swap="outerHTML">
+                        %s
+                    </button>`, sess.Key, s.tr(c, "Revoke this session?"), s.tr(c, "Revoke"))
+		if sess.Key == current {
+			action = `<em>` + s.tr(c, "This session") + `</em>`
+		}
+		out += fmt.Sprintf(`
+            <tr>
+                <td><strong>%s</strong></td>
+                <td>%s</td>
+                <td><small>%s</small></td>
+                <td>%s</td>
+                <td>%s</td>
+                <td class="actions">%s</td>
+            </tr>`, html.EscapeString(sess.Username), html.EscapeString(sess.IP), html.EscapeString(sess.UserAgent),
+			sess.CreatedAt.Format("2006-01-02 15:04"), sess.LastSeen.Format("2006-01-02 15:04"), action)
+	}
+	out += `</tbody></table>`
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.String(http.StatusOK, out)
+}
+
+// revokeSession ends one session. Users may revoke their own sessions,
+// admins any session.
+func (s *Server) revokeSession(c *gin.Context) {
+	sess, err := s.sessions.Get(c.Param("key"))
+	if err != nil {
+		c.String(http.StatusNotFound, `<div class="error">`+s.tr(c, "Session not found")+`</div>`)
+		return
+	}
+	u := currentUser(c)
+	if !u.HasRole(db.RoleAdmin) && !sess.belongsTo(u.ID, u.Username) {
+		s.forbidden(c)
+		return
+	}
+	if err := s.sessions.Delete(sess.Key); err != nil {
+		c.String(http.StatusInternalServerError, `<div class="error">`+s.tr(c, "Error revoking session")+`</div>`)
+		return
+	}
+	c.String(http.StatusOK, "")
+}
+
+// logoutOtherSessions ends every session of the caller except the current one
+func (s *Server) logoutOtherSessions(c *gin.Context) {
+	u := currentUser(c)
+	if _, err := s.sessions.DeleteUser(u.ID, u.Username, currentSessionKey(c)); err != nil {
+		c.String(http.StatusInternalServerError, `<div class="error">`+s.tr(c, "Error revoking session")+`</div>`)
+		return
+	}
+	s.listSessions(c)
+}
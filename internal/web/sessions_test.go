@@ -0,0 +1,152 @@
+package web
+
+import (
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    "namedot/internal/config"
+    dbm "namedot/internal/db"
+)
+
+func TestMemorySessionStore_ConcurrentAndPrune(t *testing.T) {
+    store := NewMemorySessionStore()
+    now := time.Now()
+
+    var wg sync.WaitGroup
+    for i := 0; i < 50; i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            key := sessionKey(fmt.Sprint("cookie-", i))
+            _ = store.Save(&Session{Key: key, UserID: uint(i%5 + 1), LastSeen: now, ExpiresAt: now.Add(time.Hour)})
+            _ = store.Touch(key, now)
+            _, _ = store.Get(key)
+            _, _ = store.List()
+        }(i)
+    }
+    wg.Wait()
+    if all, _ := store.List(); len(all) != 50 { t.Fatalf("want 50 sessions, got %d", len(all)) }
+
+    // DeleteUser keeps the excepted session
+    keep := sessionKey("cookie-0")
+    if n, _ := store.DeleteUser(1, "", keep); n != 9 { t.Fatalf("want 9 deleted, got %d", n) }
+    if _, err := store.Get(keep); err != nil { t.Fatalf("excepted session deleted: %v", err) }
+
+    _ = store.Save(&Session{Key: "idle", LastSeen: now.Add(-3 * time.Hour), ExpiresAt: now.Add(time.Hour)})
+    _ = store.Save(&Session{Key: "old", LastSeen: now, ExpiresAt: now.Add(-time.Second)})
+    if n, _ := store.Prune(now, 2*time.Hour); n != 2 { t.Fatalf("want 2 pruned, got %d", n) }
+    if _, err := store.Get("idle"); err != ErrSessionNotFound { t.Fatalf("idle session survived: %v", err) }
+}
+
+func TestSessionJanitor_PrunesUntilStopped(t *testing.T) {
+    store := NewMemorySessionStore()
+    _ = store.Save(&Session{Key: "old", LastSeen: time.Now(), ExpiresAt: time.Now().Add(-time.Second)})
+    stop := make(chan struct{})
+    done := make(chan struct{})
+    go func() {
+        runSessionJanitor(store, time.Millisecond, time.Hour, stop)
+        close(done)
+    }()
+    deadline := time.Now().Add(2 * time.Second)
+    for {
+        if _, err := store.Get("old"); err == ErrSessionNotFound { break }
+        if time.Now().After(deadline) { t.Fatalf("janitor did not prune the expired session") }
+        time.Sleep(time.Millisecond)
+    }
+    close(stop)
+    select {
+    case <-done:
+    case <-time.After(2 * time.Second):
+        t.Fatalf("janitor did not stop")
+    }
+}
+
+func TestSessions_IdleTimeoutLogsOut(t *testing.T) {
+    s, r := newTestWeb(t)
+    u, sid := loginAs(t, s, "idle-user", dbm.RoleViewer)
+    if w := getAs(r, sid, "/admin/zones"); w.Code != http.StatusOK { t.Fatalf("status %d", w.Code) }
+
+    addSession(s, sid, &Session{UserID: u.ID, Username: u.Username, LastSeen: time.Now().Add(-3 * time.Hour), ExpiresAt: time.Now().Add(time.Hour)})
+    if w := getAs(r, sid, "/admin/zones"); w.Code != http.StatusFound { t.Fatalf("idle session should redirect, got %d", w.Code) }
+    if _, err := s.sessions.Get(sessionKey(sid)); err != ErrSessionNotFound { t.Fatalf("idle session not removed: %v", err) }
+}
+
+func TestSessions_ListRevokeAndLogoutOthers(t *testing.T) {
+    s, r := newTestWeb(t)
+    u, sid := loginAs(t, s, "multi-device", dbm.RoleEditor)
+    addSession(s, "multi-phone", &Session{UserID: u.ID, Username: u.Username, UserAgent: "PhoneBrowser/1.0", ExpiresAt: time.Now().Add(time.Hour)})
+    addSession(s, "multi-tablet", &Session{UserID: u.ID, Username: u.Username, ExpiresAt: time.Now().Add(time.Hour)})
+    _, otherSid := loginAs(t, s, "multi-other", dbm.RoleEditor)
+
+    w := getAs(r, sid, "/admin/sessions")
+    if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "PhoneBrowser/1.0") || strings.Contains(w.Body.String(), "multi-other") {
+        t.Fatalf("list should show only own sessions: %d %s", w.Code, w.Body.String())
+    }
+
+    // Another user's session cannot be revoked by a non-admin
+    if w := sendAs(r, "DELETE", sid, "/admin/sessions/"+sessionKey(otherSid), nil); w.Code != http.StatusForbidden {
+        t.Fatalf("revoking a foreign session should be forbidden, got %d", w.Code)
+    }
+    if w := sendAs(r, "DELETE", sid, "/admin/sessions/"+sessionKey("multi-phone"), nil); w.Code != http.StatusOK {
+        t.Fatalf("revoke: %d %s", w.Code, w.Body.String())
+    }
+    if w := getAs(r, "multi-phone", "/admin/zones"); w.Code != http.StatusFound { t.Fatalf("revoked session still valid: %d", w.Code) }
+
+    if w := sendAs(r, "POST", sid, "/admin/sessions/logout-all", url.Values{}); w.Code != http.StatusOK {
+        t.Fatalf("logout others: %d %s", w.Code, w.Body.String())
+    }
+    if w := getAs(r, "multi-tablet", "/admin/zones"); w.Code != http.StatusFound { t.Fatalf("other session still valid: %d", w.Code) }
+    if w := getAs(r, sid, "/admin/zones"); w.Code != http.StatusOK { t.Fatalf("current session lost: %d", w.Code) }
+    if w := getAs(r, otherSid, "/admin/zones"); w.Code != http.StatusOK { t.Fatalf("foreign session lost: %d", w.Code) }
+}
+
+func TestSessions_DBStoreSurvivesRestart(t *testing.T) {
+    cfg := &config.Config{Admin: config.AdminConfig{Enabled: true, SessionStore: "db"}}
+    gdb := newTestDB(t)
+    if _, err := dbm.CreateUser(gdb, "persistent-user", "secret-pass", dbm.RoleViewer); err != nil { t.Fatalf("create user: %v", err) }
+
+    first, err := NewServer(cfg, gdb, nil)
+    if err != nil { t.Fatalf("new web: %v", err) }
+    r := gin.New()
+    first.RegisterRoutes(r)
+    req := httptest.NewRequest("POST", "/admin/login", strings.NewReader(url.Values{"username": {"persistent-user"}, "password": {"secret-pass"}}.Encode()))
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+    var sid string
+    for _, ck := range w.Result().Cookies() {
+        if ck.Name == "session" { sid = ck.Value }
+    }
+    if sid == "" { t.Fatalf("no session cookie: %d %s", w.Code, w.Body.String()) }
+
+    var row dbm.WebSession
+    gdb.Where("id = ?", sessionKey(sid)).First(&row)
+    if row.Username != "persistent-user" || row.ID == sid { t.Fatalf("session not stored by digest: %+v", row) }
+
+    second, err := NewServer(cfg, gdb, nil)
+    if err != nil { t.Fatalf("new web: %v", err) }
+    r2 := gin.New()
+    second.RegisterRoutes(r2)
+    if w := getAs(r2, sid, "/admin/zones"); w.Code != http.StatusOK { t.Fatalf("session lost across restart: %d", w.Code) }
+}
+
+func sendAs(r *gin.Engine, method, sid, path string, form url.Values) *httptest.ResponseRecorder {
+    if form == nil { form = url.Values{} }
+    req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    req.Header.Set("X-CSRF-Token", "csrf-"+sid)
+    req.Header.Set("Origin", "http://example.com")
+    req.Host = "example.com"
+    req.AddCookie(&http.Cookie{Name: "session", Value: sid, Path: "/admin"})
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+    return w
+}
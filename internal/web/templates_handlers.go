@@ -8,6 +8,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"namedot/internal/db"
+	"namedot/internal/idn"
+	"namedot/internal/validate"
 )
 
 func (s *Server) listTemplates(c *gin.Context) {
@@ -111,6 +113,7 @@ func (s *Server) createTemplate(c *gin.Context) {
         c.String(http.StatusInternalServerError, fmt.Sprintf(`<div class="error">`+s.tr(c, "Error creating template: %s")+`</div>`, err.Error()))
         return
     }
+    s.audit(c, db.AuditTemplateCreate, 0, nil, template)
 
 	// Redirect to edit to add records
 	c.Header("HX-Redirect", fmt.Sprintf("/admin/templates/%d/edit", template.ID))
@@ -332,6 +335,7 @@ func (s *Server) updateTemplate(c *gin.Context) {
 		return
 	}
 
+	before := s.templateSnapshot(template.ID)
 	template.Name = name
 	template.Description = description
 
@@ -339,6 +343,7 @@ func (s *Server) updateTemplate(c *gin.Context) {
         c.String(http.StatusInternalServerError, fmt.Sprintf(`<div class="error">`+s.tr(c, "Error updating template: %s")+`</div>`, err.Error()))
         return
     }
+    s.audit(c, db.AuditTemplateUpdate, 0, before, s.templateSnapshot(template.ID))
 
 	s.editTemplateForm(c)
 }
@@ -350,10 +355,14 @@ func (s *Server) deleteTemplate(c *gin.Context) {
         return
     }
 
+    before := s.templateSnapshot(uint(id))
     if err := s.db.Delete(&db.Template{}, id).Error; err != nil {
         c.String(http.StatusInternalServerError, s.tr(c, "Error deleting template"))
         return
     }
+    if before != nil {
+        s.audit(c, db.AuditTemplateDelete, 0, before, nil)
+    }
 
 	c.Status(http.StatusOK)
 }
@@ -386,6 +395,7 @@ html := fmt.Sprintf(`
                                     style="width: 100%%; padding: 0.5rem; border: 1px solid #cbd5e0; border-radius: 4px;">
                                     <option value="A">A</option>
                                     <option value="AAAA">AAAA</option>
+                                    <option value="ALIAS">ALIAS</option>
                                     <option value="CNAME">CNAME</option>
                                     <option value="MX">MX</option>
                                     <option value="TXT">TXT</option>
@@ -533,11 +543,24 @@ func (s *Server) createTemplateRecord(c *gin.Context) {
 		ttl = 300
 	}
 
+    // Validate against a sample domain; data is stored as entered to keep placeholders
+    sample := func(v string) string { return strings.ReplaceAll(v, "{domain}", "example.com") }
+    sampleName := sample(name)
+    if !strings.HasSuffix(sampleName, ".") {
+        sampleName += "."
+    }
+    set := db.RRSet{Name: sampleName, Type: recType, TTL: uint32(ttl), Records: []db.RData{{Data: sample(data)}}}
+    if errs := validate.RRSet(&set, "example.com"); len(errs) > 0 {
+        c.String(http.StatusBadRequest, s.invalidMessage(c, errs))
+        return
+    }
+
 	asn := 0
 	if asnStr != "" {
 		asn, _ = strconv.Atoi(asnStr)
 	}
 
+	before := s.templateSnapshot(uint(templateID))
 	record := db.TemplateRecord{
 		TemplateID: uint(templateID),
 		Name:       name,
@@ -554,9 +577,10 @@ func (s *Server) createTemplateRecord(c *gin.Context) {
         c.String(http.StatusInternalServerError, fmt.Sprintf(s.tr(c, "Error creating record: %s"), err.Error()))
         return
     }
+    s.audit(c, db.AuditTemplateUpdate, 0, before, s.templateSnapshot(uint(templateID)))
 
 	// Return to edit form
-	c.Params = append(c.Params, gin.Param{Key: "id", Value: fmt.Sprintf("%d", templateID)})
+	setParam(c, "id", fmt.Sprintf("%d", templateID))
 	s.editTemplateForm(c)
 }
 
@@ -567,10 +591,17 @@ func (s *Server) deleteTemplateRecord(c *gin.Context) {
 		return
 	}
 
-    if err := s.db.Delete(&db.TemplateRecord{}, id).Error; err != nil {
+    var record db.TemplateRecord
+    if err := s.db.First(&record, id).Error; err != nil {
+        c.String(http.StatusNotFound, s.tr(c, "Record not found"))
+        return
+    }
+    before := s.templateSnapshot(record.TemplateID)
+    if err := s.db.Delete(&record).Error; err != nil {
         c.String(http.StatusInternalServerError, s.tr(c, "Error deleting record"))
         return
     }
+    s.audit(c, db.AuditTemplateUpdate, 0, before, s.templateSnapshot(record.TemplateID))
 
 	c.Status(http.StatusOK)
 }
@@ -589,6 +620,9 @@ func (s *Server) applyTemplateForm(c *gin.Context) {
 
 	var zone db.Zone
 	zid, _ := strconv.ParseUint(zoneID, 10, 32)
+    if !s.authorizeZone(c, uint(zid), db.RoleEditor) {
+        return
+    }
     if err := s.db.First(&zone, zid).Error; err != nil {
         c.String(http.StatusNotFound, s.tr(c, "Zone not found"))
         return
@@ -626,7 +660,7 @@ html := fmt.Sprintf(`
 				<td>%s</td>
 				<td>%d</td>
 				<td><code>%s</code></td>
-			</tr>`, previewName, rec.Type, rec.TTL, previewData)
+			</tr>`, idn.ToUnicode(previewName), rec.Type, rec.TTL, previewData)
 	}
 
 html += fmt.Sprintf(`
@@ -661,6 +695,9 @@ func (s *Server) applyTemplate(c *gin.Context) {
         c.String(http.StatusBadRequest, s.tr(c, "Invalid zone ID"))
         return
     }
+    if !s.authorizeZone(c, uint(zoneID), db.RoleEditor) {
+        return
+    }
 
 	var template db.Template
     if err := s.db.Preload("Records").First(&template, templateID).Error; err != nil {
@@ -677,21 +714,85 @@ func (s *Server) applyTemplate(c *gin.Context) {
 	// Extract domain from zone name
 	domain := strings.TrimSuffix(zone.Name, ".")
 
-	// Apply each template record
-	for _, tplRec := range template.Records {
-		// Replace placeholders
+	// Validate the RRSets as they will be after applying the template,
+	// before creating any record
+	type rrsetKey struct{ name, rtype string }
+	prospective := map[rrsetKey]*db.RRSet{}
+	var keys []rrsetKey
+	names := make([]string, len(template.Records))
+	at := make([]int, len(template.Records))
+	for i, tplRec := range template.Records {
 		name := strings.ReplaceAll(tplRec.Name, "{domain}", domain)
-		data := strings.ReplaceAll(tplRec.Data, "{domain}", domain)
-
-		// Ensure name ends with dot
 		if !strings.HasSuffix(name, ".") {
 			name += "."
 		}
+		if a, err := idn.ToASCII(name); err == nil {
+			name = a
+		}
+		names[i] = name
+		k := rrsetKey{name, tplRec.Type}
+		set := prospective[k]
+		if set == nil {
+			set = &db.RRSet{Name: name, Type: tplRec.Type, TTL: tplRec.TTL}
+			var existing db.RRSet
+			if s.db.Preload("Records").Where("zone_id = ? AND name = ? AND type = ?", zoneID, name, tplRec.Type).First(&existing).Error == nil {
+				set.TTL, set.Records = existing.TTL, existing.Records
+			}
+			prospective[k] = set
+			keys = append(keys, k)
+		}
+		at[i] = len(set.Records)
+		set.Records = append(set.Records, db.RData{
+			Data:      strings.ReplaceAll(tplRec.Data, "{domain}", domain),
+			Country:   tplRec.Country,
+			Continent: tplRec.Continent,
+			ASN:       tplRec.ASN,
+			Subnet:    tplRec.Subnet,
+		})
+	}
+	var errs validate.Errors
+	for _, k := range keys {
+		errs = append(errs, validate.RRSet(prospective[k], zone.Name)...)
+	}
+	if len(errs) == 0 {
+		var sets []db.RRSet
+		for _, rs := range db.ZoneSnapshot(s.db, zone.ID) {
+			if prospective[rrsetKey{rs.Name, rs.Type}] == nil {
+				sets = append(sets, rs)
+			}
+		}
+		for _, k := range keys {
+			sets = append(sets, *prospective[k])
+		}
+		errs = validate.Zone(sets, zone.Name)
+	}
+	if len(errs) > 0 {
+		c.String(http.StatusBadRequest, s.invalidMessage(c, errs))
+		return
+	}
+	datas := make([]string, len(template.Records))
+	for i, tplRec := range template.Records {
+		datas[i] = prospective[rrsetKey{names[i], tplRec.Type}].Records[at[i]].Data
+	}
+
+	// RRSets touched by the template, with their state before it
+	touched := map[uint]*db.RRSet{}
+	var order []uint
+
+	// Apply each template record
+	for i, tplRec := range template.Records {
+		name := names[i]
+		data := datas[i]
 
 		// Find or create RRSet
 		var rrset db.RRSet
 		result := s.db.Where("zone_id = ? AND name = ? AND type = ?", zoneID, name, tplRec.Type).First(&rrset)
-		if result.Error != nil {
+		if result.Error == nil {
+			if _, seen := touched[rrset.ID]; !seen {
+				touched[rrset.ID] = db.RRSetSnapshot(s.db, rrset.ID)
+				order = append(order, rrset.ID)
+			}
+		} else {
 			rrset = db.RRSet{
 				ZoneID: uint(zoneID),
 				Name:   name,
@@ -701,6 +802,8 @@ func (s *Server) applyTemplate(c *gin.Context) {
 			if err := s.db.Create(&rrset).Error; err != nil {
 				continue
 			}
+			touched[rrset.ID] = nil
+			order = append(order, rrset.ID)
 		}
 
 		// Create record data
@@ -715,8 +818,14 @@ func (s *Server) applyTemplate(c *gin.Context) {
 
 		s.db.Create(&record)
 	}
+	for _, id := range order {
+		s.audit(c, db.AuditTemplateApply, zone.ID, touched[id], db.RRSetSnapshot(s.db, id))
+	}
+	if len(order) > 0 {
+		s.bumpSerial(zone.ID)
+	}
 
 	// Return to zone records
-	c.Params = append(c.Params, gin.Param{Key: "id", Value: fmt.Sprintf("%d", zoneID)})
+	setParam(c, "id", fmt.Sprintf("%d", zoneID))
 	s.listRecords(c)
 }
@@ -0,0 +1,277 @@
+package web
+
+import (
+	"errors"
+	"html"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"namedot/internal/authguard"
+	"namedot/internal/db"
+	"namedot/internal/totp"
+)
+
+// mfaLoginTTL bounds the time between the password and the second factor
+const mfaLoginTTL = 5 * time.Minute
+
+// totpIssuer names the account in authenticator apps
+const totpIssuer = "namedot"
+
+// enrollmentPaths stay reachable for users who must enroll before anything else
+var enrollmentPaths = map[string]bool{
+	"/admin/2fa":        true,
+	"/admin/2fa/enable": true,
+	"/admin/logout":     true,
+}
+
+// mustEnroll reports whether require_2fa blocks the user until they enroll.
+// The config file admin is covered by admin.totp_secret and SSO users by
+// their identity provider.
+func (s *Server) mustEnroll(u *db.User) bool {
+	return s.cfg.Admin.Require2FA && u.ID != 0 && u.Source == "" && !u.TOTPEnabled
+}
+
+// pendingSession returns the login of the request waiting for its second factor
+func (s *Server) pendingSession(c *gin.Context) (*Session, bool) {
+	cookie, err := c.Cookie("session")
+	if err != nil {
+		return nil, false
+	}
+	sess, err := s.sessions.Get(sessionKey(cookie))
+	if err != nil || !sess.MFAPending || sess.Expired(time.Now(), 0) {
+		return nil, false
+	}
+	return sess, true
+}
+
+func (s *Server) twoFactorPage(c *gin.Context) {
+	if _, ok := s.pendingSession(c); !ok {
+		c.Redirect(http.StatusFound, "/admin/login")
+		return
+	}
+	s.renderLoginStep(c, http.StatusOK, "", true)
+}
+
+// twoFactorSubmit finishes a login with a TOTP or recovery code
+func (s *Server) twoFactorSubmit(c *gin.Context) {
+	loginError := func(status int, msg string) {
+		c.Header("HX-Retarget", "#error")
+		c.Header("HX-Reswap", "innerHTML")
+		c.String(status, `<div class="error">`+msg+`</div>`)
+	}
+	sess, ok := s.pendingSession(c)
+	if !ok {
+		c.Header("HX-Redirect", "/admin/login")
+		loginError(http.StatusUnauthorized, s.tr(c, "Login session expired, please try again"))
+		return
+	}
+
+	attempt := authguard.Attempt{Source: "web", IP: c.ClientIP(), Username: sess.Username}
+	if wait := s.guard.Check(attempt); wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		loginError(http.StatusTooManyRequests, s.trf(c, "Too many failed attempts, try again in %d seconds", int(wait.Seconds())+1))
+		return
+	}
+	if err := s.verifyLoginCode(sess, c.PostForm("code")); err != nil {
+		s.guard.Fail(attempt)
+		loginError(http.StatusUnauthorized, s.tr(c, "Invalid authentication code"))
+		return
+	}
+	s.guard.Succeed(attempt)
+
+	// Replace the pending session with a fresh one
+	s.sessions.Delete(sess.Key)
+	if err := s.startSession(c, sess.UserID, sess.Username); err != nil {
+		loginError(http.StatusInternalServerError, s.tr(c, "Error creating session"))
+		return
+	}
+	c.Header("HX-Redirect", "/admin")
+	c.Status(http.StatusOK)
+}
+
+// verifyLoginCode checks the second factor of the account behind a pending login
+func (s *Server) verifyLoginCode(sess *Session, code string) error {
+	if sess.UserID == 0 {
+		return s.verifyConfigAdminCode(code)
+	}
+	var u db.User
+	if err := s.db.Limit(1).Find(&u, sess.UserID).Error; err != nil || u.ID == 0 || u.Disabled {
+		return db.ErrInvalidCode
+	}
+	return db.VerifySecondFactor(s.db, &u, code, time.Now())
+}
+
+// verifyConfigAdminCode checks a code against admin.totp_secret. The last
+// accepted step is kept in memory, so codes cannot be replayed.
+func (s *Server) verifyConfigAdminCode(code string) error {
+	s.totpMu.Lock()
+	defer s.totpMu.Unlock()
+	step, ok := totp.Validate(s.cfg.Admin.TOTPSecret, code, time.Now(), s.adminTOTPStep)
+	if !ok {
+		return db.ErrInvalidCode
+	}
+	s.adminTOTPStep = step
+	return nil
+}
+
+// renderTwoFactor shows the two-factor settings of the current user
+func (s *Server) renderTwoFactor(c *gin.Context, status int, extra gin.H) {
+	u := currentUser(c)
+	if u.ID != 0 {
+		var fresh db.User
+		if err := s.db.First(&fresh, u.ID).Error; err == nil {
+			u = &fresh
+		}
+	}
+	data := gin.H{
+		"Lang":       s.getLang(c),
+		"Username":   u.Username,
+		"CSRFToken":  c.GetString("csrf_token"),
+		"Required":   s.cfg.Admin.Require2FA,
+		"MustEnroll": s.mustEnroll(u),
+	}
+	switch {
+	case u.ID == 0:
+		data["Mode"] = "config"
+		data["Enabled"] = s.cfg.Admin.TOTPSecret != ""
+	case u.Source != "":
+		data["Mode"] = "sso"
+	case u.TOTPEnabled:
+		data["Mode"] = "enabled"
+		data["Remaining"] = s.trf(c, "%d unused recovery codes left.", db.RemainingRecoveryCodes(s.db, u.ID))
+	default:
+		data["Mode"] = "enroll"
+		secret, err := db.BeginTOTPEnrollment(s.db, u.ID)
+		if err != nil {
+			c.String(http.StatusInternalServerError, s.tr(c, "Error starting two-factor enrollment"))
+			return
+		}
+		// The otpauth link opens authenticator apps on the device itself
+		data["URI"] = template.URL(totp.URI(totpIssuer, u.Username, secret))
+		data["Secret"] = groupSecret(secret)
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	s.tmpl.ExecuteTemplate(c.Writer, "twofactor.html", data)
+}
+
+// groupSecret splits a base32 secret into blocks of four for manual entry
+func groupSecret(secret string) string {
+	var parts []string
+	for len(secret) > 4 {
+		parts = append(parts, secret[:4])
+		secret = secret[4:]
+	}
+	return strings.Join(append(parts, secret), " ")
+}
+
+func (s *Server) twoFactorSettings(c *gin.Context) {
+	s.renderTwoFactor(c, http.StatusOK, nil)
+}
+
+// localUser returns the current user if it manages its own second factor
+func (s *Server) localUser(c *gin.Context) (*db.User, bool) {
+	u := currentUser(c)
+	if u.ID == 0 || u.Source != "" {
+		s.forbidden(c)
+		return nil, false
+	}
+	var fresh db.User
+	if err := s.db.First(&fresh, u.ID).Error; err != nil {
+		s.forbidden(c)
+		return nil, false
+	}
+	return &fresh, true
+}
+
+// checkCode verifies a code of the current user for a settings change,
+// throttled like logins. It renders the error and returns false on failure.
+func (s *Server) checkCode(c *gin.Context, u *db.User) bool {
+	attempt := authguard.Attempt{Source: "web", IP: c.ClientIP(), Username: u.Username}
+	if wait := s.guard.Check(attempt); wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		s.renderTwoFactor(c, http.StatusTooManyRequests, gin.H{"Error": s.trf(c, "Too many failed attempts, try again in %d seconds", int(wait.Seconds())+1)})
+		return false
+	}
+	if err := db.VerifySecondFactor(s.db, u, c.PostForm("code"), time.Now()); err != nil {
+		s.guard.Fail(attempt)
+		s.renderTwoFactor(c, http.StatusBadRequest, gin.H{"Error": s.tr(c, "Invalid authentication code")})
+		return false
+	}
+	s.guard.Succeed(attempt)
+	return true
+}
+
+// enableTOTP confirms the enrollment and shows the recovery codes once
+func (s *Server) enableTOTP(c *gin.Context) {
+	u, ok := s.localUser(c)
+	if !ok {
+		return
+	}
+	codes, err := db.EnableTOTP(s.db, u.ID, c.PostForm("code"), time.Now())
+	if errors.Is(err, db.ErrInvalidCode) {
+		s.renderTwoFactor(c, http.StatusBadRequest, gin.H{"Error": s.tr(c, "Invalid authentication code")})
+		return
+	}
+	if err != nil {
+		s.renderTwoFactor(c, http.StatusBadRequest, gin.H{"Error": err.Error()})
+		return
+	}
+	s.renderTwoFactor(c, http.StatusOK, gin.H{"RecoveryCodes": codes})
+}
+
+func (s *Server) disableTOTP(c *gin.Context) {
+	u, ok := s.localUser(c)
+	if !ok {
+		return
+	}
+	if s.cfg.Admin.Require2FA {
+		s.renderTwoFactor(c, http.StatusForbidden, gin.H{"Error": s.tr(c, "Two-factor authentication is required for all users")})
+		return
+	}
+	if !s.checkCode(c, u) {
+		return
+	}
+	if err := db.DisableTOTP(s.db, u.ID); err != nil {
+		s.renderTwoFactor(c, http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		return
+	}
+	s.renderTwoFactor(c, http.StatusOK, gin.H{"Message": s.tr(c, "Two-factor authentication disabled")})
+}
+
+func (s *Server) regenerateRecoveryCodes(c *gin.Context) {
+	u, ok := s.localUser(c)
+	if !ok {
+		return
+	}
+	if !s.checkCode(c, u) {
+		return
+	}
+	codes, err := db.RegenerateRecoveryCodes(s.db, u.ID)
+	if err != nil {
+		s.renderTwoFactor(c, http.StatusInternalServerError, gin.H{"Error": err.Error()})
+		return
+	}
+	s.renderTwoFactor(c, http.StatusOK, gin.H{"RecoveryCodes": codes})
+}
+
+// resetUserTOTP removes the second factor of a user who lost their device
+func (s *Server) resetUserTOTP(c *gin.Context) {
+	u, ok := s.loadUser(c)
+	if !ok {
+		return
+	}
+	if err := db.DisableTOTP(s.db, u.ID); err != nil {
+		c.String(http.StatusInternalServerError, `<div class="error">`+html.EscapeString(err.Error())+`</div>`)
+		return
+	}
+	c.String(http.StatusOK, `<em>`+s.tr(c, "Two-factor authentication reset")+`</em>`)
+}
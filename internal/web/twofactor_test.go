@@ -0,0 +1,137 @@
+package web
+
+import (
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    dbm "namedot/internal/db"
+    "namedot/internal/totp"
+)
+
+// enrollTOTP enables TOTP for a user, spending the code of the previous step
+// so the current one is still valid for a login
+func enrollTOTP(t *testing.T, s *Server, userID uint) (string, []string) {
+    t.Helper()
+    secret, err := dbm.BeginTOTPEnrollment(s.db, userID)
+    if err != nil { t.Fatalf("begin: %v", err) }
+    past := time.Now().Add(-totp.Period)
+    code, _ := totp.Code(secret, past)
+    codes, err := dbm.EnableTOTP(s.db, userID, code, past)
+    if err != nil { t.Fatalf("enable: %v", err) }
+    return secret, codes
+}
+
+func postLogin(r *gin.Engine, path string, form url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+    req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    if cookie != nil { req.AddCookie(cookie) }
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+    return w
+}
+
+func sessionCookie(t *testing.T, w *httptest.ResponseRecorder) *http.Cookie {
+    t.Helper()
+    for _, c := range w.Result().Cookies() {
+        if c.Name == "session" { return c }
+    }
+    t.Fatalf("no session cookie set")
+    return nil
+}
+
+func TestTwoFactor_LoginNeedsCode(t *testing.T) {
+    s, r := newTestWeb(t)
+    u, err := dbm.CreateUser(s.db, "mfa-login", "right-password", dbm.RoleViewer)
+    if err != nil { t.Fatalf("create user: %v", err) }
+    secret, recovery := enrollTOTP(t, s, u.ID)
+    password := url.Values{"username": {"mfa-login"}, "password": {"right-password"}}
+
+    w := postLogin(r, "/admin/login", password, nil)
+    if w.Code != http.StatusOK || w.Header().Get("HX-Redirect") != "/admin/login/2fa" {
+        t.Fatalf("password step should lead to the code step, got %d %q", w.Code, w.Header().Get("HX-Redirect"))
+    }
+    pending := sessionCookie(t, w)
+    if w := getAs(r, pending.Value, "/admin/zones"); w.Code != http.StatusFound || w.Header().Get("Location") != "/admin/login/2fa" {
+        t.Fatalf("pending login must not reach the admin, got %d %q", w.Code, w.Header().Get("Location"))
+    }
+    if w := getAs(r, pending.Value, "/admin/login/2fa"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `name="code"`) {
+        t.Fatalf("code page: %d", w.Code)
+    }
+
+    if w := postLogin(r, "/admin/login/2fa", url.Values{"code": {"000000"}}, pending); w.Code != http.StatusUnauthorized {
+        t.Fatalf("wrong code: want 401, got %d", w.Code)
+    }
+    code, _ := totp.Code(secret, time.Now())
+    w = postLogin(r, "/admin/login/2fa", url.Values{"code": {code}}, pending)
+    if w.Code != http.StatusOK || w.Header().Get("HX-Redirect") != "/admin" {
+        t.Fatalf("right code should log in, got %d: %s", w.Code, w.Body.String())
+    }
+    full := sessionCookie(t, w)
+    if full.Value == pending.Value { t.Fatalf("session must be renewed after the second factor") }
+    if w := getAs(r, full.Value, "/admin/zones"); w.Code != http.StatusOK { t.Fatalf("zones after login: %d", w.Code) }
+
+    // The same code cannot be used again, a recovery code can once
+    pending = sessionCookie(t, postLogin(r, "/admin/login", password, nil))
+    if w := postLogin(r, "/admin/login/2fa", url.Values{"code": {code}}, pending); w.Code != http.StatusUnauthorized {
+        t.Fatalf("replayed code: want 401, got %d", w.Code)
+    }
+    if w := postLogin(r, "/admin/login/2fa", url.Values{"code": {recovery[0]}}, pending); w.Code != http.StatusOK {
+        t.Fatalf("recovery code: want 200, got %d", w.Code)
+    }
+    pending = sessionCookie(t, postLogin(r, "/admin/login", password, nil))
+    if w := postLogin(r, "/admin/login/2fa", url.Values{"code": {recovery[0]}}, pending); w.Code != http.StatusUnauthorized {
+        t.Fatalf("used recovery code: want 401, got %d", w.Code)
+    }
+}
+
+func TestTwoFactor_RequiredForcesEnrollment(t *testing.T) {
+    s, r := newTestWeb(t)
+    s.cfg.Admin.Require2FA = true
+    u, sid := loginAs(t, s, "mfa-required", dbm.RoleEditor)
+
+    if w := getAs(r, sid, "/admin/zones"); w.Code != http.StatusFound || w.Header().Get("Location") != "/admin/2fa" {
+        t.Fatalf("want redirect to enrollment, got %d %q", w.Code, w.Header().Get("Location"))
+    }
+    w := getAs(r, sid, "/admin/2fa")
+    if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `href="otpauth://totp/`) {
+        t.Fatalf("enrollment page should link the otpauth URI, got %d", w.Code)
+    }
+
+    var fresh dbm.User
+    s.db.First(&fresh, u.ID)
+    code, _ := totp.Code(fresh.TOTPSecret, time.Now())
+    w = sendAs(r, "POST", sid, "/admin/2fa/enable", url.Values{"code": {code}})
+    if w.Code != http.StatusOK || strings.Count(w.Body.String(), "-") < dbm.RecoveryCodeCount {
+        t.Fatalf("enable should show recovery codes, got %d", w.Code)
+    }
+    if w := getAs(r, sid, "/admin/zones"); w.Code != http.StatusOK { t.Fatalf("zones after enrollment: %d", w.Code) }
+    if w := sendAs(r, "POST", sid, "/admin/2fa/disable", url.Values{"code": {code}}); w.Code != http.StatusForbidden {
+        t.Fatalf("disable must be refused while required, got %d", w.Code)
+    }
+}
+
+func TestTwoFactor_AdminReset(t *testing.T) {
+    s, r := newTestWeb(t)
+    _, adminSid := loginAs(t, s, "mfa-admin", dbm.RoleAdmin)
+    _, editorSid := loginAs(t, s, "mfa-editor", dbm.RoleEditor)
+    target, err := dbm.CreateUser(s.db, "mfa-lost-phone", "password123", dbm.RoleViewer)
+    if err != nil { t.Fatalf("create user: %v", err) }
+    enrollTOTP(t, s, target.ID)
+    path := fmt.Sprintf("/admin/users/%d/2fa", target.ID)
+
+    if w := sendAs(r, "DELETE", editorSid, path, nil); w.Code != http.StatusForbidden {
+        t.Fatalf("editor reset: want 403, got %d", w.Code)
+    }
+    if w := sendAs(r, "DELETE", adminSid, path, nil); w.Code != http.StatusOK { t.Fatalf("admin reset: %d", w.Code) }
+    s.db.First(&target, target.ID)
+    if target.TOTPEnabled || dbm.RemainingRecoveryCodes(s.db, target.ID) != 0 {
+        t.Fatalf("reset should remove the second factor")
+    }
+}
@@ -0,0 +1,363 @@
+package web
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"namedot/internal/db"
+	"namedot/internal/idn"
+)
+
+// currentUser returns the user of the session, set by authMiddleware
+func currentUser(c *gin.Context) *db.User {
+	if v, ok := c.Get("user"); ok {
+		if u, ok2 := v.(*db.User); ok2 {
+			return u
+		}
+	}
+	return &db.User{}
+}
+
+// sessionUser resolves the account behind a session. Sessions without a
+// user ID belong to the admin from the config file.
+func (s *Server) sessionUser(session *Session) *db.User {
+	if session.UserID == 0 {
+		if s.cfg.Admin.Username == "" || session.Username != s.cfg.Admin.Username {
+			return nil
+		}
+		return &db.User{Username: session.Username, Role: db.RoleAdmin}
+	}
+	var u db.User
+	if err := s.db.Limit(1).Find(&u, session.UserID).Error; err != nil || u.ID == 0 || u.Disabled {
+		return nil
+	}
+	return &u
+}
+
+// requireRole rejects users below role
+func (s *Server) requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !currentUser(c).HasRole(role) {
+			s.forbidden(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+func (s *Server) forbidden(c *gin.Context) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.String(http.StatusForbidden, `<div class="error">`+s.tr(c, "You do not have permission for this action")+`</div>`)
+	c.Abort()
+}
+
+// canAccessZone reports whether the user may see zoneID; admins see all zones
+func (s *Server) canAccessZone(u *db.User, zoneID uint) bool {
+	if u.HasRole(db.RoleAdmin) {
+		return true
+	}
+	return u.ID != 0 && !u.Disabled && db.OwnsZone(s.db, u.ID, zoneID)
+}
+
+// authorizeZone checks that the caller has role and access to zoneID, and
+// writes a 403 response otherwise.
+func (s *Server) authorizeZone(c *gin.Context, zoneID uint, role string) bool {
+	u := currentUser(c)
+	if !u.HasRole(role) || !s.canAccessZone(u, zoneID) {
+		s.forbidden(c)
+		return false
+	}
+	return true
+}
+
+// scopeZones restricts a zone query to the zones visible to the caller
+func (s *Server) scopeZones(c *gin.Context, q *gorm.DB) *gorm.DB {
+	u := currentUser(c)
+	if u.HasRole(db.RoleAdmin) {
+		return q
+	}
+	return q.Where("id IN (?)", db.OwnedZoneIDs(s.db, u.ID))
+}
+
+func (s *Server) listUsers(c *gin.Context) {
+	var users []db.User
+	if err := s.db.Preload("Zones").Order("username").Find(&users).Error; err != nil {
+		c.String(http.StatusInternalServerError, s.tr(c, "Error loading users"))
+		return
+	}
+
+	out := `<table>
+        <thead>
+            <tr>
+                <th>` + s.tr(c, "Username") + `</th>
+                <th>` + s.tr(c, "Role") + `</th>
+                <th>` + s.tr(c, "Zones") + `</th>
+                <th>` + s.tr(c, "Status") + `</th>
+                <th>` + s.tr(c, "Actions") + `</th>
+            </tr>
+        </thead>
+        <tbody>`
+	if s.cfg.Admin.Username != "" {
+		out += fmt.Sprintf(`
+            <tr>
+                <td><strong>%s</strong></td>
+                <td>%s</td>
+                <td>%s</td>
+                <td><em>%s</em></td>
+                <td></td>
+            </tr>`, html.EscapeString(s.cfg.Admin.Username), s.tr(c, "admin"), s.tr(c, "All zones"), s.tr(c, "Config file"))
+	}
+	for _, u := range users {
+		zones := s.tr(c, "All zones")
+		if u.Role != db.RoleAdmin {
+			zones = strconv.Itoa(len(u.Zones))
+		}
+		role := s.tr(c, u.Role)
+		if u.Source != "" {
+			role += " (SSO)"
+		}
+		status := s.tr(c, "Active")
+		if u.Disabled {
+			status = s.tr(c, "Disabled")
+		}
+		if u.TOTPEnabled {
+			status += " · 2FA"
+		}
+		out += fmt.Sprintf(`
+            <tr>
+                <td><strong>%s</strong></td>
+                <td>%s</td>
+                <td>%s</td>
+                <td>%s</td>
+                <td class="actions">
+                    <button class="btn btn-sm" hx-get="/admin/users/%d/edit" hx-target="#users-content" hx-swap="innerHTML">
+                        %s
+                    </button>
+                    <button class="btn btn-sm btn-danger"
+                        hx-delete="/admin/users/%d"
+                        hx-confirm="%s"
+                        hx-target="closest tr"
+                        hx-swap="outerHTML">
+                        %s
+                    </button>
+                </td>
+            </tr>`, html.EscapeString(u.Username), role, zones, status,
+			u.ID, s.tr(c, "Edit"), u.ID, html.EscapeString(s.trf(c, "Delete user %s?", u.Username)), s.tr(c, "Delete"))
+	}
+	out += `</tbody></table>`
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.String(http.StatusOK, out)
+}
+
+func (s *Server) roleSelect(c *gin.Context, current string) string {
+	opts := make([]option, 0, len(db.Roles))
+	for _, r := range db.Roles {
+		opts = append(opts, option{r, s.tr(c, r)})
+	}
+	return selectInput("role", current, opts...)
+}
+
+func (s *Server) newUserForm(c *gin.Context) {
+	out := `
+    <div style="background: #f7fafc; padding: 1.5rem; border-radius: 4px; margin-bottom: 1rem;">
+        <h3>` + s.tr(c, "Create New User") + `</h3>
+        <form hx-post="/admin/users" hx-target="#users-content" hx-swap="innerHTML" style="margin-top: 1rem;">
+            <div style="display: grid; grid-template-columns: 1fr 1fr 1fr; gap: 1rem;">
+                ` + s.formField(c, "Username", textInput("username", "", "", true)) + `
+                <div><label>` + s.tr(c, "Password") + `</label><input type="password" name="password" minlength="8" required style="` + inputStyle + `"></div>
+                ` + s.formField(c, "Role", s.roleSelect(c, db.RoleViewer)) + `
+            </div>
+            <div style="display: flex; gap: 1rem; margin-top: 1rem;">
+                <button type="submit" class="btn">` + s.tr(c, "Create") + `</button>
+                <button type="button" class="btn" style="background: #718096;"
+                    hx-get="/admin/users" hx-target="#users-content" hx-swap="innerHTML">
+                    ` + s.tr(c, "Cancel") + `
+                </button>
+            </div>
+        </form>
+    </div>
+    <div hx-get="/admin/users" hx-trigger="load" hx-swap="innerHTML"></div>
+    `
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.String(http.StatusOK, out)
+}
+
+func (s *Server) createUser(c *gin.Context) {
+	username := c.PostForm("username")
+	if username == s.cfg.Admin.Username {
+		c.String(http.StatusBadRequest, `<div class="error">`+s.tr(c, "Username is taken by the config file admin")+`</div>`)
+		return
+	}
+	if _, err := db.CreateUser(s.db, username, c.PostForm("password"), c.PostForm("role")); err != nil {
+		c.String(http.StatusBadRequest, `<div class="error">`+html.EscapeString(s.trf(c, "Error creating user: %s", err.Error()))+`</div>`)
+		return
+	}
+	s.listUsers(c)
+}
+
+func (s *Server) loadUser(c *gin.Context) (*db.User, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, s.tr(c, "Invalid user ID"))
+		return nil, false
+	}
+	var u db.User
+	if err := s.db.Preload("Zones").First(&u, id).Error; err != nil {
+		c.String(http.StatusNotFound, s.tr(c, "User not found"))
+		return nil, false
+	}
+	return &u, true
+}
+
+func (s *Server) editUserForm(c *gin.Context) {
+	u, ok := s.loadUser(c)
+	if !ok {
+		return
+	}
+	owned := map[uint]bool{}
+	for _, z := range u.Zones {
+		owned[z.ID] = true
+	}
+	var zones []db.Zone
+	s.db.Order("name").Find(&zones)
+
+	disabled := ""
+	if u.Disabled {
+		disabled = " checked"
+	}
+	zoneBoxes := ""
+	for _, z := range zones {
+		checked := ""
+		if owned[z.ID] {
+			checked = " checked"
+		}
+		label := idn.ToUnicode(z.Name)
+		if z.View != "" {
+			label += " (" + z.View + ")"
+		}
+		zoneBoxes += fmt.Sprintf(`<label style="display: block;"><input type="checkbox" name="zones" value="%d"%s> %s</label>`,
+			z.ID, checked, html.EscapeString(label))
+	}
+	if zoneBoxes == "" {
+		zoneBoxes = `<em>` + s.tr(c, "No zones found. Create your first zone!") + `</em>`
+	}
+	reset2FA := ""
+	if u.TOTPEnabled {
+		reset2FA = fmt.Sprintf(`<button type="button" class="btn btn-danger" hx-delete="/admin/users/%d/2fa" hx-confirm="%s" hx-swap="outerHTML">%s</button>`,
+			u.ID, html.EscapeString(s.trf(c, "Reset two-factor authentication of %s?", u.Username)), s.tr(c, "Reset 2FA"))
+	}
+
+	out := fmt.Sprintf(`
+    <div style="background: #f7fafc; padding: 1.5rem; border-radius: 4px; margin-bottom: 1rem;">
+        <h3>%s</h3>
+        <form hx-put="/admin/users/%d" hx-target="#users-content" hx-swap="innerHTML" style="margin-top: 1rem;">
+            <div style="display: grid; grid-template-columns: 1fr 1fr 1fr; gap: 1rem;">
+                %s
+                <div><label>%s</label><input type="password" name="password" minlength="8" placeholder="%s" style="%s"></div>
+                <div><label><input type="checkbox" name="disabled" value="1"%s> %s</label></div>
+            </div>
+            <div style="margin-top: 1rem;">
+                <label>%s</label>
+                <div style="max-height: 16rem; overflow-y: auto; border: 1px solid #cbd5e0; border-radius: 4px; padding: 0.5rem; background: white;">%s</div>
+                <small style="color: #718096;">%s</small>
+            </div>
+            <div style="display: flex; gap: 1rem; margin-top: 1rem;">
+                <button type="submit" class="btn">%s</button>
+                <button type="button" class="btn" style="background: #718096;"
+                    hx-get="/admin/users" hx-target="#users-content" hx-swap="innerHTML">
+                    %s
+                </button>
+                %s
+            </div>
+        </form>
+    </div>`,
+		html.EscapeString(s.trf(c, "Edit User %s", u.Username)), u.ID,
+		s.formField(c, "Role", s.roleSelect(c, u.Role)),
+		s.tr(c, "New Password"), s.tr(c, "Leave empty to keep"), inputStyle,
+		disabled, s.tr(c, "Disabled"),
+		s.tr(c, "Owned Zones"), zoneBoxes, s.tr(c, "Admins can access all zones"),
+		s.tr(c, "Save"), s.tr(c, "Cancel"), reset2FA)
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.String(http.StatusOK, out)
+}
+
+func (s *Server) updateUser(c *gin.Context) {
+	u, ok := s.loadUser(c)
+	if !ok {
+		return
+	}
+	role, err := db.ParseRole(c.PostForm("role"))
+	if err != nil {
+		c.String(http.StatusBadRequest, `<div class="error">`+html.EscapeString(err.Error())+`</div>`)
+		return
+	}
+	disabled := c.PostForm("disabled") != ""
+	if u.ID == currentUser(c).ID && (disabled || role != db.RoleAdmin) {
+		c.String(http.StatusBadRequest, `<div class="error">`+s.tr(c, "You cannot demote or disable yourself")+`</div>`)
+		return
+	}
+
+	var zoneIDs []uint
+	for _, v := range c.PostFormArray("zones") {
+		if id, err := strconv.ParseUint(v, 10, 32); err == nil {
+			zoneIDs = append(zoneIDs, uint(id))
+		}
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(u).Updates(map[string]any{"role": role, "disabled": disabled}).Error; err != nil {
+			return err
+		}
+		if p := c.PostForm("password"); p != "" {
+			if err := db.SetUserPassword(tx, u.ID, p); err != nil {
+				return err
+			}
+		}
+		return db.SetUserZones(tx, u.ID, zoneIDs)
+	})
+	if err != nil {
+		c.String(http.StatusBadRequest, `<div class="error">`+html.EscapeString(s.trf(c, "Error updating user: %s", err.Error()))+`</div>`)
+		return
+	}
+	if disabled {
+		s.dropUserSessions(u.ID)
+	}
+	s.listUsers(c)
+}
+
+func (s *Server) deleteUser(c *gin.Context) {
+	u, ok := s.loadUser(c)
+	if !ok {
+		return
+	}
+	if u.ID == currentUser(c).ID {
+		c.String(http.StatusBadRequest, `<div class="error">`+s.tr(c, "You cannot delete yourself")+`</div>`)
+		return
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := db.SetUserZones(tx, u.ID, nil); err != nil {
+			return err
+		}
+		if err := db.DisableTOTP(tx, u.ID); err != nil {
+			return err
+		}
+		return tx.Delete(u).Error
+	})
+	if err != nil {
+		c.String(http.StatusInternalServerError, s.tr(c, "Error deleting user"))
+		return
+	}
+	s.dropUserSessions(u.ID)
+	c.Status(http.StatusOK)
+}
+
+// dropUserSessions logs out every session of userID
+func (s *Server) dropUserSessions(userID uint) {
+	s.sessions.DeleteUser(userID, "", "")
+}
@@ -0,0 +1,161 @@
+package web
+
+import (
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    dbm "namedot/internal/db"
+)
+
+// loginAs injects a session for a new database user with role
+func loginAs(t *testing.T, s *Server, username, role string) (dbm.User, string) {
+    t.Helper()
+    u, err := dbm.CreateUser(s.db, username, "password123", role)
+    if err != nil { t.Fatalf("create user: %v", err) }
+    sid := "sess-" + username
+    addSession(s, sid, &Session{UserID: u.ID, Username: username, CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour), CSRFToken: "csrf-" + sid})
+    return u, sid
+}
+
+func getAs(r *gin.Engine, sid, path string) *httptest.ResponseRecorder {
+    req := httptest.NewRequest("GET", path, nil)
+    req.AddCookie(&http.Cookie{Name: "session", Value: sid, Path: "/admin"})
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+    return w
+}
+
+func TestUsers_ViewerSeesOnlyOwnedZonesReadOnly(t *testing.T) {
+    s, r := newTestWeb(t)
+    viewer, sid := loginAs(t, s, "viewer-one", dbm.RoleViewer)
+    owned := dbm.Zone{Name: "viewer-owned.test."}
+    other := dbm.Zone{Name: "viewer-other.test."}
+    s.db.Create(&owned)
+    s.db.Create(&other)
+    if err := dbm.GrantZone(s.db, viewer.ID, owned.ID); err != nil { t.Fatalf("grant: %v", err) }
+
+    w := getAs(r, sid, "/admin/zones?search=viewer-")
+    if w.Code != http.StatusOK { t.Fatalf("status %d", w.Code) }
+    if !strings.Contains(w.Body.String(), owned.Name) || strings.Contains(w.Body.String(), other.Name) {
+        t.Fatalf("viewer should only list owned zones: %s", w.Body.String())
+    }
+
+    if w := getAs(r, sid, fmt.Sprintf("/admin/zones/%d/records", owned.ID)); w.Code != http.StatusOK {
+        t.Fatalf("viewer should read owned zone, got %d", w.Code)
+    }
+    if w := getAs(r, sid, fmt.Sprintf("/admin/zones/%d/records", other.ID)); w.Code != http.StatusForbidden {
+        t.Fatalf("viewer must not read other zones, got %d", w.Code)
+    }
+    form := url.Values{"name": {"www"}, "type": {"A"}, "ttl": {"300"}, "data": {"192.0.2.1"}}
+    if w := postRecordForm(r, sid, fmt.Sprintf("/admin/zones/%d/records", owned.ID), form); w.Code != http.StatusForbidden {
+        t.Fatalf("viewer must not create records, got %d", w.Code)
+    }
+    if w := getAs(r, sid, "/admin/"); !strings.Contains(w.Body.String(), "(viewer)") || strings.Contains(w.Body.String(), "/admin/zones/new") {
+        t.Fatalf("dashboard should show the role and hide editing: %s", w.Body.String())
+    }
+}
+
+func TestUsers_EditorLimitedToOwnedZones(t *testing.T) {
+    s, r := newTestWeb(t)
+    editor, sid := loginAs(t, s, "editor-one", dbm.RoleEditor)
+    owned := dbm.Zone{Name: "editor-owned.test."}
+    other := dbm.Zone{Name: "editor-other.test."}
+    s.db.Create(&owned)
+    s.db.Create(&other)
+    dbm.GrantZone(s.db, editor.ID, owned.ID)
+
+    form := url.Values{"name": {"www"}, "type": {"A"}, "ttl": {"300"}, "data": {"192.0.2.1"}}
+    if w := postRecordForm(r, sid, fmt.Sprintf("/admin/zones/%d/records", owned.ID), form); w.Code != http.StatusOK {
+        t.Fatalf("editor should create records in owned zone, got %d: %s", w.Code, w.Body.String())
+    }
+    if w := postRecordForm(r, sid, fmt.Sprintf("/admin/zones/%d/records", other.ID), form); w.Code != http.StatusForbidden {
+        t.Fatalf("editor must not write other zones, got %d", w.Code)
+    }
+
+    // Records of other zones cannot be edited through their own IDs either
+    set := dbm.RRSet{ZoneID: other.ID, Name: "www.editor-other.test.", Type: "A", TTL: 300, Records: []dbm.RData{{Data: "192.0.2.9"}}}
+    s.db.Create(&set)
+    if w := getAs(r, sid, fmt.Sprintf("/admin/records/%d/edit", set.Records[0].ID)); w.Code != http.StatusForbidden {
+        t.Fatalf("editor must not edit foreign records, got %d", w.Code)
+    }
+
+    // Zones created by an editor belong to them
+    w := postRecordForm(r, sid, "/admin/zones", url.Values{"name": {"editor-new.test"}})
+    if w.Code != http.StatusOK { t.Fatalf("create zone: %d %s", w.Code, w.Body.String()) }
+    var created dbm.Zone
+    if err := s.db.Where("name = ?", "editor-new.test.").First(&created).Error; err != nil { t.Fatalf("zone not created: %v", err) }
+    if !dbm.OwnsZone(s.db, editor.ID, created.ID) { t.Fatalf("editor should own the created zone") }
+
+    // Admin-only areas
+    if w := getAs(r, sid, "/admin/users"); w.Code != http.StatusForbidden { t.Fatalf("users page needs admin, got %d", w.Code) }
+    if w := postRecordForm(r, sid, "/admin/templates", url.Values{"name": {"tpl"}}); w.Code != http.StatusForbidden {
+        t.Fatalf("template changes need admin, got %d", w.Code)
+    }
+}
+
+func TestUsers_AdminManagesUsersAndLogin(t *testing.T) {
+    s, r := newTestWeb(t)
+    _, sid := loginAs(t, s, "admin-one", dbm.RoleAdmin)
+
+    w := postRecordForm(r, sid, "/admin/users", url.Values{"username": {"new-editor"}, "password": {"secret-pass"}, "role": {"editor"}})
+    if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "new-editor") {
+        t.Fatalf("create user: %d %s", w.Code, w.Body.String())
+    }
+    if w := postRecordForm(r, sid, "/admin/users", url.Values{"username": {"admin"}, "password": {"secret-pass"}, "role": {"viewer"}}); w.Code != http.StatusBadRequest {
+        t.Fatalf("config admin name must be reserved, got %d", w.Code)
+    }
+
+    req := httptest.NewRequest("POST", "/admin/login", strings.NewReader(url.Values{"username": {"new-editor"}, "password": {"secret-pass"}}.Encode()))
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    w = httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+    if w.Code != http.StatusOK || w.Header().Get("HX-Redirect") != "/admin" {
+        t.Fatalf("database user login failed: %d %s", w.Code, w.Body.String())
+    }
+
+    // Disabling a user ends their sessions at once
+    var u dbm.User
+    s.db.Where("username = ?", "new-editor").First(&u)
+    addSession(s, "sess-new-editor", &Session{UserID: u.ID, Username: u.Username, ExpiresAt: time.Now().Add(time.Hour)})
+    req = httptest.NewRequest("PUT", fmt.Sprintf("/admin/users/%d", u.ID), strings.NewReader(url.Values{
+        "role": {"editor"}, "disabled": {"1"}, "csrf_token": {"csrf-" + sid},
+    }.Encode()))
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    req.Header.Set("Origin", "http://example.com")
+    req.Host = "example.com"
+    req.AddCookie(&http.Cookie{Name: "session", Value: sid, Path: "/admin"})
+    w = httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+    if w.Code != http.StatusOK { t.Fatalf("update user: %d %s", w.Code, w.Body.String()) }
+    if w := getAs(r, "sess-new-editor", "/admin/zones"); w.Code != http.StatusFound {
+        t.Fatalf("disabled user should be logged out, got %d", w.Code)
+    }
+}
+
+func TestLogin_BruteForceBackoff(t *testing.T) {
+    s, r := newTestWeb(t)
+    if _, err := dbm.CreateUser(s.db, "brute-target", "right-password", dbm.RoleViewer); err != nil { t.Fatalf("create user: %v", err) }
+    login := func(password string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest("POST", "/admin/login", strings.NewReader(url.Values{"username": {"brute-target"}, "password": {password}}.Encode()))
+        req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+        w := httptest.NewRecorder()
+        r.ServeHTTP(w, req)
+        return w
+    }
+
+    for i := 0; i < 5; i++ {
+        if w := login("wrong-password"); w.Code != http.StatusUnauthorized { t.Fatalf("attempt %d: want 401, got %d", i+1, w.Code) }
+    }
+    login("wrong-password")
+    w := login("right-password")
+    if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+        t.Fatalf("want 429 with Retry-After after repeated failures, got %d", w.Code)
+    }
+}
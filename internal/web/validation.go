@@ -0,0 +1,27 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"namedot/internal/db"
+	"namedot/internal/validate"
+)
+
+// checkRRSet validates the prospective state of an RRSet of zone and
+// canonicalizes its record data. A CNAME must not share its name with the
+// other live RRSets of the zone.
+func (s *Server) checkRRSet(zone db.Zone, set *db.RRSet) error {
+	if errs := validate.RRSet(set, zone.Name); len(errs) > 0 {
+		return errs
+	}
+	var others []db.RRSet
+	if err := s.db.Preload("Records").Where("zone_id = ? AND name = ? AND type <> ?", zone.ID, set.Name, set.Type).Find(&others).Error; err != nil {
+		return err
+	}
+	return validate.Coexistence(*set, others).Err()
+}
+
+// invalidMessage renders a failed validation as an error fragment
+func (s *Server) invalidMessage(c *gin.Context, err error) string {
+	return `<div class="error">` + s.trf(c, "Invalid record: %s", err.Error()) + `</div>`
+}
@@ -0,0 +1,324 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"namedot/internal/db"
+	"namedot/internal/idn"
+)
+
+// versionsPerPage is the page size of the zone versions list
+const versionsPerPage = 30
+
+// serials returns the SOA serial manager configured for this server
+func (s *Server) serials() db.SerialManager {
+	return db.SerialManager{AutoSOA: s.cfg.AutoSOAOnMissing, Policy: s.cfg.SOASerialPolicy}
+}
+
+// bumpSerial bumps the SOA serial of a changed zone, which also records a
+// new zone version, and drops the DNS cache
+func (s *Server) bumpSerial(zoneID uint) {
+	defer s.invalidateCache()
+	var zone db.Zone
+	if err := s.db.First(&zone, zoneID).Error; err != nil {
+		return
+	}
+	if err := s.serials().Bump(s.db, zone); err != nil {
+		log.Printf("SOA serial bump of %s failed: %v", zone.Name, err)
+	}
+}
+
+// invalidateCache drops the answers the DNS server cached, so zone changes
+// are served at once
+func (s *Server) invalidateCache() {
+	if s.cache != nil {
+		s.cache.InvalidateZoneCache()
+	}
+}
+
+// syncPTRs updates the PTR records of auto_ptr addresses in the hosted
+// reverse zones after an RRSet of a zone changed from before to after.
+// Reverse zones the user may not access are left alone; that is logged.
+func (s *Server) syncPTRs(c *gin.Context, zoneID uint, before, after *db.RRSet) {
+	var zone db.Zone
+	if err := s.db.First(&zone, zoneID).Error; err != nil {
+		return
+	}
+	var old, cur []db.RRSet
+	if before != nil {
+		old = append(old, *before)
+	}
+	if after != nil {
+		cur = append(cur, *after)
+	}
+	user := currentUser(c)
+	allow := func(rz db.Zone) bool { return s.canAccessZone(user, rz.ID) }
+	var updates []db.PTRUpdate
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if updates, err = db.SyncAutoPTR(tx, zone.View, old, cur, allow); err != nil {
+			return err
+		}
+		bumps := s.serials().Batch(tx)
+		for _, u := range updates {
+			if !u.Denied {
+				bumps.Touch(u.Zone)
+			}
+		}
+		return bumps.Flush()
+	})
+	if err != nil {
+		log.Printf("auto PTR update for %s failed: %v", zone.Name, err)
+		return
+	}
+	for _, u := range updates {
+		if u.Denied {
+			log.Printf("auto PTR update for %s skipped %s: user %q may not access it", zone.Name, u.Zone.Name, user.Username)
+			continue
+		}
+		s.audit(c, db.AuditRRSetAutoPTR, u.Zone.ID, u.Before, u.After)
+	}
+}
+
+// authorizedZone loads the zone of the request after checking the role and zone access
+func (s *Server) authorizedZone(c *gin.Context, role string) (db.Zone, bool) {
+	var zone db.Zone
+	zoneID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, s.tr(c, "Invalid zone ID"))
+		return zone, false
+	}
+	if !s.authorizeZone(c, uint(zoneID), role) {
+		return zone, false
+	}
+	if err := s.db.First(&zone, zoneID).Error; err != nil {
+		c.String(http.StatusNotFound, s.tr(c, "Zone not found"))
+		return zone, false
+	}
+	return zone, true
+}
+
+// zoneVersions lists the versions of a zone with compare and rollback actions
+func (s *Server) zoneVersions(c *gin.Context) {
+	zone, ok := s.authorizedZone(c, db.RoleViewer)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	versions, total, err := db.ListVersions(s.db, zone.ID, versionsPerPage, (page-1)*versionsPerPage)
+	if err != nil {
+		c.String(http.StatusInternalServerError, s.tr(c, "Error loading versions"))
+		return
+	}
+
+	out := fmt.Sprintf(`
+	<div style="margin-bottom: 1rem;">
+		<button class="btn" style="background: #718096;" hx-get="/admin/zones/%d/records" hx-target="#zones-list" hx-swap="innerHTML">
+			%s
+		</button>
+		<h2 style="margin-top: 1rem;">%s</h2>
+	</div>`, zone.ID, s.tr(c, "← Back to Records"), html.EscapeString(s.trf(c, "Versions of %s", idn.ToUnicode(zone.Name))))
+
+	if len(versions) == 0 {
+		out += `<div class="empty-state">` + s.tr(c, "No versions recorded yet") + `</div>`
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.String(http.StatusOK, out)
+		return
+	}
+
+	// Compare any two versions, or a version with the current state
+	options := func(withCurrent bool) string {
+		opts := ""
+		if withCurrent {
+			opts += `<option value="current">` + s.tr(c, "Current") + `</option>`
+		}
+		for _, v := range versions {
+			opts += fmt.Sprintf(`<option value="%d">#%d (%s)</option>`, v.ID, v.ID, v.CreatedAt.Format("2006-01-02 15:04:05"))
+		}
+		return opts
+	}
+	out += fmt.Sprintf(`
+	<form hx-get="/admin/zones/%d/versions/diff" hx-target="#version-diff" hx-swap="innerHTML"
+		style="margin-bottom: 1rem; display: flex; gap: 0.5rem; align-items: center;">
+		<label>%s</label>
+		<select name="from" style="padding: 0.5rem; border: 1px solid #cbd5e0; border-radius: 4px;">%s</select>
+		<label>%s</label>
+		<select name="to" style="padding: 0.5rem; border: 1px solid #cbd5e0; border-radius: 4px;">%s</select>
+		<button type="submit" class="btn">%s</button>
+	</form>
+	<div id="version-diff"></div>`, zone.ID, s.tr(c, "From"), options(false), s.tr(c, "To"), options(true), s.tr(c, "Compare"))
+
+	canRollback := currentUser(c).HasRole(db.RoleEditor)
+	out += `<table>
+        <thead>
+            <tr>
+                <th>` + s.tr(c, "Version") + `</th>
+                <th>` + s.tr(c, "Time") + `</th>
+                <th>` + s.tr(c, "Serial") + `</th>
+                <th>` + s.tr(c, "Records") + `</th>
+                <th>` + s.tr(c, "Note") + `</th>
+                <th>` + s.tr(c, "Actions") + `</th>
+            </tr>
+        </thead>
+        <tbody>`
+	for _, v := range versions {
+		actions := fmt.Sprintf(`<button class="btn btn-sm" hx-get="/admin/zones/%d/versions/diff?from=%d&to=current" hx-target="#version-diff" hx-swap="innerHTML">%s</button>`,
+			zone.ID, v.ID, s.tr(c, "Diff with current"))
+		if canRollback {
+			actions += fmt.Sprintf(`
+                    <button class="btn btn-sm btn-danger"
+                        hx-post="/admin/zones/%d/versions/%d/rollback"
+                        hx-confirm="%s"
+                        hx-target="#zones-list"
+                        hx-swap="innerHTML">
+                        %s
+                    </button>`, zone.ID, v.ID, html.EscapeString(s.trf(c, "Restore version #%d of %s?", v.ID, idn.ToUnicode(zone.Name))), s.tr(c, "Rollback"))
+		}
+		out += fmt.Sprintf(`
+            <tr>
+                <td><strong>#%d</strong></td>
+                <td>%s</td>
+                <td>%d</td>
+                <td>%d</td>
+                <td>%s</td>
+                <td class="actions">%s</td>
+            </tr>`, v.ID, v.CreatedAt.Format("2006-01-02 15:04:05"), v.Serial, v.RRSetCount, html.EscapeString(v.Note), actions)
+	}
+	out += `</tbody></table>`
+
+	if totalPages := int((total + versionsPerPage - 1) / versionsPerPage); totalPages > 1 {
+		out += `<div style="display: flex; justify-content: center; gap: 0.5rem; margin-top: 1rem;">`
+		if page > 1 {
+			out += fmt.Sprintf(`<button class="btn btn-sm" hx-get="/admin/zones/%d/versions?page=%d" hx-target="#zones-list" hx-swap="innerHTML">« `+s.tr(c, "Prev")+`</button>`, zone.ID, page-1)
+		}
+		if page < totalPages {
+			out += fmt.Sprintf(`<button class="btn btn-sm" hx-get="/admin/zones/%d/versions?page=%d" hx-target="#zones-list" hx-swap="innerHTML">`+s.tr(c, "Next")+` »</button>`, zone.ID, page+1)
+		}
+		out += `</div>`
+		out += fmt.Sprintf(`<div style="text-align: center; margin-top: 0.5rem; color: #718096; font-size: 0.875rem;">`+s.tr(c, "Page %d of %d")+` (%d `+s.tr(c, "total")+`)</div>`, page, totalPages, total)
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.String(http.StatusOK, out)
+}
+
+// versionSets resolves a version ID, or "current" for the live zone
+func (s *Server) versionSets(zone db.Zone, id string) ([]db.RRSet, error) {
+	if id == "" || id == "current" {
+		return db.ZoneSnapshot(s.db, zone.ID), nil
+	}
+	vid, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	v, err := db.GetVersion(s.db, zone.ID, uint(vid))
+	if err != nil {
+		return nil, err
+	}
+	return v.RRSets()
+}
+
+// versionDiff renders the RRSets changed between two versions
+func (s *Server) versionDiff(c *gin.Context) {
+	zone, ok := s.authorizedZone(c, db.RoleViewer)
+	if !ok {
+		return
+	}
+	from, err := s.versionSets(zone, c.Query("from"))
+	if err == nil {
+		var to []db.RRSet
+		if to, err = s.versionSets(zone, c.Query("to")); err == nil {
+			s.renderDiff(c, db.DiffRRSets(from, to))
+			return
+		}
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.String(http.StatusNotFound, s.tr(c, "Version not found"))
+		return
+	}
+	c.String(http.StatusInternalServerError, s.tr(c, "Error loading versions"))
+}
+
+func (s *Server) renderDiff(c *gin.Context, changes []db.RRSetChange) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.String(http.StatusOK, s.diffTable(c, changes))
+}
+
+// diffTable renders RRSet changes as a table
+func (s *Server) diffTable(c *gin.Context, changes []db.RRSetChange) string {
+	if len(changes) == 0 {
+		return `<div class="empty-state">` + s.tr(c, "No differences") + `</div>`
+	}
+	records := func(rs *db.RRSet) string {
+		if rs == nil {
+			return ""
+		}
+		lines := make([]string, 0, len(rs.Records))
+		for _, r := range rs.Records {
+			lines = append(lines, html.EscapeString(fmt.Sprintf("%d %s", rs.TTL, r.Data)))
+		}
+		return strings.Join(lines, "<br>")
+	}
+	colors := map[string]string{db.ChangeAdded: "#f0fff4", db.ChangeRemoved: "#fff5f5", db.ChangeChanged: "#fffff0"}
+	out := `<table style="margin-bottom: 1rem;">
+        <thead>
+            <tr>
+                <th>` + s.tr(c, "Name") + `</th>
+                <th>` + s.tr(c, "Type") + `</th>
+                <th>` + s.tr(c, "Change") + `</th>
+                <th>` + s.tr(c, "Before") + `</th>
+                <th>` + s.tr(c, "After") + `</th>
+            </tr>
+        </thead>
+        <tbody>`
+	for _, ch := range changes {
+		out += fmt.Sprintf(`
+            <tr style="background: %s;">
+                <td><strong>%s</strong></td>
+                <td>%s</td>
+                <td>%s</td>
+                <td><code>%s</code></td>
+                <td><code>%s</code></td>
+            </tr>`, colors[ch.Change], html.EscapeString(ch.Name), html.EscapeString(ch.Type), s.tr(c, ch.Change),
+			records(ch.Before), records(ch.After))
+	}
+	return out + `</tbody></table>`
+}
+
+// rollbackZone restores a version of a zone and shows the records
+func (s *Server) rollbackZone(c *gin.Context) {
+	zone, ok := s.authorizedZone(c, db.RoleEditor)
+	if !ok {
+		return
+	}
+	vid, err := strconv.ParseUint(c.Param("vid"), 10, 32)
+	if err != nil {
+		c.String(http.StatusBadRequest, s.tr(c, "Version not found"))
+		return
+	}
+	before := db.ZoneSnapshot(s.db, zone.ID)
+	if _, err := db.RollbackZone(s.db, zone, uint(vid), s.serials()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.String(http.StatusNotFound, s.tr(c, "Version not found"))
+			return
+		}
+		c.String(http.StatusInternalServerError, s.trf(c, "Error restoring version: %s", err.Error()))
+		return
+	}
+	s.audit(c, db.AuditZoneRollback, zone.ID, before, db.ZoneSnapshot(s.db, zone.ID))
+	s.invalidateCache()
+
+	s.listRecords(c)
+}
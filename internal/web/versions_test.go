@@ -0,0 +1,53 @@
+package web
+
+import (
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+    "testing"
+
+    dbm "namedot/internal/db"
+)
+
+func TestVersions_DiffAndRollback(t *testing.T) {
+    s, r := newTestWeb(t)
+    editor, sid := loginAs(t, s, "versions-editor", dbm.RoleEditor)
+    viewer, viewerSid := loginAs(t, s, "versions-viewer", dbm.RoleViewer)
+    zone := dbm.Zone{Name: "versions.test."}
+    s.db.Create(&zone)
+    // The database is shared; later tests expect an empty zone list
+    t.Cleanup(func() { s.db.Unscoped().Delete(&zone) })
+    for _, u := range []dbm.User{editor, viewer} {
+        if err := dbm.GrantZone(s.db, u.ID, zone.ID); err != nil { t.Fatalf("grant: %v", err) }
+    }
+
+    records := fmt.Sprintf("/admin/zones/%d/records", zone.ID)
+    postRecordForm(r, sid, records, url.Values{"name": {"www"}, "type": {"A"}, "ttl": {"300"}, "data": {"192.0.2.10"}})
+    postRecordForm(r, sid, records, url.Values{"name": {"bad"}, "type": {"A"}, "ttl": {"300"}, "data": {"203.0.113.66"}})
+
+    versions, total, _ := dbm.ListVersions(s.db, zone.ID, 0, 0)
+    if total != 2 { t.Fatalf("each change must record a version, got %d", total) }
+    good := versions[1]
+
+    page := fmt.Sprintf("/admin/zones/%d/versions", zone.ID)
+    if w := getAs(r, viewerSid, page); w.Code != http.StatusOK || strings.Contains(w.Body.String(), "/rollback") {
+        t.Fatalf("viewer sees versions without rollback: %d %s", w.Code, w.Body.String())
+    }
+    w := getAs(r, viewerSid, fmt.Sprintf("%s/diff?from=%d&to=current", page, good.ID))
+    if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "bad.versions.test.") || !strings.Contains(w.Body.String(), "added") {
+        t.Fatalf("diff: %d %s", w.Code, w.Body.String())
+    }
+
+    rollback := fmt.Sprintf("%s/%d/rollback", page, good.ID)
+    if w := sendAs(r, "POST", viewerSid, rollback, nil); w.Code != http.StatusForbidden {
+        t.Fatalf("viewer rollback: want 403, got %d", w.Code)
+    }
+    w = sendAs(r, "POST", sid, rollback, nil)
+    if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "203.0.113.66") || !strings.Contains(w.Body.String(), "192.0.2.10") {
+        t.Fatalf("rollback: %d %s", w.Code, w.Body.String())
+    }
+    if _, total, _ := dbm.ListAudit(s.db, dbm.AuditFilter{ZoneID: zone.ID, Action: dbm.AuditZoneRollback}); total != 1 {
+        t.Fatalf("rollback must be audited")
+    }
+}
@@ -2,13 +2,17 @@ package web
 
 import (
     "fmt"
+    "html"
     "net/http"
     "net/url"
     "strconv"
     "strings"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 	"namedot/internal/db"
+	"namedot/internal/idn"
+	"namedot/internal/validate"
 )
 
 // cleanZoneSearch cleans up search query from URL protocols and paths
@@ -38,6 +42,41 @@ func cleanZoneSearch(query string) string {
 	return query
 }
 
+// displayName renders a domain name in Unicode, with the punycode form it is
+// stored in as a tooltip when the two differ
+func displayName(name string) string {
+	u := idn.ToUnicode(name)
+	if u == name {
+		return html.EscapeString(name)
+	}
+	return fmt.Sprintf(`<span title="%s">%s</span>`, html.EscapeString(name), html.EscapeString(u))
+}
+
+// zoneMetaLabel renders view, kind, state, tags and description of a zone
+// for the zones list
+func (s *Server) zoneMetaLabel(c *gin.Context, zone db.Zone) string {
+	badge := func(text, style string) string {
+		return ` <span style="` + style + ` padding: 0.125rem 0.375rem; border-radius: 4px; font-size: 0.75rem;">` + html.EscapeString(text) + `</span>`
+	}
+	out := ""
+	if zone.View != "" {
+		out += badge(zone.View, "background: #48bb78; color: white;")
+	}
+	if zone.Kind != "" && zone.Kind != db.ZoneKindPrimary {
+		out += badge(s.tr(c, "zone kind "+zone.Kind), "background: #4299e1; color: white;")
+	}
+	if zone.Disabled {
+		out += badge(s.tr(c, "disabled"), "background: #a0aec0; color: white;")
+	}
+	for _, tag := range zone.TagList() {
+		out += badge(tag, "background: #edf2f7; color: #4a5568;")
+	}
+	if zone.Description != "" {
+		out += `<br><small style="color: #718096;">` + html.EscapeString(zone.Description) + `</small>`
+	}
+	return out
+}
+
 func (s *Server) listZones(c *gin.Context) {
 	// Get pagination and search parameters
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
@@ -48,11 +87,18 @@ func (s *Server) listZones(c *gin.Context) {
 	offset := (page - 1) * perPage
 
 	search := cleanZoneSearch(c.Query("search"))
+	view, viewFilter := c.GetQuery("view")
+	if viewFilter && view == "*" {
+		viewFilter = false
+	}
 
-	// Build query
-	query := s.db.Model(&db.Zone{})
+	// Build query, limited to the zones visible to the caller
+	query := s.scopeZones(c, s.db.Model(&db.Zone{}))
 	if search != "" {
-		query = query.Where("name LIKE ?", "%"+search+"%")
+		query = query.Where(db.NameContains(s.db, s.scopeZones(c, s.db.Model(&db.Zone{})), search))
+	}
+	if viewFilter {
+		query = query.Where("view = ?", view)
 	}
 
 	// Get total count
@@ -75,13 +121,14 @@ func (s *Server) listZones(c *gin.Context) {
 		<form hx-get="/admin/zones" hx-target="#zones-list" hx-swap="innerHTML" style="display: flex; gap: 0.5rem;">
 			<input type="text" name="search" placeholder="`+s.tr(c, "Search zones (domain, URL, or name)...")+`" value="%s"
 				style="flex: 1; padding: 0.5rem; border: 1px solid #cbd5e0; border-radius: 4px;">
+			%s
 			<button type="submit" class="btn">`+s.tr(c, "Search")+`</button>
 			<button type="button" class="btn" style="background: #718096;"
 				hx-get="/admin/zones" hx-target="#zones-list" hx-swap="innerHTML">
 				`+s.tr(c, "Clear")+`
 			</button>
 		</form>
-	</div>`, search)
+	</div>`, search, s.viewFilterSelect(c, view, viewFilter))
 
 	html := searchForm + `<table>
         <thead>
@@ -101,6 +148,8 @@ func (s *Server) listZones(c *gin.Context) {
 		}
 	} else {
 		for _, zone := range zones {
+			zoneLabel := displayName(zone.Name) + s.zoneMetaLabel(c, zone)
+
 			// Load the zone with preloaded RRSets and Records
 			var zoneWithRecords db.Zone
 			s.db.Preload("RRSets.Records").First(&zoneWithRecords, zone.ID)
@@ -119,6 +168,9 @@ func (s *Server) listZones(c *gin.Context) {
                     <button class="btn btn-sm" hx-get="/admin/zones/%d/records" hx-target="#zones-list" hx-swap="innerHTML">
                         %s
                     </button>
+                    <button class="btn btn-sm" hx-get="/admin/zones/%d/edit" hx-target="#zones-list" hx-swap="innerHTML">
+                        %s
+                    </button>
                     <button class="btn btn-sm btn-danger"
                         hx-delete="/admin/zones/delete/%d"
                         hx-confirm="%s"
@@ -127,7 +179,7 @@ func (s *Server) listZones(c *gin.Context) {
                         %s
                     </button>
                 </td>
-            </tr>`, zone.Name, recordCount, zone.ID, s.tr(c, "View Records"), zone.ID, s.trf(c, "Delete zone %s?", zone.Name), s.tr(c, "Delete"))
+            </tr>`, zoneLabel, recordCount, zone.ID, s.tr(c, "View Records"), zone.ID, s.tr(c, "Edit"), zone.ID, s.trf(c, "Delete zone %s?", idn.ToUnicode(zone.Name)), s.tr(c, "Delete"))
 		}
 	}
 
@@ -137,9 +189,15 @@ func (s *Server) listZones(c *gin.Context) {
 	if totalPages > 1 {
 		html += `<div style="display: flex; justify-content: center; gap: 0.5rem; margin-top: 1rem; flex-wrap: wrap;">`
 
+		// Keep the view filter across pages
+		searchParam := url.QueryEscape(search)
+		if viewFilter {
+			searchParam += "&view=" + url.QueryEscape(view)
+		}
+
 		// Previous button
 		if page > 1 {
-			html += fmt.Sprintf(`<button class="btn btn-sm" hx-get="/admin/zones?page=%d&search=%s" hx-target="#zones-list" hx-swap="innerHTML">« `+s.tr(c, "Prev")+`</button>`, page-1, url.QueryEscape(search))
+			html += fmt.Sprintf(`<button class="btn btn-sm" hx-get="/admin/zones?page=%d&search=%s" hx-target="#zones-list" hx-swap="innerHTML">« `+s.tr(c, "Prev")+`</button>`, page-1, searchParam)
 		}
 
 		// Page numbers
@@ -147,7 +205,7 @@ func (s *Server) listZones(c *gin.Context) {
 			if i == page {
 				html += fmt.Sprintf(`<button class="btn btn-sm" style="background: #667eea; color: white;">%d</button>`, i)
 			} else if i == 1 || i == totalPages || (i >= page-2 && i <= page+2) {
-				html += fmt.Sprintf(`<button class="btn btn-sm" hx-get="/admin/zones?page=%d&search=%s" hx-target="#zones-list" hx-swap="innerHTML">%d</button>`, i, url.QueryEscape(search), i)
+				html += fmt.Sprintf(`<button class="btn btn-sm" hx-get="/admin/zones?page=%d&search=%s" hx-target="#zones-list" hx-swap="innerHTML">%d</button>`, i, searchParam, i)
 			} else if i == page-3 || i == page+3 {
 				html += `<span style="padding: 0.25rem 0.5rem;">...</span>`
 			}
@@ -155,7 +213,7 @@ func (s *Server) listZones(c *gin.Context) {
 
 		// Next button
 		if page < totalPages {
-			html += fmt.Sprintf(`<button class="btn btn-sm" hx-get="/admin/zones?page=%d&search=%s" hx-target="#zones-list" hx-swap="innerHTML">`+s.tr(c, "Next")+` »</button>`, page+1, url.QueryEscape(search))
+			html += fmt.Sprintf(`<button class="btn btn-sm" hx-get="/admin/zones?page=%d&search=%s" hx-target="#zones-list" hx-swap="innerHTML">`+s.tr(c, "Next")+` »</button>`, page+1, searchParam)
 		}
 
 		html += `</div>`
@@ -176,6 +234,7 @@ func (s *Server) newZoneForm(c *gin.Context) {
                 <input type="text" name="name" placeholder="example.com" required
                     style="width: 100%; padding: 0.5rem; border: 1px solid #cbd5e0; border-radius: 4px;">
             </div>
+            ` + s.viewSelect(c) + `
             <button type="submit" class="btn">` + s.tr(c, "Create") + `</button>
             <button type="button" class="btn" style="background: #718096;"
                 hx-get="/admin/zones" hx-target="#zones-list" hx-swap="innerHTML">
@@ -197,15 +256,36 @@ func (s *Server) createZone(c *gin.Context) {
     }
 
 	// Normalize zone name
-	if name[len(name)-1] != '.' {
-		name += "."
+	name, err := validate.ZoneName(name)
+	if err != nil {
+		c.String(http.StatusBadRequest, `<div class="error">`+s.trf(c, "Invalid zone name: %s", err.Error())+`</div>`)
+		return
 	}
+	name += "."
 
-	zone := db.Zone{Name: name}
-    if err := s.db.Create(&zone).Error; err != nil {
+	view := c.PostForm("view")
+    if !s.cfg.HasView(view) {
+        c.String(http.StatusBadRequest, `<div class="error">`+s.trf(c, "Unknown view %s", view)+`</div>`)
+        return
+    }
+
+	zone := db.Zone{Name: name, View: view, Kind: db.ZoneKindPrimary}
+    user := currentUser(c)
+    err = s.db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Create(&zone).Error; err != nil {
+            return err
+        }
+        // Non-admins own the zones they create
+        if !user.HasRole(db.RoleAdmin) {
+            return db.GrantZone(tx, user.ID, zone.ID)
+        }
+        return nil
+    })
+    if err != nil {
         c.String(http.StatusInternalServerError, fmt.Sprintf(`<div class="error">`+s.tr(c, "Error creating zone: %s")+`</div>`, err.Error()))
         return
     }
+    s.audit(c, db.AuditZoneCreate, zone.ID, nil, zone)
 
 	// Return updated zones list
 	s.listZones(c)
@@ -217,21 +297,210 @@ func (s *Server) deleteZone(c *gin.Context) {
         c.Status(http.StatusBadRequest)
         return
     }
+    if !s.authorizeZone(c, uint(id), db.RoleEditor) {
+        return
+    }
 
+    before := db.ZoneSnapshot(s.db, uint(id))
     if err := s.db.Delete(&db.Zone{}, id).Error; err != nil {
         c.String(http.StatusInternalServerError, s.tr(c, "Error deleting zone"))
         return
     }
+    s.audit(c, db.AuditZoneDelete, uint(id), before, nil)
+    s.invalidateCache()
 
     c.Status(http.StatusOK)
 }
 
+// viewSelect renders the view picker of the new zone form; empty when no views are configured
+func (s *Server) viewSelect(c *gin.Context) string {
+	if len(s.cfg.Views) == 0 {
+		return ""
+	}
+	html := `<div>
+                <label>` + s.tr(c, "DNS View") + `</label>
+                <select name="view" style="width: 100%; padding: 0.5rem; border: 1px solid #cbd5e0; border-radius: 4px;">
+                    <option value="">` + s.tr(c, "All views (default)") + `</option>`
+	for _, v := range s.cfg.ViewNames() {
+		html += fmt.Sprintf(`<option value="%s">%s</option>`, v, v)
+	}
+	return html + `</select>
+            </div>`
+}
+
+// viewFilterSelect renders the view filter of the zones list; empty when no views are configured
+func (s *Server) viewFilterSelect(c *gin.Context, current string, active bool) string {
+	if len(s.cfg.Views) == 0 {
+		return ""
+	}
+	opt := func(value, label string, selected bool) string {
+		sel := ""
+		if selected {
+			sel = " selected"
+		}
+		return fmt.Sprintf(`<option value="%s"%s>%s</option>`, value, sel, label)
+	}
+	html := `<select name="view" style="padding: 0.5rem; border: 1px solid #cbd5e0; border-radius: 4px;">`
+	html += opt("*", s.tr(c, "All Views"), !active)
+	html += opt("", s.tr(c, "Default view"), active && current == "")
+	for _, v := range s.cfg.ViewNames() {
+		html += opt(v, v, active && current == v)
+	}
+	return html + `</select>`
+}
+
+// editZoneForm renders the metadata of a zone for editing
 func (s *Server) editZoneForm(c *gin.Context) {
-	// Placeholder for edit functionality
-	c.String(http.StatusOK, "Edit zone form")
+	zone, ok := s.authorizedZone(c, db.RoleEditor)
+	if !ok {
+		return
+	}
+	input := func(name, value, placeholder string) string {
+		return fmt.Sprintf(`<input type="text" name="%s" value="%s" placeholder="%s"
+                    style="width: 100%%; padding: 0.5rem; border: 1px solid #cbd5e0; border-radius: 4px;">`,
+			name, html.EscapeString(value), html.EscapeString(placeholder))
+	}
+	number := func(name string, value uint32) string {
+		v := ""
+		if value > 0 {
+			v = strconv.FormatUint(uint64(value), 10)
+		}
+		return fmt.Sprintf(`<input type="number" name="%s" value="%s" min="0"
+                    style="width: 100%%; padding: 0.5rem; border: 1px solid #cbd5e0; border-radius: 4px;">`, name, v)
+	}
+	field := func(label, control string) string {
+		return `<div><label>` + label + `</label>` + control + `</div>`
+	}
+	kinds := `<select name="kind" style="width: 100%; padding: 0.5rem; border: 1px solid #cbd5e0; border-radius: 4px;">`
+	for _, k := range db.ZoneKinds {
+		sel := ""
+		if k == zone.Kind {
+			sel = " selected"
+		}
+		kinds += fmt.Sprintf(`<option value="%s"%s>%s</option>`, k, sel, s.tr(c, "zone kind "+k))
+	}
+	kinds += `</select>`
+	policies := `<select name="serial_policy" style="width: 100%; padding: 0.5rem; border: 1px solid #cbd5e0; border-radius: 4px;">`
+	for _, p := range append([]string{""}, db.SerialPolicies...) {
+		sel := ""
+		if p == zone.SerialPolicy {
+			sel = " selected"
+		}
+		policies += fmt.Sprintf(`<option value="%s"%s>%s</option>`, p, sel, s.tr(c, "serial policy "+p))
+	}
+	policies += `</select>`
+	disabled := ""
+	if zone.Disabled {
+		disabled = " checked"
+	}
+	soa := zone.SOA
+
+	out := fmt.Sprintf(`
+    <div style="background: #f7fafc; padding: 1rem; border-radius: 4px; margin-bottom: 1rem;">
+        <h3>%s</h3>
+        <form hx-put="/admin/zones/%d" hx-target="#zones-list" hx-swap="innerHTML"
+            style="display: grid; grid-template-columns: 1fr 1fr; gap: 1rem; margin-top: 1rem;">
+            %s
+            %s
+            <div style="grid-column: span 2;">
+                <label>%s</label>
+                <textarea name="description" rows="2"
+                    style="width: 100%%; padding: 0.5rem; border: 1px solid #cbd5e0; border-radius: 4px;">%s</textarea>
+            </div>
+            %s
+            %s
+            <div style="grid-column: span 2;"><strong>%s</strong></div>
+            %s
+            %s
+            %s
+            %s
+            %s
+            %s
+            %s
+            %s
+            <div style="grid-column: span 2;">
+                <label><input type="checkbox" name="disabled" value="1"%s> %s</label>
+            </div>
+            <div style="grid-column: span 2; display: flex; gap: 1rem;">
+                <button type="submit" class="btn">%s</button>
+                <button type="button" class="btn" style="background: #718096;"
+                    hx-get="/admin/zones" hx-target="#zones-list" hx-swap="innerHTML">
+                    %s
+                </button>
+            </div>
+        </form>
+    </div>`,
+		s.trf(c, "Edit Zone: %s", html.EscapeString(idn.ToUnicode(zone.Name))), zone.ID,
+		field(s.tr(c, "Kind"), kinds),
+		field(s.tr(c, "Owner"), input("owner", zone.Owner, "hostmaster@example.com")),
+		s.tr(c, "Description"), html.EscapeString(zone.Description),
+		field(s.tr(c, "Tags"), input("tags", strings.Join(zone.TagList(), ", "), "prod, customer-a")),
+		field(s.tr(c, "Default TTL (seconds)"), number("default_ttl", zone.DefaultTTL)),
+		s.tr(c, "SOA defaults (used when the SOA is created automatically)"),
+		field(s.tr(c, "Primary name server"), input("soa_primary", soa.Primary, "ns1."+zone.Name)),
+		field(s.tr(c, "Hostmaster"), input("soa_hostmaster", soa.Hostmaster, "hostmaster."+zone.Name)),
+		field(s.tr(c, "Refresh"), number("soa_refresh", soa.Refresh)),
+		field(s.tr(c, "Retry"), number("soa_retry", soa.Retry)),
+		field(s.tr(c, "Expire"), number("soa_expire", soa.Expire)),
+		field(s.tr(c, "Minimum TTL"), number("soa_minimum", soa.Minimum)),
+		field(s.tr(c, "SOA TTL"), number("soa_ttl", soa.TTL)),
+		field(s.tr(c, "Serial policy"), policies),
+		disabled, s.tr(c, "Disabled (kept but not served)"),
+		s.tr(c, "Save"), s.tr(c, "Cancel"))
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.String(http.StatusOK, out)
 }
 
+// updateZone stores the metadata of a zone from the edit form
 func (s *Server) updateZone(c *gin.Context) {
-	// Placeholder for update functionality
-	c.Status(http.StatusOK)
+	zone, ok := s.authorizedZone(c, db.RoleEditor)
+	if !ok {
+		return
+	}
+	before := zone
+
+	var errs validate.Errors
+	number := func(name string) uint32 {
+		v := strings.TrimSpace(c.PostForm(name))
+		if v == "" {
+			return 0
+		}
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			errs.Add(name, "must be a number of seconds")
+		}
+		return uint32(n)
+	}
+	zone.Kind = c.PostForm("kind")
+	zone.Description = strings.TrimSpace(c.PostForm("description"))
+	zone.Owner = c.PostForm("owner")
+	zone.Tags = db.JoinTags(strings.Split(c.PostForm("tags"), ","))
+	zone.DefaultTTL = number("default_ttl")
+	zone.SOA = db.SOADefaults{
+		Primary:    strings.TrimSpace(c.PostForm("soa_primary")),
+		Hostmaster: strings.TrimSpace(c.PostForm("soa_hostmaster")),
+		Refresh:    number("soa_refresh"),
+		Retry:      number("soa_retry"),
+		Expire:     number("soa_expire"),
+		Minimum:    number("soa_minimum"),
+		TTL:        number("soa_ttl"),
+	}
+	zone.SerialPolicy = c.PostForm("serial_policy")
+	zone.Disabled = c.PostForm("disabled") != ""
+	errs = append(errs, validate.ZoneMeta(&zone)...)
+	if len(errs) > 0 {
+		c.String(http.StatusBadRequest, `<div class="error">`+s.trf(c, "Invalid zone settings: %s", html.EscapeString(errs.Error()))+`</div>`)
+		return
+	}
+
+	if err := db.UpdateZoneMeta(s.db, &zone); err != nil {
+		c.String(http.StatusInternalServerError, `<div class="error">`+s.trf(c, "Error updating zone: %s", html.EscapeString(err.Error()))+`</div>`)
+		return
+	}
+	s.audit(c, db.AuditZoneUpdate, zone.ID, before, zone)
+	// Disabling a zone or changing its metadata changes the answers
+	s.invalidateCache()
+
+	s.listZones(c)
 }
@@ -0,0 +1,76 @@
+package web
+
+import (
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+    "testing"
+
+    dbm "namedot/internal/db"
+)
+
+// countingCache counts the invalidations of the DNS cache
+type countingCache struct{ n int }
+
+func (c *countingCache) InvalidateZoneCache() { c.n++ }
+
+func TestZones_EditMetadata(t *testing.T) {
+    s, r := newTestWeb(t)
+    cache := &countingCache{}
+    s.cache = cache
+    editor, sid := loginAs(t, s, "zonemeta-editor", dbm.RoleEditor)
+    viewer, viewerSid := loginAs(t, s, "zonemeta-viewer", dbm.RoleViewer)
+    zone := dbm.Zone{Name: "zonemeta.test.", Kind: dbm.ZoneKindPrimary}
+    s.db.Create(&zone)
+    // The database is shared; later tests expect an empty zone list
+    t.Cleanup(func() { s.db.Unscoped().Delete(&zone) })
+    for _, u := range []dbm.User{editor, viewer} {
+        if err := dbm.GrantZone(s.db, u.ID, zone.ID); err != nil { t.Fatalf("grant: %v", err) }
+    }
+
+    edit := fmt.Sprintf("/admin/zones/%d/edit", zone.ID)
+    if w := getAs(r, viewerSid, edit); w.Code != http.StatusForbidden {
+        t.Fatalf("viewer edit form: want 403, got %d", w.Code)
+    }
+    if w := getAs(r, sid, edit); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `name="soa_hostmaster"`) {
+        t.Fatalf("edit form: %d %s", w.Code, w.Body.String())
+    }
+
+    path := fmt.Sprintf("/admin/zones/%d", zone.ID)
+    form := url.Values{"kind": {"forward"}, "description": {"Office <LAN>"}, "tags": {"office, Lab"},
+        "default_ttl": {"600"}, "soa_refresh": {"abc"}}
+    if w := sendAs(r, "PUT", sid, path, form); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "soa_refresh") {
+        t.Fatalf("invalid refresh: %d %s", w.Code, w.Body.String())
+    }
+    if cache.n != 0 { t.Fatalf("a rejected update must not drop the DNS cache") }
+    form.Set("soa_refresh", "3600")
+    form.Set("disabled", "1")
+    w := sendAs(r, "PUT", sid, path, form)
+    if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Office &lt;LAN&gt;") {
+        t.Fatalf("update: %d %s", w.Code, w.Body.String())
+    }
+    if cache.n != 1 { t.Fatalf("disabling the zone must drop the DNS cache, got %d invalidations", cache.n) }
+    var stored dbm.Zone
+    s.db.First(&stored, zone.ID)
+    if stored.Kind != dbm.ZoneKindForward || stored.Tags != "lab,office" || stored.DefaultTTL != 600 || stored.SOA.Refresh != 3600 || !stored.Disabled {
+        t.Fatalf("unexpected zone: %+v", stored)
+    }
+    if _, total, _ := dbm.ListAudit(s.db, dbm.AuditFilter{ZoneID: zone.ID, Action: dbm.AuditZoneUpdate}); total != 1 {
+        t.Fatalf("zone update must be audited")
+    }
+}
+
+func TestZones_ListEscapesView(t *testing.T) {
+    s, r := newTestWeb(t)
+    _, sid := loginAs(t, s, "zoneview-admin", dbm.RoleAdmin)
+    zone := dbm.Zone{Name: "zoneview.test.", View: `<script>alert(1)</script>`}
+    s.db.Create(&zone)
+    t.Cleanup(func() { s.db.Unscoped().Delete(&zone) })
+
+    w := getAs(r, sid, "/admin/zones")
+    if w.Code != http.StatusOK { t.Fatalf("zones list: %d", w.Code) }
+    if strings.Contains(w.Body.String(), "<script>") || !strings.Contains(w.Body.String(), "&lt;script&gt;") {
+        t.Fatalf("view must be escaped: %s", w.Body.String())
+    }
+}